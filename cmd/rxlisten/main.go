@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
 	"time"
 
@@ -16,6 +19,9 @@ func main() {
 	idleLog := flag.Duration("idle-log", 10*time.Second, "how often to print idle message when no packets arrive")
 	flag.Parse()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	r, err := radio.NewRadio(*port)
 	if err != nil {
 		log.Fatalf("open radio: %v", err)
@@ -26,7 +32,10 @@ func main() {
 	lastIdleLog := time.Now()
 
 	for {
-		fromRadioPackets, err := r.ReadResponse(true)
+		fromRadioPackets, err := r.ReadResponse(ctx, true)
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
 			log.Printf("[ERR] read response: %v", err)
 			time.Sleep(300 * time.Millisecond)
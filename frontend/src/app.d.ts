@@ -22,6 +22,38 @@ declare global {
     summary: ChirpInfoSummary;
   };
 
+  type ChirpNodePosition = {
+    lat: number;
+    lon: number;
+    alt: number;
+    time?: number;
+  };
+
+  type ChirpNodeEntry = {
+    num: number;
+    id: string;
+    long_name: string;
+    short_name: string;
+    hw_model: string;
+    role: string;
+    last_heard: number;
+    snr: number;
+    hops_away?: number;
+    battery_level?: number;
+    voltage?: number;
+    position?: ChirpNodePosition;
+    distance_m?: number;
+    self: boolean;
+    favorite: boolean;
+    ignored: boolean;
+    via_mqtt: boolean;
+  };
+
+  type ChirpNodesView = {
+    self: string;
+    nodes: ChirpNodeEntry[];
+  };
+
   type ChirpListenerStatus = {
     running: boolean;
   };
@@ -40,9 +72,11 @@ declare global {
           Health: () => Promise<string>;
           ListPorts: () => Promise<string[]>;
           Connect: (port: string) => Promise<void>;
+          ConnectHost: (host: string) => Promise<void>;
           Disconnect: () => Promise<void>;
           ConnectionStatus: () => Promise<ChirpConnectionStatus>;
           LoadInfo: () => Promise<ChirpInfoView>;
+          LoadNodes: () => Promise<ChirpNodesView>;
           StartListener: () => Promise<void>;
           StopListener: () => Promise<void>;
           GetListenerStatus: () => Promise<ChirpListenerStatus>;
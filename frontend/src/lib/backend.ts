@@ -21,6 +21,10 @@ export async function connect(port: string): Promise<void> {
   return getBindings().Connect(port);
 }
 
+export async function connectHost(host: string): Promise<void> {
+  return getBindings().ConnectHost(host);
+}
+
 export async function disconnect(): Promise<void> {
   return getBindings().Disconnect();
 }
@@ -33,6 +37,10 @@ export async function loadInfo(): Promise<ChirpInfoView> {
   return getBindings().LoadInfo();
 }
 
+export async function loadNodes(): Promise<ChirpNodesView> {
+  return getBindings().LoadNodes();
+}
+
 export async function startListener(): Promise<void> {
   return getBindings().StartListener();
 }
@@ -0,0 +1,106 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+// requestAdmin sends an admin request to the destination node and returns its
+// response, bounded by the request timeout.
+func (s *Service) requestAdmin(ctx context.Context, msg *pb.AdminMessage) (*pb.AdminMessage, error) {
+	reqCtx, cancel := s.requestContext(ctx)
+	defer cancel()
+	return s.client.RequestAdmin(reqCtx, s.dest, msg)
+}
+
+// sendAdmin sends an admin write to the destination node and waits for its
+// ack, bounded by the request timeout.
+func (s *Service) sendAdmin(ctx context.Context, msg *pb.AdminMessage) error {
+	reqCtx, cancel := s.requestContext(ctx)
+	defer cancel()
+	return s.client.SendAdmin(reqCtx, s.dest, msg)
+}
+
+// writeAdmin sends one write on its own, and several in an edit-settings
+// transaction.
+func (s *Service) writeAdmin(ctx context.Context, writes []*pb.AdminMessage) error {
+	if len(writes) == 1 {
+		if err := s.sendAdmin(ctx, writes[0]); err != nil {
+			return fmt.Errorf("%s: %w", adminWriteName(writes[0]), err)
+		}
+		return nil
+	}
+	return s.editSettings(ctx, writes)
+}
+
+// editSettings sends writes between BeginEditSettings and CommitEditSettings,
+// so the node saves them, and reboots if it must, once. A node left inside the
+// transaction holds back every later change unsaved, so when a write fails the
+// transaction is committed anyway, which saves the writes before it, and the
+// error says which writes landed.
+func (s *Service) editSettings(ctx context.Context, writes []*pb.AdminMessage) error {
+	begin := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_BeginEditSettings{BeginEditSettings: true}}
+	if err := s.sendAdmin(ctx, begin); err != nil {
+		return fmt.Errorf("begin edit settings: %w", err)
+	}
+	for i, msg := range writes {
+		if err := s.sendAdmin(ctx, msg); err != nil {
+			return s.commitAfterFailedWrite(ctx, writes, i, err)
+		}
+	}
+	if err := s.sendAdmin(ctx, commitEditSettings()); err != nil {
+		return fmt.Errorf("commit edit settings: %w", err)
+	}
+	return nil
+}
+
+// commitAfterFailedWrite closes the transaction editSettings opened after
+// writes[failed] failed with err. ctx may be what ended, so the commit gets a
+// fresh request timeout. The cause is formatted rather than wrapped: a timeout
+// would otherwise be reported as just that, hiding which writes were saved.
+func (s *Service) commitAfterFailedWrite(ctx context.Context, writes []*pb.AdminMessage, failed int, err error) error {
+	timeout := s.requestTimeout
+	if timeout <= 0 {
+		timeout = commitTimeout
+	}
+	commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	failure := fmt.Sprintf("%s failed after %d of %d writes: %v", adminWriteName(writes[failed]), failed, len(writes), err)
+	if commitErr := s.client.SendAdmin(commitCtx, s.dest, commitEditSettings()); commitErr != nil {
+		return fmt.Errorf("%s; commit edit settings also failed, so the node may still hold the earlier writes unsaved: %w", failure, commitErr)
+	}
+	return fmt.Errorf("%s; the earlier writes were committed, the failed one may or may not have been applied, and the rest were not sent", failure)
+}
+
+// commitTimeout bounds the commit after a failed write when the service has
+// no request timeout.
+const commitTimeout = 10 * time.Second
+
+func commitEditSettings() *pb.AdminMessage {
+	return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_CommitEditSettings{CommitEditSettings: true}}
+}
+
+// adminWriteName describes an admin write for error messages, such as
+// "set lora config" or "set channel 2".
+func adminWriteName(msg *pb.AdminMessage) string {
+	switch v := msg.GetPayloadVariant().(type) {
+	case *pb.AdminMessage_SetConfig:
+		return "set " + configSectionName(v.SetConfig) + " config"
+	case *pb.AdminMessage_SetModuleConfig:
+		return "set " + moduleConfigSectionName(v.SetModuleConfig) + " config"
+	case *pb.AdminMessage_SetChannel:
+		return fmt.Sprintf("set channel %d", v.SetChannel.GetIndex())
+	case *pb.AdminMessage_SetOwner:
+		return "set owner"
+	case *pb.AdminMessage_SetFixedPosition:
+		return "set fixed position"
+	case *pb.AdminMessage_SetCannedMessageModuleMessages:
+		return "set canned messages"
+	default:
+		return fmt.Sprintf("admin %T", v)
+	}
+}
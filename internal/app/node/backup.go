@@ -0,0 +1,78 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+// ParseBackupLocation parses "flash" or "sd", the places a node keeps its
+// preferences backup.
+func ParseBackupLocation(s string) (pb.AdminMessage_BackupLocation, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "flash":
+		return pb.AdminMessage_FLASH, nil
+	case "sd":
+		return pb.AdminMessage_SD, nil
+	}
+	return 0, invalidf("backup location %q must be flash or sd", s)
+}
+
+// BackupPreferences has the node copy its config, module config, channels and
+// owner to a backup at loc.
+func (s *Service) BackupPreferences(ctx context.Context, loc pb.AdminMessage_BackupLocation) error {
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_BackupPreferences{BackupPreferences: loc}}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return fmt.Errorf("backup preferences: %w", err)
+	}
+	return nil
+}
+
+// RestorePreferences has the node replace its preferences with the backup at
+// loc. The node reboots to apply them.
+func (s *Service) RestorePreferences(ctx context.Context, loc pb.AdminMessage_BackupLocation) error {
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_RestorePreferences{RestorePreferences: loc}}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return fmt.Errorf("restore preferences: %w", err)
+	}
+	return nil
+}
+
+// RemoveBackupPreferences deletes the node's backup at loc.
+func (s *Service) RemoveBackupPreferences(ctx context.Context, loc pb.AdminMessage_BackupLocation) error {
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_RemoveBackupPreferences{RemoveBackupPreferences: loc}}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return fmt.Errorf("remove backup preferences: %w", err)
+	}
+	return nil
+}
+
+// ExportBackup reads the owner, every config and module config section and
+// every channel slot into a BackupPreferences, the message the firmware keeps
+// its own backups in. Keys are included, so the result is as sensitive as the
+// node itself. Version is left at zero; it tracks the firmware's storage
+// format, which a client cannot know.
+func (s *Service) ExportBackup(ctx context.Context) (*pb.BackupPreferences, error) {
+	owner, err := s.getOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config, moduleConfig, err := s.readLocalConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := s.GetChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BackupPreferences{
+		Timestamp:    uint32(time.Now().Unix()),
+		Config:       config,
+		ModuleConfig: moduleConfig,
+		Channels:     &pb.ChannelFile{Channels: channels},
+		Owner:        owner,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+func TestParseBackupLocation(t *testing.T) {
+	for in, want := range map[string]pb.AdminMessage_BackupLocation{"flash": pb.AdminMessage_FLASH, "SD": pb.AdminMessage_SD} {
+		got, err := ParseBackupLocation(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseBackupLocation(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+
+	_, err := ParseBackupLocation("usb")
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+func TestServiceBackupMessages(t *testing.T) {
+	fc := &fakeClient{}
+	svc := NewService(fc)
+	ctx := context.Background()
+
+	if err := svc.BackupPreferences(ctx, pb.AdminMessage_SD); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if err := svc.RestorePreferences(ctx, pb.AdminMessage_SD); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if err := svc.RemoveBackupPreferences(ctx, pb.AdminMessage_FLASH); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	if len(fc.adminSent) != 3 {
+		t.Fatalf("unexpected admin messages: %v", fc.adminSent)
+	}
+	if v, ok := fc.adminSent[0].GetPayloadVariant().(*pb.AdminMessage_BackupPreferences); !ok || v.BackupPreferences != pb.AdminMessage_SD {
+		t.Fatalf("unexpected backup message: %v", fc.adminSent[0])
+	}
+	if v, ok := fc.adminSent[1].GetPayloadVariant().(*pb.AdminMessage_RestorePreferences); !ok || v.RestorePreferences != pb.AdminMessage_SD {
+		t.Fatalf("unexpected restore message: %v", fc.adminSent[1])
+	}
+	if v, ok := fc.adminSent[2].GetPayloadVariant().(*pb.AdminMessage_RemoveBackupPreferences); !ok || v.RemoveBackupPreferences != pb.AdminMessage_FLASH {
+		t.Fatalf("unexpected remove message: %v", fc.adminSent[2])
+	}
+}
+
+func TestServiceExportBackupKeepsKeys(t *testing.T) {
+	security := &pb.Config_SecurityConfig{PrivateKey: []byte("secret")}
+	fc := &fakeClient{admin: fakeNodeAdmin(&pb.User{LongName: "Base", ShortName: "BS"}, &pb.Config_LoRaConfig{HopLimit: 3}, security)}
+
+	backup, err := NewService(fc).ExportBackup(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if backup.GetOwner().GetLongName() != "Base" || backup.GetConfig().GetLora().GetHopLimit() != 3 {
+		t.Fatalf("unexpected backup: %v", backup)
+	}
+	if string(backup.GetConfig().GetSecurity().GetPrivateKey()) != "secret" {
+		t.Fatalf("private key missing: %v", backup.GetConfig().GetSecurity())
+	}
+	if backup.GetModuleConfig().GetMqtt() == nil {
+		t.Fatalf("module config missing: %v", backup.GetModuleConfig())
+	}
+	if n := len(backup.GetChannels().GetChannels()); n != MaxChannels {
+		t.Fatalf("channels = %d, want %d", n, MaxChannels)
+	}
+	if backup.GetTimestamp() == 0 {
+		t.Fatalf("timestamp not set")
+	}
+}
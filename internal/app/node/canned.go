@@ -0,0 +1,189 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// MaxCannedMessagesBytes is the length of the '|'-joined list the firmware
+	// stores, in a 201-byte field with its NUL.
+	MaxCannedMessagesBytes = 200
+	// maxCannedMessages is how many entries the module splits the list into.
+	maxCannedMessages = 50
+)
+
+// CannedMessages is the canned message list and the module settings that
+// decide how it is picked on the device.
+type CannedMessages struct {
+	Messages []string `json:"messages"`
+	// Enabled and InputSource are only read by older firmware; newer firmware
+	// enables the module when an input device is configured.
+	Enabled     bool   `json:"enabled"`
+	Rotary      bool   `json:"rotary1_enabled"`
+	UpDown      bool   `json:"updown1_enabled"`
+	SendBell    bool   `json:"send_bell"`
+	InputSource string `json:"allow_input_source"`
+}
+
+// CannedUpdate changes the canned messages and module settings; nil fields
+// are left as they are.
+type CannedUpdate struct {
+	Messages    *[]string
+	Enabled     *bool
+	Rotary      *bool
+	UpDown      *bool
+	SendBell    *bool
+	InputSource *string
+}
+
+func (u CannedUpdate) setsModule() bool {
+	return u.Enabled != nil || u.Rotary != nil || u.UpDown != nil || u.SendBell != nil || u.InputSource != nil
+}
+
+// ParseCannedMessages splits text into messages at '|' and line breaks, so
+// both "Ok|On my way" and a file of one message per line work. Space around
+// each message and blank lines are dropped.
+func ParseCannedMessages(text string) []string {
+	var messages []string
+	for _, line := range strings.Split(text, "\n") {
+		for _, msg := range strings.Split(line, "|") {
+			if msg = strings.TrimSpace(msg); msg != "" {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return messages
+}
+
+// ValidateCannedUpdate checks the messages against the firmware's limits.
+func ValidateCannedUpdate(u CannedUpdate) error {
+	if u.Messages == nil && !u.setsModule() {
+		return invalidf("give messages or at least one module setting")
+	}
+	if u.Messages == nil {
+		return nil
+	}
+
+	messages := *u.Messages
+	if len(messages) == 0 {
+		// The firmware ignores an empty list rather than clearing the messages.
+		return invalidf("give at least one message; the node cannot clear the list")
+	}
+	if len(messages) > maxCannedMessages {
+		return invalidf("%d messages given; the module holds at most %d", len(messages), maxCannedMessages)
+	}
+	for i, msg := range messages {
+		switch {
+		case !utf8.ValidString(msg):
+			return invalidf("message %d is not valid UTF-8", i+1)
+		case strings.TrimSpace(msg) == "":
+			return invalidf("message %d is empty", i+1)
+		case strings.Contains(msg, "|"):
+			return invalidf("message %d %q contains |, which separates messages", i+1, msg)
+		case strings.ContainsFunc(msg, unicode.IsControl):
+			return invalidf("message %d %q contains control characters", i+1, msg)
+		}
+	}
+	if n := len(JoinCannedMessages(messages)); n > MaxCannedMessagesBytes {
+		return invalidf("messages take %d bytes joined with |; the node stores at most %d", n, MaxCannedMessagesBytes)
+	}
+	return nil
+}
+
+// JoinCannedMessages formats messages the way the firmware stores them.
+func JoinCannedMessages(messages []string) string {
+	return strings.Join(messages, "|")
+}
+
+// GetCannedMessages reads the canned messages and the canned_message module
+// config.
+func (s *Service) GetCannedMessages(ctx context.Context) (CannedMessages, error) {
+	messages, err := s.getCannedMessageText(ctx)
+	if err != nil {
+		return CannedMessages{}, err
+	}
+	section, err := s.GetConfigSection(ctx, "canned_message")
+	if err != nil {
+		return CannedMessages{}, err
+	}
+	return newCannedMessages(ParseCannedMessages(messages), section.Message.(*pb.ModuleConfig_CannedMessageConfig)), nil
+}
+
+func (s *Service) getCannedMessageText(ctx context.Context) (string, error) {
+	resp, err := s.requestAdmin(ctx, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetCannedMessageModuleMessagesRequest{
+		GetCannedMessageModuleMessagesRequest: true,
+	}})
+	if err != nil {
+		return "", fmt.Errorf("get canned messages: %w", err)
+	}
+	if _, ok := resp.GetPayloadVariant().(*pb.AdminMessage_GetCannedMessageModuleMessagesResponse); !ok {
+		return "", fmt.Errorf("get canned messages: unexpected admin response %T", resp.GetPayloadVariant())
+	}
+	return resp.GetGetCannedMessageModuleMessagesResponse(), nil
+}
+
+func newCannedMessages(messages []string, c *pb.ModuleConfig_CannedMessageConfig) CannedMessages {
+	if messages == nil {
+		messages = []string{}
+	}
+	return CannedMessages{
+		Messages:    messages,
+		Enabled:     c.GetEnabled(),
+		Rotary:      c.GetRotary1Enabled(),
+		UpDown:      c.GetUpdown1Enabled(),
+		SendBell:    c.GetSendBell(),
+		InputSource: c.GetAllowInputSource(),
+	}
+}
+
+// SetCannedMessages applies u, writing the messages and the module config in
+// one edit-settings transaction when both change, then reads both back and
+// returns what the node reports.
+func (s *Service) SetCannedMessages(ctx context.Context, u CannedUpdate) (CannedMessages, error) {
+	if err := ValidateCannedUpdate(u); err != nil {
+		return CannedMessages{}, err
+	}
+
+	section, err := s.GetConfigSection(ctx, "canned_message")
+	if err != nil {
+		return CannedMessages{}, err
+	}
+	module := proto.Clone(section.Message).(*pb.ModuleConfig_CannedMessageConfig)
+
+	var writes []*pb.AdminMessage
+	if u.Messages != nil {
+		writes = append(writes, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetCannedMessageModuleMessages{
+			SetCannedMessageModuleMessages: JoinCannedMessages(*u.Messages),
+		}})
+	}
+	if u.setsModule() {
+		setIf(&module.Enabled, u.Enabled)
+		setIf(&module.Rotary1Enabled, u.Rotary)
+		setIf(&module.Updown1Enabled, u.UpDown)
+		setIf(&module.SendBell, u.SendBell)
+		setIf(&module.AllowInputSource, u.InputSource)
+		desc, err := lookupConfigSection("canned_message")
+		if err != nil {
+			return CannedMessages{}, err
+		}
+		writes = append(writes, setConfigMessage(desc, module))
+	}
+
+	if err := s.writeAdmin(ctx, writes); err != nil {
+		return CannedMessages{}, err
+	}
+	return s.GetCannedMessages(ctx)
+}
+
+func setIf[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
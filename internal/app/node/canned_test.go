@@ -0,0 +1,86 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseCannedMessages(t *testing.T) {
+	got := ParseCannedMessages("Ok | On my way\n\nNeed help|\r\nBack at camp\n")
+	if want := []string{"Ok", "On my way", "Need help", "Back at camp"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateCannedUpdate(t *testing.T) {
+	messages := func(m ...string) *[]string { return &m }
+	for _, tc := range []struct {
+		u    CannedUpdate
+		want string
+	}{
+		{u: CannedUpdate{Messages: messages("Ok", "On my way")}},
+		{u: CannedUpdate{SendBell: proto.Bool(true)}},
+		{u: CannedUpdate{Messages: messages(strings.Repeat("ü", 100))}},
+		{u: CannedUpdate{}, want: "give messages"},
+		{u: CannedUpdate{Messages: messages()}, want: "cannot clear"},
+		{u: CannedUpdate{Messages: messages(strings.Repeat("ü", 100), "x")}, want: "202 bytes"},
+		{u: CannedUpdate{Messages: messages(strings.Split(strings.Repeat("a|", 51), "|")[:51]...)}, want: "at most 50"},
+		{u: CannedUpdate{Messages: messages("a|b")}, want: "contains |"},
+		{u: CannedUpdate{Messages: messages("a\tb")}, want: "control characters"},
+	} {
+		err := ValidateCannedUpdate(tc.u)
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			continue
+		}
+		var vErr *ValidationError
+		if !errors.As(err, &vErr) || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("got %v, want validation error containing %q", err, tc.want)
+		}
+	}
+}
+
+func TestServiceSetCannedMessagesWithModuleSettings(t *testing.T) {
+	fc := &fakeClient{admin: fakeNodeAdmin(&pb.User{}, &pb.Config_LoRaConfig{}, nil)}
+	svc := NewService(fc)
+
+	canned, err := svc.GetCannedMessages(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(canned.Messages, []string{"hi", "bye"}) || canned.Rotary {
+		t.Fatalf("unexpected canned messages: %+v", canned)
+	}
+
+	messages := []string{"Ok", "On my way"}
+	canned, err = svc.SetCannedMessages(context.Background(), CannedUpdate{Messages: &messages, Rotary: proto.Bool(true)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The transaction is followed by a read of the messages and module config.
+	writes, reads := fc.adminSent[len(fc.adminSent)-6:len(fc.adminSent)-2], fc.adminSent[len(fc.adminSent)-2:]
+	if !writes[0].GetBeginEditSettings() || !writes[3].GetCommitEditSettings() {
+		t.Fatalf("expected one edit-settings transaction, got %v", fc.adminSent)
+	}
+	if got := writes[1].GetSetCannedMessageModuleMessages(); got != "Ok|On my way" {
+		t.Fatalf("messages write = %q", got)
+	}
+	if !writes[2].GetSetModuleConfig().GetCannedMessage().GetRotary1Enabled() {
+		t.Fatalf("rotary not enabled: %v", writes[2])
+	}
+	if !reads[0].GetGetCannedMessageModuleMessagesRequest() || reads[1].GetGetModuleConfigRequest() != pb.AdminMessage_CANNEDMSG_CONFIG {
+		t.Fatalf("expected the result to be read back, got %v", reads)
+	}
+	// The fake node ignores writes, so what it reports is still the old list.
+	if !reflect.DeepEqual(canned.Messages, []string{"hi", "bye"}) || canned.Rotary {
+		t.Fatalf("result is not what the node reports: %+v", canned)
+	}
+}
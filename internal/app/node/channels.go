@@ -0,0 +1,496 @@
+package node
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// MaxChannels is the number of channel slots a node has.
+const MaxChannels = 8
+
+// maxChannelNameBytes is the longest channel name the firmware keeps.
+const maxChannelNameBytes = 11
+
+// ChannelURLPrefix starts every channel URL; the fragment is a base64url
+// ChannelSet.
+const ChannelURLPrefix = "https://meshtastic.org/e/#"
+
+// GetChannels reads every channel slot, in index order.
+func (s *Service) GetChannels(ctx context.Context) ([]*pb.Channel, error) {
+	channels := make([]*pb.Channel, 0, MaxChannels)
+	for i := range MaxChannels {
+		// The request carries the channel index plus one.
+		req := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetChannelRequest{GetChannelRequest: uint32(i) + 1}}
+		resp, err := s.requestAdmin(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("get channel %d: %w", i, err)
+		}
+		ch := resp.GetGetChannelResponse()
+		if ch == nil {
+			return nil, fmt.Errorf("get channel %d: unexpected admin response %T", i, resp.GetPayloadVariant())
+		}
+		channels = append(channels, ch)
+	}
+	return channels, nil
+}
+
+// NewChannelSet collects the settings of every enabled channel, primary
+// first, with the LoRa config they are used with.
+func NewChannelSet(channels []*pb.Channel, lora *pb.Config_LoRaConfig) *pb.ChannelSet {
+	set := &pb.ChannelSet{LoraConfig: lora}
+	for _, role := range []pb.Channel_Role{pb.Channel_PRIMARY, pb.Channel_SECONDARY} {
+		for _, ch := range channels {
+			if ch.GetRole() == role {
+				set.Settings = append(set.Settings, ch.GetSettings())
+			}
+		}
+	}
+	return set
+}
+
+// ChannelURL encodes set as a channel URL.
+func ChannelURL(set *pb.ChannelSet) (string, error) {
+	b, err := proto.Marshal(set)
+	if err != nil {
+		return "", fmt.Errorf("marshal channel set: %w", err)
+	}
+	return ChannelURLPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ParseChannelURL decodes a channel URL. The fragment may be padded, and the
+// "/e/?add=true#" form other clients produce is accepted too.
+func ParseChannelURL(url string) (*pb.ChannelSet, error) {
+	url = strings.TrimSpace(url)
+	_, fragment, ok := strings.Cut(url, "#")
+	if !ok || !strings.Contains(strings.ToLower(url), "meshtastic.org/e/") {
+		return nil, invalidf("%q is not a channel URL (want %s...)", url, ChannelURLPrefix)
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(fragment, "="))
+	if err != nil {
+		return nil, invalidf("channel URL is not valid base64url: %v", err)
+	}
+	var set pb.ChannelSet
+	if err := proto.Unmarshal(b, &set); err != nil {
+		return nil, invalidf("channel URL does not hold a channel set: %v", err)
+	}
+	if len(set.GetSettings()) == 0 {
+		return nil, invalidf("channel URL has no channels")
+	}
+	if len(set.GetSettings()) > MaxChannels {
+		return nil, invalidf("channel URL has %d channels, a node holds at most %d", len(set.GetSettings()), MaxChannels)
+	}
+	return &set, nil
+}
+
+// ChannelSetEntries describes the channels a set holds, in the slots
+// ApplyChannelURL would put them in when replacing.
+func ChannelSetEntries(set *pb.ChannelSet) []ChannelEntry {
+	channels := channelsFromSet(set)[:len(set.GetSettings())]
+	entries := make([]ChannelEntry, len(channels))
+	for i, ch := range channels {
+		entries[i] = NewChannelEntry(ch)
+	}
+	return entries
+}
+
+// GetChannelURL encodes the node's enabled channels and LoRa config as a
+// channel URL.
+func (s *Service) GetChannelURL(ctx context.Context) (string, error) {
+	channels, err := s.GetChannels(ctx)
+	if err != nil {
+		return "", err
+	}
+	lora, err := s.GetConfigSection(ctx, "lora")
+	if err != nil {
+		return "", err
+	}
+	return ChannelURL(NewChannelSet(channels, lora.Message.(*pb.Config_LoRaConfig)))
+}
+
+// ApplyChannelURL configures the node from a channel URL and returns its
+// channels afterwards. By default the URL's channels replace every slot and
+// its LoRa config replaces the node's. With add, the URL's channels go into
+// free slots as secondaries, channels the node already has are skipped, and
+// the LoRa config is left alone.
+func (s *Service) ApplyChannelURL(ctx context.Context, url string, add bool) ([]ChannelEntry, error) {
+	set, err := ParseChannelURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var channels []*pb.Channel
+	var writes []*pb.AdminMessage
+	if add {
+		current, err := s.GetChannels(ctx)
+		if err != nil {
+			return nil, err
+		}
+		channels, err = addChannelSet(current, set)
+		if err != nil {
+			return nil, err
+		}
+		for i, ch := range channels {
+			if !proto.Equal(ch, current[i]) {
+				writes = append(writes, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetChannel{SetChannel: ch}})
+			}
+		}
+	} else {
+		channels = channelsFromSet(set)
+		writes = replaceChannelWrites(set, true)
+	}
+
+	if len(writes) > 0 {
+		if err := s.writeAdmin(ctx, writes); err != nil {
+			return nil, err
+		}
+	}
+	entries := make([]ChannelEntry, len(channels))
+	for i, ch := range channels {
+		entries[i] = NewChannelEntry(ch)
+	}
+	return entries, nil
+}
+
+// addChannelSet puts each of set's channels that current does not already
+// have, by name and key, into the next free secondary slot.
+func addChannelSet(current []*pb.Channel, set *pb.ChannelSet) ([]*pb.Channel, error) {
+	channels := make([]*pb.Channel, len(current))
+	for i, ch := range current {
+		channels[i] = proto.Clone(ch).(*pb.Channel)
+	}
+
+	next := 1
+	for _, settings := range set.GetSettings() {
+		known := false
+		for _, ch := range channels {
+			if ch.GetRole() != pb.Channel_DISABLED && ch.GetSettings().GetName() == settings.GetName() && string(ch.GetSettings().GetPsk()) == string(settings.GetPsk()) {
+				known = true
+				break
+			}
+		}
+		if known {
+			continue
+		}
+
+		for next < len(channels) && channels[next].GetRole() != pb.Channel_DISABLED {
+			next++
+		}
+		if next == len(channels) {
+			return nil, invalidf("not enough free channel slots for the URL's channels; delete some first")
+		}
+		channels[next] = &pb.Channel{Index: int32(next), Role: pb.Channel_SECONDARY, Settings: proto.Clone(settings).(*pb.ChannelSettings)}
+	}
+	return channels, nil
+}
+
+// replaceChannelWrites writes set over every channel slot and, withLora,
+// replaces the LoRa config with the one set carries.
+func replaceChannelWrites(set *pb.ChannelSet, withLora bool) []*pb.AdminMessage {
+	var writes []*pb.AdminMessage
+	for _, ch := range channelsFromSet(set) {
+		writes = append(writes, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetChannel{SetChannel: ch}})
+	}
+	if withLora && set.GetLoraConfig() != nil {
+		writes = append(writes, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetConfig{SetConfig: &pb.Config{
+			PayloadVariant: &pb.Config_Lora{Lora: set.GetLoraConfig()},
+		}}})
+	}
+	return writes
+}
+
+// channelsFromSet lays set out over every channel slot: the first settings
+// become the primary channel, the rest secondaries, and the remaining slots
+// are disabled.
+func channelsFromSet(set *pb.ChannelSet) []*pb.Channel {
+	channels := make([]*pb.Channel, MaxChannels)
+	for i := range channels {
+		ch := &pb.Channel{Index: int32(i), Role: pb.Channel_DISABLED, Settings: &pb.ChannelSettings{}}
+		if i < len(set.GetSettings()) {
+			ch.Settings = proto.Clone(set.GetSettings()[i]).(*pb.ChannelSettings)
+			ch.Role = pb.Channel_SECONDARY
+			if i == 0 {
+				ch.Role = pb.Channel_PRIMARY
+			}
+		}
+		channels[i] = ch
+	}
+	return channels
+}
+
+// ChannelEntry describes one channel slot without revealing its key.
+type ChannelEntry struct {
+	Index    int    `json:"index"`
+	Role     string `json:"role"`
+	Name     string `json:"name"`
+	PSK      string `json:"psk"`
+	Uplink   bool   `json:"uplink"`
+	Downlink bool   `json:"downlink"`
+}
+
+func NewChannelEntry(ch *pb.Channel) ChannelEntry {
+	settings := ch.GetSettings()
+	entry := ChannelEntry{
+		Index:    int(ch.GetIndex()),
+		Role:     ch.GetRole().String(),
+		Name:     settings.GetName(),
+		Uplink:   settings.GetUplinkEnabled(),
+		Downlink: settings.GetDownlinkEnabled(),
+	}
+	if ch.GetRole() != pb.Channel_DISABLED {
+		entry.PSK = PSKStrength(settings.GetPsk())
+	}
+	return entry
+}
+
+// PSKStrength names the kind of key psk is: "none", "default", "simple1"
+// through "simple9" for the well-known one-byte keys, "aes128" or "aes256".
+// Any other key is reported as invalid.
+func PSKStrength(psk []byte) string {
+	switch len(psk) {
+	case 0:
+		return "none"
+	case 1:
+		switch psk[0] {
+		case 0:
+			return "none"
+		case 1:
+			return "default"
+		}
+		if psk[0] <= 10 {
+			return fmt.Sprintf("simple%d", psk[0]-1)
+		}
+		return fmt.Sprintf("invalid (one-byte key %d)", psk[0])
+	case 16:
+		return "aes128"
+	case 32:
+		return "aes256"
+	default:
+		return fmt.Sprintf("invalid (%d bytes)", len(psk))
+	}
+}
+
+// ParsePSK turns a --psk value into a key: "random" for a new 256-bit key,
+// "none", "default", "simple0" through "simple9", or a 16 or 32 byte key as
+// "0x"-prefixed hex or "base64:"-prefixed base64.
+func ParsePSK(value string) ([]byte, error) {
+	v := strings.TrimSpace(value)
+	lower := strings.ToLower(v)
+	switch {
+	case lower == "random":
+		psk := make([]byte, 32)
+		if _, err := rand.Read(psk); err != nil {
+			return nil, fmt.Errorf("generate psk: %w", err)
+		}
+		return psk, nil
+	case lower == "none":
+		return []byte{0}, nil
+	case lower == "default":
+		return []byte{1}, nil
+	case strings.HasPrefix(lower, "simple"):
+		n, err := strconv.Atoi(lower[len("simple"):])
+		if err != nil || n < 0 || n > 9 {
+			return nil, invalidf("--psk %q: simple keys are simple0 through simple9", value)
+		}
+		return []byte{byte(n + 1)}, nil
+	}
+
+	var psk []byte
+	var err error
+	switch {
+	case strings.HasPrefix(lower, "0x"):
+		psk, err = hex.DecodeString(v[2:])
+	case strings.HasPrefix(lower, "base64:"):
+		psk, err = base64.StdEncoding.DecodeString(v[len("base64:"):])
+	default:
+		return nil, invalidf("--psk must be random, none, default, simple0-9, 0x<hex> or base64:<key>")
+	}
+	if err != nil {
+		return nil, invalidf("--psk %q cannot be decoded: %v", value, err)
+	}
+	if len(psk) != 16 && len(psk) != 32 {
+		return nil, invalidf("--psk must be 16 bytes (AES-128) or 32 bytes (AES-256), got %d", len(psk))
+	}
+	return psk, nil
+}
+
+// ChannelUpdate changes a channel's settings; nil fields are left as they are.
+type ChannelUpdate struct {
+	Name     *string
+	PSK      *string
+	Uplink   *bool
+	Downlink *bool
+}
+
+func ValidateChannelUpdate(u ChannelUpdate) error {
+	if u.Name != nil && len(*u.Name) > maxChannelNameBytes {
+		return invalidf("--name must be at most %d bytes, got %d", maxChannelNameBytes, len(*u.Name))
+	}
+	if u.PSK != nil {
+		if _, err := ParsePSK(*u.PSK); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidateChannelIndex(index int) error {
+	if index < 0 || index >= MaxChannels {
+		return invalidf("channel index must be 0-%d, got %d", MaxChannels-1, index)
+	}
+	return nil
+}
+
+func (u ChannelUpdate) apply(settings *pb.ChannelSettings) error {
+	if u.Name != nil {
+		settings.Name = *u.Name
+	}
+	if u.PSK != nil {
+		psk, err := ParsePSK(*u.PSK)
+		if err != nil {
+			return err
+		}
+		settings.Psk = psk
+	}
+	if u.Uplink != nil {
+		settings.UplinkEnabled = *u.Uplink
+	}
+	if u.Downlink != nil {
+		settings.DownlinkEnabled = *u.Downlink
+	}
+	return nil
+}
+
+// ListChannels reads every channel slot.
+func (s *Service) ListChannels(ctx context.Context) ([]ChannelEntry, error) {
+	channels, err := s.GetChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ChannelEntry, len(channels))
+	for i, ch := range channels {
+		entries[i] = NewChannelEntry(ch)
+	}
+	return entries, nil
+}
+
+// AddChannel enables the first disabled slot as a secondary channel. A new
+// channel needs a name and gets a random 256-bit key unless one is given.
+func (s *Service) AddChannel(ctx context.Context, u ChannelUpdate) (ChannelEntry, error) {
+	if u.Name == nil || *u.Name == "" {
+		return ChannelEntry{}, invalidf("--name is required for a new channel")
+	}
+	if u.PSK == nil {
+		random := "random"
+		u.PSK = &random
+	}
+	if err := ValidateChannelUpdate(u); err != nil {
+		return ChannelEntry{}, err
+	}
+
+	channels, err := s.GetChannels(ctx)
+	if err != nil {
+		return ChannelEntry{}, err
+	}
+	for _, ch := range channels {
+		if ch.GetRole() != pb.Channel_DISABLED && ch.GetSettings().GetName() == *u.Name {
+			return ChannelEntry{}, invalidf("channel %d is already named %q", ch.GetIndex(), *u.Name)
+		}
+	}
+	for i, ch := range channels {
+		if i == 0 || ch.GetRole() != pb.Channel_DISABLED {
+			continue
+		}
+		ch = &pb.Channel{Index: int32(i), Role: pb.Channel_SECONDARY, Settings: &pb.ChannelSettings{}}
+		if err := u.apply(ch.Settings); err != nil {
+			return ChannelEntry{}, err
+		}
+		if err := s.writeChannels(ctx, ch); err != nil {
+			return ChannelEntry{}, err
+		}
+		return NewChannelEntry(ch), nil
+	}
+	return ChannelEntry{}, invalidf("all %d channel slots are in use; delete one first", MaxChannels)
+}
+
+// UpdateChannel changes an enabled channel's settings.
+func (s *Service) UpdateChannel(ctx context.Context, index int, u ChannelUpdate) (ChannelEntry, error) {
+	if err := ValidateChannelIndex(index); err != nil {
+		return ChannelEntry{}, err
+	}
+	if err := ValidateChannelUpdate(u); err != nil {
+		return ChannelEntry{}, err
+	}
+
+	channels, err := s.GetChannels(ctx)
+	if err != nil {
+		return ChannelEntry{}, err
+	}
+	ch := channels[index]
+	if ch.GetRole() == pb.Channel_DISABLED {
+		return ChannelEntry{}, invalidf("channel %d is disabled; use channel add", index)
+	}
+	if ch.Settings == nil {
+		ch.Settings = &pb.ChannelSettings{}
+	}
+	if err := u.apply(ch.Settings); err != nil {
+		return ChannelEntry{}, err
+	}
+	if err := s.writeChannels(ctx, ch); err != nil {
+		return ChannelEntry{}, err
+	}
+	return NewChannelEntry(ch), nil
+}
+
+// DeleteChannel disables a secondary channel. Later channels move down a slot
+// so that the enabled channels stay contiguous, as other clients expect. The
+// primary channel cannot be deleted.
+func (s *Service) DeleteChannel(ctx context.Context, index int) error {
+	if err := ValidateChannelIndex(index); err != nil {
+		return err
+	}
+	if index == 0 {
+		return invalidf("channel 0 is the primary channel and cannot be deleted")
+	}
+
+	channels, err := s.GetChannels(ctx)
+	if err != nil {
+		return err
+	}
+	switch channels[index].GetRole() {
+	case pb.Channel_PRIMARY:
+		return invalidf("channel %d is the primary channel and cannot be deleted", index)
+	case pb.Channel_DISABLED:
+		return invalidf("channel %d is already disabled", index)
+	}
+
+	var writes []*pb.Channel
+	for i := index; i < len(channels); i++ {
+		next := &pb.Channel{Index: int32(i), Role: pb.Channel_DISABLED, Settings: &pb.ChannelSettings{}}
+		if i+1 < len(channels) {
+			next.Role = channels[i+1].GetRole()
+			next.Settings = channels[i+1].GetSettings()
+		}
+		if next.GetRole() == channels[i].GetRole() && proto.Equal(next.GetSettings(), channels[i].GetSettings()) {
+			continue
+		}
+		writes = append(writes, next)
+	}
+	return s.writeChannels(ctx, writes...)
+}
+
+func (s *Service) writeChannels(ctx context.Context, channels ...*pb.Channel) error {
+	writes := make([]*pb.AdminMessage, len(channels))
+	for i, ch := range channels {
+		writes[i] = &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetChannel{SetChannel: ch}}
+	}
+	return s.writeAdmin(ctx, writes)
+}
@@ -0,0 +1,236 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeChannels answers channel requests from the given slots; slots beyond
+// them are disabled.
+func fakeChannels(channels ...*pb.Channel) func(*pb.AdminMessage) (*pb.AdminMessage, error) {
+	return func(msg *pb.AdminMessage) (*pb.AdminMessage, error) {
+		if msg.GetPayloadVariant() == nil || msg.GetGetChannelRequest() == 0 {
+			return nil, errors.New("unexpected request")
+		}
+		index := int(msg.GetGetChannelRequest()) - 1
+		ch := &pb.Channel{Index: int32(index), Settings: &pb.ChannelSettings{}}
+		if index < len(channels) {
+			ch = proto.Clone(channels[index]).(*pb.Channel)
+		}
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetChannelResponse{GetChannelResponse: ch}}, nil
+	}
+}
+
+func testChannel(index int32, role pb.Channel_Role, name string) *pb.Channel {
+	return &pb.Channel{Index: index, Role: role, Settings: &pb.ChannelSettings{Name: name, Psk: []byte{1}}}
+}
+
+func TestPSKStrengthAndParsePSK(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{in: "none", want: "none"},
+		{in: "default", want: "default"},
+		{in: "simple3", want: "simple3"},
+		{in: "0x" + strings.Repeat("ab", 16), want: "aes128"},
+		{in: "base64:" + strings.Repeat("A", 43) + "=", want: "aes256"},
+		{in: "random", want: "aes256"},
+	} {
+		psk, err := ParsePSK(tc.in)
+		if err != nil {
+			t.Fatalf("ParsePSK(%q): %v", tc.in, err)
+		}
+		if got := PSKStrength(psk); got != tc.want {
+			t.Fatalf("PSKStrength(ParsePSK(%q)) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+
+	a, _ := ParsePSK("random")
+	b, _ := ParsePSK("random")
+	if string(a) == string(b) {
+		t.Fatalf("random keys repeat")
+	}
+	for _, in := range []string{"simple10", "0x1234", "hunter2"} {
+		if _, err := ParsePSK(in); err == nil {
+			t.Fatalf("ParsePSK(%q) succeeded", in)
+		}
+	}
+}
+
+func TestPSKStrengthReportsUnknownKeysAsInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		psk  []byte
+		want string
+	}{
+		{psk: []byte{10}, want: "simple9"},
+		{psk: []byte{11}, want: "invalid (one-byte key 11)"},
+		{psk: []byte{255}, want: "invalid (one-byte key 255)"},
+		{psk: []byte{1, 2}, want: "invalid (2 bytes)"},
+	} {
+		if got := PSKStrength(tc.psk); got != tc.want {
+			t.Fatalf("PSKStrength(%v) = %q, want %q", tc.psk, got, tc.want)
+		}
+	}
+}
+
+func TestServiceAddChannelUsesFirstFreeSlotWithRandomKey(t *testing.T) {
+	fc := &fakeClient{admin: fakeChannels(
+		testChannel(0, pb.Channel_PRIMARY, ""),
+		testChannel(1, pb.Channel_SECONDARY, "ops"),
+	)}
+	name := "team"
+
+	entry, err := NewService(fc).AddChannel(context.Background(), ChannelUpdate{Name: &name})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Index != 2 || entry.Role != "SECONDARY" || entry.PSK != "aes256" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	set := fc.adminSent[len(fc.adminSent)-1].GetSetChannel()
+	if set.GetIndex() != 2 || len(set.GetSettings().GetPsk()) != 32 {
+		t.Fatalf("unexpected write: %v", set)
+	}
+
+	dup := "ops"
+	if _, err := NewService(fc).AddChannel(context.Background(), ChannelUpdate{Name: &dup}); err == nil {
+		t.Fatalf("expected error for a duplicate name")
+	}
+}
+
+func TestServiceDeleteChannelShiftsLaterChannelsDown(t *testing.T) {
+	fc := &fakeClient{admin: fakeChannels(
+		testChannel(0, pb.Channel_PRIMARY, ""),
+		testChannel(1, pb.Channel_SECONDARY, "ops"),
+		testChannel(2, pb.Channel_SECONDARY, "team"),
+	)}
+
+	if err := NewService(fc).DeleteChannel(context.Background(), 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var writes []*pb.Channel
+	for _, msg := range fc.adminSent {
+		if ch := msg.GetSetChannel(); ch != nil {
+			writes = append(writes, ch)
+		}
+	}
+	if len(writes) != 2 {
+		t.Fatalf("expected two channel writes, got %v", writes)
+	}
+	if writes[0].GetIndex() != 1 || writes[0].GetSettings().GetName() != "team" || writes[0].GetRole() != pb.Channel_SECONDARY {
+		t.Fatalf("channel 2 not moved to slot 1: %v", writes[0])
+	}
+	if writes[1].GetIndex() != 2 || writes[1].GetRole() != pb.Channel_DISABLED {
+		t.Fatalf("slot 2 not disabled: %v", writes[1])
+	}
+	if !fc.adminSent[MaxChannels].GetBeginEditSettings() || !fc.adminSent[len(fc.adminSent)-1].GetCommitEditSettings() {
+		t.Fatalf("writes not wrapped in a transaction: %v", fc.adminSent[MaxChannels:])
+	}
+}
+
+func TestServiceDeleteChannelRefusesPrimary(t *testing.T) {
+	fc := &fakeClient{admin: fakeChannels(
+		testChannel(0, pb.Channel_SECONDARY, "odd"),
+		testChannel(1, pb.Channel_PRIMARY, ""),
+	)}
+
+	for _, index := range []int{0, 1} {
+		err := NewService(fc).DeleteChannel(context.Background(), index)
+		var verr *ValidationError
+		if !errors.As(err, &verr) || !strings.Contains(err.Error(), "primary") {
+			t.Fatalf("DeleteChannel(%d) = %v, want primary channel error", index, err)
+		}
+	}
+	for _, msg := range fc.adminSent {
+		if msg.GetSetChannel() != nil {
+			t.Fatalf("channel written: %v", msg)
+		}
+	}
+}
+
+func TestChannelURLRoundTrip(t *testing.T) {
+	set := &pb.ChannelSet{
+		Settings:   []*pb.ChannelSettings{{Psk: []byte{1}}, {Name: "ops", Psk: make([]byte, 32), UplinkEnabled: true}},
+		LoraConfig: &pb.Config_LoRaConfig{Region: pb.Config_LoRaConfig_EU_868, ModemPreset: pb.Config_LoRaConfig_MEDIUM_FAST},
+	}
+	url, err := ChannelURL(set)
+	if err != nil {
+		t.Fatalf("ChannelURL: %v", err)
+	}
+	if !strings.HasPrefix(url, ChannelURLPrefix) || strings.ContainsAny(url[len(ChannelURLPrefix):], "+/=") {
+		t.Fatalf("url = %q, want unpadded base64url after %s", url, ChannelURLPrefix)
+	}
+
+	fragment := url[len(ChannelURLPrefix):]
+	for _, in := range []string{url, " " + url + "==", "https://meshtastic.org/e/?add=true#" + fragment} {
+		got, err := ParseChannelURL(in)
+		if err != nil {
+			t.Fatalf("ParseChannelURL(%q): %v", in, err)
+		}
+		if !proto.Equal(got, set) {
+			t.Fatalf("ParseChannelURL(%q) = %v, want %v", in, got, set)
+		}
+	}
+
+	entries := ChannelSetEntries(set)
+	if len(entries) != 2 || entries[1].Role != "SECONDARY" || entries[1].Name != "ops" || entries[1].PSK != "aes256" || !entries[1].Uplink {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	for _, bad := range []string{"https://meshtastic.org/e/", "https://example.com/#" + fragment, ChannelURLPrefix + "!!!", ChannelURLPrefix} {
+		if _, err := ParseChannelURL(bad); err == nil {
+			t.Fatalf("ParseChannelURL(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestServiceApplyChannelURL(t *testing.T) {
+	url, err := ChannelURL(&pb.ChannelSet{
+		Settings:   []*pb.ChannelSettings{{Psk: []byte{1}}, {Name: "team", Psk: []byte{2}}},
+		LoraConfig: &pb.Config_LoRaConfig{Region: pb.Config_LoRaConfig_EU_868},
+	})
+	if err != nil {
+		t.Fatalf("ChannelURL: %v", err)
+	}
+	current := []*pb.Channel{testChannel(0, pb.Channel_PRIMARY, ""), testChannel(1, pb.Channel_SECONDARY, "ops")}
+
+	t.Run("add", func(t *testing.T) {
+		fc := &fakeClient{admin: fakeChannels(current...)}
+		entries, err := NewService(fc).ApplyChannelURL(context.Background(), url, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if entries[1].Name != "ops" || entries[2].Name != "team" || entries[2].Role != "SECONDARY" {
+			t.Fatalf("unexpected channels: %+v", entries)
+		}
+		writes := fc.adminSent[MaxChannels:]
+		if len(writes) != 1 || writes[0].GetSetChannel().GetIndex() != 2 {
+			t.Fatalf("expected only the new channel written, got %v", writes)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		fc := &fakeClient{admin: fakeChannels(current...)}
+		entries, err := NewService(fc).ApplyChannelURL(context.Background(), url, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if entries[1].Name != "team" || entries[2].Role != "DISABLED" {
+			t.Fatalf("unexpected channels: %+v", entries)
+		}
+		sent := fc.adminSent
+		if len(sent) != MaxChannels+3 || !sent[0].GetBeginEditSettings() || !sent[len(sent)-1].GetCommitEditSettings() {
+			t.Fatalf("expected every slot and the lora config in one transaction, got %v", sent)
+		}
+		if got := sent[len(sent)-2].GetSetConfig().GetLora().GetRegion(); got != pb.Config_LoRaConfig_EU_868 {
+			t.Fatalf("lora region = %v, want EU_868", got)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package node
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ConfigSection is one section of a node's config or module config, such as
+// lora or mqtt.
+type ConfigSection struct {
+	Name string
+	// Module is true for ModuleConfig sections.
+	Module bool
+	// Message is the section itself, such as *pb.Config_LoRaConfig.
+	Message proto.Message
+}
+
+// ConfigField is one leaf of a section, addressed by its dotted path within
+// the section, such as "ipv4_config.ip".
+type ConfigField struct {
+	Path  string
+	Value string
+}
+
+// configSectionDesc ties a section name to its payload_variant field in Config
+// or ModuleConfig. The field number is the admin ConfigType or
+// ModuleConfigType plus one.
+type configSectionDesc struct {
+	name   string
+	module bool
+	field  protoreflect.FieldDescriptor
+}
+
+var configSections = buildConfigSections()
+
+// buildConfigSections lists every Config and ModuleConfig variant under the
+// name configSectionName and moduleConfigSectionName give it. The session key
+// is not configuration and is left out.
+func buildConfigSections() []configSectionDesc {
+	var out []configSectionDesc
+	add := func(msg proto.Message, module bool, name func(proto.Message) string) {
+		oneof := msg.ProtoReflect().Descriptor().Oneofs().ByName("payload_variant")
+		for i := 0; i < oneof.Fields().Len(); i++ {
+			fd := oneof.Fields().Get(i)
+			probe := proto.Clone(msg)
+			m := probe.ProtoReflect()
+			m.Set(fd, m.NewField(fd))
+			if n := name(probe); n != "unknown" && n != "sessionkey" {
+				out = append(out, configSectionDesc{name: n, module: module, field: fd})
+			}
+		}
+	}
+	add(&pb.Config{}, false, func(m proto.Message) string { return configSectionName(m.(*pb.Config)) })
+	add(&pb.ModuleConfig{}, true, func(m proto.Message) string { return moduleConfigSectionName(m.(*pb.ModuleConfig)) })
+	return out
+}
+
+// ConfigSectionNames returns every config section name followed by every
+// module config section name, in protobuf field order.
+func ConfigSectionNames() []string {
+	names := make([]string, len(configSections))
+	for i, s := range configSections {
+		names[i] = s.name
+	}
+	return names
+}
+
+func lookupConfigSection(name string) (configSectionDesc, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, s := range configSections {
+		if s.name == name {
+			return s, nil
+		}
+	}
+	return configSectionDesc{}, invalidf("unknown config section %q (want one of: %s)", name, strings.Join(ConfigSectionNames(), ", "))
+}
+
+// GetConfigSection reads one section from the node with a GetConfigRequest or
+// GetModuleConfigRequest.
+func (s *Service) GetConfigSection(ctx context.Context, name string) (ConfigSection, error) {
+	desc, err := lookupConfigSection(name)
+	if err != nil {
+		return ConfigSection{}, err
+	}
+
+	req := &pb.AdminMessage{}
+	if desc.module {
+		req.PayloadVariant = &pb.AdminMessage_GetModuleConfigRequest{
+			GetModuleConfigRequest: pb.AdminMessage_ModuleConfigType(desc.field.Number() - 1),
+		}
+	} else {
+		req.PayloadVariant = &pb.AdminMessage_GetConfigRequest{
+			GetConfigRequest: pb.AdminMessage_ConfigType(desc.field.Number() - 1),
+		}
+	}
+
+	resp, err := s.requestAdmin(ctx, req)
+	if err != nil {
+		return ConfigSection{}, fmt.Errorf("get %s config: %w", desc.name, err)
+	}
+
+	var container proto.Message = resp.GetGetConfigResponse()
+	if desc.module {
+		container = resp.GetGetModuleConfigResponse()
+	}
+	m := container.ProtoReflect()
+	if !m.IsValid() || !m.Has(desc.field) {
+		return ConfigSection{}, fmt.Errorf("get %s config: unexpected admin response %T", desc.name, resp.GetPayloadVariant())
+	}
+
+	return ConfigSection{Name: desc.name, Module: desc.module, Message: m.Get(desc.field).Message().Interface()}, nil
+}
+
+// FlattenConfig lists every field of msg, set or not, as dotted paths in
+// field order. Enums are written by name, bytes as base64 and lists as
+// comma-separated values.
+func FlattenConfig(msg proto.Message) []ConfigField {
+	var out []ConfigField
+	flattenMessage(msg.ProtoReflect(), "", &out)
+	return out
+}
+
+func flattenMessage(m protoreflect.Message, prefix string, out *[]ConfigField) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		path := prefix + string(fd.Name())
+		v := m.Get(fd)
+
+		if isMessageField(fd) {
+			flattenMessage(v.Message(), path+".", out)
+			continue
+		}
+		*out = append(*out, ConfigField{Path: path, Value: formatConfigField(fd, v)})
+	}
+}
+
+func isMessageField(fd protoreflect.FieldDescriptor) bool {
+	return !fd.IsList() && !fd.IsMap() && (fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind)
+}
+
+// formatConfigField formats a scalar, list or map field's value.
+func formatConfigField(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		items := make([]string, list.Len())
+		for j := range items {
+			items[j] = formatConfigValue(fd, list.Get(j))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case fd.IsMap():
+		return fmt.Sprintf("{%d entries}", v.Map().Len())
+	default:
+		return formatConfigValue(fd, v)
+	}
+}
+
+func formatConfigValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	case protoreflect.StringKind:
+		return strconv.Quote(v.String())
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32)
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "{...}"
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
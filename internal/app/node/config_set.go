@@ -0,0 +1,326 @@
+package node
+
+import (
+	"context"
+	"encoding/base64"
+	"math"
+	"strconv"
+	"strings"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ConfigAssignment sets the field at Path, such as "lora.region", to Value.
+// The first path element names the config section.
+type ConfigAssignment struct {
+	Path  string
+	Value string
+}
+
+// ConfigChange reports one field written by SetConfig, with values formatted
+// as FlattenConfig formats them.
+type ConfigChange struct {
+	Path string `json:"path"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// ParseConfigAssignment splits "section.field=value".
+func ParseConfigAssignment(arg string) (ConfigAssignment, error) {
+	path, value, ok := strings.Cut(arg, "=")
+	path = strings.TrimSpace(path)
+	if !ok || path == "" {
+		return ConfigAssignment{}, invalidf("%q must be in the form section.field=value", arg)
+	}
+	return ConfigAssignment{Path: path, Value: strings.TrimSpace(value)}, nil
+}
+
+type sectionEdit struct {
+	desc configSectionDesc
+	// fields are paths within the section, in the order given.
+	fields []ConfigAssignment
+}
+
+// SetConfig reads each section the assignments touch, applies them and writes
+// the section back with a SetConfig or SetModuleConfig. Every assignment is
+// checked against the schema before anything is sent. Changes to more than
+// one section are written in a single edit-settings transaction so the node
+// saves, and reboots if it must, only once.
+func (s *Service) SetConfig(ctx context.Context, assignments []ConfigAssignment) ([]ConfigChange, error) {
+	edits, err := planConfigEdits(assignments)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []ConfigChange
+	writes := make([]*pb.AdminMessage, 0, len(edits))
+	for _, edit := range edits {
+		section, err := s.GetConfigSection(ctx, edit.desc.name)
+		if err != nil {
+			return nil, err
+		}
+		m := section.Message.ProtoReflect()
+		for _, a := range edit.fields {
+			fd, old, err := setConfigField(m, edit.desc.name, a.Path, a.Value)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, ConfigChange{
+				Path: edit.desc.name + "." + a.Path,
+				Old:  old,
+				New:  formatConfigField(fd, fieldOwner(m, a.Path).Get(fd)),
+			})
+		}
+		writes = append(writes, setConfigMessage(edit.desc, section.Message))
+	}
+
+	if err := s.writeAdmin(ctx, writes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ValidateConfigAssignments checks every path and value against the config
+// schema without contacting the node.
+func ValidateConfigAssignments(assignments []ConfigAssignment) error {
+	_, err := planConfigEdits(assignments)
+	return err
+}
+
+// planConfigEdits groups assignments by section, in the order each section
+// first appears, and dry-runs them against empty sections so that bad paths
+// and values are reported before talking to the radio.
+func planConfigEdits(assignments []ConfigAssignment) ([]sectionEdit, error) {
+	if len(assignments) == 0 {
+		return nil, invalidf("at least one section.field=value is required")
+	}
+
+	var edits []sectionEdit
+	seen := make(map[string]bool)
+	for _, a := range assignments {
+		name, field, ok := strings.Cut(a.Path, ".")
+		if !ok || field == "" {
+			return nil, invalidf("%q must name a field within a section, such as lora.region", a.Path)
+		}
+		desc, err := lookupConfigSection(name)
+		if err != nil {
+			return nil, err
+		}
+		full := desc.name + "." + field
+		if seen[full] {
+			return nil, invalidf("%s is set more than once", full)
+		}
+		seen[full] = true
+
+		probe := desc.container().NewField(desc.field).Message()
+		if _, _, err := setConfigField(probe, desc.name, field, a.Value); err != nil {
+			return nil, err
+		}
+
+		i := 0
+		for i < len(edits) && edits[i].desc.name != desc.name {
+			i++
+		}
+		if i == len(edits) {
+			edits = append(edits, sectionEdit{desc: desc})
+		}
+		edits[i].fields = append(edits[i].fields, ConfigAssignment{Path: field, Value: a.Value})
+	}
+	return edits, nil
+}
+
+func (d configSectionDesc) container() protoreflect.Message {
+	if d.module {
+		return (&pb.ModuleConfig{}).ProtoReflect()
+	}
+	return (&pb.Config{}).ProtoReflect()
+}
+
+func setConfigMessage(desc configSectionDesc, section proto.Message) *pb.AdminMessage {
+	c := desc.container()
+	c.Set(desc.field, protoreflect.ValueOfMessage(section.ProtoReflect()))
+	if desc.module {
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetModuleConfig{SetModuleConfig: c.Interface().(*pb.ModuleConfig)}}
+	}
+	return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetConfig{SetConfig: c.Interface().(*pb.Config)}}
+}
+
+// setConfigField parses value into the field at the dotted path within
+// section m, creating nested messages on the way, and returns the field and
+// its previous value.
+func setConfigField(m protoreflect.Message, section, path, value string) (protoreflect.FieldDescriptor, string, error) {
+	fd, err := resolveConfigField(m.Descriptor(), section, path)
+	if err != nil {
+		return nil, "", err
+	}
+	label := section + "." + path
+	owner := fieldOwner(m, path)
+	old := formatConfigField(fd, owner.Get(fd))
+
+	if fd.IsList() {
+		list := owner.Mutable(fd).List()
+		list.Truncate(0)
+		for _, item := range splitConfigList(value) {
+			v, err := parseConfigValue(fd, label, item)
+			if err != nil {
+				return nil, "", err
+			}
+			list.Append(v)
+		}
+		return fd, old, nil
+	}
+
+	v, err := parseConfigValue(fd, label, value)
+	if err != nil {
+		return nil, "", err
+	}
+	owner.Set(fd, v)
+	return fd, old, nil
+}
+
+func resolveConfigField(md protoreflect.MessageDescriptor, section, path string) (protoreflect.FieldDescriptor, error) {
+	parts := strings.Split(path, ".")
+	path = section + "." + path
+	for i, part := range parts {
+		fields := md.Fields()
+		fd := fields.ByName(protoreflect.Name(part))
+		if fd == nil {
+			names := make([]string, fields.Len())
+			for j := range names {
+				names[j] = string(fields.Get(j).Name())
+			}
+			return nil, invalidf("unknown field %q in %s (want one of: %s)", part, strings.Join(append([]string{section}, parts[:i]...), "."), strings.Join(names, ", "))
+		}
+
+		last := i == len(parts)-1
+		switch {
+		case fd.IsMap():
+			return nil, invalidf("%s is a map and cannot be set", path)
+		case isMessageField(fd) && last:
+			return nil, invalidf("%s is a group of fields; set one of them, such as %s.%s", path, path, fd.Message().Fields().Get(0).Name())
+		case isMessageField(fd):
+			md = fd.Message()
+		case !last:
+			return nil, invalidf("%s has no field %q", strings.Join(append([]string{section}, parts[:i+1]...), "."), parts[i+1])
+		default:
+			return fd, nil
+		}
+	}
+	return nil, invalidf("empty field path")
+}
+
+// fieldOwner returns the message holding the last element of path, which
+// resolveConfigField has already checked.
+func fieldOwner(m protoreflect.Message, path string) protoreflect.Message {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(part))
+		m = m.Mutable(fd).Message()
+	}
+	return m
+}
+
+// splitConfigList accepts "a,b" or the "[a, b]" form FlattenConfig prints.
+func splitConfigList(value string) []string {
+	value = strings.TrimSpace(value)
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	items := strings.Split(value, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+func parseConfigValue(fd protoreflect.FieldDescriptor, path, value string) (protoreflect.Value, error) {
+	bad := func(want string) error {
+		return invalidf("%s: %q is not %s", path, value, want)
+	}
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		switch strings.ToLower(value) {
+		case "true", "on", "yes", "1":
+			return protoreflect.ValueOfBool(true), nil
+		case "false", "off", "no", "0":
+			return protoreflect.ValueOfBool(false), nil
+		}
+		return protoreflect.Value{}, bad("a boolean")
+	case protoreflect.EnumKind:
+		return parseConfigEnum(fd.Enum(), path, value)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(value, 0, 32)
+		if err != nil {
+			return protoreflect.Value{}, bad("a 32-bit integer")
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return protoreflect.Value{}, bad("a 64-bit integer")
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(value, 0, 32)
+		if err != nil {
+			return protoreflect.Value{}, bad("an unsigned 32-bit integer")
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return protoreflect.Value{}, bad("an unsigned 64-bit integer")
+		}
+		return protoreflect.ValueOfUint64(n), nil
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return protoreflect.Value{}, bad("a number")
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return protoreflect.Value{}, bad("a number")
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.StringKind:
+		if len(value) >= 2 && value[0] == '"' {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return protoreflect.Value{}, bad("a valid quoted string")
+			}
+			value = unquoted
+		}
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return protoreflect.Value{}, bad("base64")
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	default:
+		return protoreflect.Value{}, invalidf("%s cannot be set from the command line", path)
+	}
+}
+
+// parseConfigEnum accepts a value name in any case, or a defined number.
+func parseConfigEnum(ed protoreflect.EnumDescriptor, path, value string) (protoreflect.Value, error) {
+	values := ed.Values()
+	names := make([]string, values.Len())
+	for i := range names {
+		ev := values.Get(i)
+		names[i] = string(ev.Name())
+		if strings.EqualFold(names[i], value) {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+	}
+	if n, err := strconv.ParseInt(value, 10, 32); err == nil && values.ByNumber(protoreflect.EnumNumber(n)) != nil {
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	}
+	return protoreflect.Value{}, invalidf("%s: unknown value %q (want one of: %s)", path, value, strings.Join(names, ", "))
+}
@@ -0,0 +1,249 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/coreyvan/chirp/pkg/radio/sim"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+func TestConfigSectionNamesCoverConfigAndModules(t *testing.T) {
+	names := ConfigSectionNames()
+	for _, want := range []string{"device", "position", "power", "network", "display", "lora", "bluetooth", "security", "mqtt", "telemetry", "canned_message"} {
+		if !slices.Contains(names, want) {
+			t.Fatalf("section %q missing from %v", want, names)
+		}
+	}
+	if slices.Contains(names, "sessionkey") || slices.Contains(names, "unknown") {
+		t.Fatalf("unexpected sections in %v", names)
+	}
+}
+
+func TestServiceGetConfigSectionRequestsByType(t *testing.T) {
+	fc := &fakeClient{admin: func(msg *pb.AdminMessage) (*pb.AdminMessage, error) {
+		switch msg.GetPayloadVariant().(type) {
+		case *pb.AdminMessage_GetConfigRequest:
+			return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigResponse{GetConfigResponse: &pb.Config{
+				PayloadVariant: &pb.Config_Lora{Lora: &pb.Config_LoRaConfig{HopLimit: 5, Region: pb.Config_LoRaConfig_EU_868}},
+			}}}, nil
+		case *pb.AdminMessage_GetModuleConfigRequest:
+			return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetModuleConfigResponse{GetModuleConfigResponse: &pb.ModuleConfig{
+				PayloadVariant: &pb.ModuleConfig_Mqtt{Mqtt: &pb.ModuleConfig_MQTTConfig{Enabled: true}},
+			}}}, nil
+		}
+		return nil, errors.New("unexpected request")
+	}}
+	svc := NewService(fc)
+
+	lora, err := svc.GetConfigSection(context.Background(), "lora")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fc.adminSent[0].GetGetConfigRequest(); got != pb.AdminMessage_LORA_CONFIG {
+		t.Fatalf("requested %v, want LORA_CONFIG", got)
+	}
+	if lora.Module || lora.Message.(*pb.Config_LoRaConfig).GetHopLimit() != 5 {
+		t.Fatalf("unexpected section: %+v", lora)
+	}
+
+	mqtt, err := svc.GetConfigSection(context.Background(), "MQTT")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fc.adminSent[1].GetGetModuleConfigRequest(); got != pb.AdminMessage_MQTT_CONFIG {
+		t.Fatalf("requested %v, want MQTT_CONFIG", got)
+	}
+	if !mqtt.Module || !mqtt.Message.(*pb.ModuleConfig_MQTTConfig).GetEnabled() {
+		t.Fatalf("unexpected section: %+v", mqtt)
+	}
+
+	// A response for a different section is not accepted.
+	if _, err := svc.GetConfigSection(context.Background(), "device"); err == nil {
+		t.Fatalf("expected error for mismatched response")
+	}
+
+	var validationErr *ValidationError
+	if _, err := svc.GetConfigSection(context.Background(), "bogus"); !errors.As(err, &validationErr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+func TestFlattenConfigNamesEnumsAndNestedFields(t *testing.T) {
+	fields := FlattenConfig(&pb.Config_NetworkConfig{
+		WifiSsid:         "mesh",
+		AddressMode:      pb.Config_NetworkConfig_STATIC,
+		Ipv4Config:       &pb.Config_NetworkConfig_IpV4Config{Ip: 42},
+		EnabledProtocols: 1,
+	})
+
+	got := map[string]string{}
+	for _, f := range fields {
+		got[f.Path] = f.Value
+	}
+	for path, want := range map[string]string{
+		"wifi_ssid":         `"mesh"`,
+		"address_mode":      "STATIC",
+		"ipv4_config.ip":    "42",
+		"wifi_enabled":      "false",
+		"enabled_protocols": "1",
+	} {
+		if got[path] != want {
+			t.Fatalf("%s = %q, want %q (all: %v)", path, got[path], want, got)
+		}
+	}
+}
+
+func TestServiceSetConfigWritesSectionsInOneTransaction(t *testing.T) {
+	fc := &fakeClient{admin: func(msg *pb.AdminMessage) (*pb.AdminMessage, error) {
+		switch msg.GetPayloadVariant().(type) {
+		case *pb.AdminMessage_GetConfigRequest:
+			return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigResponse{GetConfigResponse: &pb.Config{
+				PayloadVariant: &pb.Config_Lora{Lora: &pb.Config_LoRaConfig{HopLimit: 3, TxPower: 20, Region: pb.Config_LoRaConfig_EU_868}},
+			}}}, nil
+		case *pb.AdminMessage_GetModuleConfigRequest:
+			return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetModuleConfigResponse{GetModuleConfigResponse: &pb.ModuleConfig{
+				PayloadVariant: &pb.ModuleConfig_Telemetry{Telemetry: &pb.ModuleConfig_TelemetryConfig{}},
+			}}}, nil
+		}
+		return nil, errors.New("unexpected request")
+	}}
+
+	changes, err := NewService(fc).SetConfig(context.Background(), []ConfigAssignment{
+		{Path: "lora.region", Value: "us"},
+		{Path: "lora.hop_limit", Value: "4"},
+		{Path: "telemetry.device_update_interval", Value: "900"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []ConfigChange{
+		{Path: "lora.region", Old: "EU_868", New: "US"},
+		{Path: "lora.hop_limit", Old: "3", New: "4"},
+		{Path: "telemetry.device_update_interval", Old: "0", New: "900"},
+	}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("changes = %+v, want %+v", changes, want)
+	}
+
+	sent := fc.adminSent[2:]
+	if len(sent) != 4 || !sent[0].GetBeginEditSettings() || !sent[3].GetCommitEditSettings() {
+		t.Fatalf("expected begin, two sets and commit, got %v", sent)
+	}
+	lora := sent[1].GetSetConfig().GetLora()
+	if lora.GetRegion() != pb.Config_LoRaConfig_US || lora.GetHopLimit() != 4 || lora.GetTxPower() != 20 {
+		t.Fatalf("lora written back without its other fields: %v", lora)
+	}
+	if got := sent[2].GetSetModuleConfig().GetTelemetry().GetDeviceUpdateInterval(); got != 900 {
+		t.Fatalf("device_update_interval = %d, want 900", got)
+	}
+}
+
+// failingWrite passes admin messages through to a radio, except that the nth
+// config write times out without being sent.
+type failingWrite struct {
+	*radio.Radio
+	n, writes int
+}
+
+func (f *failingWrite) SendAdmin(ctx context.Context, to uint32, msg *pb.AdminMessage) error {
+	if msg.GetSetConfig() != nil || msg.GetSetModuleConfig() != nil {
+		f.writes++
+		if f.writes == f.n {
+			return context.DeadlineExceeded
+		}
+	}
+	return f.Radio.SendAdmin(ctx, to, msg)
+}
+
+func TestServiceSetConfigCommitsAfterFailedWrite(t *testing.T) {
+	node, err := sim.New(sim.DefaultScenario())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := radio.NewRadioFromStreamer(node)
+	defer r.Close()
+	if _, err := r.GetRadioInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(&failingWrite{Radio: r, n: 2})
+	svc.SetRequestTimeout(time.Second)
+	_, err = svc.SetConfig(context.Background(), []ConfigAssignment{
+		{Path: "lora.hop_limit", Value: "5"},
+		{Path: "telemetry.device_update_interval", Value: "900"},
+		{Path: "position.gps_update_interval", Value: "120"},
+	})
+	if err == nil {
+		t.Fatalf("expected the failed write to be reported")
+	}
+	for _, want := range []string{"set telemetry config failed after 1 of 3 writes", "earlier writes were committed", "deadline exceeded"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q missing %q", err, want)
+		}
+	}
+	if node.Editing() {
+		t.Fatalf("node left inside the edit-settings transaction")
+	}
+
+	lora, err := svc.GetConfigSection(context.Background(), "lora")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lora.Message.(*pb.Config_LoRaConfig).GetHopLimit(); got != 5 {
+		t.Fatalf("write before the failure not saved: hop_limit = %d", got)
+	}
+}
+
+func TestServiceSetConfigSingleChangeSkipsTransaction(t *testing.T) {
+	fc := &fakeClient{admin: func(*pb.AdminMessage) (*pb.AdminMessage, error) {
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigResponse{GetConfigResponse: &pb.Config{
+			PayloadVariant: &pb.Config_Network{Network: &pb.Config_NetworkConfig{}},
+		}}}, nil
+	}}
+
+	if _, err := NewService(fc).SetConfig(context.Background(), []ConfigAssignment{{Path: "network.ipv4_config.dns", Value: "0x08080808"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fc.adminSent) != 2 {
+		t.Fatalf("expected a get and a set, got %v", fc.adminSent)
+	}
+	if got := fc.adminSent[1].GetSetConfig().GetNetwork().GetIpv4Config().GetDns(); got != 0x08080808 {
+		t.Fatalf("dns = %#x", got)
+	}
+}
+
+func TestValidateConfigAssignmentsRejectsBadInput(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   ConfigAssignment
+		want string
+	}{
+		{name: "no field", in: ConfigAssignment{Path: "lora", Value: "1"}, want: "must name a field"},
+		{name: "unknown section", in: ConfigAssignment{Path: "warp.speed", Value: "9"}, want: "unknown config section"},
+		{name: "unknown field", in: ConfigAssignment{Path: "lora.regoin", Value: "US"}, want: `unknown field "regoin" in lora`},
+		{name: "enum", in: ConfigAssignment{Path: "lora.region", Value: "MARS"}, want: `lora.region: unknown value "MARS"`},
+		{name: "range", in: ConfigAssignment{Path: "lora.hop_limit", Value: "-1"}, want: "not an unsigned 32-bit integer"},
+		{name: "bool", in: ConfigAssignment{Path: "mqtt.enabled", Value: "maybe"}, want: "not a boolean"},
+		{name: "group", in: ConfigAssignment{Path: "network.ipv4_config", Value: "x"}, want: "group of fields"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateConfigAssignments([]ConfigAssignment{tc.in})
+			var verr *ValidationError
+			if !errors.As(err, &verr) || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected validation error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+
+	dup := []ConfigAssignment{{Path: "lora.region", Value: "US"}, {Path: "LORA.region", Value: "EU_868"}}
+	if err := ValidateConfigAssignments(dup); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Fatalf("expected duplicate error, got %v", err)
+	}
+}
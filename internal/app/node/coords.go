@@ -0,0 +1,331 @@
+package node
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// WGS84 ellipsoid and UTM projection constants.
+const (
+	wgs84A     = 6378137.0
+	wgs84F     = 1 / 298.257223563
+	utmK0      = 0.9996
+	utmFalseE  = 500000.0
+	utmFalseNS = 10000000.0
+)
+
+// mgrsBands are the 8° latitude bands from 80°S; X stretches to 84°N.
+const mgrsBands = "CDEFGHJKLMNPQRSTUVWX"
+
+// mgrsColumns are the 100 km column letters, which repeat every three zones.
+var mgrsColumns = [3]string{"ABCDEFGH", "JKLMNPQR", "STUVWXYZ"}
+
+// mgrsRows are the 100 km row letters; even zones start five letters in.
+const mgrsRows = "ABCDEFGHJKLMNPQRSTUV"
+
+// ParseLatitude reads a latitude in decimal degrees ("37.7749", "-33.86") or
+// degrees, minutes and seconds with a hemisphere ("37°46'29.6\"N",
+// "37 46 29.6 N", "S 33 51.6").
+func ParseLatitude(s string) (float64, error) {
+	return parseAngle(s, "latitude", 'N', 'S', 90)
+}
+
+// ParseLongitude is ParseLatitude for longitudes, with E and W hemispheres.
+func ParseLongitude(s string) (float64, error) {
+	return parseAngle(s, "longitude", 'E', 'W', 180)
+}
+
+func parseAngle(s, name string, pos, neg rune, limit float64) (float64, error) {
+	in := strings.TrimSpace(s)
+	if in == "" {
+		return 0, invalidf("%s cannot be empty", name)
+	}
+
+	// A hemisphere letter may lead or trail.
+	sign := 1.0
+	hemisphere := false
+	body := in
+	for _, end := range []int{0, len(body) - 1} {
+		switch unicode.ToUpper(rune(body[end])) {
+		case pos:
+		case neg:
+			sign = -1
+		default:
+			continue
+		}
+		hemisphere = true
+		if end == 0 {
+			body = body[1:]
+		} else {
+			body = body[:end]
+		}
+		break
+	}
+
+	body = strings.Map(func(r rune) rune {
+		switch r {
+		case '°', 'º', '\'', '′', '"', '″', ',':
+			return ' '
+		}
+		return r
+	}, body)
+	fields := strings.Fields(body)
+	if len(fields) == 0 || len(fields) > 3 {
+		return 0, invalidf("%s %q is not decimal degrees or degrees, minutes and seconds", name, in)
+	}
+
+	var value float64
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, invalidf("%s %q is not decimal degrees or degrees, minutes and seconds", name, in)
+		}
+		if i > 0 && (v < 0 || v >= 60) {
+			return 0, invalidf("%s %q: minutes and seconds must be between 0 and 60", name, in)
+		}
+		if i < len(fields)-1 && v != math.Trunc(v) {
+			return 0, invalidf("%s %q: only the last part may have a fraction", name, in)
+		}
+		if i == 0 && v < 0 {
+			if hemisphere {
+				return 0, invalidf("%s %q has both a sign and a hemisphere", name, in)
+			}
+			sign, v = -1, -v
+		}
+		value += v / math.Pow(60, float64(i))
+	}
+
+	value *= sign
+	if math.Abs(value) > limit {
+		return 0, invalidf("%s %q is outside ±%.0f°", name, in, limit)
+	}
+	return value, nil
+}
+
+// ParseUTM reads UTM coordinates as "<zone><band> <easting> <northing>", such
+// as "10S 551131 4180999". The letter is the MGRS latitude band (C-M south of
+// the equator, N-X north), so "N" and "S" are bands, not hemispheres.
+func ParseUTM(s string) (lat, lon float64, err error) {
+	fields := strings.Fields(strings.ToUpper(s))
+	if len(fields) == 4 {
+		fields = append([]string{fields[0] + fields[1]}, fields[2:]...)
+	}
+	if len(fields) != 3 {
+		return 0, 0, invalidf("UTM %q must be \"<zone><band> <easting> <northing>\"", s)
+	}
+
+	zone, band, err := parseZoneBand(fields[0])
+	if err != nil {
+		return 0, 0, invalidf("UTM %q: %v", s, err)
+	}
+	easting, err1 := strconv.ParseFloat(fields[1], 64)
+	northing, err2 := strconv.ParseFloat(fields[2], 64)
+	if err1 != nil || err2 != nil || easting < 100000 || easting >= 900000 || northing < 0 || northing > utmFalseNS {
+		return 0, 0, invalidf("UTM %q: easting must be 100000-899999 and northing 0-10000000 meters", s)
+	}
+
+	lat, lon = utmToLatLon(zone, band >= 'N', easting, northing)
+	return lat, lon, nil
+}
+
+// ParseMGRS reads an MGRS grid reference such as "10SEG5113080998" or
+// "10S EG 51130 80998", at any precision from 100 km to 1 m. The position is
+// the south-west corner of the referenced square.
+func ParseMGRS(s string) (lat, lon float64, err error) {
+	ref := strings.ToUpper(strings.Join(strings.Fields(s), ""))
+
+	i := 0
+	for i < len(ref) && i < 2 && ref[i] >= '0' && ref[i] <= '9' {
+		i++
+	}
+	if i == 0 || len(ref) < i+3 {
+		return 0, 0, invalidf("MGRS %q must start with a zone, band and 100 km square, as in 10SEG", s)
+	}
+	zone, band, err := parseZoneBand(ref[:i+1])
+	if err != nil {
+		return 0, 0, invalidf("MGRS %q: %v", s, err)
+	}
+
+	col := strings.IndexByte(mgrsColumns[(zone-1)%3], ref[i+1])
+	row := strings.IndexByte(mgrsRows, ref[i+2])
+	if col < 0 || row < 0 {
+		return 0, 0, invalidf("MGRS %q: %q is not a 100 km square of zone %d", s, ref[i+1:i+3], zone)
+	}
+
+	digits := ref[i+3:]
+	if len(digits)%2 != 0 || len(digits) > 10 || strings.Trim(digits, "0123456789") != "" {
+		return 0, 0, invalidf("MGRS %q: easting and northing need the same number of digits, up to 5 each", s)
+	}
+	precision := len(digits) / 2
+	scale := math.Pow(10, float64(5-precision))
+	var e, n float64
+	if precision > 0 {
+		ev, _ := strconv.Atoi(digits[:precision])
+		nv, _ := strconv.Atoi(digits[precision:])
+		e, n = float64(ev)*scale, float64(nv)*scale
+	}
+
+	if zone%2 == 0 {
+		row = (row + len(mgrsRows) - 5) % len(mgrsRows)
+	}
+	easting := float64(col+1)*100000 + e
+	northing := float64(row)*100000 + n
+
+	// Row letters repeat every 2000 km; the band says which repetition.
+	north := band >= 'N'
+	bandLat := float64(strings.IndexByte(mgrsBands, band))*8 - 80
+	_, minNorthing := latLonToUTM(bandLat, float64(zone*6-183), zone)
+	for northing < minNorthing-100000 {
+		northing += 2000000
+	}
+
+	lat, lon = utmToLatLon(zone, north, easting, northing)
+	return lat, lon, nil
+}
+
+func parseZoneBand(s string) (int, byte, error) {
+	if len(s) < 2 {
+		return 0, 0, fmt.Errorf("missing zone or band")
+	}
+	zone, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || zone < 1 || zone > 60 {
+		return 0, 0, fmt.Errorf("zone must be 1-60")
+	}
+	band := s[len(s)-1]
+	if strings.IndexByte(mgrsBands, band) < 0 {
+		return 0, 0, fmt.Errorf("band %q is not one of %s", band, mgrsBands)
+	}
+	return zone, band, nil
+}
+
+// FormatDMS writes a position as degrees, minutes and seconds, such as
+// 37°46'29.6"N 122°25'09.8"W.
+func FormatDMS(lat, lon float64) string {
+	return formatDMSAngle(lat, 'N', 'S') + " " + formatDMSAngle(lon, 'E', 'W')
+}
+
+func formatDMSAngle(v float64, pos, neg byte) string {
+	hemisphere := pos
+	if v < 0 {
+		hemisphere, v = neg, -v
+	}
+	tenths := int64(math.Round(v * 36000))
+	deg := tenths / 36000
+	minutes := tenths / 600 % 60
+	seconds := float64(tenths%600) / 10
+	return fmt.Sprintf("%d°%02d'%04.1f\"%c", deg, minutes, seconds, hemisphere)
+}
+
+// FormatMGRS writes a position as a 1 m MGRS grid reference, such as
+// "10S EG 51130 80998". Positions outside 80°S-84°N have no UTM zone and
+// yield an empty string.
+func FormatMGRS(lat, lon float64) string {
+	if lat < -80 || lat > 84 {
+		return ""
+	}
+
+	zone := utmZone(lat, lon)
+	easting, northing := latLonToUTM(lat, lon, zone)
+	band := mgrsBands[min(int((lat+80)/8), len(mgrsBands)-1)]
+
+	col := mgrsColumns[(zone-1)%3][int(easting/100000)-1]
+	rowIndex := int(northing/100000) % len(mgrsRows)
+	if zone%2 == 0 {
+		rowIndex = (rowIndex + 5) % len(mgrsRows)
+	}
+	e := int(math.Floor(easting)) % 100000
+	n := int(math.Floor(northing)) % 100000
+	return fmt.Sprintf("%d%c %c%c %05d %05d", zone, band, col, mgrsRows[rowIndex], e, n)
+}
+
+// utmZone returns the UTM zone of a position, with the Norway and Svalbard
+// exceptions.
+func utmZone(lat, lon float64) int {
+	zone := int(math.Floor((lon+180)/6)) + 1
+	if zone > 60 {
+		zone = 60
+	}
+	if lat >= 56 && lat < 64 && lon >= 3 && lon < 12 {
+		return 32
+	}
+	if lat >= 72 && lat < 84 {
+		switch {
+		case lon >= 0 && lon < 9:
+			return 31
+		case lon >= 9 && lon < 21:
+			return 33
+		case lon >= 21 && lon < 33:
+			return 35
+		case lon >= 33 && lon < 42:
+			return 37
+		}
+	}
+	return zone
+}
+
+// latLonToUTM projects a position onto the transverse Mercator of zone. South
+// of the equator the northing includes the 10000 km false northing.
+func latLonToUTM(lat, lon float64, zone int) (easting, northing float64) {
+	e2 := wgs84F * (2 - wgs84F)
+	ep2 := e2 / (1 - e2)
+	phi := lat * math.Pi / 180
+	lambda0 := float64(zone*6-183) * math.Pi / 180
+
+	sin, cos, tan := math.Sin(phi), math.Cos(phi), math.Tan(phi)
+	n := wgs84A / math.Sqrt(1-e2*sin*sin)
+	t := tan * tan
+	c := ep2 * cos * cos
+	a := cos * (lon*math.Pi/180 - lambda0)
+	m := meridianArc(phi, e2)
+
+	easting = utmFalseE + utmK0*n*(a+(1-t+c)*math.Pow(a, 3)/6+
+		(5-18*t+t*t+72*c-58*ep2)*math.Pow(a, 5)/120)
+	northing = utmK0 * (m + n*tan*(a*a/2+(5-t+9*c+4*c*c)*math.Pow(a, 4)/24+
+		(61-58*t+t*t+600*c-330*ep2)*math.Pow(a, 6)/720))
+	if lat < 0 {
+		northing += utmFalseNS
+	}
+	return easting, northing
+}
+
+// utmToLatLon inverts latLonToUTM.
+func utmToLatLon(zone int, north bool, easting, northing float64) (lat, lon float64) {
+	e2 := wgs84F * (2 - wgs84F)
+	ep2 := e2 / (1 - e2)
+	if !north {
+		northing -= utmFalseNS
+	}
+
+	mu := northing / utmK0 / (wgs84A * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	phi1 := mu + (3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin, cos, tan := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	n1 := wgs84A / math.Sqrt(1-e2*sin*sin)
+	t1 := tan * tan
+	c1 := ep2 * cos * cos
+	r1 := wgs84A * (1 - e2) / math.Pow(1-e2*sin*sin, 1.5)
+	d := (easting - utmFalseE) / (n1 * utmK0)
+
+	phi := phi1 - (n1*tan/r1)*(d*d/2-(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+	lambda := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 +
+		(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120) / cos
+
+	return phi * 180 / math.Pi, float64(zone*6-183) + lambda*180/math.Pi
+}
+
+// meridianArc is the distance along the meridian from the equator to phi.
+func meridianArc(phi, e2 float64) float64 {
+	e4, e6 := e2*e2, e2*e2*e2
+	return wgs84A * ((1-e2/4-3*e4/64-5*e6/256)*phi -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*phi) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*phi) -
+		(35*e6/3072)*math.Sin(6*phi))
+}
@@ -0,0 +1,105 @@
+package node
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseLatitudeAndLongitude(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		lon  bool
+		want float64
+	}{
+		{in: "37.7749", want: 37.7749},
+		{in: `37°46'29.64"N`, want: 37.7749},
+		{in: "37 46 29.64 n", want: 37.7749},
+		{in: "S 33 51.6", want: -33.86},
+		{in: "-33.86", want: -33.86},
+		{in: `122°25'9.84"W`, lon: true, want: -122.4194},
+		{in: "E151 12.918", lon: true, want: 151.2153},
+	} {
+		parse := ParseLatitude
+		if tc.lon {
+			parse = ParseLongitude
+		}
+		got, err := parse(tc.in)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.in, err)
+		}
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Fatalf("parse %q = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+
+	for _, in := range []string{"", "91", "N -3", "12 61", "1.5 30", "37 N 5", "north"} {
+		var verr *ValidationError
+		if _, err := ParseLatitude(in); !errors.As(err, &verr) {
+			t.Fatalf("ParseLatitude(%q) = %v, want validation error", in, err)
+		}
+	}
+}
+
+func TestGridReferencesRoundTrip(t *testing.T) {
+	for _, p := range [][2]float64{
+		{37.7749, -122.4194},
+		{-33.8568, 151.2153},
+		{60.0, 5.0},   // Norway's widened zone 32V
+		{78.0, 15.0},  // Svalbard
+		{-79.9, 0.01}, // southernmost band
+		{83.9, -170.0},
+	} {
+		ref := FormatMGRS(p[0], p[1])
+		lat, lon, err := ParseMGRS(ref)
+		if err != nil {
+			t.Fatalf("ParseMGRS(%q): %v", ref, err)
+		}
+		// A 1 m reference is the south-west corner of its square.
+		if math.Abs(lat-p[0]) > 2e-5 || math.Abs(lon-p[1]) > 5e-5 {
+			t.Fatalf("ParseMGRS(FormatMGRS(%v)) = %v, %v via %q", p, lat, lon, ref)
+		}
+	}
+
+	lat, lon, err := ParseUTM("10S 551131 4180999")
+	if err != nil {
+		t.Fatalf("ParseUTM: %v", err)
+	}
+	if math.Abs(lat-37.7749) > 1e-5 || math.Abs(lon+122.4194) > 1e-5 {
+		t.Fatalf("ParseUTM = %v, %v", lat, lon)
+	}
+	if got := FormatMGRS(37.7749, -122.4194); got != "10S EG 51130 80998" {
+		t.Fatalf("FormatMGRS = %q", got)
+	}
+	if got := FormatDMS(37.7749, -122.4194); got != `37°46'29.6"N 122°25'09.8"W` {
+		t.Fatalf("FormatDMS = %q", got)
+	}
+
+	for _, in := range []string{"10SEG511", "10SIG5113080998", "61SEG", "10S EG 51130 8099X"} {
+		if _, _, err := ParseMGRS(in); err == nil {
+			t.Fatalf("ParseMGRS(%q) succeeded", in)
+		}
+	}
+}
+
+func TestParsePositionInput(t *testing.T) {
+	p, err := ParsePositionInput(PositionInput{MGRS: "10SEG5113080998", Alt: 30})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(p.Lat-37.7749) > 1e-4 || p.Alt != 30 {
+		t.Fatalf("unexpected position: %+v", p)
+	}
+
+	for _, in := range []PositionInput{
+		{},
+		{Lat: "37.7"},
+		{Lat: "37.7", Lon: "-122.4", UTM: "10S 551131 4180999"},
+		{Lat: "0", Lon: "0"},
+	} {
+		var verr *ValidationError
+		if _, err := ParsePositionInput(in); !errors.As(err, &verr) {
+			t.Fatalf("ParsePositionInput(%+v) = %v, want validation error", in, err)
+		}
+	}
+}
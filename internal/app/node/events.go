@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/coreyvan/chirp/pkg/radio"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 	"google.golang.org/protobuf/proto"
 )
@@ -142,6 +143,16 @@ func RenderFromRadio(fr *pb.FromRadio) []StreamLine {
 	}
 }
 
+// RenderConnEvent renders reconnect progress from a radio.Supervisor. Failed
+// attempts are labelled ERR; losing and restoring the link are events.
+func RenderConnEvent(ev radio.ConnEvent) StreamLine {
+	label := "EVT"
+	if ev.Kind == radio.ConnRetrying {
+		label = "ERR"
+	}
+	return StreamLine{Label: label, Message: ev.String(), Category: StreamCategoryEvent}
+}
+
 func RenderMeshPacket(mp *pb.MeshPacket) []StreamLine {
 	if mp == nil {
 		return []StreamLine{{Label: "PKT", Message: "nil", Category: StreamCategoryPacket}}
@@ -187,6 +198,8 @@ func RenderMeshPacket(mp *pb.MeshPacket) []StreamLine {
 		})
 	case pb.PortNum_TELEMETRY_APP:
 		lines = append(lines, renderTelemetry(decoded.GetPayload())...)
+	case pb.PortNum_TRACEROUTE_APP:
+		lines = append(lines, renderRouteDiscovery(mp, decoded.GetPayload()))
 	}
 
 	return lines
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestRenderMeshPacketIncludesPacketAndMessage(t *testing.T) {
@@ -53,3 +54,25 @@ func TestRenderFromRadioMetadata(t *testing.T) {
 		t.Fatalf("unexpected metadata line: %+v", lines[0])
 	}
 }
+
+func TestRenderMeshPacketTracerouteRequest(t *testing.T) {
+	payload, err := proto.Marshal(&pb.RouteDiscovery{Route: []uint32{3}, SnrTowards: []int32{20}})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	lines := RenderMeshPacket(&pb.MeshPacket{
+		From: 1,
+		To:   2,
+		PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+			Portnum: pb.PortNum_TRACEROUTE_APP,
+			Payload: payload,
+		}},
+	})
+
+	if len(lines) != 2 || lines[1].Label != "RTE" {
+		t.Fatalf("unexpected lines: %+v", lines)
+	}
+	if want := "traceroute request towards=!00000001 -> !00000003 (5.00dB)"; lines[1].Message != want {
+		t.Fatalf("route line = %q, want %q", lines[1].Message, want)
+	}
+}
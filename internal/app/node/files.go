@@ -0,0 +1,88 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+// File is an entry of the file manifest a node sends with its config.
+type File struct {
+	Name string `json:"name"`
+	Size uint32 `json:"size_bytes"`
+}
+
+// ValidateFileName checks that name is a path the node can open.
+func ValidateFileName(name string) error {
+	if err := xmodem.ValidateName(name); err != nil {
+		return invalidf("%v", err)
+	}
+	return nil
+}
+
+// ListFiles returns the node's file manifest, sorted by name. Firmware that
+// predates the manifest sends none, so the list is empty there.
+func (s *Service) ListFiles(ctx context.Context) ([]File, error) {
+	reqCtx, cancel := s.requestContext(ctx)
+	defer cancel()
+	responses, err := s.client.Handshake(reqCtx, radio.HandshakeConfigOnly)
+	if err != nil {
+		return nil, fmt.Errorf("list files: %w", err)
+	}
+
+	files := []File{}
+	for _, info := range BuildSnapshot(responses).Files {
+		files = append(files, File{Name: info.GetFileName(), Size: info.GetSizeBytes()})
+	}
+	slices.SortFunc(files, func(a, b File) int { return strings.Compare(a.Name, b.Name) })
+	return files, nil
+}
+
+// GetFile downloads the file name into w over XModem. The request timeout
+// bounds the wait for each block rather than the whole transfer.
+func (s *Service) GetFile(ctx context.Context, name string, w io.Writer, progress func(xmodem.Progress)) (int64, error) {
+	if err := s.checkFileTransfer(name); err != nil {
+		return 0, err
+	}
+	return s.client.GetFile(ctx, name, w, s.xmodemOptions(progress))
+}
+
+// PutFile uploads the contents of r as the file name over XModem, replacing
+// any file of that name. The request timeout bounds the wait for each block
+// rather than the whole transfer.
+func (s *Service) PutFile(ctx context.Context, name string, r io.Reader, progress func(xmodem.Progress)) (int64, error) {
+	if err := s.checkFileTransfer(name); err != nil {
+		return 0, err
+	}
+	return s.client.PutFile(ctx, name, r, s.xmodemOptions(progress))
+}
+
+func (s *Service) checkFileTransfer(name string) error {
+	if s.dest != 0 {
+		return invalidf("files move over XModem, which only reaches the connected node")
+	}
+	return ValidateFileName(name)
+}
+
+func (s *Service) xmodemOptions(progress func(xmodem.Progress)) xmodem.Options {
+	return xmodem.Options{Timeout: s.requestTimeout, Progress: progress}
+}
+
+// DeleteFile removes the file name from the node's filesystem. The firmware
+// acknowledges the request even when there is no such file.
+func (s *Service) DeleteFile(ctx context.Context, name string) error {
+	if err := ValidateFileName(name); err != nil {
+		return err
+	}
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_DeleteFileRequest{DeleteFileRequest: name}}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return fmt.Errorf("delete file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+func TestServiceListFilesSortsManifest(t *testing.T) {
+	fc := &fakeClient{infoResponses: []*pb.FromRadio{
+		{PayloadVariant: &pb.FromRadio_FileInfo{FileInfo: &pb.FileInfo{FileName: "/prefs/db.proto", SizeBytes: 900}}},
+		{PayloadVariant: &pb.FromRadio_FileInfo{FileInfo: &pb.FileInfo{FileName: "/prefs/config.proto", SizeBytes: 120}}},
+	}}
+
+	files, err := NewService(fc).ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.infoMode != radio.HandshakeConfigOnly {
+		t.Fatalf("handshake mode = %v", fc.infoMode)
+	}
+	want := []File{{Name: "/prefs/config.proto", Size: 120}, {Name: "/prefs/db.proto", Size: 900}}
+	if len(files) != 2 || files[0] != want[0] || files[1] != want[1] {
+		t.Fatalf("files = %v, want %v", files, want)
+	}
+}
+
+func TestServiceFileTransfersUseRequestTimeoutPerBlock(t *testing.T) {
+	fc := &fakeClient{}
+	svc := NewService(fc)
+	svc.SetRequestTimeout(3 * time.Second)
+
+	n, err := svc.PutFile(context.Background(), "/notes.txt", strings.NewReader("hello"), nil)
+	if err != nil || n != 5 {
+		t.Fatalf("PutFile = %d, %v", n, err)
+	}
+	if fc.fileName != "/notes.txt" || fc.fileOpts.Timeout != 3*time.Second {
+		t.Fatalf("unexpected transfer: %q %+v", fc.fileName, fc.fileOpts)
+	}
+
+	var out bytes.Buffer
+	if _, err := svc.GetFile(context.Background(), "/notes.txt", &out, nil); err != nil || out.String() != "hello" {
+		t.Fatalf("GetFile = %q, %v", out.String(), err)
+	}
+}
+
+func TestServiceFileTransfersRejectBadNamesAndRemoteNodes(t *testing.T) {
+	var vErr *ValidationError
+
+	_, err := NewService(&fakeClient{}).GetFile(context.Background(), "notes.txt", &bytes.Buffer{}, nil)
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected validation error for relative name, got %v", err)
+	}
+
+	svc := NewService(&fakeClient{})
+	svc.SetDestination(0xa1b2c3d4)
+	_, err = svc.PutFile(context.Background(), "/notes.txt", strings.NewReader("x"), nil)
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected validation error for remote node, got %v", err)
+	}
+}
+
+func TestServiceDeleteFileSendsAdminRequest(t *testing.T) {
+	fc := &fakeClient{}
+	if err := NewService(fc).DeleteFile(context.Background(), "/notes.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fc.adminSent) != 1 || fc.adminSent[0].GetDeleteFileRequest() != "/notes.txt" {
+		t.Fatalf("unexpected admin messages: %v", fc.adminSent)
+	}
+}
@@ -0,0 +1,115 @@
+package node
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+// NodeAction is a change to one entry of the node's NodeDB.
+type NodeAction string
+
+const (
+	NodeFavorite   NodeAction = "favorite"
+	NodeUnfavorite NodeAction = "unfavorite"
+	NodeIgnore     NodeAction = "ignore"
+	NodeUnignore   NodeAction = "unignore"
+	// NodeMute toggles the muted flag; the firmware has no separate unmute.
+	NodeMute   NodeAction = "mute"
+	NodeRemove NodeAction = "remove"
+)
+
+// NodeActionResult is the outcome of a NodeAction for one node. Error is empty
+// when the node accepted it.
+type NodeActionResult struct {
+	Node  string `json:"node"`
+	Error string `json:"error,omitempty"`
+}
+
+// ParseNodeList reads node IDs, one per line. Anything after the first field
+// of a line, blank lines and lines starting with # are ignored, so a list can
+// carry notes. Repeated IDs are kept once.
+func ParseNodeList(r io.Reader) ([]uint32, error) {
+	var (
+		nums []uint32
+		seen = make(map[uint32]bool)
+	)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		num, err := radio.ParseNodeID(fields[0])
+		if err != nil {
+			return nil, invalidf("line %d: %v", line, err)
+		}
+		if !seen[num] {
+			seen[num] = true
+			nums = append(nums, num)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read node list: %w", err)
+	}
+	return nums, nil
+}
+
+// ManageNodes applies action to each node in turn. A node that fails does not
+// stop the others; its error is in its result. Only a cancelled ctx ends the
+// run early.
+func (s *Service) ManageNodes(ctx context.Context, action NodeAction, nums []uint32) ([]NodeActionResult, error) {
+	results := make([]NodeActionResult, 0, len(nums))
+	for _, num := range nums {
+		msg, err := nodeActionMessage(action, num)
+		if err != nil {
+			return nil, err
+		}
+
+		result := NodeActionResult{Node: radio.FormatNodeID(num)}
+		if err := s.sendAdmin(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func nodeActionMessage(action NodeAction, num uint32) (*pb.AdminMessage, error) {
+	msg := &pb.AdminMessage{}
+	switch action {
+	case NodeFavorite:
+		msg.PayloadVariant = &pb.AdminMessage_SetFavoriteNode{SetFavoriteNode: num}
+	case NodeUnfavorite:
+		msg.PayloadVariant = &pb.AdminMessage_RemoveFavoriteNode{RemoveFavoriteNode: num}
+	case NodeIgnore:
+		msg.PayloadVariant = &pb.AdminMessage_SetIgnoredNode{SetIgnoredNode: num}
+	case NodeUnignore:
+		msg.PayloadVariant = &pb.AdminMessage_RemoveIgnoredNode{RemoveIgnoredNode: num}
+	case NodeMute:
+		msg.PayloadVariant = &pb.AdminMessage_ToggleMutedNode{ToggleMutedNode: num}
+	case NodeRemove:
+		msg.PayloadVariant = &pb.AdminMessage_RemoveByNodenum{RemoveByNodenum: num}
+	default:
+		return nil, invalidf("unknown node action %q", action)
+	}
+	return msg, nil
+}
+
+// ResetNodeDB clears the node's NodeDB. With keepFavorites the firmware keeps
+// favorited nodes through the reset.
+func (s *Service) ResetNodeDB(ctx context.Context, keepFavorites bool) error {
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_NodedbReset{NodedbReset: keepFavorites}}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return fmt.Errorf("reset node db: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+func TestParseNodeList(t *testing.T) {
+	nums, err := ParseNodeList(strings.NewReader("# junk nodes\n!a1b2c3d4 old tracker\n\n0x0badcafe\n!A1B2C3D4\n305419896\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []uint32{0xa1b2c3d4, 0x0badcafe, 0x12345678}; !reflect.DeepEqual(nums, want) {
+		t.Fatalf("got %x, want %x", nums, want)
+	}
+
+	_, err = ParseNodeList(strings.NewReader("!a1b2c3d4\nbroadcast\n"))
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected validation error for line 2, got %v", err)
+	}
+}
+
+func TestServiceManageNodesContinuesPastFailures(t *testing.T) {
+	fc := &fakeClient{
+		adminFail: func(msg *pb.AdminMessage) error {
+			if msg.GetSetIgnoredNode() == 2 {
+				return errors.New("NO_RESPONSE")
+			}
+			return nil
+		},
+	}
+
+	results, err := NewService(fc).ManageNodes(context.Background(), NodeIgnore, []uint32{1, 2, 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fc.adminSent) != 3 || fc.adminSent[2].GetSetIgnoredNode() != 3 {
+		t.Fatalf("unexpected admin messages: %v", fc.adminSent)
+	}
+	if len(results) != 3 || results[0].Error != "" || !strings.Contains(results[1].Error, "NO_RESPONSE") || results[1].Node != "!00000002" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
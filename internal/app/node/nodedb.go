@@ -0,0 +1,355 @@
+package node
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+const earthRadiusMeters = 6371008.8
+
+// NodeEntry is what the node DB knows about one node. Optional fields are nil
+// until the node reports them.
+type NodeEntry struct {
+	Num       uint32 `json:"num"`
+	ID        string `json:"id"`
+	LongName  string `json:"long_name"`
+	ShortName string `json:"short_name"`
+	HWModel   string `json:"hw_model"`
+	Role      string `json:"role"`
+	// LastHeard is in Unix seconds; zero when the node was never heard.
+	LastHeard uint32        `json:"last_heard"`
+	SNR       float32       `json:"snr"`
+	HopsAway  *uint32       `json:"hops_away,omitempty"`
+	Battery   *uint32       `json:"battery_level,omitempty"`
+	Voltage   *float32      `json:"voltage,omitempty"`
+	Position  *NodePosition `json:"position,omitempty"`
+	// Distance is in meters from our own node, when both have a position.
+	Distance *float64 `json:"distance_m,omitempty"`
+	Self     bool     `json:"self"`
+	Favorite bool     `json:"favorite"`
+	Ignored  bool     `json:"ignored"`
+	Muted    bool     `json:"muted"`
+	ViaMQTT  bool     `json:"via_mqtt"`
+}
+
+// NodePosition is a node's last reported position in decimal degrees and
+// meters above sea level.
+type NodePosition struct {
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	Alt  int32   `json:"alt"`
+	Time uint32  `json:"time,omitempty"`
+}
+
+// NodeDB tracks the mesh's nodes from the handshake's NodeInfo frames and the
+// packets heard afterwards. It is not safe for concurrent use.
+type NodeDB struct {
+	self  uint32
+	nodes map[uint32]*NodeEntry
+	// now stamps packets that arrive without an rx_time.
+	now func() time.Time
+}
+
+func NewNodeDB() *NodeDB {
+	return &NodeDB{nodes: make(map[uint32]*NodeEntry), now: time.Now}
+}
+
+// BuildNodeDB applies every response of a handshake, and any packets among
+// them, to a new NodeDB.
+func BuildNodeDB(responses []*pb.FromRadio) *NodeDB {
+	db := NewNodeDB()
+	for _, fr := range responses {
+		db.Apply(fr)
+	}
+	return db
+}
+
+// Self returns our own node number, or zero before MyInfo was seen.
+func (db *NodeDB) Self() uint32 {
+	return db.self
+}
+
+// Apply updates the DB from one FromRadio message. Messages that say nothing
+// about nodes are ignored.
+func (db *NodeDB) Apply(fr *pb.FromRadio) {
+	switch v := fr.GetPayloadVariant().(type) {
+	case *pb.FromRadio_MyInfo:
+		db.self = v.MyInfo.GetMyNodeNum()
+		if db.self != 0 {
+			db.entry(db.self).Self = true
+		}
+	case *pb.FromRadio_NodeInfo:
+		db.applyNodeInfo(v.NodeInfo)
+	case *pb.FromRadio_Packet:
+		db.ApplyPacket(v.Packet)
+	}
+}
+
+func (db *NodeDB) applyNodeInfo(info *pb.NodeInfo) {
+	if info.GetNum() == 0 {
+		return
+	}
+
+	e := db.entry(info.GetNum())
+	if user := info.GetUser(); user != nil {
+		e.applyUser(user)
+	}
+	if pos := newNodePosition(info.GetPosition()); pos != nil {
+		e.Position = pos
+	}
+	if dm := info.GetDeviceMetrics(); dm != nil {
+		e.applyDeviceMetrics(dm)
+	}
+	if info.LastHeard > e.LastHeard {
+		e.LastHeard = info.LastHeard
+	}
+	e.SNR = info.GetSnr()
+	if info.HopsAway != nil {
+		hops := info.GetHopsAway()
+		e.HopsAway = &hops
+	}
+	e.Favorite = info.GetIsFavorite()
+	e.Ignored = info.GetIsIgnored()
+	e.Muted = info.GetIsMuted()
+	e.ViaMQTT = info.GetViaMqtt()
+}
+
+// ApplyPacket records that the sender of mp was heard, with the packet's SNR
+// and hop count, and decodes the user, position and device telemetry it may
+// carry.
+func (db *NodeDB) ApplyPacket(mp *pb.MeshPacket) {
+	if mp.GetFrom() == 0 {
+		return
+	}
+
+	e := db.entry(mp.GetFrom())
+	heard := mp.GetRxTime()
+	if heard == 0 {
+		heard = uint32(db.now().Unix())
+	}
+	e.LastHeard = max(e.LastHeard, heard)
+	if mp.GetRxSnr() != 0 {
+		e.SNR = mp.GetRxSnr()
+	}
+	if start := mp.GetHopStart(); start != 0 && start >= mp.GetHopLimit() {
+		hops := start - mp.GetHopLimit()
+		e.HopsAway = &hops
+	}
+	e.ViaMQTT = mp.GetViaMqtt()
+
+	decoded := mp.GetDecoded()
+	switch decoded.GetPortnum() {
+	case pb.PortNum_NODEINFO_APP:
+		var user pb.User
+		if proto.Unmarshal(decoded.GetPayload(), &user) == nil {
+			e.applyUser(&user)
+		}
+	case pb.PortNum_POSITION_APP:
+		var pos pb.Position
+		if proto.Unmarshal(decoded.GetPayload(), &pos) == nil {
+			if p := newNodePosition(&pos); p != nil {
+				e.Position = p
+			}
+		}
+	case pb.PortNum_TELEMETRY_APP:
+		var t pb.Telemetry
+		if proto.Unmarshal(decoded.GetPayload(), &t) == nil && t.GetDeviceMetrics() != nil {
+			e.applyDeviceMetrics(t.GetDeviceMetrics())
+		}
+	}
+}
+
+// Get returns a copy of the entry for num.
+func (db *NodeDB) Get(num uint32) (NodeEntry, bool) {
+	e, ok := db.nodes[num]
+	if !ok {
+		return NodeEntry{}, false
+	}
+	return db.snapshot(e), true
+}
+
+// Nodes returns copies of every entry, ordered by node number, with Distance
+// filled in from our own position.
+func (db *NodeDB) Nodes() []NodeEntry {
+	out := make([]NodeEntry, 0, len(db.nodes))
+	for _, e := range db.nodes {
+		out = append(out, db.snapshot(e))
+	}
+	slices.SortFunc(out, func(a, b NodeEntry) int { return cmp.Compare(a.Num, b.Num) })
+	return out
+}
+
+func (db *NodeDB) snapshot(e *NodeEntry) NodeEntry {
+	out := *e
+	if self, ok := db.nodes[db.self]; ok && self != e && self.Position != nil && e.Position != nil {
+		d := haversine(*self.Position, *e.Position)
+		out.Distance = &d
+	}
+	return out
+}
+
+func (db *NodeDB) entry(num uint32) *NodeEntry {
+	e, ok := db.nodes[num]
+	if !ok {
+		e = &NodeEntry{Num: num, ID: radio.FormatNodeID(num), Self: num == db.self && num != 0}
+		db.nodes[num] = e
+	}
+	return e
+}
+
+func (e *NodeEntry) applyUser(user *pb.User) {
+	e.LongName = user.GetLongName()
+	e.ShortName = user.GetShortName()
+	e.HWModel = user.GetHwModel().String()
+	e.Role = user.GetRole().String()
+}
+
+func (e *NodeEntry) applyDeviceMetrics(dm *pb.DeviceMetrics) {
+	if dm.BatteryLevel != nil {
+		battery := dm.GetBatteryLevel()
+		e.Battery = &battery
+	}
+	if dm.Voltage != nil {
+		voltage := dm.GetVoltage()
+		e.Voltage = &voltage
+	}
+}
+
+// newNodePosition converts a Position, returning nil when it has no fix.
+func newNodePosition(pos *pb.Position) *NodePosition {
+	if pos == nil || pos.LatitudeI == nil || pos.LongitudeI == nil || (pos.GetLatitudeI() == 0 && pos.GetLongitudeI() == 0) {
+		return nil
+	}
+	return &NodePosition{
+		Lat:  float64(pos.GetLatitudeI()) * 1e-7,
+		Lon:  float64(pos.GetLongitudeI()) * 1e-7,
+		Alt:  pos.GetAltitude(),
+		Time: pos.GetTime(),
+	}
+}
+
+// haversine returns the great-circle distance between a and b in meters.
+func haversine(a, b NodePosition) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(min(h, 1)))
+}
+
+// NodeSort orders node listings.
+type NodeSort string
+
+const (
+	// SortLastHeard lists the most recently heard nodes first.
+	SortLastHeard NodeSort = "last-heard"
+	// SortSNR lists the strongest signals first.
+	SortSNR NodeSort = "snr"
+	// SortDistance lists the closest nodes first; nodes without a distance
+	// come last.
+	SortDistance NodeSort = "distance"
+)
+
+// NodeQuery selects and orders nodes. Zero values select everything.
+type NodeQuery struct {
+	Sort NodeSort
+	// Match keeps nodes whose ID, names or hardware model contain it, ignoring case.
+	Match string
+	// MaxHops keeps nodes known to be at most this many hops away.
+	MaxHops *uint32
+	// HeardSince keeps nodes last heard at or after it.
+	HeardSince time.Time
+	// ExcludeSelf drops our own node from the listing.
+	ExcludeSelf bool
+}
+
+func ValidateNodeQuery(q NodeQuery) error {
+	switch q.Sort {
+	case "", SortLastHeard, SortSNR, SortDistance:
+		return nil
+	default:
+		return invalidf("--sort must be one of: %s|%s|%s", SortLastHeard, SortSNR, SortDistance)
+	}
+}
+
+// Query returns the nodes matching q in q's order. Ties, and the default
+// order, fall back to node number.
+func (db *NodeDB) Query(q NodeQuery) []NodeEntry {
+	match := strings.ToLower(strings.TrimSpace(q.Match))
+	nodes := slices.DeleteFunc(db.Nodes(), func(e NodeEntry) bool {
+		switch {
+		case q.ExcludeSelf && e.Self:
+			return true
+		case q.MaxHops != nil && (e.HopsAway == nil || *e.HopsAway > *q.MaxHops):
+			return true
+		case !q.HeardSince.IsZero() && int64(e.LastHeard) < q.HeardSince.Unix():
+			return true
+		case match != "":
+			haystack := strings.ToLower(strings.Join([]string{e.ID, e.LongName, e.ShortName, e.HWModel}, " "))
+			return !strings.Contains(haystack, match)
+		}
+		return false
+	})
+
+	var order func(a, b NodeEntry) int
+	switch q.Sort {
+	case SortLastHeard:
+		order = func(a, b NodeEntry) int { return cmp.Compare(b.LastHeard, a.LastHeard) }
+	case SortSNR:
+		order = func(a, b NodeEntry) int { return cmp.Compare(b.SNR, a.SNR) }
+	case SortDistance:
+		order = func(a, b NodeEntry) int {
+			switch {
+			case a.Distance == nil && b.Distance == nil:
+				return 0
+			case a.Distance == nil:
+				return 1
+			case b.Distance == nil:
+				return -1
+			}
+			return cmp.Compare(*a.Distance, *b.Distance)
+		}
+	}
+	if order != nil {
+		slices.SortStableFunc(nodes, order)
+	}
+	return nodes
+}
+
+type NodesResult struct {
+	Self  string      `json:"self"`
+	Nodes []NodeEntry `json:"nodes"`
+}
+
+// Nodes fetches the node DB with a nodes-only handshake and returns the
+// entries q selects.
+func (s *Service) Nodes(ctx context.Context, q NodeQuery) (NodesResult, error) {
+	if err := ValidateNodeQuery(q); err != nil {
+		return NodesResult{}, err
+	}
+
+	responses, err := s.client.Handshake(ctx, radio.HandshakeNodesOnly)
+	if err != nil {
+		return NodesResult{}, fmt.Errorf("get node db: %w", err)
+	}
+
+	db := BuildNodeDB(responses)
+	return NodesResult{Self: radio.FormatNodeID(db.Self()), Nodes: db.Query(q)}, nil
+}
+
+// FormatDistance renders meters as "850m" or "12.3km".
+func FormatDistance(meters float64) string {
+	if meters < 1000 {
+		return fmt.Sprintf("%.0fm", meters)
+	}
+	return fmt.Sprintf("%.1fkm", meters/1000)
+}
@@ -0,0 +1,153 @@
+package node
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+func nodeInfoFrame(info *pb.NodeInfo) *pb.FromRadio {
+	return &pb.FromRadio{PayloadVariant: &pb.FromRadio_NodeInfo{NodeInfo: info}}
+}
+
+func position(lat, lon float64) *pb.Position {
+	latI, lonI := int32(lat*1e7), int32(lon*1e7)
+	return &pb.Position{LatitudeI: &latI, LongitudeI: &lonI}
+}
+
+func testNodeDB(t *testing.T) *NodeDB {
+	t.Helper()
+	one, two := uint32(1), uint32(2)
+	return BuildNodeDB([]*pb.FromRadio{
+		{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 10}}},
+		nodeInfoFrame(&pb.NodeInfo{Num: 10, User: &pb.User{LongName: "Base"}, Position: position(37.77, -122.42), LastHeard: 500}),
+		nodeInfoFrame(&pb.NodeInfo{Num: 20, User: &pb.User{LongName: "Ridge Relay", ShortName: "RDG", HwModel: pb.HardwareModel_RAK4631}, Position: position(37.80, -122.27), Snr: 6.5, LastHeard: 300, HopsAway: new(uint32)}),
+		nodeInfoFrame(&pb.NodeInfo{Num: 30, User: &pb.User{LongName: "Trail Walker"}, Position: position(37.76, -122.41), Snr: -4.25, LastHeard: 400, HopsAway: &one}),
+		nodeInfoFrame(&pb.NodeInfo{Num: 40, User: &pb.User{LongName: "Far Hill"}, Snr: 1, LastHeard: 100, HopsAway: &two}),
+	})
+}
+
+func nums(nodes []NodeEntry) []uint32 {
+	out := make([]uint32, len(nodes))
+	for i, n := range nodes {
+		out[i] = n.Num
+	}
+	return out
+}
+
+func TestNodeDBQuerySortsAndFilters(t *testing.T) {
+	db := testNodeDB(t)
+	maxHops := uint32(1)
+
+	for _, tc := range []struct {
+		name  string
+		query NodeQuery
+		want  []uint32
+	}{
+		{name: "default", query: NodeQuery{}, want: []uint32{10, 20, 30, 40}},
+		{name: "last heard", query: NodeQuery{Sort: SortLastHeard}, want: []uint32{10, 30, 20, 40}},
+		{name: "snr", query: NodeQuery{Sort: SortSNR, ExcludeSelf: true}, want: []uint32{20, 40, 30}},
+		{name: "distance", query: NodeQuery{Sort: SortDistance, ExcludeSelf: true}, want: []uint32{30, 20, 40}},
+		{name: "match", query: NodeQuery{Match: "rak"}, want: []uint32{20}},
+		{name: "max hops", query: NodeQuery{MaxHops: &maxHops}, want: []uint32{20, 30}},
+		{name: "heard since", query: NodeQuery{HeardSince: time.Unix(300, 0)}, want: []uint32{10, 20, 30}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := nums(db.Query(tc.query)); !equalNums(got, tc.want) {
+				t.Fatalf("nodes = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func equalNums(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNodeDBDistanceFromSelf(t *testing.T) {
+	db := testNodeDB(t)
+
+	self, _ := db.Get(10)
+	if !self.Self || self.Distance != nil {
+		t.Fatalf("unexpected self entry: %+v", self)
+	}
+	relay, _ := db.Get(20)
+	if relay.Distance == nil || math.Abs(*relay.Distance-13630) > 100 {
+		t.Fatalf("distance = %v, want about 13.6km", relay.Distance)
+	}
+	if got := FormatDistance(*relay.Distance); got != "13.6km" {
+		t.Fatalf("FormatDistance = %q", got)
+	}
+	far, _ := db.Get(40)
+	if far.Distance != nil {
+		t.Fatalf("node without position has distance %v", *far.Distance)
+	}
+}
+
+func TestNodeDBApplyPacketUpdatesHeardNode(t *testing.T) {
+	db := testNodeDB(t)
+	battery := uint32(42)
+	payload, err := proto.Marshal(&pb.Telemetry{Variant: &pb.Telemetry_DeviceMetrics{DeviceMetrics: &pb.DeviceMetrics{BatteryLevel: &battery}}})
+	if err != nil {
+		t.Fatalf("marshal telemetry: %v", err)
+	}
+
+	db.Apply(&pb.FromRadio{PayloadVariant: &pb.FromRadio_Packet{Packet: &pb.MeshPacket{
+		From:     40,
+		RxTime:   900,
+		RxSnr:    3.25,
+		HopStart: 3,
+		HopLimit: 2,
+		PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+			Portnum: pb.PortNum_TELEMETRY_APP,
+			Payload: payload,
+		}},
+	}}})
+
+	got, _ := db.Get(40)
+	if got.LastHeard != 900 || got.SNR != 3.25 || got.HopsAway == nil || *got.HopsAway != 1 {
+		t.Fatalf("packet not applied: %+v", got)
+	}
+	if got.Battery == nil || *got.Battery != 42 {
+		t.Fatalf("battery = %v, want 42", got.Battery)
+	}
+	if got.LongName != "Far Hill" {
+		t.Fatalf("packet cleared user: %+v", got)
+	}
+}
+
+func TestServiceNodesUsesNodesOnlyHandshake(t *testing.T) {
+	fc := &fakeClient{infoResponses: []*pb.FromRadio{
+		{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 10}}},
+		nodeInfoFrame(&pb.NodeInfo{Num: 10}),
+		nodeInfoFrame(&pb.NodeInfo{Num: 20}),
+	}}
+
+	result, err := NewService(fc).Nodes(context.Background(), NodeQuery{ExcludeSelf: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.infoMode != radio.HandshakeNodesOnly {
+		t.Fatalf("handshake mode = %v, want nodes-only", fc.infoMode)
+	}
+	if result.Self != "!0000000a" || len(result.Nodes) != 1 || result.Nodes[0].ID != "!00000014" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if _, err := NewService(fc).Nodes(context.Background(), NodeQuery{Sort: "name"}); err == nil {
+		t.Fatalf("expected validation error for unknown sort")
+	}
+}
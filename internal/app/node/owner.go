@@ -0,0 +1,209 @@
+package node
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// Owner is the user a node reports for itself.
+type Owner struct {
+	ID        string `json:"id"`
+	LongName  string `json:"long_name"`
+	ShortName string `json:"short_name"`
+	HWModel   string `json:"hw_model"`
+	Role      string `json:"role"`
+	// Licensed marks a licensed amateur radio operator, whose node sends
+	// unencrypted.
+	Licensed bool `json:"licensed"`
+	// Unmessagable tells other clients the node does not read direct messages,
+	// as is usual for routers and other unattended nodes.
+	Unmessagable bool   `json:"unmessagable"`
+	PublicKey    string `json:"public_key,omitempty"`
+}
+
+func newOwner(u *pb.User) Owner {
+	o := Owner{
+		ID:           u.GetId(),
+		LongName:     u.GetLongName(),
+		ShortName:    u.GetShortName(),
+		HWModel:      u.GetHwModel().String(),
+		Role:         u.GetRole().String(),
+		Licensed:     u.GetIsLicensed(),
+		Unmessagable: u.GetIsUnmessagable(),
+	}
+	if key := u.GetPublicKey(); len(key) > 0 {
+		o.PublicKey = base64.StdEncoding.EncodeToString(key)
+	}
+	return o
+}
+
+// SetOwnerRequest changes some of the owner's fields; empty names and nil
+// flags are left as they are.
+type SetOwnerRequest struct {
+	LongName     string
+	ShortName    string
+	Licensed     *bool
+	Unmessagable *bool
+}
+
+func ValidateSetOwnerRequest(req SetOwnerRequest) error {
+	if req.LongName == "" && req.ShortName == "" && req.Licensed == nil && req.Unmessagable == nil {
+		return invalidf("give at least one of --long, --short, --licensed or --unmessagable")
+	}
+	if err := validateOwnerName("--long", req.LongName, radio.MaxLongNameBytes, radio.MaxLongNameBytes); err != nil {
+		return err
+	}
+	return validateOwnerName("--short", req.ShortName, radio.MaxShortNameChars, radio.MaxShortNameBytes)
+}
+
+// validateOwnerName checks name against the firmware's limits, counting
+// characters rather than bytes, so a name is never cut inside a character.
+// An empty name is left unchanged and so is not checked.
+func validateOwnerName(flag, name string, maxChars, maxBytes int) error {
+	if name == "" {
+		return nil
+	}
+	if err := radio.CheckOwnerName(name, maxChars, maxBytes); err != nil {
+		return invalidf("%s %v", flag, err)
+	}
+	return nil
+}
+
+// GetOwner asks the node for its owner.
+func (s *Service) GetOwner(ctx context.Context) (Owner, error) {
+	u, err := s.getOwner(ctx)
+	if err != nil {
+		return Owner{}, err
+	}
+	return newOwner(u), nil
+}
+
+func (s *Service) getOwner(ctx context.Context) (*pb.User, error) {
+	resp, err := s.requestAdmin(ctx, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetOwnerRequest{GetOwnerRequest: true}})
+	if err != nil {
+		return nil, fmt.Errorf("get owner: %w", err)
+	}
+	owner := resp.GetGetOwnerResponse()
+	if owner == nil {
+		return nil, fmt.Errorf("get owner: unexpected admin response %T", resp.GetPayloadVariant())
+	}
+	return owner, nil
+}
+
+// SetOwner reads the owner, applies req and writes it back. The firmware takes
+// is_licensed from every SetOwner, so sending only the changed fields would
+// clear it.
+func (s *Service) SetOwner(ctx context.Context, req SetOwnerRequest) (Owner, error) {
+	if err := ValidateSetOwnerRequest(req); err != nil {
+		return Owner{}, err
+	}
+
+	current, err := s.getOwner(ctx)
+	if err != nil {
+		return Owner{}, err
+	}
+	owner := proto.Clone(current).(*pb.User)
+	if req.LongName != "" {
+		owner.LongName = req.LongName
+	}
+	if req.ShortName != "" {
+		owner.ShortName = req.ShortName
+	}
+	if req.Licensed != nil {
+		owner.IsLicensed = *req.Licensed
+	}
+	if req.Unmessagable != nil {
+		owner.IsUnmessagable = proto.Bool(*req.Unmessagable)
+	}
+
+	set := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetOwner{SetOwner: owner}}
+	if err := s.sendAdmin(ctx, set); err != nil {
+		return Owner{}, fmt.Errorf("set owner: %w", err)
+	}
+	return newOwner(owner), nil
+}
+
+// HamModeRequest turns on licensed amateur operation. TxPower in dBm and
+// Frequency in MHz keep the node's settings when zero; ShortName keeps the
+// owner's when empty.
+type HamModeRequest struct {
+	CallSign  string
+	ShortName string
+	TxPower   int32
+	Frequency float32
+}
+
+// ValidateHamModeRequest checks req and returns it with the call sign in
+// upper case.
+func ValidateHamModeRequest(req HamModeRequest) (HamModeRequest, error) {
+	req.CallSign = strings.ToUpper(strings.TrimSpace(req.CallSign))
+	if req.CallSign == "" {
+		return req, invalidf("--callsign cannot be empty")
+	}
+	hasDigit := false
+	for _, c := range req.CallSign {
+		switch {
+		case c >= '0' && c <= '9':
+			hasDigit = true
+		case c >= 'A' && c <= 'Z', c == '/':
+		default:
+			return req, invalidf("--callsign %q may only hold letters, digits and /", req.CallSign)
+		}
+	}
+	if !hasDigit {
+		return req, invalidf("--callsign %q has no digit", req.CallSign)
+	}
+	if err := validateOwnerName("--callsign", req.CallSign, radio.MaxLongNameBytes, radio.MaxLongNameBytes); err != nil {
+		return req, err
+	}
+	if err := validateOwnerName("--short", req.ShortName, radio.MaxShortNameChars, radio.MaxShortNameBytes); err != nil {
+		return req, err
+	}
+	if req.TxPower < 0 {
+		return req, invalidf("--tx-power cannot be negative")
+	}
+	if req.Frequency < 0 {
+		return req, invalidf("--frequency cannot be negative")
+	}
+	return req, nil
+}
+
+// SetHamMode sends the ham parameters. The firmware names the owner after the
+// call sign, marks it licensed and turns off encryption, as amateur rules
+// require.
+func (s *Service) SetHamMode(ctx context.Context, req HamModeRequest) (Owner, error) {
+	req, err := ValidateHamModeRequest(req)
+	if err != nil {
+		return Owner{}, err
+	}
+
+	current, err := s.getOwner(ctx)
+	if err != nil {
+		return Owner{}, err
+	}
+	if req.ShortName == "" {
+		req.ShortName = current.GetShortName()
+	}
+
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetHamMode{SetHamMode: &pb.HamParameters{
+		CallSign:  req.CallSign,
+		ShortName: req.ShortName,
+		TxPower:   req.TxPower,
+		Frequency: req.Frequency,
+	}}}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return Owner{}, fmt.Errorf("set ham mode: %w", err)
+	}
+
+	owner := proto.Clone(current).(*pb.User)
+	owner.LongName = req.CallSign
+	owner.ShortName = req.ShortName
+	owner.IsLicensed = true
+	return newOwner(owner), nil
+}
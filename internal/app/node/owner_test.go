@@ -0,0 +1,60 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+func TestValidateSetOwnerRequest(t *testing.T) {
+	for _, tc := range []struct {
+		req  SetOwnerRequest
+		want string
+	}{
+		{req: SetOwnerRequest{ShortName: "🦆"}},
+		{req: SetOwnerRequest{ShortName: "ABCD"}},
+		{req: SetOwnerRequest{LongName: "Größenwahn Relay"}},
+		{req: SetOwnerRequest{}, want: "give at least one"},
+		{req: SetOwnerRequest{ShortName: "ABCDE"}, want: "5 characters"},
+		{req: SetOwnerRequest{ShortName: "日本"}, want: "6 bytes"},
+		{req: SetOwnerRequest{ShortName: "  "}, want: "cannot be blank"},
+		{req: SetOwnerRequest{LongName: "a\tb"}, want: "control characters"},
+		{req: SetOwnerRequest{LongName: strings.Repeat("ü", 20)}, want: "40 bytes"},
+		{req: SetOwnerRequest{LongName: "\xff"}, want: "not valid UTF-8"},
+	} {
+		err := ValidateSetOwnerRequest(tc.req)
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected error: %v", tc.req, err)
+			}
+			continue
+		}
+		var vErr *ValidationError
+		if !errors.As(err, &vErr) || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: got %v, want validation error containing %q", tc.req, err, tc.want)
+		}
+	}
+}
+
+func TestServiceSetHamModeKeepsShortName(t *testing.T) {
+	fc := &fakeClient{admin: fakeNodeAdmin(&pb.User{LongName: "Old Name", ShortName: "OLD"}, &pb.Config_LoRaConfig{}, nil)}
+
+	owner, err := NewService(fc).SetHamMode(context.Background(), HamModeRequest{CallSign: " kd2abc/p ", TxPower: 27})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ham := fc.adminSent[len(fc.adminSent)-1].GetSetHamMode()
+	if ham.GetCallSign() != "KD2ABC/P" || ham.GetShortName() != "OLD" || ham.GetTxPower() != 27 {
+		t.Fatalf("unexpected ham parameters: %v", ham)
+	}
+	if owner.LongName != "KD2ABC/P" || !owner.Licensed {
+		t.Fatalf("unexpected owner: %+v", owner)
+	}
+
+	if _, err := ValidateHamModeRequest(HamModeRequest{CallSign: "KD-2ABC"}); err == nil {
+		t.Fatalf("expected an error for a call sign with a dash")
+	}
+}
@@ -0,0 +1,149 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+// PositionInput is a position as typed by the user: Lat and Lon in decimal
+// degrees or degrees, minutes and seconds, or a UTM or MGRS reference instead.
+type PositionInput struct {
+	Lat  string
+	Lon  string
+	UTM  string
+	MGRS string
+	// Alt is meters above sea level.
+	Alt int32
+}
+
+// FixedPosition is a position in decimal degrees, at the 1e-7 degree
+// resolution the firmware stores, and meters above sea level.
+type FixedPosition struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	Alt int32   `json:"alt"`
+}
+
+// ParsePositionInput converts in to a FixedPosition. Exactly one of Lat and
+// Lon together, UTM or MGRS must be given.
+func ParsePositionInput(in PositionInput) (FixedPosition, error) {
+	latLon := strings.TrimSpace(in.Lat) != "" || strings.TrimSpace(in.Lon) != ""
+	utm := strings.TrimSpace(in.UTM) != ""
+	mgrs := strings.TrimSpace(in.MGRS) != ""
+
+	given := 0
+	for _, set := range []bool{latLon, utm, mgrs} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		return FixedPosition{}, invalidf("give either --lat and --lon, --utm or --mgrs")
+	}
+
+	var (
+		lat, lon float64
+		err      error
+	)
+	switch {
+	case utm:
+		lat, lon, err = ParseUTM(in.UTM)
+	case mgrs:
+		lat, lon, err = ParseMGRS(in.MGRS)
+	default:
+		if strings.TrimSpace(in.Lat) == "" || strings.TrimSpace(in.Lon) == "" {
+			return FixedPosition{}, invalidf("--lat and --lon must be given together")
+		}
+		if lat, err = ParseLatitude(in.Lat); err == nil {
+			lon, err = ParseLongitude(in.Lon)
+		}
+	}
+	if err != nil {
+		return FixedPosition{}, err
+	}
+
+	p := FixedPosition{
+		Lat: float64(degreesToI(lat)) * 1e-7,
+		Lon: float64(degreesToI(lon)) * 1e-7,
+		Alt: in.Alt,
+	}
+	if degreesToI(p.Lat) == 0 && degreesToI(p.Lon) == 0 {
+		return FixedPosition{}, invalidf("0, 0 means no position to the firmware")
+	}
+	return p, nil
+}
+
+func degreesToI(v float64) int32 {
+	return int32(math.Round(v * 1e7))
+}
+
+// SetFixedPosition stores p as the node's fixed position. The firmware also
+// turns on position.fixed_position, so GPS fixes stop replacing it.
+func (s *Service) SetFixedPosition(ctx context.Context, p FixedPosition) (FixedPosition, error) {
+	lat, lon, alt := degreesToI(p.Lat), degreesToI(p.Lon), p.Alt
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetFixedPosition{SetFixedPosition: &pb.Position{
+		LatitudeI:  &lat,
+		LongitudeI: &lon,
+		Altitude:   &alt,
+	}}}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return FixedPosition{}, fmt.Errorf("set fixed position: %w", err)
+	}
+	return FixedPosition{Lat: float64(lat) * 1e-7, Lon: float64(lon) * 1e-7, Alt: alt}, nil
+}
+
+// ClearFixedPosition removes the node's fixed position and turns off
+// position.fixed_position, so the node reports its GPS position again.
+func (s *Service) ClearFixedPosition(ctx context.Context) error {
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_RemoveFixedPosition{RemoveFixedPosition: true}}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return fmt.Errorf("clear fixed position: %w", err)
+	}
+	return nil
+}
+
+// PositionReport is a node's position as our NodeDB has it, and whether the
+// node uses a fixed position.
+type PositionReport struct {
+	Node  string `json:"node"`
+	Fixed bool   `json:"fixed"`
+	// Position is nil when the node has not reported one.
+	Position *NodePosition `json:"position,omitempty"`
+}
+
+// GetPosition looks the node up in the connected node's NodeDB, then asks the
+// node itself for its position config.
+func (s *Service) GetPosition(ctx context.Context) (PositionReport, error) {
+	reqCtx, cancel := s.requestContext(ctx)
+	responses, err := s.client.Handshake(reqCtx, radio.HandshakeNodesOnly)
+	cancel()
+	if err != nil {
+		return PositionReport{}, fmt.Errorf("get node db: %w", err)
+	}
+
+	db := BuildNodeDB(responses)
+	num := s.dest
+	if num == 0 {
+		if num = db.Self(); num == 0 {
+			return PositionReport{}, fmt.Errorf("get node db: radio did not report its node number")
+		}
+	}
+	report := PositionReport{Node: radio.FormatNodeID(num)}
+	if entry, ok := db.Get(num); ok {
+		report.Position = entry.Position
+	}
+
+	resp, err := s.requestAdmin(ctx, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigRequest{
+		GetConfigRequest: pb.AdminMessage_POSITION_CONFIG,
+	}})
+	if err != nil {
+		return PositionReport{}, fmt.Errorf("get position config: %w", err)
+	}
+	report.Fixed = resp.GetGetConfigResponse().GetPosition().GetFixedPosition()
+	return report, nil
+}
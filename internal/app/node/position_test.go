@@ -0,0 +1,49 @@
+package node
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+func TestServiceFixedPosition(t *testing.T) {
+	ctx := context.Background()
+	lat, lon := int32(377749000), int32(-1224194000)
+	fc := &fakeClient{
+		infoResponses: []*pb.FromRadio{
+			{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 7}}},
+			{PayloadVariant: &pb.FromRadio_NodeInfo{NodeInfo: &pb.NodeInfo{Num: 7, Position: &pb.Position{LatitudeI: &lat, LongitudeI: &lon}}}},
+		},
+		admin: func(*pb.AdminMessage) (*pb.AdminMessage, error) {
+			return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigResponse{GetConfigResponse: &pb.Config{
+				PayloadVariant: &pb.Config_Position{Position: &pb.Config_PositionConfig{FixedPosition: true}},
+			}}}, nil
+		},
+	}
+	svc := NewService(fc)
+
+	report, err := svc.GetPosition(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Node != "!00000007" || !report.Fixed || report.Position == nil || math.Abs(report.Position.Lat-37.7749) > 1e-7 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	if _, err := svc.SetFixedPosition(ctx, FixedPosition{Lat: 37.7749, Lon: -122.4194, Alt: 30}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	set := fc.adminSent[len(fc.adminSent)-1].GetSetFixedPosition()
+	if set.GetLatitudeI() != lat || set.GetLongitudeI() != lon || set.GetAltitude() != 30 {
+		t.Fatalf("unexpected write: %v", set)
+	}
+
+	if err := svc.ClearFixedPosition(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !fc.adminSent[len(fc.adminSent)-1].GetRemoveFixedPosition() {
+		t.Fatalf("fixed position not removed: %v", fc.adminSent)
+	}
+}
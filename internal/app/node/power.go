@@ -0,0 +1,81 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+// RebootRequest asks the node to reboot after Delay, into the bootloader's OTA
+// update mode when OTA is set.
+type RebootRequest struct {
+	Delay time.Duration
+	OTA   bool
+}
+
+// ShutdownRequest asks the node to power off after Delay.
+type ShutdownRequest struct {
+	Delay time.Duration
+}
+
+// PowerResult is the command the node accepted.
+type PowerResult struct {
+	Action  string `json:"action"`
+	Seconds int32  `json:"seconds"`
+}
+
+// ValidatePowerDelay checks a reboot or shutdown delay. The firmware counts
+// whole seconds, so a fraction rounds up.
+func ValidatePowerDelay(delay time.Duration) error {
+	_, err := powerSeconds(delay)
+	return err
+}
+
+func powerSeconds(delay time.Duration) (int32, error) {
+	if delay < 0 {
+		return 0, invalidf("--in cannot be negative")
+	}
+	seconds := math.Ceil(delay.Seconds())
+	if seconds > math.MaxInt32 {
+		return 0, invalidf("--in is too long")
+	}
+	return int32(seconds), nil
+}
+
+// Reboot asks the node to reboot. The node acknowledges the request before the
+// delay starts; it does not wait for the reboot.
+func (s *Service) Reboot(ctx context.Context, req RebootRequest) (PowerResult, error) {
+	seconds, err := powerSeconds(req.Delay)
+	if err != nil {
+		return PowerResult{}, err
+	}
+
+	result := PowerResult{Action: "reboot", Seconds: seconds}
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_RebootSeconds{RebootSeconds: seconds}}
+	if req.OTA {
+		result.Action = "reboot-ota"
+		msg = &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_RebootOtaSeconds{RebootOtaSeconds: seconds}}
+	}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return PowerResult{}, fmt.Errorf("%s: %w", result.Action, err)
+	}
+	return result, nil
+}
+
+// Shutdown asks the node to power off; it stays off until its button is
+// pressed or it is power cycled.
+func (s *Service) Shutdown(ctx context.Context, req ShutdownRequest) (PowerResult, error) {
+	seconds, err := powerSeconds(req.Delay)
+	if err != nil {
+		return PowerResult{}, err
+	}
+
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_ShutdownSeconds{ShutdownSeconds: seconds}}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return PowerResult{}, fmt.Errorf("shutdown: %w", err)
+	}
+	return PowerResult{Action: "shutdown", Seconds: seconds}, nil
+}
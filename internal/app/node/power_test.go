@@ -0,0 +1,46 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+func TestServiceRebootAndShutdown(t *testing.T) {
+	ctx := context.Background()
+	fc := &fakeClient{}
+	svc := NewService(fc)
+
+	res, err := svc.Reboot(ctx, RebootRequest{Delay: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Action != "reboot" || res.Seconds != 2 || fc.adminSent[0].GetRebootSeconds() != 2 {
+		t.Fatalf("unexpected reboot: %+v, sent %v", res, fc.adminSent)
+	}
+
+	if _, err := svc.Reboot(ctx, RebootRequest{OTA: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := fc.adminSent[1].GetPayloadVariant().(*pb.AdminMessage_RebootOtaSeconds); !ok {
+		t.Fatalf("expected an OTA reboot, got %v", fc.adminSent[1])
+	}
+
+	if _, err := svc.Shutdown(ctx, ShutdownRequest{Delay: 5 * time.Second}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fc.adminSent[2].GetShutdownSeconds(); got != 5 {
+		t.Fatalf("shutdown seconds = %d, want 5", got)
+	}
+
+	var verr *ValidationError
+	if _, err := svc.Reboot(ctx, RebootRequest{Delay: -time.Second}); !errors.As(err, &verr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if len(fc.adminSent) != 3 {
+		t.Fatalf("invalid request sent: %v", fc.adminSent[3:])
+	}
+}
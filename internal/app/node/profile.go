@@ -0,0 +1,289 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileOptions controls what ExportProfile includes.
+type ProfileOptions struct {
+	// IncludeKeys keeps the node's own security keys. They identify the node,
+	// so a profile meant for provisioning other radios leaves them out.
+	IncludeKeys bool
+}
+
+// ExportProfile reads the owner names, channels, every config and module config
+// section, the fixed position when one is set and the canned messages into a
+// DeviceProfile.
+func (s *Service) ExportProfile(ctx context.Context, opts ProfileOptions) (*pb.DeviceProfile, error) {
+	profile, _, err := s.readProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.IncludeKeys {
+		if security := profile.GetConfig().GetSecurity(); security != nil {
+			security.PrivateKey = nil
+			security.PublicKey = nil
+		}
+	}
+	return profile, nil
+}
+
+// readProfile returns the node's full profile, keys included, and its owner.
+func (s *Service) readProfile(ctx context.Context) (*pb.DeviceProfile, *pb.User, error) {
+	owner, err := s.getOwner(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, moduleConfig, err := s.readLocalConfig(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	profile := &pb.DeviceProfile{
+		LongName:     proto.String(owner.GetLongName()),
+		ShortName:    proto.String(owner.GetShortName()),
+		Config:       config,
+		ModuleConfig: moduleConfig,
+	}
+
+	channels, err := s.GetChannels(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	url, err := ChannelURL(NewChannelSet(channels, profile.Config.GetLora()))
+	if err != nil {
+		return nil, nil, err
+	}
+	profile.ChannelUrl = &url
+
+	if profile.Config.GetPosition().GetFixedPosition() {
+		pos, err := s.selfPosition(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		profile.FixedPosition = pos
+	}
+
+	canned, err := s.requestAdmin(ctx, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetCannedMessageModuleMessagesRequest{GetCannedMessageModuleMessagesRequest: true}})
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+		// Nodes built without the canned message module never answer.
+	case err != nil:
+		return nil, nil, fmt.Errorf("get canned messages: %w", err)
+	default:
+		profile.CannedMessages = proto.String(canned.GetGetCannedMessageModuleMessagesResponse())
+	}
+
+	return profile, owner, nil
+}
+
+// readLocalConfig reads every config and module config section, keys
+// included.
+func (s *Service) readLocalConfig(ctx context.Context) (*pb.LocalConfig, *pb.LocalModuleConfig, error) {
+	config, moduleConfig := &pb.LocalConfig{}, &pb.LocalModuleConfig{}
+	for _, desc := range configSections {
+		local := config.ProtoReflect()
+		if desc.module {
+			local = moduleConfig.ProtoReflect()
+		}
+		fd := local.Descriptor().Fields().ByName(protoreflect.Name(desc.name))
+		if fd == nil {
+			continue
+		}
+		section, err := s.GetConfigSection(ctx, desc.name)
+		if err != nil {
+			return nil, nil, err
+		}
+		local.Set(fd, protoreflect.ValueOfMessage(section.Message.ProtoReflect()))
+	}
+	return config, moduleConfig, nil
+}
+
+// selfPosition returns our own node's position from the node DB, keeping only
+// what a fixed position sets.
+func (s *Service) selfPosition(ctx context.Context) (*pb.Position, error) {
+	reqCtx, cancel := s.requestContext(ctx)
+	defer cancel()
+	responses, err := s.client.Handshake(reqCtx, radio.HandshakeNodesOnly)
+	if err != nil {
+		return nil, fmt.Errorf("get fixed position: %w", err)
+	}
+
+	var self uint32
+	for _, fr := range responses {
+		if info := fr.GetMyInfo(); info != nil {
+			self = info.GetMyNodeNum()
+		}
+		if info := fr.GetNodeInfo(); info != nil && info.GetNum() == self && info.GetPosition() != nil {
+			pos := info.GetPosition()
+			return &pb.Position{LatitudeI: pos.LatitudeI, LongitudeI: pos.LongitudeI, Altitude: pos.Altitude}, nil
+		}
+	}
+	return nil, nil
+}
+
+// ParseProfile reads a DeviceProfile written by profile export, as YAML or
+// JSON with proto field names.
+func ParseProfile(data []byte) (*pb.DeviceProfile, error) {
+	var generic any
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return nil, invalidf("profile is not valid YAML or JSON: %v", err)
+	}
+	if _, ok := generic.(map[string]any); !ok {
+		return nil, invalidf("profile must be a mapping of fields")
+	}
+	b, err := json.Marshal(generic)
+	if err != nil {
+		return nil, invalidf("profile cannot be converted to JSON: %v", err)
+	}
+
+	var profile pb.DeviceProfile
+	if err := protojson.Unmarshal(b, &profile); err != nil {
+		return nil, invalidf("invalid profile: %v", err)
+	}
+	if err := validateOwnerName("long_name", profile.GetLongName(), radio.MaxLongNameBytes, radio.MaxLongNameBytes); err != nil {
+		return nil, err
+	}
+	if err := validateOwnerName("short_name", profile.GetShortName(), radio.MaxShortNameChars, radio.MaxShortNameBytes); err != nil {
+		return nil, err
+	}
+	if profile.ChannelUrl != nil {
+		if _, err := ParseChannelURL(profile.GetChannelUrl()); err != nil {
+			return nil, err
+		}
+	}
+	if n := len(profile.GetCannedMessages()); n > MaxCannedMessagesBytes {
+		return nil, invalidf("canned_messages is %d bytes; the node stores at most %d", n, MaxCannedMessagesBytes)
+	}
+	return &profile, nil
+}
+
+// ProfileImport is a profile checked against the node it will be applied to.
+type ProfileImport struct {
+	// Changes lists every field the profile sets that differs on the node.
+	Changes []ConfigChange
+	profile *pb.DeviceProfile
+	owner   *pb.User
+}
+
+// PlanProfileImport reads the node's current profile and compares it with
+// profile. Only what the profile sets is compared and later applied; a
+// profile without security keys leaves the node's own keys in place.
+func (s *Service) PlanProfileImport(ctx context.Context, profile *pb.DeviceProfile) (*ProfileImport, error) {
+	current, owner, err := s.readProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profile = proto.Clone(profile).(*pb.DeviceProfile)
+	if security := profile.GetConfig().GetSecurity(); security != nil && len(security.GetPrivateKey()) == 0 {
+		security.PrivateKey = current.GetConfig().GetSecurity().GetPrivateKey()
+		security.PublicKey = current.GetConfig().GetSecurity().GetPublicKey()
+	}
+
+	have := make(map[string]string)
+	for _, f := range FlattenConfig(current) {
+		have[f.Path] = f.Value
+	}
+	var changes []ConfigChange
+	for _, f := range flattenPresent(profile) {
+		old, ok := have[f.Path]
+		if !ok {
+			old = "-"
+		}
+		if old != f.Value {
+			changes = append(changes, ConfigChange{Path: f.Path, Old: old, New: f.Value})
+		}
+	}
+
+	return &ProfileImport{Changes: changes, profile: profile, owner: owner}, nil
+}
+
+// flattenPresent is FlattenConfig limited to the fields msg sets, so that
+// sections and optional fields a profile leaves out are not compared.
+func flattenPresent(msg proto.Message) []ConfigField {
+	var out []ConfigField
+	var walk func(m protoreflect.Message, prefix string)
+	walk = func(m protoreflect.Message, prefix string) {
+		fields := m.Descriptor().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if fd.HasPresence() && !m.Has(fd) {
+				continue
+			}
+			path := prefix + string(fd.Name())
+			if isMessageField(fd) {
+				walk(m.Get(fd).Message(), path+".")
+				continue
+			}
+			out = append(out, ConfigField{Path: path, Value: formatConfigField(fd, m.Get(fd))})
+		}
+	}
+	walk(msg.ProtoReflect(), "")
+	return out
+}
+
+// ApplyProfile writes everything the planned profile sets in one
+// edit-settings transaction: owner names, channels, config and module config
+// sections, the fixed position and canned messages. The LoRa config carried
+// by the channel URL is only used when the profile has no LoRa section. If a
+// write fails partway, the part written before it is still committed, so the
+// node is not left inside the transaction, and the error says how far it got.
+func (s *Service) ApplyProfile(ctx context.Context, plan *ProfileImport) error {
+	profile := plan.profile
+	var writes []*pb.AdminMessage
+
+	if profile.LongName != nil || profile.ShortName != nil {
+		owner := proto.Clone(plan.owner).(*pb.User)
+		if profile.LongName != nil {
+			owner.LongName = profile.GetLongName()
+		}
+		if profile.ShortName != nil {
+			owner.ShortName = profile.GetShortName()
+		}
+		writes = append(writes, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetOwner{SetOwner: owner}})
+	}
+
+	if profile.ChannelUrl != nil {
+		set, err := ParseChannelURL(profile.GetChannelUrl())
+		if err != nil {
+			return err
+		}
+		writes = append(writes, replaceChannelWrites(set, profile.GetConfig().GetLora() == nil)...)
+	}
+
+	for _, desc := range configSections {
+		local := profile.GetConfig().ProtoReflect()
+		if desc.module {
+			local = profile.GetModuleConfig().ProtoReflect()
+		}
+		if !local.IsValid() {
+			continue
+		}
+		fd := local.Descriptor().Fields().ByName(protoreflect.Name(desc.name))
+		if fd == nil || !local.Has(fd) {
+			continue
+		}
+		writes = append(writes, setConfigMessage(desc, local.Get(fd).Message().Interface()))
+	}
+
+	if profile.FixedPosition != nil {
+		writes = append(writes, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetFixedPosition{SetFixedPosition: profile.GetFixedPosition()}})
+	}
+	if profile.CannedMessages != nil {
+		writes = append(writes, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetCannedMessageModuleMessages{
+			SetCannedMessageModuleMessages: profile.GetCannedMessages(),
+		}})
+	}
+
+	return s.editSettings(ctx, writes)
+}
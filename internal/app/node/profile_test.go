@@ -0,0 +1,217 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fakeNodeAdmin answers admin get requests from an owner, a LoRa section and
+// a primary channel; every other section is empty.
+func fakeNodeAdmin(owner *pb.User, lora *pb.Config_LoRaConfig, security *pb.Config_SecurityConfig) func(*pb.AdminMessage) (*pb.AdminMessage, error) {
+	section := func(module bool, number protoreflect.FieldNumber) protoreflect.Message {
+		for _, desc := range configSections {
+			if desc.module != module || desc.field.Number() != number {
+				continue
+			}
+			c := desc.container()
+			switch {
+			case desc.name == "lora":
+				c.Set(desc.field, protoreflect.ValueOfMessage(proto.Clone(lora).ProtoReflect()))
+			case desc.name == "security" && security != nil:
+				c.Set(desc.field, protoreflect.ValueOfMessage(proto.Clone(security).ProtoReflect()))
+			default:
+				c.Set(desc.field, c.NewField(desc.field))
+			}
+			return c
+		}
+		return nil
+	}
+
+	return func(msg *pb.AdminMessage) (*pb.AdminMessage, error) {
+		switch v := msg.GetPayloadVariant().(type) {
+		case *pb.AdminMessage_GetOwnerRequest:
+			return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetOwnerResponse{GetOwnerResponse: owner}}, nil
+		case *pb.AdminMessage_GetConfigRequest:
+			c := section(false, protoreflect.FieldNumber(v.GetConfigRequest)+1).Interface().(*pb.Config)
+			return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigResponse{GetConfigResponse: c}}, nil
+		case *pb.AdminMessage_GetModuleConfigRequest:
+			c := section(true, protoreflect.FieldNumber(v.GetModuleConfigRequest)+1).Interface().(*pb.ModuleConfig)
+			return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetModuleConfigResponse{GetModuleConfigResponse: c}}, nil
+		case *pb.AdminMessage_GetChannelRequest:
+			ch := &pb.Channel{Index: int32(v.GetChannelRequest) - 1, Settings: &pb.ChannelSettings{}}
+			if ch.Index == 0 {
+				ch.Role = pb.Channel_PRIMARY
+				ch.Settings.Psk = []byte{1}
+			}
+			return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetChannelResponse{GetChannelResponse: ch}}, nil
+		case *pb.AdminMessage_GetCannedMessageModuleMessagesRequest:
+			return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetCannedMessageModuleMessagesResponse{GetCannedMessageModuleMessagesResponse: "hi|bye"}}, nil
+		}
+		return nil, errors.New("unexpected request")
+	}
+}
+
+func TestServiceExportProfileLeavesOutKeys(t *testing.T) {
+	security := &pb.Config_SecurityConfig{PrivateKey: []byte("secret"), PublicKey: []byte("public")}
+	fc := &fakeClient{admin: fakeNodeAdmin(&pb.User{LongName: "Base", ShortName: "BS"}, &pb.Config_LoRaConfig{HopLimit: 3}, security)}
+
+	profile, err := NewService(fc).ExportProfile(context.Background(), ProfileOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.GetLongName() != "Base" || profile.GetConfig().GetLora().GetHopLimit() != 3 || profile.GetCannedMessages() != "hi|bye" {
+		t.Fatalf("unexpected profile: %v", profile)
+	}
+	if profile.GetModuleConfig().GetMqtt() == nil {
+		t.Fatalf("module config missing: %v", profile.GetModuleConfig())
+	}
+	if !strings.HasPrefix(profile.GetChannelUrl(), ChannelURLPrefix) {
+		t.Fatalf("channel URL = %q", profile.GetChannelUrl())
+	}
+	if len(profile.GetConfig().GetSecurity().GetPrivateKey()) != 0 {
+		t.Fatalf("private key exported without IncludeKeys")
+	}
+
+	profile, err = NewService(fc).ExportProfile(context.Background(), ProfileOptions{IncludeKeys: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(profile.GetConfig().GetSecurity().GetPrivateKey()) != "secret" {
+		t.Fatalf("private key missing with IncludeKeys")
+	}
+}
+
+func TestServiceProfileImportDiffsThenAppliesInOneTransaction(t *testing.T) {
+	security := &pb.Config_SecurityConfig{PrivateKey: []byte("secret")}
+	owner := &pb.User{LongName: "Base", ShortName: "BS", IsLicensed: true}
+	fc := &fakeClient{admin: fakeNodeAdmin(owner, &pb.Config_LoRaConfig{HopLimit: 3, Region: pb.Config_LoRaConfig_US}, security)}
+	svc := NewService(fc)
+
+	url, err := ChannelURL(&pb.ChannelSet{
+		Settings:   []*pb.ChannelSettings{{Psk: []byte{1}}, {Name: "ops", Psk: make([]byte, 32)}},
+		LoraConfig: &pb.Config_LoRaConfig{Region: pb.Config_LoRaConfig_EU_868},
+	})
+	if err != nil {
+		t.Fatalf("channel URL: %v", err)
+	}
+	profile, err := ParseProfile([]byte(`
+long_name: Provisioned
+channel_url: ` + url + `
+config:
+  lora:
+    region: US
+    hop_limit: 5
+  security:
+    admin_channel_enabled: true
+`))
+	if err != nil {
+		t.Fatalf("parse profile: %v", err)
+	}
+
+	plan, err := svc.PlanProfileImport(context.Background(), profile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	paths := make([]string, len(plan.Changes))
+	for i, c := range plan.Changes {
+		paths[i] = c.Path
+	}
+	if got := strings.Join(paths, " "); got != "long_name channel_url config.lora.hop_limit config.security.admin_channel_enabled" {
+		t.Fatalf("changes = %v", plan.Changes)
+	}
+
+	fc.adminSent = nil
+	if err := svc.ApplyProfile(context.Background(), plan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sent := fc.adminSent
+	if !sent[0].GetBeginEditSettings() || !sent[len(sent)-1].GetCommitEditSettings() {
+		t.Fatalf("writes not wrapped in a transaction: %v", sent)
+	}
+	if got := sent[1].GetSetOwner(); got.GetLongName() != "Provisioned" || got.GetShortName() != "BS" || !got.GetIsLicensed() {
+		t.Fatalf("owner = %v, want names merged into the node's owner", got)
+	}
+
+	var channels, loras int
+	for _, msg := range sent {
+		if ch := msg.GetSetChannel(); ch != nil {
+			channels++
+			if ch.GetIndex() == 1 && (ch.GetRole() != pb.Channel_SECONDARY || ch.GetSettings().GetName() != "ops") {
+				t.Fatalf("channel 1 = %v", ch)
+			}
+		}
+		if lora := msg.GetSetConfig().GetLora(); lora != nil {
+			loras++
+			if lora.GetRegion() != pb.Config_LoRaConfig_US || lora.GetHopLimit() != 5 {
+				t.Fatalf("lora = %v, want the profile's section over the URL's", lora)
+			}
+		}
+		if sec := msg.GetSetConfig().GetSecurity(); sec != nil && string(sec.GetPrivateKey()) != "secret" {
+			t.Fatalf("security written without the node's own key: %v", sec)
+		}
+	}
+	if channels != MaxChannels || loras != 1 {
+		t.Fatalf("wrote %d channels and %d lora sections", channels, loras)
+	}
+}
+
+func TestServiceProfileImportCommitsAfterFailedWrite(t *testing.T) {
+	owner := &pb.User{LongName: "Base", ShortName: "BS"}
+	fc := &fakeClient{admin: fakeNodeAdmin(owner, &pb.Config_LoRaConfig{HopLimit: 3}, &pb.Config_SecurityConfig{})}
+	svc := NewService(fc)
+
+	profile, err := ParseProfile([]byte(`
+long_name: Provisioned
+config:
+  lora:
+    hop_limit: 5
+  position:
+    gps_update_interval: 120
+`))
+	if err != nil {
+		t.Fatalf("parse profile: %v", err)
+	}
+	plan, err := svc.PlanProfileImport(context.Background(), profile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fc.adminSent = nil
+	fc.adminFail = func(msg *pb.AdminMessage) error {
+		if msg.GetSetConfig().GetLora() != nil {
+			return &radio.RoutingError{From: 0x0a0b0c0d, Reason: pb.Routing_MAX_RETRANSMIT}
+		}
+		return nil
+	}
+	err = svc.ApplyProfile(context.Background(), plan)
+	if err == nil || !strings.Contains(err.Error(), "set lora config failed after 2 of 3 writes") {
+		t.Fatalf("expected the failed write to be reported, got %v", err)
+	}
+
+	sent := fc.adminSent
+	if len(sent) != 5 || !sent[0].GetBeginEditSettings() || sent[3].GetSetConfig().GetLora() == nil || !sent[4].GetCommitEditSettings() {
+		t.Fatalf("expected begin, owner, position, the failed lora write and commit, got %v", sent)
+	}
+}
+
+func TestParseProfileRejectsBadInput(t *testing.T) {
+	for _, in := range []string{
+		"- a list",
+		"long_name: [1, 2]",
+		"channel_url: https://example.com/#abc",
+		"unknown_field: 1",
+		"short_name: ABCDE",
+	} {
+		var verr *ValidationError
+		if _, err := ParseProfile([]byte(in)); !errors.As(err, &verr) {
+			t.Fatalf("ParseProfile(%q) = %v, want validation error", in, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+// GetRingtone asks the node for the RTTTL ringtone its external notification
+// buzzer plays. An empty ringtone means the firmware's default.
+func (s *Service) GetRingtone(ctx context.Context) (*pb.RTTTLConfig, error) {
+	resp, err := s.requestAdmin(ctx, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetRingtoneRequest{GetRingtoneRequest: true}})
+	if err != nil {
+		return nil, fmt.Errorf("get ringtone: %w", err)
+	}
+	if _, ok := resp.GetPayloadVariant().(*pb.AdminMessage_GetRingtoneResponse); !ok {
+		return nil, fmt.Errorf("get ringtone: unexpected admin response %T", resp.GetPayloadVariant())
+	}
+	return &pb.RTTTLConfig{Ringtone: resp.GetGetRingtoneResponse()}, nil
+}
+
+// SetRingtone checks config's ringtone with ParseRTTTL, since the firmware
+// goes silent on one it cannot play, and sends it to the node.
+func (s *Service) SetRingtone(ctx context.Context, config *pb.RTTTLConfig) (Ringtone, error) {
+	tone, err := ParseRTTTL(config.GetRingtone())
+	if err != nil {
+		return Ringtone{}, err
+	}
+	msg := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetRingtoneMessage{SetRingtoneMessage: config.GetRingtone()}}
+	if err := s.sendAdmin(ctx, msg); err != nil {
+		return Ringtone{}, fmt.Errorf("set ringtone: %w", err)
+	}
+	return tone, nil
+}
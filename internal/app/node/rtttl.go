@@ -0,0 +1,247 @@
+package node
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxRingtoneBytes is the longest RTTTL string the firmware stores, in a
+// 231-byte field with its NUL.
+const MaxRingtoneBytes = 230
+
+// RTTTL defaults when a ringtone's control section leaves them out.
+const (
+	defaultRTTTLDuration = 4
+	defaultRTTTLOctave   = 6
+	defaultRTTTLBPM      = 63
+)
+
+// Limits of the RTTTL format.
+const (
+	minRTTTLOctave = 4
+	maxRTTTLOctave = 7
+	maxRTTTLBPM    = 900
+)
+
+// Ringtone is a parsed RTTTL string, such as "alert:d=8,o=5,b=140:c,e,g,2c6".
+type Ringtone struct {
+	Name string `json:"name"`
+	// Duration, Octave and BPM are the defaults from the control section.
+	Duration int    `json:"duration"`
+	Octave   int    `json:"octave"`
+	BPM      int    `json:"bpm"`
+	Notes    []Note `json:"notes"`
+}
+
+// Note is one note or pause of a Ringtone.
+type Note struct {
+	// Pitch is a note name such as "c" or "f#", or "p" for a pause.
+	Pitch string `json:"pitch"`
+	// Octave is zero for pauses.
+	Octave int `json:"octave,omitempty"`
+	// Duration is the note value: 1 for a whole note, 4 for a quarter.
+	Duration int  `json:"duration"`
+	Dotted   bool `json:"dotted,omitempty"`
+	// Length is how long the note plays at the ringtone's tempo.
+	Length time.Duration `json:"length_ns"`
+}
+
+// Rest reports whether n is a pause.
+func (n Note) Rest() bool {
+	return n.Pitch == "p"
+}
+
+// String formats n as "f#6" or "p".
+func (n Note) String() string {
+	if n.Rest() {
+		return "p"
+	}
+	return n.Pitch + strconv.Itoa(n.Octave)
+}
+
+// Frequency returns the pitch of n in hertz with A4 at 440 Hz, or zero for a
+// pause.
+func (n Note) Frequency() float64 {
+	if n.Rest() {
+		return 0
+	}
+	semitone := strings.Index("c c#d d#e f f#g g#a a#b ", fmt.Sprintf("%-2s", n.Pitch)) / 2
+	midi := (n.Octave+1)*12 + semitone
+	return 440 * math.Pow(2, float64(midi-69)/12)
+}
+
+// Total returns how long r takes to play.
+func (r Ringtone) Total() time.Duration {
+	var total time.Duration
+	for _, n := range r.Notes {
+		total += n.Length
+	}
+	return total
+}
+
+// ParseRTTTL parses and checks an RTTTL string: a name, a control section of
+// d (duration), o (octave) and b (beats per minute) defaults, and a comma
+// separated list of notes written as [duration]note[#][.][octave][.].
+func ParseRTTTL(s string) (Ringtone, error) {
+	if len(s) > MaxRingtoneBytes {
+		return Ringtone{}, invalidf("ringtone is %d bytes; the node stores at most %d", len(s), MaxRingtoneBytes)
+	}
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return Ringtone{}, invalidf("ringtone must have the form name:d=4,o=5,b=120:notes, with exactly two colons")
+	}
+
+	r := Ringtone{
+		Name:     strings.TrimSpace(parts[0]),
+		Duration: defaultRTTTLDuration,
+		Octave:   defaultRTTTLOctave,
+		BPM:      defaultRTTTLBPM,
+	}
+	if err := r.parseControl(parts[1]); err != nil {
+		return Ringtone{}, err
+	}
+
+	whole := 4 * time.Minute / time.Duration(r.BPM)
+	for i, field := range strings.Split(parts[2], ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return Ringtone{}, invalidf("note %d is empty", i+1)
+		}
+		n, err := r.parseNote(field)
+		if err != nil {
+			return Ringtone{}, invalidf("note %d %q: %v", i+1, field, err)
+		}
+		n.Length = whole / time.Duration(n.Duration)
+		if n.Dotted {
+			n.Length += n.Length / 2
+		}
+		r.Notes = append(r.Notes, n)
+	}
+	return r, nil
+}
+
+func (r *Ringtone) parseControl(section string) error {
+	if strings.TrimSpace(section) == "" {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for _, setting := range strings.Split(section, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok {
+			return invalidf("control setting %q must be key=value", setting)
+		}
+		if seen[key] {
+			return invalidf("control setting %s is given twice", key)
+		}
+		seen[key] = true
+
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return invalidf("control setting %s=%s is not a number", key, value)
+		}
+		switch key {
+		case "d":
+			if !validRTTTLDuration(n) {
+				return invalidf("default duration d=%d must be 1, 2, 4, 8, 16 or 32", n)
+			}
+			r.Duration = n
+		case "o":
+			if n < minRTTTLOctave || n > maxRTTTLOctave {
+				return invalidf("default octave o=%d must be %d to %d", n, minRTTTLOctave, maxRTTTLOctave)
+			}
+			r.Octave = n
+		case "b":
+			if n < 1 || n > maxRTTTLBPM {
+				return invalidf("tempo b=%d must be 1 to %d beats per minute", n, maxRTTTLBPM)
+			}
+			r.BPM = n
+		default:
+			return invalidf("unknown control setting %q (want d, o or b)", key)
+		}
+	}
+	return nil
+}
+
+func (r *Ringtone) parseNote(field string) (Note, error) {
+	rest := strings.ToLower(field)
+	n := Note{Duration: r.Duration}
+
+	digits := leadingDigits(rest)
+	if digits != "" {
+		d, _ := strconv.Atoi(digits)
+		if !validRTTTLDuration(d) {
+			return Note{}, fmt.Errorf("duration %s must be 1, 2, 4, 8, 16 or 32", digits)
+		}
+		n.Duration = d
+		rest = rest[len(digits):]
+	}
+
+	if rest == "" {
+		return Note{}, fmt.Errorf("missing note name")
+	}
+	switch pitch := rest[0]; pitch {
+	case 'c', 'd', 'e', 'f', 'g', 'a', 'b', 'p':
+		n.Pitch = string(pitch)
+	case 'h':
+		// German notation, used by some ringtones, names B as H.
+		n.Pitch = "b"
+	default:
+		return Note{}, fmt.Errorf("unknown note %q (want a-g, h or p)", pitch)
+	}
+	rest = rest[1:]
+
+	if strings.HasPrefix(rest, "#") {
+		if n.Rest() || n.Pitch == "e" || n.Pitch == "b" {
+			return Note{}, fmt.Errorf("%s has no sharp", n.Pitch)
+		}
+		n.Pitch += "#"
+		rest = rest[1:]
+	}
+	if strings.HasPrefix(rest, ".") {
+		n.Dotted = true
+		rest = rest[1:]
+	}
+
+	if digits := leadingDigits(rest); digits != "" {
+		if n.Rest() {
+			return Note{}, fmt.Errorf("a pause has no octave")
+		}
+		o, _ := strconv.Atoi(digits)
+		if o < minRTTTLOctave || o > maxRTTTLOctave {
+			return Note{}, fmt.Errorf("octave %s must be %d to %d", digits, minRTTTLOctave, maxRTTTLOctave)
+		}
+		n.Octave = o
+		rest = rest[len(digits):]
+	} else if !n.Rest() {
+		n.Octave = r.Octave
+	}
+
+	if strings.HasPrefix(rest, ".") && !n.Dotted {
+		n.Dotted = true
+		rest = rest[1:]
+	}
+	if rest != "" {
+		return Note{}, fmt.Errorf("unexpected %q after the note", rest)
+	}
+	return n, nil
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+func validRTTTLDuration(d int) bool {
+	switch d {
+	case 1, 2, 4, 8, 16, 32:
+		return true
+	}
+	return false
+}
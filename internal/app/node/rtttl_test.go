@@ -0,0 +1,103 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+func TestParseRTTTL(t *testing.T) {
+	tone, err := ParseRTTTL("alert: d=8, o=5, b=120 : c, 4e, g#6, 2p, 16a., h, c6.")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tone.Name != "alert" || tone.Duration != 8 || tone.Octave != 5 || tone.BPM != 120 {
+		t.Fatalf("unexpected defaults: %+v", tone)
+	}
+
+	var got []string
+	for _, n := range tone.Notes {
+		got = append(got, n.String())
+	}
+	if strings.Join(got, " ") != "c5 e5 g#6 p a5 b5 c6" {
+		t.Fatalf("unexpected notes: %v", got)
+	}
+	// A whole note lasts 2s at 120 bpm.
+	ms := time.Millisecond
+	for i, want := range []time.Duration{250 * ms, 500 * ms, 250 * ms, 1000 * ms, 187500 * time.Microsecond, 250 * ms, 375 * ms} {
+		if tone.Notes[i].Length != want {
+			t.Fatalf("note %d lasts %s, want %s", i+1, tone.Notes[i].Length, want)
+		}
+	}
+	if tone.Total() != 2812500*time.Microsecond {
+		t.Fatalf("total = %s", tone.Total())
+	}
+	if f := (Note{Pitch: "a", Octave: 4}).Frequency(); f != 440 {
+		t.Fatalf("a4 = %v Hz", f)
+	}
+	if f := (Note{Pitch: "c", Octave: 5}).Frequency(); math.Abs(f-523.25) > 0.01 {
+		t.Fatalf("c5 = %v Hz", f)
+	}
+}
+
+func TestParseRTTTLDefaults(t *testing.T) {
+	tone, err := ParseRTTTL("::c,8p")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tone.Duration != 4 || tone.Octave != 6 || tone.BPM != 63 || tone.Notes[0].Octave != 6 || tone.Notes[1].Octave != 0 {
+		t.Fatalf("unexpected tone: %+v", tone)
+	}
+}
+
+func TestParseRTTTLRejectsBadInput(t *testing.T) {
+	for in, want := range map[string]string{
+		"no colons":                          "two colons",
+		"a:d=4:c:d":                          "two colons",
+		"a:d=3:c":                            "d=3 must be",
+		"a:o=8:c":                            "o=8 must be",
+		"a:b=0:c":                            "b=0 must be",
+		"a:b=fast:c":                         "not a number",
+		"a:x=1:c":                            "unknown control setting",
+		"a:d=4,d=8:c":                        "given twice",
+		"a:d=4:c,,e":                         "note 2 is empty",
+		"a:d=4:":                             "note 1 is empty",
+		"a:d=4:12c":                          "duration 12",
+		"a:d=4:x":                            "unknown note",
+		"a:d=4:c3":                           "octave 3 must be 4 to 7",
+		"a:d=4:e#":                           "e has no sharp",
+		"a:d=4:p5":                           "pause has no octave",
+		"a:d=4:c5x":                          "unexpected \"x\"",
+		"a:d=4:" + strings.Repeat("c,", 120): "at most 230",
+	} {
+		_, err := ParseRTTTL(in)
+		var vErr *ValidationError
+		if !errors.As(err, &vErr) || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseRTTTL(%q) = %v, want validation error containing %q", in, err, want)
+		}
+	}
+}
+
+func TestServiceSetRingtoneChecksBeforeSending(t *testing.T) {
+	fc := &fakeClient{}
+	svc := NewService(fc)
+
+	if _, err := svc.SetRingtone(context.Background(), &pb.RTTTLConfig{Ringtone: "a:d=4:c9"}); err == nil {
+		t.Fatalf("expected an error for octave 9")
+	}
+	if len(fc.adminSent) != 0 {
+		t.Fatalf("bad ringtone was sent: %v", fc.adminSent)
+	}
+
+	if _, err := svc.SetRingtone(context.Background(), &pb.RTTTLConfig{Ringtone: "a:d=4:c"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fc.adminSent[0].GetSetRingtoneMessage(); got != "a:d=4:c" {
+		t.Fatalf("ringtone write = %q", got)
+	}
+}
@@ -3,29 +3,64 @@ package node
 import (
 	"context"
 	"fmt"
+	"io"
 	"math"
 	"strings"
+	"time"
 
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 )
 
+// Client is the radio surface the Service drives. Every call stops waiting and
+// returns ctx.Err() once ctx is done.
 type Client interface {
-	GetRadioInfo() ([]*pb.FromRadio, error)
-	SendTextMessage(message string, to int64, channel int64) error
-	SetRadioOwner(name string) error
-	SetModemMode(mode string) error
-	SetLocation(lat int32, long int32, alt int32) error
-	FactoryReset() error
+	Handshake(ctx context.Context, mode radio.HandshakeMode) ([]*pb.FromRadio, error)
+	SendTextMessage(ctx context.Context, message string, to int64, channel int64) error
+	SendTextMessageAck(ctx context.Context, message string, to int64, channel int64) (radio.Ack, error)
+	SetModemMode(ctx context.Context, mode string) error
+	SetLocation(ctx context.Context, lat int32, long int32, alt int32) error
+	FactoryReset(ctx context.Context) error
+	Traceroute(ctx context.Context, to uint32, channel uint32) (radio.TracerouteReply, error)
+	// RequestAdmin and SendAdmin address our own node when to is zero.
+	RequestAdmin(ctx context.Context, to uint32, msg *pb.AdminMessage) (*pb.AdminMessage, error)
+	SendAdmin(ctx context.Context, to uint32, msg *pb.AdminMessage) error
+	GetFile(ctx context.Context, name string, w io.Writer, opts xmodem.Options) (int64, error)
+	PutFile(ctx context.Context, name string, r io.Reader, opts xmodem.Options) (int64, error)
 }
 
 type Service struct {
-	client Client
+	client         Client
+	requestTimeout time.Duration
+	dest           uint32
 }
 
 func NewService(client Client) *Service {
 	return &Service{client: client}
 }
 
+// SetRequestTimeout bounds each exchange with the radio in operations that
+// make several, such as reading every config section. Zero leaves only the
+// caller's context in charge.
+func (s *Service) SetRequestTimeout(d time.Duration) {
+	s.requestTimeout = d
+}
+
+// SetDestination points admin operations at the remote node num, reached over
+// the mesh, instead of the node the client is connected to. Zero means the
+// connected node.
+func (s *Service) SetDestination(num uint32) {
+	s.dest = num
+}
+
+func (s *Service) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.requestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.requestTimeout)
+}
+
 type InfoSummary struct {
 	Responses     int    `json:"responses"`
 	MyNode        string `json:"my_node"`
@@ -40,17 +75,26 @@ type InfoSummary struct {
 
 type InfoResult struct {
 	Summary   InfoSummary
+	Snapshot  Snapshot
 	Responses []*pb.FromRadio
 }
 
-func (s *Service) Info(_ context.Context) (InfoResult, error) {
-	responses, err := s.client.GetRadioInfo()
+// Info runs a full config handshake. It fails unless the radio completes the
+// handshake before ctx ends, so the snapshot is never partial.
+func (s *Service) Info(ctx context.Context) (InfoResult, error) {
+	return s.InfoMode(ctx, radio.HandshakeFull)
+}
+
+// InfoMode is Info with a choice of handshake, such as config only.
+func (s *Service) InfoMode(ctx context.Context, mode radio.HandshakeMode) (InfoResult, error) {
+	responses, err := s.client.Handshake(ctx, mode)
 	if err != nil {
 		return InfoResult{}, fmt.Errorf("get radio info: %w", err)
 	}
 
 	return InfoResult{
 		Summary:   BuildInfoSummary(responses),
+		Snapshot:  BuildSnapshot(responses),
 		Responses: responses,
 	}, nil
 }
@@ -96,12 +140,43 @@ type SendTextRequest struct {
 	Message string
 	To      int64
 	Channel int64
+	// WaitAck makes SendText wait for the mesh to acknowledge the message,
+	// until ctx's deadline.
+	WaitAck bool
 }
 
 type SendTextResult struct {
-	Message string `json:"message"`
-	To      int64  `json:"to"`
-	Channel int64  `json:"channel"`
+	Message string     `json:"message"`
+	To      int64      `json:"to"`
+	Channel int64      `json:"channel"`
+	Ack     *AckResult `json:"ack,omitempty"`
+}
+
+// AckResult is how the mesh answered a message sent with WaitAck.
+type AckResult struct {
+	PacketID uint32 `json:"packet_id"`
+	// Status is delivered, implicit, nak or timeout.
+	Status string `json:"status"`
+	From   string `json:"from,omitempty"`
+	// Error names the routing error of a NAK, such as MAX_RETRANSMIT.
+	Error string `json:"error,omitempty"`
+}
+
+// Delivered reports whether the destination, or for broadcasts a neighbour,
+// received the message.
+func (a AckResult) Delivered() bool {
+	return a.Status == string(radio.AckDelivered) || a.Status == string(radio.AckImplicit)
+}
+
+func newAckResult(ack radio.Ack) *AckResult {
+	result := &AckResult{PacketID: ack.PacketID, Status: string(ack.Status)}
+	if ack.From != 0 {
+		result.From = fmt.Sprintf("!%08x", ack.From)
+	}
+	if ack.Status == radio.AckNak {
+		result.Error = ack.Reason.String()
+	}
+	return result
 }
 
 func ValidateSendTextRequest(req SendTextRequest) error {
@@ -117,45 +192,30 @@ func ValidateSendTextRequest(req SendTextRequest) error {
 	return nil
 }
 
-func (s *Service) SendText(_ context.Context, req SendTextRequest) (SendTextResult, error) {
+func (s *Service) SendText(ctx context.Context, req SendTextRequest) (SendTextResult, error) {
 	if err := ValidateSendTextRequest(req); err != nil {
 		return SendTextResult{}, err
 	}
 
-	if err := s.client.SendTextMessage(req.Message, req.To, req.Channel); err != nil {
-		return SendTextResult{}, fmt.Errorf("send text: %w", err)
-	}
-
-	return SendTextResult{
+	result := SendTextResult{
 		Message: req.Message,
 		To:      req.To,
 		Channel: req.Channel,
-	}, nil
-}
-
-type SetOwnerRequest struct {
-	Name string
-}
-
-type SetOwnerResult struct {
-	Name string `json:"name"`
-}
-
-func ValidateSetOwnerRequest(req SetOwnerRequest) error {
-	if strings.TrimSpace(req.Name) == "" {
-		return invalidf("--name cannot be empty")
 	}
-	return nil
-}
 
-func (s *Service) SetOwner(_ context.Context, req SetOwnerRequest) (SetOwnerResult, error) {
-	if err := ValidateSetOwnerRequest(req); err != nil {
-		return SetOwnerResult{}, err
+	if !req.WaitAck {
+		if err := s.client.SendTextMessage(ctx, req.Message, req.To, req.Channel); err != nil {
+			return SendTextResult{}, fmt.Errorf("send text: %w", err)
+		}
+		return result, nil
 	}
-	if err := s.client.SetRadioOwner(req.Name); err != nil {
-		return SetOwnerResult{}, fmt.Errorf("set owner: %w", err)
+
+	ack, err := s.client.SendTextMessageAck(ctx, req.Message, req.To, req.Channel)
+	if err != nil {
+		return SendTextResult{}, fmt.Errorf("send text: %w", err)
 	}
-	return SetOwnerResult{Name: req.Name}, nil
+	result.Ack = newAckResult(ack)
+	return result, nil
 }
 
 var validModemModes = map[string]struct{}{
@@ -188,12 +248,12 @@ func NormalizeAndValidateModemMode(mode string) (string, error) {
 	return normalized, nil
 }
 
-func (s *Service) SetModem(_ context.Context, req SetModemRequest) (SetModemResult, error) {
+func (s *Service) SetModem(ctx context.Context, req SetModemRequest) (SetModemResult, error) {
 	mode, err := NormalizeAndValidateModemMode(req.Mode)
 	if err != nil {
 		return SetModemResult{}, err
 	}
-	if err := s.client.SetModemMode(mode); err != nil {
+	if err := s.client.SetModemMode(ctx, mode); err != nil {
 		return SetModemResult{}, fmt.Errorf("set modem: %w", err)
 	}
 	return SetModemResult{Mode: mode}, nil
@@ -232,13 +292,13 @@ func ValidateAndConvertSetLocationRequest(req SetLocationRequest) (SetLocationRe
 	}, nil
 }
 
-func (s *Service) SetLocation(_ context.Context, req SetLocationRequest) (SetLocationResult, error) {
+func (s *Service) SetLocation(ctx context.Context, req SetLocationRequest) (SetLocationResult, error) {
 	converted, err := ValidateAndConvertSetLocationRequest(req)
 	if err != nil {
 		return SetLocationResult{}, err
 	}
 
-	if err := s.client.SetLocation(converted.LatI, converted.LonI, converted.Alt); err != nil {
+	if err := s.client.SetLocation(ctx, converted.LatI, converted.LonI, converted.Alt); err != nil {
 		return SetLocationResult{}, fmt.Errorf("set location: %w", err)
 	}
 	return converted, nil
@@ -251,8 +311,15 @@ func int32FromInt64(flagName string, value int64) (int32, error) {
 	return int32(value), nil
 }
 
-func (s *Service) FactoryReset(_ context.Context) error {
-	if err := s.client.FactoryReset(); err != nil {
+func (s *Service) FactoryReset(ctx context.Context) error {
+	if s.dest != 0 {
+		reset := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_FactoryResetDevice{FactoryResetDevice: 1}}
+		if err := s.sendAdmin(ctx, reset); err != nil {
+			return fmt.Errorf("factory-reset: %w", err)
+		}
+		return nil
+	}
+	if err := s.client.FactoryReset(ctx); err != nil {
 		return fmt.Errorf("factory-reset: %w", err)
 	}
 	return nil
@@ -2,25 +2,29 @@ package node
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"strings"
 	"testing"
 
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
 )
 
 type fakeClient struct {
 	infoResponses []*pb.FromRadio
 	infoErr       error
+	infoMode      radio.HandshakeMode
 
 	sendErr     error
 	sendCalls   int
 	sendMessage string
 	sendTo      int64
 	sendChannel int64
-
-	ownerErr   error
-	ownerCalls int
-	ownerName  string
+	sendAck     radio.Ack
 
 	modemErr   error
 	modemCalls int
@@ -34,37 +38,98 @@ type fakeClient struct {
 
 	resetErr   error
 	resetCalls int
+
+	traceReply radio.TracerouteReply
+	traceErr   error
+	traceTo    uint32
+
+	// admin answers RequestAdmin; every admin message sent is recorded, along
+	// with the node it was addressed to.
+	admin     func(*pb.AdminMessage) (*pb.AdminMessage, error)
+	adminSent []*pb.AdminMessage
+	adminTo   []uint32
+	adminErr  error
+	// adminFail, when set, picks which SendAdmin calls fail.
+	adminFail func(*pb.AdminMessage) error
+
+	// fileData is served by GetFile and replaced by PutFile.
+	fileName string
+	fileData []byte
+	fileOpts xmodem.Options
+	fileErr  error
 }
 
-func (f *fakeClient) GetRadioInfo() ([]*pb.FromRadio, error) { return f.infoResponses, f.infoErr }
-func (f *fakeClient) SendTextMessage(message string, to int64, channel int64) error {
+func (f *fakeClient) Handshake(_ context.Context, mode radio.HandshakeMode) ([]*pb.FromRadio, error) {
+	f.infoMode = mode
+	return f.infoResponses, f.infoErr
+}
+func (f *fakeClient) SendTextMessage(_ context.Context, message string, to int64, channel int64) error {
 	f.sendCalls++
 	f.sendMessage = message
 	f.sendTo = to
 	f.sendChannel = channel
 	return f.sendErr
 }
-func (f *fakeClient) SetRadioOwner(name string) error {
-	f.ownerCalls++
-	f.ownerName = name
-	return f.ownerErr
+func (f *fakeClient) SendTextMessageAck(_ context.Context, message string, to int64, channel int64) (radio.Ack, error) {
+	f.sendCalls++
+	f.sendMessage = message
+	f.sendTo = to
+	f.sendChannel = channel
+	return f.sendAck, f.sendErr
 }
-func (f *fakeClient) SetModemMode(mode string) error {
+func (f *fakeClient) SetModemMode(_ context.Context, mode string) error {
 	f.modemCalls++
 	f.modemMode = mode
 	return f.modemErr
 }
-func (f *fakeClient) SetLocation(lat int32, long int32, alt int32) error {
+func (f *fakeClient) SetLocation(_ context.Context, lat int32, long int32, alt int32) error {
 	f.locationCalls++
 	f.locationLat = lat
 	f.locationLon = long
 	f.locationAlt = alt
 	return f.locationErr
 }
-func (f *fakeClient) FactoryReset() error {
+func (f *fakeClient) FactoryReset(context.Context) error {
 	f.resetCalls++
 	return f.resetErr
 }
+func (f *fakeClient) RequestAdmin(_ context.Context, to uint32, msg *pb.AdminMessage) (*pb.AdminMessage, error) {
+	f.adminSent = append(f.adminSent, msg)
+	f.adminTo = append(f.adminTo, to)
+	if f.admin == nil {
+		return nil, errors.New("no admin responder")
+	}
+	return f.admin(msg)
+}
+func (f *fakeClient) SendAdmin(_ context.Context, to uint32, msg *pb.AdminMessage) error {
+	f.adminSent = append(f.adminSent, msg)
+	f.adminTo = append(f.adminTo, to)
+	if f.adminFail != nil {
+		return f.adminFail(msg)
+	}
+	return f.adminErr
+}
+func (f *fakeClient) GetFile(_ context.Context, name string, w io.Writer, opts xmodem.Options) (int64, error) {
+	f.fileName, f.fileOpts = name, opts
+	if f.fileErr != nil {
+		return 0, f.fileErr
+	}
+	n, err := w.Write(f.fileData)
+	return int64(n), err
+}
+func (f *fakeClient) PutFile(_ context.Context, name string, r io.Reader, opts xmodem.Options) (int64, error) {
+	f.fileName, f.fileOpts = name, opts
+	if f.fileErr != nil {
+		return 0, f.fileErr
+	}
+	data, err := io.ReadAll(r)
+	f.fileData = data
+	return int64(len(data)), err
+}
+func (f *fakeClient) Traceroute(_ context.Context, to uint32, _ uint32) (radio.TracerouteReply, error) {
+	f.traceTo = to
+	return f.traceReply, f.traceErr
+}
 
 func TestServiceSendTextValidationAndSuccess(t *testing.T) {
 	svc := NewService(&fakeClient{})
@@ -92,15 +157,19 @@ func TestServiceSendTextValidationAndSuccess(t *testing.T) {
 
 func TestServiceSetOwnerAndModem(t *testing.T) {
 	ctx := context.Background()
-	fc := &fakeClient{}
+	fc := &fakeClient{admin: fakeNodeAdmin(&pb.User{LongName: "Old Name", ShortName: "OLD", IsLicensed: true}, &pb.Config_LoRaConfig{}, nil)}
 	svc := NewService(fc)
 
-	ownerRes, err := svc.SetOwner(ctx, SetOwnerRequest{Name: "Moon Station"})
+	ownerRes, err := svc.SetOwner(ctx, SetOwnerRequest{LongName: "Moon Station", ShortName: "🌙", Unmessagable: proto.Bool(true)})
 	if err != nil {
 		t.Fatalf("unexpected owner error: %v", err)
 	}
-	if ownerRes.Name != "Moon Station" || fc.ownerName != "Moon Station" || fc.ownerCalls != 1 {
-		t.Fatalf("unexpected owner result/call")
+	set := fc.adminSent[len(fc.adminSent)-1].GetSetOwner()
+	if set.GetLongName() != "Moon Station" || set.GetShortName() != "🌙" || !set.GetIsLicensed() || !set.GetIsUnmessagable() {
+		t.Fatalf("unexpected owner write: %v", set)
+	}
+	if ownerRes.LongName != "Moon Station" || !ownerRes.Licensed {
+		t.Fatalf("unexpected owner result: %+v", ownerRes)
 	}
 
 	modemRes, err := svc.SetModem(ctx, SetModemRequest{Mode: "LF"})
@@ -112,6 +181,29 @@ func TestServiceSetOwnerAndModem(t *testing.T) {
 	}
 }
 
+func TestServiceDestinationSendsAdminToRemoteNode(t *testing.T) {
+	const peer = uint32(0x0badcafe)
+	ctx := context.Background()
+	fc := &fakeClient{admin: fakeNodeAdmin(&pb.User{LongName: "Hilltop"}, &pb.Config_LoRaConfig{HopLimit: 3}, nil)}
+	svc := NewService(fc)
+	svc.SetDestination(peer)
+
+	if _, err := svc.SetConfig(ctx, []ConfigAssignment{{Path: "lora.hop_limit", Value: "5"}}); err != nil {
+		t.Fatalf("unexpected config error: %v", err)
+	}
+	if _, err := svc.SetOwner(ctx, SetOwnerRequest{LongName: "Summit"}); err != nil {
+		t.Fatalf("unexpected owner error: %v", err)
+	}
+	if got := fc.adminSent[len(fc.adminSent)-1].GetSetOwner().GetLongName(); got != "Summit" {
+		t.Fatalf("owner write = %q, want Summit", got)
+	}
+	for i, to := range fc.adminTo {
+		if to != peer {
+			t.Fatalf("admin message %d addressed to %d, want %d", i, to, peer)
+		}
+	}
+}
+
 func TestServiceSetLocationValidationAndSuccess(t *testing.T) {
 	ctx := context.Background()
 	fc := &fakeClient{}
@@ -161,3 +253,118 @@ func TestServiceInfoAndFactoryReset(t *testing.T) {
 		t.Fatalf("reset calls = %d, want 1", fc.resetCalls)
 	}
 }
+
+func TestServiceInfoBuildsTypedSnapshot(t *testing.T) {
+	fc := &fakeClient{
+		infoResponses: []*pb.FromRadio{
+			{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 7}}},
+			{PayloadVariant: &pb.FromRadio_NodeInfo{NodeInfo: &pb.NodeInfo{Num: 7, User: &pb.User{LongName: "Base"}}}},
+			{PayloadVariant: &pb.FromRadio_NodeInfo{NodeInfo: &pb.NodeInfo{Num: 8}}},
+			{PayloadVariant: &pb.FromRadio_Channel{Channel: &pb.Channel{Index: 1, Role: pb.Channel_SECONDARY}}},
+			{PayloadVariant: &pb.FromRadio_Channel{Channel: &pb.Channel{Index: 0, Role: pb.Channel_PRIMARY}}},
+			{PayloadVariant: &pb.FromRadio_Config{Config: &pb.Config{PayloadVariant: &pb.Config_Lora{Lora: &pb.Config_LoRaConfig{HopLimit: 5}}}}},
+			{PayloadVariant: &pb.FromRadio_ModuleConfig{ModuleConfig: &pb.ModuleConfig{PayloadVariant: &pb.ModuleConfig_Mqtt{Mqtt: &pb.ModuleConfig_MQTTConfig{Enabled: true}}}}},
+			{PayloadVariant: &pb.FromRadio_ConfigCompleteId{ConfigCompleteId: 1}},
+		},
+	}
+
+	info, err := NewService(fc).InfoMode(context.Background(), radio.HandshakeConfigOnly)
+	if err != nil {
+		t.Fatalf("unexpected info error: %v", err)
+	}
+	if fc.infoMode != radio.HandshakeConfigOnly {
+		t.Fatalf("handshake mode = %v, want config-only", fc.infoMode)
+	}
+
+	snap := info.Snapshot
+	if snap.Self.GetUser().GetLongName() != "Base" || len(snap.Nodes) != 2 {
+		t.Fatalf("unexpected nodes: self=%v nodes=%d", snap.Self, len(snap.Nodes))
+	}
+	if len(snap.Channels) != 2 || snap.Channels[0].GetRole() != pb.Channel_PRIMARY {
+		t.Fatalf("channels not ordered by index: %v", snap.Channels)
+	}
+	if snap.Config["lora"].GetLora().GetHopLimit() != 5 || !snap.ModuleConfig["mqtt"].GetMqtt().GetEnabled() {
+		t.Fatalf("unexpected config sections: %v %v", snap.Config, snap.ModuleConfig)
+	}
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("marshal snapshot: %v", err)
+	}
+	if !strings.Contains(string(b), `"hopLimit":5`) {
+		t.Fatalf("snapshot JSON missing config: %s", b)
+	}
+}
+
+func TestServiceInfoFailsOnIncompleteHandshake(t *testing.T) {
+	fc := &fakeClient{infoErr: radio.ErrHandshakeIncomplete}
+
+	_, err := NewService(fc).Info(context.Background())
+	if !errors.Is(err, radio.ErrHandshakeIncomplete) {
+		t.Fatalf("err = %v, want ErrHandshakeIncomplete", err)
+	}
+}
+
+func TestServiceSendTextWaitsForAck(t *testing.T) {
+	fc := &fakeClient{sendAck: radio.Ack{PacketID: 99, Status: radio.AckNak, From: 0x0a0b0c0d, Reason: pb.Routing_NO_ROUTE}}
+
+	result, err := NewService(fc).SendText(context.Background(), SendTextRequest{Message: "hi", To: 5, WaitAck: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := AckResult{PacketID: 99, Status: "nak", From: "!0a0b0c0d", Error: "NO_ROUTE"}
+	if result.Ack == nil || *result.Ack != want {
+		t.Fatalf("ack = %+v, want %+v", result.Ack, want)
+	}
+	if result.Ack.Delivered() {
+		t.Fatalf("a NAK must not count as delivered")
+	}
+}
+
+func TestServiceTracerouteBuildsHopsBothWays(t *testing.T) {
+	const self, relay, dest = 0x5ca1ab1e, 0x0a0b0c0d, 0xa1b2c3d4
+	fc := &fakeClient{traceReply: radio.TracerouteReply{
+		Packet: &pb.MeshPacket{From: dest, To: self},
+		Route: &pb.RouteDiscovery{
+			Route:      []uint32{relay, unknownHopNum},
+			SnrTowards: []int32{26, unknownSNR, -17},
+			RouteBack:  []uint32{relay},
+			SnrBack:    []int32{-8, 24},
+		},
+	}}
+
+	result, err := NewService(fc).Traceroute(context.Background(), TracerouteRequest{To: dest})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.traceTo != dest {
+		t.Fatalf("traceroute sent to %x, want %x", fc.traceTo, dest)
+	}
+
+	forward := FormatRoute(result.Forward)
+	if want := "!5ca1ab1e -> !0a0b0c0d (6.50dB) -> unknown (?dB) -> !a1b2c3d4 (-4.25dB)"; forward != want {
+		t.Fatalf("forward = %q, want %q", forward, want)
+	}
+	back := FormatRoute(result.Return)
+	if want := "!a1b2c3d4 -> !0a0b0c0d (-2.00dB) -> !5ca1ab1e (6.00dB)"; back != want {
+		t.Fatalf("return = %q, want %q", back, want)
+	}
+}
+
+func TestServiceTracerouteWithoutReturnLeg(t *testing.T) {
+	fc := &fakeClient{traceReply: radio.TracerouteReply{
+		Packet: &pb.MeshPacket{From: 2, To: 1},
+		Route:  &pb.RouteDiscovery{SnrTowards: []int32{40}},
+	}}
+
+	result, err := NewService(fc).Traceroute(context.Background(), TracerouteRequest{To: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Forward) != 2 || len(result.Return) != 0 {
+		t.Fatalf("unexpected hops: %+v", result)
+	}
+	if FormatRoute(result.Return) != "-" {
+		t.Fatalf("empty return leg rendered as %q", FormatRoute(result.Return))
+	}
+}
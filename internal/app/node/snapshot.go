@@ -0,0 +1,126 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Snapshot is the device state reported by one config handshake, grouped by
+// type. Sections the handshake mode did not ask for stay empty.
+type Snapshot struct {
+	MyInfo   *pb.MyNodeInfo
+	Metadata *pb.DeviceMetadata
+	// Self is the NodeInfo of the connected node; it is also included in Nodes.
+	Self     *pb.NodeInfo
+	Nodes    []*pb.NodeInfo
+	Channels []*pb.Channel
+	// Config and ModuleConfig are keyed by section name, such as "lora" or "mqtt".
+	Config       map[string]*pb.Config
+	ModuleConfig map[string]*pb.ModuleConfig
+	Files        []*pb.FileInfo
+}
+
+// BuildSnapshot groups handshake responses into a Snapshot. Later responses
+// for the same config section or channel index replace earlier ones.
+func BuildSnapshot(responses []*pb.FromRadio) Snapshot {
+	snap := Snapshot{
+		Config:       make(map[string]*pb.Config),
+		ModuleConfig: make(map[string]*pb.ModuleConfig),
+	}
+
+	var channels []*pb.Channel
+	for _, fr := range responses {
+		switch v := fr.GetPayloadVariant().(type) {
+		case *pb.FromRadio_MyInfo:
+			snap.MyInfo = v.MyInfo
+		case *pb.FromRadio_Metadata:
+			snap.Metadata = v.Metadata
+		case *pb.FromRadio_NodeInfo:
+			snap.Nodes = append(snap.Nodes, v.NodeInfo)
+		case *pb.FromRadio_Channel:
+			channels = append(channels, v.Channel)
+		case *pb.FromRadio_Config:
+			snap.Config[configSectionName(v.Config)] = v.Config
+		case *pb.FromRadio_ModuleConfig:
+			snap.ModuleConfig[moduleConfigSectionName(v.ModuleConfig)] = v.ModuleConfig
+		case *pb.FromRadio_FileInfo:
+			snap.Files = append(snap.Files, v.FileInfo)
+		}
+	}
+
+	for _, ch := range channels {
+		index := int(ch.GetIndex())
+		for len(snap.Channels) <= index {
+			snap.Channels = append(snap.Channels, nil)
+		}
+		snap.Channels[index] = ch
+	}
+
+	if num := snap.MyInfo.GetMyNodeNum(); num != 0 {
+		for _, n := range snap.Nodes {
+			if n.GetNum() == num {
+				snap.Self = n
+				break
+			}
+		}
+	}
+
+	return snap
+}
+
+// MarshalJSON renders every message with protojson, so field names and enum
+// values match the rest of chirp's JSON output.
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	var err error
+	raw := func(m proto.Message) json.RawMessage {
+		if err != nil || m == nil || !m.ProtoReflect().IsValid() {
+			return nil
+		}
+		var b []byte
+		b, err = protojson.Marshal(m)
+		return b
+	}
+	rawList := func(ms []proto.Message) []json.RawMessage {
+		out := make([]json.RawMessage, 0, len(ms))
+		for _, m := range ms {
+			out = append(out, raw(m))
+		}
+		return out
+	}
+
+	config := make(map[string]json.RawMessage, len(s.Config))
+	for name, c := range s.Config {
+		config[name] = raw(c)
+	}
+	moduleConfig := make(map[string]json.RawMessage, len(s.ModuleConfig))
+	for name, c := range s.ModuleConfig {
+		moduleConfig[name] = raw(c)
+	}
+
+	out := map[string]any{
+		"my_info":       raw(s.MyInfo),
+		"metadata":      raw(s.Metadata),
+		"self":          raw(s.Self),
+		"nodes":         rawList(messages(s.Nodes)),
+		"channels":      rawList(messages(s.Channels)),
+		"config":        config,
+		"module_config": moduleConfig,
+		"files":         rawList(messages(s.Files)),
+	}
+	if err != nil {
+		return nil, fmt.Errorf("marshal snapshot: %w", err)
+	}
+	return json.Marshal(out)
+}
+
+func messages[M proto.Message](ms []M) []proto.Message {
+	out := make([]proto.Message, len(ms))
+	for i, m := range ms {
+		out[i] = m
+	}
+	return out
+}
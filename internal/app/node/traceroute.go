@@ -0,0 +1,143 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// unknownSNR is the SNR a RouteDiscovery records for a hop that did not
+// report one.
+const unknownSNR = int32(-128)
+
+// unknownHopNum stands in for relays that did not add themselves to a route,
+// such as nodes running firmware without traceroute support.
+const unknownHopNum = uint32(0xffffffff)
+
+type TracerouteRequest struct {
+	To      uint32
+	Channel uint32
+}
+
+// RouteHop is one node on a traced path. SNR is the signal-to-noise ratio, in
+// dB, at which the node heard the previous hop; it is nil for the first node
+// and for hops that did not record it.
+type RouteHop struct {
+	Node string   `json:"node"`
+	SNR  *float64 `json:"snr"`
+}
+
+type TracerouteResult struct {
+	To string `json:"to"`
+	// Forward runs from our node to the destination and Return back again.
+	// Return is empty when the destination's firmware does not record it.
+	Forward []RouteHop `json:"forward"`
+	Return  []RouteHop `json:"return"`
+}
+
+func (s *Service) Traceroute(ctx context.Context, req TracerouteRequest) (TracerouteResult, error) {
+	reply, err := s.client.Traceroute(ctx, req.To, req.Channel)
+	if err != nil {
+		var routingErr *radio.RoutingError
+		if errors.As(err, &routingErr) {
+			return TracerouteResult{}, fmt.Errorf("traceroute to %s failed: %w", radio.FormatNodeID(req.To), err)
+		}
+		return TracerouteResult{}, fmt.Errorf("traceroute: %w", err)
+	}
+
+	// The answer travels from the destination back to us, so its sender and
+	// receiver are the two ends of the route.
+	origin, dest := reply.Packet.GetTo(), reply.Packet.GetFrom()
+	result := TracerouteResult{
+		To:      radio.FormatNodeID(req.To),
+		Forward: RouteHops(origin, dest, reply.Route.GetRoute(), reply.Route.GetSnrTowards()),
+		Return:  []RouteHop{},
+	}
+	if returnRecorded(reply.Route) {
+		result.Return = RouteHops(dest, origin, reply.Route.GetRouteBack(), reply.Route.GetSnrBack())
+	}
+	return result, nil
+}
+
+// RouteHops lists the path from start through relays towards end. Each node
+// appends the SNR it heard the packet at, and the final node only its SNR, so
+// end is included only once snrs has an entry for it. Routes still in flight
+// therefore stop at the last relay.
+func RouteHops(start, end uint32, relays []uint32, snrs []int32) []RouteHop {
+	hops := []RouteHop{{Node: radio.FormatNodeID(start)}}
+	for i, relay := range relays {
+		hops = append(hops, RouteHop{Node: hopName(relay), SNR: hopSNR(snrs, i)})
+	}
+	if len(snrs) > len(relays) {
+		hops = append(hops, RouteHop{Node: radio.FormatNodeID(end), SNR: hopSNR(snrs, len(relays))})
+	}
+	return hops
+}
+
+// FormatRoute renders hops on one line, such as "!5ca1ab1e -> !a1b2c3d4 (6.50dB)".
+func FormatRoute(hops []RouteHop) string {
+	if len(hops) == 0 {
+		return "-"
+	}
+
+	parts := make([]string, 0, len(hops))
+	for i, hop := range hops {
+		switch {
+		case i == 0:
+			parts = append(parts, hop.Node)
+		case hop.SNR == nil:
+			parts = append(parts, hop.Node+" (?dB)")
+		default:
+			parts = append(parts, fmt.Sprintf("%s (%.2fdB)", hop.Node, *hop.SNR))
+		}
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// returnRecorded reports whether route carries a return leg; firmware before
+// 2.5 only traces the way towards the destination.
+func returnRecorded(route *pb.RouteDiscovery) bool {
+	return len(route.GetRouteBack()) > 0 || len(route.GetSnrBack()) > 0
+}
+
+func hopName(num uint32) string {
+	if num == unknownHopNum {
+		return "unknown"
+	}
+	return radio.FormatNodeID(num)
+}
+
+func hopSNR(snrs []int32, i int) *float64 {
+	if i >= len(snrs) || snrs[i] == unknownSNR {
+		return nil
+	}
+	snr := float64(snrs[i]) / 4
+	return &snr
+}
+
+// renderRouteDiscovery describes a traceroute packet overheard on the mesh. A
+// request names its sender as the origin; an answer comes from the destination.
+func renderRouteDiscovery(mp *pb.MeshPacket, payload []byte) StreamLine {
+	var route pb.RouteDiscovery
+	if err := proto.Unmarshal(payload, &route); err != nil {
+		return StreamLine{Label: "RTE", Message: fmt.Sprintf("decode_error=%v", err), Category: StreamCategoryPacket}
+	}
+
+	origin, dest := mp.GetFrom(), mp.GetTo()
+	kind := "request"
+	if mp.GetDecoded().GetRequestId() != 0 {
+		origin, dest = dest, origin
+		kind = "reply"
+	}
+
+	msg := fmt.Sprintf("traceroute %s towards=%s", kind, FormatRoute(RouteHops(origin, dest, route.GetRoute(), route.GetSnrTowards())))
+	if returnRecorded(&route) {
+		msg += " back=" + FormatRoute(RouteHops(dest, origin, route.GetRouteBack(), route.GetSnrBack()))
+	}
+	return StreamLine{Label: "RTE", Message: msg, Category: StreamCategoryPacket}
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+func newBackupCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "backup",
+		Short:       "Back up, restore and remove the node's preferences backup",
+		Long:        "Have the node copy its config, module config, channels and owner to a backup\nin its flash or on its SD card, restore from that backup, or remove it.",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+	}
+
+	cmd.AddCommand(newBackupActionCommand(cliCtx, opener, backupCreate))
+	cmd.AddCommand(newBackupActionCommand(cliCtx, opener, backupRestore))
+	cmd.AddCommand(newBackupActionCommand(cliCtx, opener, backupRemove))
+	cmd.AddCommand(newBackupExportCommand(cliCtx, opener))
+	return cmd
+}
+
+// backupAction describes one of the backup subcommands that send a single
+// admin message.
+type backupAction struct {
+	use     string
+	short   string
+	long    string
+	prompt  string
+	done    string
+	destroy bool
+	run     func(*appnode.Service, context.Context, pb.AdminMessage_BackupLocation) error
+}
+
+var (
+	backupCreate = backupAction{
+		use:   "create",
+		short: "Back up the node's preferences on the node",
+		long:  "Have the node write its config, module config, channels and owner to a backup\nat --location, replacing any backup already there.",
+		done:  "backup created",
+		run:   (*appnode.Service).BackupPreferences,
+	}
+	backupRestore = backupAction{
+		use:     "restore",
+		short:   "Restore the node's preferences from its backup",
+		long:    "Have the node replace its preferences with the backup at --location. The node\nreboots to apply them.",
+		prompt:  "Replace the node's preferences with its %s backup? [y/N] ",
+		done:    "restore requested; the node will reboot",
+		destroy: true,
+		run:     (*appnode.Service).RestorePreferences,
+	}
+	backupRemove = backupAction{
+		use:     "remove",
+		short:   "Remove the node's preferences backup",
+		long:    "Delete the backup the node keeps at --location.",
+		prompt:  "Remove the node's %s backup? [y/N] ",
+		done:    "backup removed",
+		destroy: true,
+		run:     (*appnode.Service).RemoveBackupPreferences,
+	}
+)
+
+func newBackupActionCommand(cliCtx *Context, opener radioOpener, action backupAction) *cobra.Command {
+	var (
+		location string
+		yes      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   action.use,
+		Short: action.short,
+		Long:  action.long,
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			loc, err := appnode.ParseBackupLocation(location)
+			if err != nil {
+				return mapServiceError(err)
+			}
+			if action.destroy {
+				if err := confirmOrCancel(cmd, yes, fmt.Sprintf(action.prompt, location), action.use+" cancelled"); err != nil {
+					return err
+				}
+			}
+
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				if err := action.run(newService(cliCtx, r), ctx, loc); err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{
+						"ok":       true,
+						"location": location,
+					})
+				}
+				_, err := fmt.Fprintf(cmd.OutOrStdout(), "%s (%s)\n", action.done, location)
+				return err
+			}))
+		},
+	}
+
+	cmd.Flags().StringVar(&location, "location", "flash", "where the node keeps the backup: flash or sd")
+	if action.destroy {
+		cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
+	}
+	return cmd
+}
+
+func newBackupExportCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	return &cobra.Command{
+		Use:   "export <file>",
+		Short: "Save the node's preferences to a local file",
+		Long: "Read the owner, every config and module config section and every channel and\n" +
+			"save them to file as a binary BackupPreferences protobuf, the format the node\n" +
+			"keeps its own backups in. The file holds the node's private key and channel\n" +
+			"keys, so it is created readable by you alone. Use - to write to standard\n" +
+			"output. --timeout applies to each request.",
+		Example: "  chirp backup export node.backup",
+		Args:    wrapPositionalArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				n, err := exportBackup(ctx, cmd, newService(cliCtx, r), path)
+				if err != nil {
+					return err
+				}
+				if path == "-" {
+					return nil
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{
+						"ok":    true,
+						"file":  path,
+						"bytes": n,
+					})
+				}
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "backup saved to %s (%d bytes)\n", path, n)
+				return err
+			}))
+		},
+	}
+}
+
+// exportBackup reads the node's preferences and writes them to path, or to
+// standard output when path is "-", returning the number of bytes written.
+func exportBackup(ctx context.Context, cmd *cobra.Command, service *appnode.Service, path string) (int, error) {
+	backup, err := service.ExportBackup(ctx)
+	if err != nil {
+		return 0, mapServiceError(err)
+	}
+	b, err := proto.Marshal(backup)
+	if err != nil {
+		return 0, fmt.Errorf("marshal backup: %w", err)
+	}
+
+	if path == "-" {
+		_, err = cmd.OutOrStdout().Write(b)
+	} else {
+		err = os.WriteFile(path, b, 0o600)
+	}
+	if err != nil {
+		return 0, newRuntimeError(fmt.Errorf("write backup: %w", err))
+	}
+	return len(b), nil
+}
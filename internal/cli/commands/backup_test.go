@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBackupCreateAgainstSimulatedNode(t *testing.T) {
+	out, err := runSimCommand(t, "backup", "create", "--location", "sd")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "backup created (sd)\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestBackupRejectsBadLocationBeforeConnecting(t *testing.T) {
+	cmd := newBackupCommand(&Context{Port: "/dev/test", Timeout: time.Second}, func(string) (Radio, error) {
+		t.Fatalf("opener should not be called for invalid args")
+		return nil, nil
+	})
+	cmd.SetArgs([]string{"restore", "--location", "usb", "--yes"})
+	cmd.SilenceUsage = true
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), "flash or sd") {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
+
+func TestFactoryResetChecksBackupLocationOnlyWithBackup(t *testing.T) {
+	out, err := runSimCommand(t, "factory-reset", "--yes", "--backup-location", "usb")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "factory reset command sent\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	_, err = runSimCommand(t, "factory-reset", "--yes", "--backup", "--backup-location", "usb")
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), "flash or sd") {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
+
+func TestFactoryResetSavesBackupFileFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.backup")
+	out, err := runSimCommand(t, "factory-reset", "--yes", "--backup", "--backup-file", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "backup saved to " + path + "\nbackup created (flash)\nfactory reset command sent\n"
+	if out != want {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat backup: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("backup mode = %v, want 0600", info.Mode().Perm())
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read backup: %v", err)
+	}
+	var backup pb.BackupPreferences
+	if err := proto.Unmarshal(b, &backup); err != nil {
+		t.Fatalf("unmarshal backup: %v", err)
+	}
+	if backup.GetOwner().GetLongName() == "" || backup.GetConfig().GetLora() == nil || len(backup.GetChannels().GetChannels()) == 0 {
+		t.Fatalf("incomplete backup: %v", &backup)
+	}
+}
@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/spf13/cobra"
+)
+
+func newCannedCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "canned",
+		Short:       "Read and set canned messages",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+	}
+
+	cmd.AddCommand(newCannedGetCommand(cliCtx, opener))
+	cmd.AddCommand(newCannedSetCommand(cliCtx, opener))
+	return cmd
+}
+
+func newCannedGetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get",
+		Short: "Show the canned messages and canned_message module settings",
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				canned, err := newService(cliCtx, r).GetCannedMessages(ctx)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(canned)
+				}
+				return writeCannedMessages(cmd.OutOrStdout(), canned)
+			}))
+		},
+	}
+}
+
+func newCannedSetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var (
+		file        string
+		enabled     bool
+		rotary      bool
+		upDown      bool
+		sendBell    bool
+		inputSource string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "set [\"msg|msg|...\"]",
+		Short: "Replace the canned messages and change module settings",
+		Long: "Replace the canned messages with the '|'-separated list given, or with the\n" +
+			"messages in --file, one per line or '|'-separated. Joined with '|', the list\n" +
+			fmt.Sprintf("must fit in %d bytes. Module flags not given keep their value; pins and\n", appnode.MaxCannedMessagesBytes) +
+			"input events are set with \"chirp config set canned_message.<field>=...\".",
+		Example: "  chirp canned set \"Ok|On my way|Need help\"\n" +
+			"  chirp canned set --file messages.txt --rotary --send-bell",
+		Args: wrapPositionalArgs(cobra.MaximumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var u appnode.CannedUpdate
+
+			if len(args) == 1 && file != "" {
+				return newUserInputError(fmt.Errorf("give messages as an argument or with --file, not both"))
+			}
+			switch {
+			case len(args) == 1:
+				messages := appnode.ParseCannedMessages(args[0])
+				u.Messages = &messages
+			case file != "":
+				data, err := readInputFile(cmd, file)
+				if err != nil {
+					return newUserInputError(err)
+				}
+				messages := appnode.ParseCannedMessages(string(data))
+				u.Messages = &messages
+			}
+
+			flags := cmd.Flags()
+			if flags.Changed("enabled") {
+				u.Enabled = &enabled
+			}
+			if flags.Changed("rotary") {
+				u.Rotary = &rotary
+			}
+			if flags.Changed("updown") {
+				u.UpDown = &upDown
+			}
+			if flags.Changed("send-bell") {
+				u.SendBell = &sendBell
+			}
+			if flags.Changed("input-source") {
+				u.InputSource = &inputSource
+			}
+			if err := appnode.ValidateCannedUpdate(u); err != nil {
+				return mapServiceError(err)
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				canned, err := newService(cliCtx, r).SetCannedMessages(ctx, u)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{"ok": true, "canned": canned})
+				}
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "canned messages set (%d messages, %d/%d bytes)\n",
+					len(canned.Messages), len(appnode.JoinCannedMessages(canned.Messages)), appnode.MaxCannedMessagesBytes)
+				return err
+			}))
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "", "read messages from a file (- for stdin)")
+	cmd.Flags().BoolVar(&enabled, "enabled", false, "enable the module (older firmware)")
+	cmd.Flags().BoolVar(&rotary, "rotary", false, "pick messages with the rotary encoder")
+	cmd.Flags().BoolVar(&upDown, "updown", false, "pick messages with the up/down encoder")
+	cmd.Flags().BoolVar(&sendBell, "send-bell", false, "send a bell character with each message")
+	cmd.Flags().StringVar(&inputSource, "input-source", "", "accepted input, such as rotEnc1, upDownEnc1, cardkb or _any (older firmware)")
+	return cmd
+}
+
+func writeCannedMessages(out io.Writer, canned appnode.CannedMessages) error {
+	if err := printKeyValueTable(out, []keyValueRow{
+		{Key: "enabled", Value: strconv.FormatBool(canned.Enabled)},
+		{Key: "rotary", Value: strconv.FormatBool(canned.Rotary)},
+		{Key: "updown", Value: strconv.FormatBool(canned.UpDown)},
+		{Key: "send bell", Value: strconv.FormatBool(canned.SendBell)},
+		{Key: "input source", Value: orDash(canned.InputSource)},
+		{Key: "size", Value: fmt.Sprintf("%d/%d bytes", len(appnode.JoinCannedMessages(canned.Messages)), appnode.MaxCannedMessagesBytes)},
+	}); err != nil {
+		return err
+	}
+	if len(canned.Messages) == 0 {
+		_, err := fmt.Fprintln(out, "\nno canned messages")
+		return err
+	}
+
+	if _, err := fmt.Fprintln(out); err != nil {
+		return err
+	}
+	rows := make([][]string, len(canned.Messages))
+	for i, msg := range canned.Messages {
+		rows[i] = []string{strconv.Itoa(i + 1), msg}
+	}
+	return printTable(out, []string{"#", "MESSAGE"}, rows)
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCannedSetAgainstSimulatedNode(t *testing.T) {
+	out, err := runSimCommand(t, "canned", "set", "Ok|On my way|Need help", "--send-bell")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "canned messages set (3 messages, 22/200 bytes)\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestCannedSetRejectsTooLongListBeforeConnecting(t *testing.T) {
+	cmd := newCannedSetCommand(&Context{Port: "/dev/test", Timeout: time.Second}, func(string) (Radio, error) {
+		t.Fatalf("opener should not be called for invalid args")
+		return nil, nil
+	})
+	cmd.SetArgs([]string{strings.Repeat("Need help now|", 15)})
+	cmd.SilenceUsage = true
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), "at most 200") {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
+
+func TestCannedSetRejectsEmptyFileBeforeConnecting(t *testing.T) {
+	cmd := newCannedSetCommand(&Context{Port: "/dev/test", Timeout: time.Second}, func(string) (Radio, error) {
+		t.Fatalf("opener should not be called for invalid args")
+		return nil, nil
+	})
+	cmd.SetArgs([]string{"--file", "-"})
+	cmd.SilenceUsage = true
+	cmd.SetIn(strings.NewReader("\n\n"))
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), "cannot clear") {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
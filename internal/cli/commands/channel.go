@@ -0,0 +1,350 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/spf13/cobra"
+)
+
+const pskFlagUsage = "key: random (256-bit), none, default, simple0-9, 0x<hex> or base64:<key>"
+
+func newChannelCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "channel",
+		Short:       "List and change channels",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+	}
+
+	cmd.AddCommand(newChannelListCommand(cliCtx, opener))
+	cmd.AddCommand(newChannelAddCommand(cliCtx, opener))
+	cmd.AddCommand(newChannelSetCommand(cliCtx, opener))
+	cmd.AddCommand(newChannelDeleteCommand(cliCtx, opener))
+	cmd.AddCommand(newChannelEnableUplinkCommand(cliCtx, opener))
+	cmd.AddCommand(newChannelURLCommand(cliCtx, opener))
+	cmd.AddCommand(newChannelApplyCommand(cliCtx, opener))
+	cmd.AddCommand(newChannelDecodeCommand(cliCtx))
+	return cmd
+}
+
+func newChannelListCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List every channel slot",
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				entries, err := newService(cliCtx, r).ListChannels(ctx)
+				if err != nil {
+					return mapServiceError(err)
+				}
+				return writeChannels(cmd.OutOrStdout(), entries, cliCtx.JSON)
+			}))
+		},
+	}
+}
+
+func newChannelAddCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var update channelUpdateFlags
+
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add a secondary channel in the first free slot",
+		Long:  "Add a secondary channel in the first free slot. It gets a random 256-bit key\nunless --psk says otherwise.",
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			u := update.build(cmd)
+			if u.Name == nil {
+				return newUserInputError(fmt.Errorf("--name is required"))
+			}
+			if err := appnode.ValidateChannelUpdate(u); err != nil {
+				return mapServiceError(err)
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				entry, err := newService(cliCtx, r).AddChannel(ctx, u)
+				if err != nil {
+					return mapServiceError(err)
+				}
+				return writeChannels(cmd.OutOrStdout(), []appnode.ChannelEntry{entry}, cliCtx.JSON)
+			}))
+		},
+	}
+
+	update.register(cmd)
+	return cmd
+}
+
+func newChannelSetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var update channelUpdateFlags
+
+	cmd := &cobra.Command{
+		Use:   "set <index>",
+		Short: "Change an enabled channel",
+		Args:  wrapPositionalArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := parseChannelIndex(args[0])
+			if err != nil {
+				return err
+			}
+			u := update.build(cmd)
+			if u == (appnode.ChannelUpdate{}) {
+				return newUserInputError(fmt.Errorf("at least one of --name, --psk, --uplink or --downlink is required"))
+			}
+			if err := appnode.ValidateChannelUpdate(u); err != nil {
+				return mapServiceError(err)
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				entry, err := newService(cliCtx, r).UpdateChannel(ctx, index, u)
+				if err != nil {
+					return mapServiceError(err)
+				}
+				return writeChannels(cmd.OutOrStdout(), []appnode.ChannelEntry{entry}, cliCtx.JSON)
+			}))
+		},
+	}
+
+	update.register(cmd)
+	return cmd
+}
+
+func newChannelDeleteCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <index>",
+		Short: "Delete a secondary channel",
+		Long:  "Delete a secondary channel. Later channels move down a slot. The primary\nchannel cannot be deleted.",
+		Args:  wrapPositionalArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := parseChannelIndex(args[0])
+			if err != nil {
+				return err
+			}
+			if index == 0 {
+				return newUserInputError(fmt.Errorf("channel 0 is the primary channel and cannot be deleted"))
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				service := newService(cliCtx, r)
+				if err := service.DeleteChannel(ctx, index); err != nil {
+					return mapServiceError(err)
+				}
+				entries, err := service.ListChannels(ctx)
+				if err != nil {
+					return mapServiceError(err)
+				}
+				return writeChannels(cmd.OutOrStdout(), entries, cliCtx.JSON)
+			}))
+		},
+	}
+}
+
+func newChannelEnableUplinkCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var downlink bool
+
+	cmd := &cobra.Command{
+		Use:   "enable-uplink <index>",
+		Short: "Let MQTT gateways publish a channel's messages",
+		Args:  wrapPositionalArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := parseChannelIndex(args[0])
+			if err != nil {
+				return err
+			}
+			enabled := true
+			u := appnode.ChannelUpdate{Uplink: &enabled}
+			if downlink {
+				u.Downlink = &enabled
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				entry, err := newService(cliCtx, r).UpdateChannel(ctx, index, u)
+				if err != nil {
+					return mapServiceError(err)
+				}
+				return writeChannels(cmd.OutOrStdout(), []appnode.ChannelEntry{entry}, cliCtx.JSON)
+			}))
+		},
+	}
+
+	cmd.Flags().BoolVar(&downlink, "downlink", false, "also forward messages from MQTT to the mesh")
+	return cmd
+}
+
+func newChannelURLCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var noQR bool
+	var invert bool
+
+	cmd := &cobra.Command{
+		Use:   "url",
+		Short: "Print the channel URL and its QR code",
+		Long: "Print a https://meshtastic.org/e/# URL holding the enabled channels and the\n" +
+			"LoRa config, followed by a QR code other clients can scan. The URL carries\n" +
+			"the channel keys.",
+		Args: wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				url, err := newService(cliCtx, r).GetChannelURL(ctx)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				out := cmd.OutOrStdout()
+				if cliCtx.JSON {
+					return json.NewEncoder(out).Encode(map[string]any{"url": url})
+				}
+				if _, err := fmt.Fprintln(out, url); err != nil {
+					return err
+				}
+				if noQR {
+					return nil
+				}
+				if _, err := fmt.Fprintln(out); err != nil {
+					return err
+				}
+				return writeQR(out, url, invert)
+			}))
+		},
+	}
+
+	cmd.Flags().BoolVar(&noQR, "no-qr", false, "print only the URL")
+	cmd.Flags().BoolVar(&invert, "invert", false, "draw the QR code for a light terminal background")
+	return cmd
+}
+
+func newChannelApplyCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var add bool
+
+	cmd := &cobra.Command{
+		Use:   "apply <url>",
+		Short: "Configure channels from a channel URL",
+		Long: "Configure channels from a channel URL. The URL's channels replace every slot\n" +
+			"and its LoRa config replaces the node's. With --add, its channels go into free\n" +
+			"slots as secondaries instead, channels the node already has are skipped, and\n" +
+			"the LoRa config is left alone.",
+		Args: wrapPositionalArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := appnode.ParseChannelURL(args[0]); err != nil {
+				return mapServiceError(err)
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				entries, err := newService(cliCtx, r).ApplyChannelURL(ctx, args[0], add)
+				if err != nil {
+					return mapServiceError(err)
+				}
+				return writeChannels(cmd.OutOrStdout(), entries, cliCtx.JSON)
+			}))
+		},
+	}
+
+	cmd.Flags().BoolVar(&add, "add", false, "add the URL's channels instead of replacing every channel")
+	return cmd
+}
+
+func newChannelDecodeCommand(cliCtx *Context) *cobra.Command {
+	return &cobra.Command{
+		Use:         "decode <url>",
+		Short:       "Show what a channel URL holds, without a radio",
+		Args:        wrapPositionalArgs(cobra.ExactArgs(1)),
+		Annotations: map[string]string{remoteAdminAnnotation: "false"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			set, err := appnode.ParseChannelURL(args[0])
+			if err != nil {
+				return mapServiceError(err)
+			}
+			entries := appnode.ChannelSetEntries(set)
+
+			out := cmd.OutOrStdout()
+			if cliCtx.JSON {
+				lora, err := configJSON.Marshal(set.GetLoraConfig())
+				if err != nil {
+					return fmt.Errorf("marshal lora config: %w", err)
+				}
+				return json.NewEncoder(out).Encode(map[string]any{
+					"channels": entries,
+					"lora":     json.RawMessage(lora),
+				})
+			}
+
+			if err := writeChannels(out, entries, false); err != nil {
+				return err
+			}
+			if set.GetLoraConfig() == nil {
+				return nil
+			}
+			if _, err := fmt.Fprintln(out); err != nil {
+				return err
+			}
+			return writeConfigSections(out, []appnode.ConfigSection{{Name: "lora", Message: set.GetLoraConfig()}})
+		},
+	}
+}
+
+// channelUpdateFlags holds the flags add and set share; only flags given on
+// the command line end up in the update.
+type channelUpdateFlags struct {
+	name     string
+	psk      string
+	uplink   bool
+	downlink bool
+}
+
+func (f *channelUpdateFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.name, "name", "", "channel name, at most 11 bytes")
+	cmd.Flags().StringVar(&f.psk, "psk", "", pskFlagUsage)
+	cmd.Flags().BoolVar(&f.uplink, "uplink", false, "let MQTT gateways publish this channel")
+	cmd.Flags().BoolVar(&f.downlink, "downlink", false, "forward this channel's MQTT messages to the mesh")
+}
+
+func (f *channelUpdateFlags) build(cmd *cobra.Command) appnode.ChannelUpdate {
+	var u appnode.ChannelUpdate
+	if cmd.Flags().Changed("name") {
+		u.Name = &f.name
+	}
+	if cmd.Flags().Changed("psk") {
+		u.PSK = &f.psk
+	}
+	if cmd.Flags().Changed("uplink") {
+		u.Uplink = &f.uplink
+	}
+	if cmd.Flags().Changed("downlink") {
+		u.Downlink = &f.downlink
+	}
+	return u
+}
+
+func parseChannelIndex(arg string) (int, error) {
+	index, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, newUserInputError(fmt.Errorf("channel index must be a number, got %q", arg))
+	}
+	if err := appnode.ValidateChannelIndex(index); err != nil {
+		return 0, mapServiceError(err)
+	}
+	return index, nil
+}
+
+func writeChannels(out io.Writer, entries []appnode.ChannelEntry, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(out).Encode(map[string]any{"channels": entries})
+	}
+
+	rows := make([][]string, len(entries))
+	for i, e := range entries {
+		rows[i] = []string{
+			strconv.Itoa(e.Index),
+			e.Role,
+			orDash(e.Name),
+			orDash(e.PSK),
+			strconv.FormatBool(e.Uplink),
+			strconv.FormatBool(e.Downlink),
+		}
+	}
+	return printTable(out, []string{"INDEX", "ROLE", "NAME", "PSK", "UPLINK", "DOWNLINK"}, rows)
+}
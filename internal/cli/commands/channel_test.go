@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestChannelListShowsEverySlot(t *testing.T) {
+	out, err := runSimCommand(t, "channel", "list", "--json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var result struct {
+		Channels []struct {
+			Index int    `json:"index"`
+			Role  string `json:"role"`
+			PSK   string `json:"psk"`
+		} `json:"channels"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("unmarshal output: %v, output=%q", err, out)
+	}
+	if len(result.Channels) != 8 || result.Channels[0].Role != "PRIMARY" || result.Channels[0].PSK != "default" || result.Channels[7].Role != "DISABLED" {
+		t.Fatalf("unexpected channels: %+v", result.Channels)
+	}
+}
+
+func TestChannelAddGeneratesKey(t *testing.T) {
+	out, err := runSimCommand(t, "channel", "add", "--name", "ops", "--uplink")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fields := strings.Fields(strings.Split(out, "\n")[1])
+	if strings.Join(fields, " ") != "1 SECONDARY ops aes256 true false" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestChannelDeleteRefusesPrimary(t *testing.T) {
+	_, err := runSimCommand(t, "channel", "delete", "0")
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), "primary channel") {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
+
+func TestChannelURLPrintsQRCode(t *testing.T) {
+	out, err := runSimCommand(t, "channel", "url")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if !strings.HasPrefix(lines[0], "https://meshtastic.org/e/#") || lines[1] != "" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	qr := lines[2:]
+	width := len([]rune(qr[0]))
+	if width < 21+2*qrQuietZone || len(qr) != (width+1)/2 {
+		t.Fatalf("QR code is %d lines of %d columns:\n%s", len(qr), width, out)
+	}
+	// The quiet zone is light on every side, including the padded last line.
+	quiet := strings.Repeat("█", width)
+	if qr[0] != quiet || qr[len(qr)-1] != quiet {
+		t.Fatalf("quiet zone is not light:\n%s", out)
+	}
+}
+
+func TestChannelDecodeNeedsNoRadio(t *testing.T) {
+	url := "https://meshtastic.org/e/#CgMSAQESCAgBOAFAA0gB"
+
+	cmd := newRootCommand()
+	cmd.SetArgs([]string{"channel", "decode", url, "--port", "/dev/does-not-exist"})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"PRIMARY", "default", "[lora]", "LONG_FAST"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
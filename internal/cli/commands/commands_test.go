@@ -2,11 +2,17 @@ package commands
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"strings"
 	"testing"
 	"time"
 
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/coreyvan/chirp/pkg/radio/capture"
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 )
 
@@ -16,8 +22,6 @@ type commandTestRadio struct {
 	sendTextMessage   string
 	sendTextTo        int64
 	sendTextChannel   int64
-	setOwnerCalls     int
-	setOwnerName      string
 	setModemCalls     int
 	setModemMode      string
 	setLocationCalls  int
@@ -26,44 +30,74 @@ type commandTestRadio struct {
 	setLocationAlt    int32
 	infoCalls         int
 	infoResponses     []*pb.FromRadio
+	infoMode          radio.HandshakeMode
+	sendTextAck       radio.Ack
 }
 
 func (f *commandTestRadio) Close() error { return nil }
-func (f *commandTestRadio) ReadResponse(bool) ([]*pb.FromRadio, error) {
+func (f *commandTestRadio) OpenInbox()   {}
+func (f *commandTestRadio) ReadResponse(context.Context, bool) ([]*pb.FromRadio, error) {
 	return nil, nil
 }
-func (f *commandTestRadio) GetRadioInfo() ([]*pb.FromRadio, error) {
+func (f *commandTestRadio) Stats() radio.Stats          { return radio.Stats{} }
+func (f *commandTestRadio) SetRecorder(*capture.Writer) {}
+func (f *commandTestRadio) GetRadioInfo(ctx context.Context) ([]*pb.FromRadio, error) {
+	return f.Handshake(ctx, radio.HandshakeFull)
+}
+func (f *commandTestRadio) Handshake(_ context.Context, mode radio.HandshakeMode) ([]*pb.FromRadio, error) {
 	f.infoCalls++
+	f.infoMode = mode
 	return f.infoResponses, nil
 }
-func (f *commandTestRadio) SendTextMessage(message string, to int64, channel int64) error {
+func (f *commandTestRadio) SendTextMessage(_ context.Context, message string, to int64, channel int64) error {
 	f.sendTextCalls++
 	f.sendTextMessage = message
 	f.sendTextTo = to
 	f.sendTextChannel = channel
 	return nil
 }
-func (f *commandTestRadio) SetRadioOwner(name string) error {
-	f.setOwnerCalls++
-	f.setOwnerName = name
-	return nil
+func (f *commandTestRadio) SendTextMessageAck(ctx context.Context, message string, to int64, channel int64) (radio.Ack, error) {
+	if err := f.SendTextMessage(ctx, message, to, channel); err != nil {
+		return radio.Ack{}, err
+	}
+	return f.sendTextAck, nil
 }
-func (f *commandTestRadio) SetModemMode(mode string) error {
+func (f *commandTestRadio) SetModemMode(_ context.Context, mode string) error {
 	f.setModemCalls++
 	f.setModemMode = mode
 	return nil
 }
-func (f *commandTestRadio) SetLocation(lat int32, lon int32, alt int32) error {
+func (f *commandTestRadio) SetLocation(_ context.Context, lat int32, lon int32, alt int32) error {
 	f.setLocationCalls++
 	f.setLocationLat = lat
 	f.setLocationLon = lon
 	f.setLocationAlt = alt
 	return nil
 }
-func (f *commandTestRadio) FactoryReset() error {
+func (f *commandTestRadio) FactoryReset(context.Context) error {
 	f.factoryResetCalls++
 	return nil
 }
+func (f *commandTestRadio) Traceroute(ctx context.Context, _ uint32, _ uint32) (radio.TracerouteReply, error) {
+	<-ctx.Done()
+	return radio.TracerouteReply{}, ctx.Err()
+}
+func (f *commandTestRadio) RequestAdmin(ctx context.Context, _ uint32, _ *pb.AdminMessage) (*pb.AdminMessage, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+func (f *commandTestRadio) SendAdmin(ctx context.Context, _ uint32, _ *pb.AdminMessage) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+func (f *commandTestRadio) GetFile(ctx context.Context, _ string, _ io.Writer, _ xmodem.Options) (int64, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+func (f *commandTestRadio) PutFile(ctx context.Context, _ string, _ io.Reader, _ xmodem.Options) (int64, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
 
 func TestSendTextRejectsEmptyMessage(t *testing.T) {
 	cliCtx := &Context{Port: "/dev/test", Timeout: time.Second}
@@ -251,23 +285,28 @@ func TestSendTextSuccessJSONOutput(t *testing.T) {
 }
 
 func TestSetOwnerSuccess(t *testing.T) {
-	cliCtx := &Context{Port: "/dev/test", Timeout: time.Second}
-	r := &commandTestRadio{}
-	cmd := newSetOwnerCommand(cliCtx, func(string) (Radio, error) { return r, nil })
-	cmd.SetArgs([]string{"--name", "Moon Station"})
-
-	var out bytes.Buffer
-	cmd.SetOut(&out)
-	cmd.SetErr(&out)
-
-	if err := cmd.Execute(); err != nil {
+	out, err := runSimCommand(t, "set", "owner", "--long", "Moon Station", "--short", "🌙")
+	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if r.setOwnerCalls != 1 || r.setOwnerName != "Moon Station" {
-		t.Fatalf("unexpected set owner call: calls=%d name=%q", r.setOwnerCalls, r.setOwnerName)
+	if !strings.Contains(out, `owner set to "Moon Station" (🌙)`) {
+		t.Fatalf("missing owner output: %q", out)
 	}
-	if !strings.Contains(out.String(), `owner set to "Moon Station"`) {
-		t.Fatalf("missing owner output: %q", out.String())
+}
+
+func TestSetOwnerRejectsLongShortName(t *testing.T) {
+	cmd := newSetOwnerCommand(&Context{Port: "/dev/test", Timeout: time.Second}, func(string) (Radio, error) {
+		t.Fatalf("opener should not be called for invalid args")
+		return nil, nil
+	})
+	cmd.SetArgs([]string{"--short", "ÄÖÜ"})
+	cmd.SilenceUsage = true
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), "6 bytes") {
+		t.Fatalf("expected user input error, got %v", err)
 	}
 }
 
@@ -381,3 +420,142 @@ func TestInfoSuccessTextAndJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestSendTextWaitAckAgainstSimulatedNode(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		to      string
+		wantOut string
+		wantErr bool
+	}{
+		{name: "peer acks", to: "2712847316", wantOut: "delivered by !a1b2c3d4"},
+		{name: "broadcast", to: "0", wantOut: "implicit (rebroadcast heard)"},
+		{name: "unknown node", to: "305419896", wantOut: "nak MAX_RETRANSMIT from !5ca1ab1e", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newSendTextCommand(&Context{Port: "sim://", Timeout: time.Second}, nil)
+			cmd.SetArgs([]string{"--message", "hi", "--to", tc.to, "--wait-ack", "--ack-timeout", "5s"})
+
+			var out bytes.Buffer
+			cmd.SetOut(&out)
+			cmd.SetErr(&out)
+
+			err := cmd.Execute()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if tc.wantErr && ExitCode(err) != 1 {
+				t.Fatalf("exit code = %d, want 1", ExitCode(err))
+			}
+			if !strings.Contains(out.String(), tc.wantOut) {
+				t.Fatalf("output %q missing %q", out.String(), tc.wantOut)
+			}
+		})
+	}
+}
+
+func TestSendTextWaitAckTimeoutJSON(t *testing.T) {
+	cliCtx := &Context{Port: "/dev/test", Timeout: time.Second, JSON: true}
+	r := &commandTestRadio{sendTextAck: radio.Ack{PacketID: 7, Status: radio.AckTimeout}}
+	cmd := newSendTextCommand(cliCtx, func(string) (Radio, error) { return r, nil })
+	cmd.SetArgs([]string{"--message", "hi", "--to", "5", "--wait-ack"})
+	cmd.SilenceUsage = true
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+
+	if err := cmd.Execute(); ExitCode(err) != 1 {
+		t.Fatalf("expected runtime error for timeout, got %v", err)
+	}
+
+	var got struct {
+		OK  bool `json:"ok"`
+		Ack struct {
+			PacketID uint32 `json:"packet_id"`
+			Status   string `json:"status"`
+		} `json:"ack"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal output: %v, output=%q", err, out.String())
+	}
+	if got.OK || got.Ack.Status != "timeout" || got.Ack.PacketID != 7 {
+		t.Fatalf("unexpected JSON: %s", out.String())
+	}
+}
+
+func TestTracerouteAgainstSimulatedNode(t *testing.T) {
+	cmd := newTracerouteCommand(&Context{Port: "sim://", Timeout: time.Second}, nil)
+	cmd.SetArgs([]string{"--to", "!0badcafe", "--response-timeout", "5s"})
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"traceroute to !0badcafe",
+		"forward  !5ca1ab1e -> !a1b2c3d4 (6.50dB) -> !0badcafe (-4.25dB)",
+		"return   !0badcafe -> !a1b2c3d4 (-4.25dB) -> !5ca1ab1e (6.50dB)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output %q missing %q", out.String(), want)
+		}
+	}
+}
+
+func TestTracerouteSimulatedJSON(t *testing.T) {
+	cmd := newTracerouteCommand(&Context{Port: "sim://", Timeout: time.Second, JSON: true}, nil)
+	cmd.SetArgs([]string{"--to", "!a1b2c3d4"})
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got appnode.TracerouteResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal output: %v, output=%q", err, out.String())
+	}
+	if got.To != "!a1b2c3d4" || len(got.Forward) != 2 || len(got.Return) != 2 {
+		t.Fatalf("unexpected JSON: %s", out.String())
+	}
+	if got.Forward[0].SNR != nil || got.Forward[1].SNR == nil || *got.Forward[1].SNR != 6.5 {
+		t.Fatalf("unexpected forward SNRs: %s", out.String())
+	}
+}
+
+func TestTracerouteTimesOutWithoutResponse(t *testing.T) {
+	cliCtx := &Context{Port: "/dev/test", Timeout: time.Second}
+	cmd := newTracerouteCommand(cliCtx, func(string) (Radio, error) { return &commandTestRadio{}, nil })
+	cmd.SetArgs([]string{"--to", "!a1b2c3d4", "--response-timeout", "20ms"})
+	cmd.SilenceUsage = true
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if ExitCode(err) != 1 || !strings.Contains(err.Error(), "no traceroute response from !a1b2c3d4 within 20ms") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTracerouteRejectsBadNodeID(t *testing.T) {
+	cliCtx := &Context{Port: "/dev/test", Timeout: time.Second}
+	cmd := newTracerouteCommand(cliCtx, func(string) (Radio, error) {
+		t.Fatalf("opener should not be called for invalid args")
+		return nil, nil
+	})
+	cmd.SetArgs([]string{"--to", "!nothex"})
+	cmd.SilenceUsage = true
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	if err := cmd.Execute(); ExitCode(err) != 2 {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
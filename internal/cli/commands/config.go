@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+)
+
+func newConfigCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "config",
+		Short:       "Read and change config and module config sections",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+	}
+
+	cmd.AddCommand(newConfigGetCommand(cliCtx, opener))
+	cmd.AddCommand(newConfigSetCommand(cliCtx, opener))
+	return cmd
+}
+
+func newConfigGetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var asYAML bool
+
+	cmd := &cobra.Command{
+		Use:   "get [section]",
+		Short: "Print one config section, or all of them",
+		Long:  "Print one config section, or all of them.\n\nSections: " + strings.Join(appnode.ConfigSectionNames(), ", "),
+		Args:  wrapPositionalArgs(cobra.MaximumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if asYAML && cliCtx.JSON {
+				return newUserInputError(fmt.Errorf("--yaml and --json cannot be combined"))
+			}
+			names := appnode.ConfigSectionNames()
+			if len(args) == 1 {
+				names = args[:1]
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				service := newService(cliCtx, r)
+				sections := make([]appnode.ConfigSection, 0, len(names))
+				for _, name := range names {
+					section, err := service.GetConfigSection(ctx, name)
+					if err != nil {
+						return mapServiceError(err)
+					}
+					sections = append(sections, section)
+				}
+
+				switch {
+				case cliCtx.JSON:
+					return writeConfigSectionsJSON(cmd.OutOrStdout(), sections)
+				case asYAML:
+					return writeConfigSectionsYAML(cmd.OutOrStdout(), sections)
+				default:
+					return writeConfigSections(cmd.OutOrStdout(), sections)
+				}
+			}))
+		},
+	}
+
+	cmd.Flags().BoolVar(&asYAML, "yaml", false, "print YAML instead of tables")
+
+	return cmd
+}
+
+func newConfigSetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set section.field=value...",
+		Short: "Change config fields",
+		Long: "Change config fields by path, such as lora.region=US or mqtt.enabled=true.\n\n" +
+			"Paths and value names match config get. Enums take their names, bytes base64\n" +
+			"and lists comma-separated values. Each section is read, changed and written\n" +
+			"back whole; changes to several sections are written in one edit-settings\n" +
+			"transaction. Some changes, such as LoRa settings, make the node reboot.",
+		Example: "  chirp config set lora.region=US lora.hop_limit=4 telemetry.device_update_interval=900",
+		Args:    wrapPositionalArgs(cobra.MinimumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			assignments := make([]appnode.ConfigAssignment, len(args))
+			for i, arg := range args {
+				a, err := appnode.ParseConfigAssignment(arg)
+				if err != nil {
+					return mapServiceError(err)
+				}
+				assignments[i] = a
+			}
+			if err := appnode.ValidateConfigAssignments(assignments); err != nil {
+				return mapServiceError(err)
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				changes, err := newService(cliCtx, r).SetConfig(ctx, assignments)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{"changes": changes})
+				}
+				rows := make([]keyValueRow, len(changes))
+				for i, c := range changes {
+					rows[i] = keyValueRow{Key: c.Path, Value: c.Old + " -> " + c.New}
+				}
+				return printKeyValueTable(cmd.OutOrStdout(), rows)
+			}))
+		},
+	}
+}
+
+// configJSON marshals sections with proto field names, including unset
+// fields, so keys match the paths config set accepts.
+var configJSON = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+func writeConfigSections(out io.Writer, sections []appnode.ConfigSection) error {
+	for i, section := range sections {
+		if i > 0 {
+			if _, err := fmt.Fprintln(out); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(out, "[%s]\n", section.Name); err != nil {
+			return err
+		}
+
+		fields := appnode.FlattenConfig(section.Message)
+		rows := make([]keyValueRow, len(fields))
+		for j, f := range fields {
+			rows[j] = keyValueRow{Key: f.Path, Value: f.Value}
+		}
+		if err := printKeyValueTable(out, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func configSectionsMap(sections []appnode.ConfigSection) (map[string]json.RawMessage, error) {
+	out := make(map[string]json.RawMessage, len(sections))
+	for _, section := range sections {
+		b, err := configJSON.Marshal(section.Message)
+		if err != nil {
+			return nil, fmt.Errorf("marshal %s config: %w", section.Name, err)
+		}
+		out[section.Name] = b
+	}
+	return out, nil
+}
+
+func writeConfigSectionsJSON(out io.Writer, sections []appnode.ConfigSection) error {
+	m, err := configSectionsMap(sections)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(out).Encode(m)
+}
+
+func writeConfigSectionsYAML(out io.Writer, sections []appnode.ConfigSection) error {
+	m, err := configSectionsMap(sections)
+	if err != nil {
+		return err
+	}
+	return writeYAML(out, m)
+}
+
+// writeYAML converts v through JSON, so protojson output keeps its field names
+// and value formats, and writes it as YAML.
+func writeYAML(out io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var generic any
+	if err := yaml.Unmarshal(b, &generic); err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(generic); err != nil {
+		return err
+	}
+	return enc.Close()
+}
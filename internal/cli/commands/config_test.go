@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runSimCommand runs the root command with args against the built-in
+// simulated node and returns what it printed.
+func runSimCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	cmd := newRootCommand()
+	cmd.SetArgs(append(args, "--port", "sim://", "--timeout", "5s"))
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestConfigGetSectionTable(t *testing.T) {
+	out, err := runSimCommand(t, "config", "get", "lora")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out, "[lora]\n") {
+		t.Fatalf("missing section header:\n%s", out)
+	}
+	for _, want := range []string{"region ", " US\n", "modem_preset ", " LONG_FAST\n", "hop_limit "} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConfigGetAllJSONAndYAML(t *testing.T) {
+	out, err := runSimCommand(t, "config", "get", "--json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var sections map[string]map[string]any
+	if err := json.Unmarshal([]byte(out), &sections); err != nil {
+		t.Fatalf("unmarshal output: %v, output=%q", err, out)
+	}
+	if sections["lora"]["region"] != "US" || sections["mqtt"] == nil || sections["device"] == nil {
+		t.Fatalf("unexpected sections: %v", sections)
+	}
+
+	out, err = runSimCommand(t, "config", "get", "lora", "--yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var doc map[string]map[string]any
+	if err := yaml.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("unmarshal YAML: %v, output=%q", err, out)
+	}
+	if doc["lora"]["modem_preset"] != "LONG_FAST" {
+		t.Fatalf("unexpected YAML:\n%s", out)
+	}
+}
+
+func TestConfigGetRejectsUnknownSection(t *testing.T) {
+	_, err := runSimCommand(t, "config", "get", "warp_drive")
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), `unknown config section "warp_drive"`) {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
+
+func TestConfigSetPrintsChanges(t *testing.T) {
+	out, err := runSimCommand(t, "config", "set", "lora.region=EU_868", "lora.hop_limit=4", "telemetry.device_update_interval=900")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"lora.region ", "US -> EU_868\n", "3 -> 4\n", "telemetry.device_update_interval ", "0 -> 900\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConfigSetRejectsBadValueBeforeConnecting(t *testing.T) {
+	cmd := newRootCommand()
+	cmd.SetArgs([]string{"config", "set", "lora.region=MARS", "--port", "/dev/does-not-exist"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), `lora.region: unknown value "MARS"`) {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
+
+func TestConfigSetOnRemoteNode(t *testing.T) {
+	out, err := runSimCommand(t, "config", "set", "lora.hop_limit=5", "--dest", "!0badcafe")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "lora.hop_limit ") || !strings.Contains(out, "3 -> 5\n") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestDestRejectedWhereUnsupported(t *testing.T) {
+	for _, args := range [][]string{
+		{"info", "--dest", "!0badcafe"},
+		{"config", "get", "lora", "--dest", "nobody"},
+		{"channel", "decode", "https://meshtastic.org/e/#CgMSAQESBggBOANAAw", "--dest", "!0badcafe"},
+		{"ringtone", "parse", "beep:d=4,o=5,b=100:c", "--dest", "!0badcafe"},
+	} {
+		cmd := newRootCommand()
+		cmd.SetArgs(append(args, "--port", "/dev/does-not-exist"))
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+
+		err := cmd.Execute()
+		if ExitCode(err) != 2 || !strings.Contains(err.Error(), "--dest") {
+			t.Fatalf("%v: expected user input error naming --dest, got %v", args, err)
+		}
+	}
+}
@@ -1,16 +1,46 @@
 package commands
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio"
+)
 
 const (
 	defaultPort    = "/dev/cu.usbmodem101"
 	defaultTimeout = 2 * time.Second
+	// defaultDestTimeout replaces the default --timeout with --dest, since
+	// every admin exchange then crosses the mesh, possibly over several hops.
+	defaultDestTimeout = 30 * time.Second
+	defaultReplaySpeed = 1.0
+	replayScheme       = "replay://"
 )
 
 // Context contains process-wide CLI settings resolved from persistent flags.
 type Context struct {
-	Port    string
-	Timeout time.Duration
-	JSON    bool
-	Verbose bool
+	Port        string
+	Host        string
+	Replay      string
+	ReplaySpeed float64
+	Timeout     time.Duration
+	JSON        bool
+	Verbose     bool
+	// Dest is the remote node admin commands address, such as !a1b2c3d4;
+	// empty means the connected node.
+	Dest string
+
+	destNum uint32
+}
+
+// endpoint returns the radio target commands should open: the capture when
+// --replay is set, a tcp:// address when --host is set, otherwise the serial port.
+func (c *Context) endpoint() string {
+	if replay := strings.TrimSpace(c.Replay); replay != "" {
+		return replayScheme + replay
+	}
+	if host := strings.TrimSpace(c.Host); host != "" {
+		return radio.TCPScheme + host
+	}
+	return c.Port
 }
@@ -8,17 +8,39 @@ import (
 	"strings"
 
 	appnode "github.com/coreyvan/chirp/internal/app/node"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 	"github.com/spf13/cobra"
 )
 
 func newFactoryResetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
-	var yes bool
+	var (
+		yes            bool
+		backup         bool
+		backupLocation string
+		backupFile     string
+	)
 
 	cmd := &cobra.Command{
 		Use:   "factory-reset",
 		Short: "Factory reset the radio",
-		Args:  wrapPositionalArgs(cobra.NoArgs),
+		Long: "Factory reset the radio. --backup first has the node back up its preferences\n" +
+			"to --backup-location, which a reset leaves in place, so `chirp backup restore`\n" +
+			"can bring them back. --backup-file first saves them to a local file as\n" +
+			"`chirp backup export` does. Either backup failing stops the reset.",
+		Example:     "  chirp factory-reset --backup --backup-file node.backup",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
 		RunE: func(cmd *cobra.Command, _ []string) error {
+			var loc pb.AdminMessage_BackupLocation
+			if backup {
+				var err error
+				if loc, err = appnode.ParseBackupLocation(backupLocation); err != nil {
+					return mapServiceError(err)
+				}
+			}
+			if backupFile == "-" {
+				return newUserInputError(fmt.Errorf("--backup-file needs a file name, not -"))
+			}
 			if !yes {
 				confirmed, err := promptConfirm(cmd, "This action is destructive. Continue? [y/N] ")
 				if err != nil {
@@ -29,25 +51,60 @@ func newFactoryResetCommand(cliCtx *Context, opener radioOpener) *cobra.Command
 				}
 			}
 
-			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(_ context.Context, radio Radio) error {
-				service := appnode.NewService(radio)
-				if err := service.FactoryReset(cmd.Context()); err != nil {
+			// Exporting reads every section, so it is bounded per request
+			// rather than by one overall timeout.
+			run := runWithRadio
+			if backupFile != "" {
+				run = runWithRadioNoTimeout
+			}
+			return run(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(runCtx context.Context, radio Radio) error {
+				service := newService(cliCtx, radio)
+				if backupFile != "" {
+					if _, err := exportBackup(runCtx, cmd, service, backupFile); err != nil {
+						return err
+					}
+				}
+				if backup {
+					if err := service.BackupPreferences(runCtx, loc); err != nil {
+						return mapServiceError(err)
+					}
+				}
+				if err := service.FactoryReset(runCtx); err != nil {
 					return mapServiceError(err)
 				}
 
 				if cliCtx.JSON {
-					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{
-						"ok": true,
-					})
+					result := map[string]any{"ok": true}
+					if backup {
+						result["backup_location"] = backupLocation
+					}
+					if backupFile != "" {
+						result["backup_file"] = backupFile
+					}
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(result)
 				}
 
-				_, err := fmt.Fprintln(cmd.OutOrStdout(), "factory reset command sent")
+				out := cmd.OutOrStdout()
+				if backupFile != "" {
+					if _, err := fmt.Fprintf(out, "backup saved to %s\n", backupFile); err != nil {
+						return err
+					}
+				}
+				if backup {
+					if _, err := fmt.Fprintf(out, "backup created (%s)\n", backupLocation); err != nil {
+						return err
+					}
+				}
+				_, err := fmt.Fprintln(out, "factory reset command sent")
 				return err
 			}))
 		},
 	}
 
 	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
+	cmd.Flags().BoolVar(&backup, "backup", false, "have the node back up its preferences before the reset")
+	cmd.Flags().StringVar(&backupLocation, "backup-location", "flash", "where the node keeps the --backup: flash or sd")
+	cmd.Flags().StringVar(&backupFile, "backup-file", "", "save the node's preferences to this local file before the reset")
 	return cmd
 }
 
@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
+	"github.com/spf13/cobra"
+)
+
+func newFilesCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "files",
+		Short: "List, download, upload and delete files on the node",
+		Long: "List the files in the node's filesystem and move them over XModem. XModem\n" +
+			"only reaches the node chirp is connected to, so these commands take no --dest.",
+		Args: wrapPositionalArgs(cobra.NoArgs),
+	}
+
+	cmd.AddCommand(newFilesListCommand(cliCtx, opener))
+	cmd.AddCommand(newFilesGetCommand(cliCtx, opener))
+	cmd.AddCommand(newFilesPutCommand(cliCtx, opener))
+	cmd.AddCommand(newFilesRmCommand(cliCtx, opener))
+	return cmd
+}
+
+func newFilesListCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the files the node reports",
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				files, err := newService(cliCtx, r).ListFiles(ctx)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(files)
+				}
+				if len(files) == 0 {
+					_, err := fmt.Fprintln(cmd.OutOrStdout(), "the node reported no files")
+					return err
+				}
+				rows := make([][]string, len(files))
+				for i, f := range files {
+					rows[i] = []string{f.Name, strconv.FormatUint(uint64(f.Size), 10)}
+				}
+				return printTable(cmd.OutOrStdout(), []string{"NAME", "BYTES"}, rows)
+			}))
+		},
+	}
+}
+
+func newFilesGetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "get <name>",
+		Short: "Download a file from the node",
+		Long: "Download a file from the node over XModem into --output, by default a file\n" +
+			"of the same base name in the current directory; - writes to standard output.\n" +
+			"An existing file is only replaced once the download completes, and a new one\n" +
+			"is readable by you alone, as node files can hold keys. --timeout applies to\n" +
+			"each block. After a failed download the node cancels the next transfer, so\n" +
+			"that one has to be run again.",
+		Example: "  chirp files get /prefs/config.proto\n" +
+			"  chirp files get /static/welcome.txt --output -",
+		Args: wrapPositionalArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := appnode.ValidateFileName(name); err != nil {
+				return mapServiceError(err)
+			}
+			if output == "" {
+				output = path.Base(name)
+				if output == "/" || output == "." || output == ".." {
+					return newUserInputError(fmt.Errorf("%s has no file name to save to; give --output", name))
+				}
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				service := newService(cliCtx, r)
+				size, err := manifestSize(ctx, service, name)
+				if err != nil {
+					return err
+				}
+
+				var buf bytes.Buffer
+				progress, done := transferProgress(cmd, cliCtx, name, size)
+				n, err := service.GetFile(ctx, name, &buf, progress)
+				done()
+				if err != nil {
+					return mapServiceError(err)
+				}
+				if output == "-" {
+					_, err := cmd.OutOrStdout().Write(buf.Bytes())
+					return err
+				}
+				if err := replaceFile(output, buf.Bytes()); err != nil {
+					return newRuntimeError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{
+						"ok":    true,
+						"name":  name,
+						"file":  output,
+						"bytes": n,
+					})
+				}
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "saved %s to %s (%d bytes)\n", name, output, n)
+				return err
+			}))
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write, or - for standard output")
+	return cmd
+}
+
+func newFilesPutCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	return &cobra.Command{
+		Use:   "put <file> <name>",
+		Short: "Upload a file to the node",
+		Long: "Upload file, or standard input when file is -, to the node over XModem as the\n" +
+			"absolute path name, replacing any file already there. A failed upload is\n" +
+			"cancelled, which makes the node delete the partial file. --timeout applies\n" +
+			"to each block.",
+		Example: "  chirp files put ringtones.txt /static/ringtones.txt",
+		Args:    wrapPositionalArgs(cobra.ExactArgs(2)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, name := args[0], args[1]
+			if err := appnode.ValidateFileName(name); err != nil {
+				return mapServiceError(err)
+			}
+			data, err := readInputFile(cmd, file)
+			if err != nil {
+				return newUserInputError(fmt.Errorf("read %s: %w", file, err))
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				progress, done := transferProgress(cmd, cliCtx, name, int64(len(data)))
+				n, err := newService(cliCtx, r).PutFile(ctx, name, bytes.NewReader(data), progress)
+				done()
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{
+						"ok":    true,
+						"name":  name,
+						"bytes": n,
+					})
+				}
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "stored %s (%d bytes)\n", name, n)
+				return err
+			}))
+		},
+	}
+}
+
+func newFilesRmCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Delete a file from the node",
+		Args:  wrapPositionalArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := appnode.ValidateFileName(name); err != nil {
+				return mapServiceError(err)
+			}
+			if err := confirmOrCancel(cmd, yes, fmt.Sprintf("Delete %s from the node? [y/N] ", name), "rm cancelled"); err != nil {
+				return err
+			}
+
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				if err := newService(cliCtx, r).DeleteFile(ctx, name); err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{"ok": true, "name": name})
+				}
+				_, err := fmt.Fprintf(cmd.OutOrStdout(), "deleted %s\n", name)
+				return err
+			}))
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
+	return cmd
+}
+
+// manifestSize looks name up in the node's file manifest, so a missing file
+// is reported before a transfer starts. It returns zero when the node sends no
+// manifest at all.
+func manifestSize(ctx context.Context, service *appnode.Service, name string) (int64, error) {
+	files, err := service.ListFiles(ctx)
+	if err != nil {
+		return 0, mapServiceError(err)
+	}
+	for _, f := range files {
+		if f.Name == name {
+			return int64(f.Size), nil
+		}
+	}
+	if len(files) > 0 {
+		return 0, newRuntimeError(fmt.Errorf("the node has no file %s; see chirp files list", name))
+	}
+	return 0, nil
+}
+
+// transferProgress returns a progress callback that rewrites one line on
+// stderr, and a function that ends the line. Nothing is printed with --json.
+func transferProgress(cmd *cobra.Command, cliCtx *Context, name string, total int64) (func(xmodem.Progress), func()) {
+	if cliCtx.JSON {
+		return nil, func() {}
+	}
+
+	out := cmd.ErrOrStderr()
+	printed := false
+	progress := func(p xmodem.Progress) {
+		printed = true
+		line := fmt.Sprintf("%s: %d bytes", name, p.Bytes)
+		if total > 0 {
+			line = fmt.Sprintf("%s: %d/%d bytes (%d%%)", name, p.Bytes, total, p.Bytes*100/total)
+		}
+		if p.Retries > 0 {
+			line += fmt.Sprintf(", %d retries", p.Retries)
+		}
+		fmt.Fprintf(out, "\r%s", line)
+	}
+	done := func() {
+		if printed {
+			fmt.Fprintln(out)
+		}
+	}
+	return progress, done
+}
+
+// replaceFile writes data to a temporary file next to path and renames it
+// into place, so path is never left half written.
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilesListAgainstSimulatedNode(t *testing.T) {
+	out, err := runSimCommand(t, "files", "list")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "/static/welcome.txt  32\n") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestFilesGetWritesOutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "welcome.txt")
+	out, err := runSimCommand(t, "files", "get", "/static/welcome.txt", "--output", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "saved /static/welcome.txt to "+path+" (32 bytes)\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "Welcome to the chirp simulator.\n" {
+		t.Fatalf("file = %q, %v", data, err)
+	}
+}
+
+func TestFilesGetReportsMissingFileBeforeTransfer(t *testing.T) {
+	_, err := runSimCommand(t, "files", "get", "/missing.txt", "--output", filepath.Join(t.TempDir(), "x"))
+	if ExitCode(err) != 1 || !strings.Contains(err.Error(), "no file /missing.txt") {
+		t.Fatalf("expected runtime error, got %v", err)
+	}
+}
+
+func TestFilesPutAgainstSimulatedNode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("note "), 60), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runSimCommand(t, "files", "put", path, "/notes.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "stored /notes.txt (300 bytes)\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestFilesRejectRelativeNameBeforeConnecting(t *testing.T) {
+	cmd := newFilesCommand(&Context{Port: "/dev/test", Timeout: time.Second}, func(string) (Radio, error) {
+		t.Fatalf("opener should not be called for invalid args")
+		return nil, nil
+	})
+	cmd.SetArgs([]string{"rm", "prefs/config.proto", "--yes"})
+	cmd.SilenceUsage = true
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), "absolute path") {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
+
+func TestFilesGetNeedsOutputWithoutBaseName(t *testing.T) {
+	for _, name := range []string{"/", "/prefs/.."} {
+		cmd := newFilesCommand(&Context{Port: "/dev/test", Timeout: time.Second}, func(string) (Radio, error) {
+			t.Fatalf("opener should not be called for invalid args")
+			return nil, nil
+		})
+		cmd.SetArgs([]string{"get", name})
+		cmd.SilenceUsage = true
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+
+		err := cmd.Execute()
+		if ExitCode(err) != 2 || !strings.Contains(err.Error(), "give --output") {
+			t.Fatalf("%s: expected user input error, got %v", name, err)
+		}
+	}
+}
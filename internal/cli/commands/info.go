@@ -8,26 +8,39 @@ import (
 	"strconv"
 
 	appnode "github.com/coreyvan/chirp/internal/app/node"
-	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/coreyvan/chirp/pkg/radio"
 	"github.com/spf13/cobra"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
 func newInfoCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
-	return &cobra.Command{
+	var configOnly, nodesOnly bool
+
+	cmd := &cobra.Command{
 		Use:   "info",
 		Short: "Fetch and print radio info",
 		Args:  wrapPositionalArgs(cobra.NoArgs),
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(_ context.Context, radio Radio) error {
-				service := appnode.NewService(radio)
-				result, err := service.Info(cmd.Context())
+			if configOnly && nodesOnly {
+				return newUserInputError(fmt.Errorf("--config-only and --nodes-only cannot be combined"))
+			}
+			mode := radio.HandshakeFull
+			switch {
+			case configOnly:
+				mode = radio.HandshakeConfigOnly
+			case nodesOnly:
+				mode = radio.HandshakeNodesOnly
+			}
+
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(runCtx context.Context, r Radio) error {
+				service := appnode.NewService(r)
+				result, err := service.InfoMode(runCtx, mode)
 				if err != nil {
 					return mapServiceError(err)
 				}
 
 				if cliCtx.JSON {
-					return writeInfoJSON(result.Summary, result.Responses, cmd.OutOrStdout())
+					return writeInfoJSON(result, cmd.OutOrStdout())
 				}
 
 				out := cmd.OutOrStdout()
@@ -48,11 +61,16 @@ func newInfoCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
 			}))
 		},
 	}
+
+	cmd.Flags().BoolVar(&configOnly, "config-only", false, "fetch the node's own config and channels without the node DB")
+	cmd.Flags().BoolVar(&nodesOnly, "nodes-only", false, "fetch the node DB without config")
+
+	return cmd
 }
 
-func writeInfoJSON(summary appnode.InfoSummary, responses []*pb.FromRadio, out io.Writer) error {
-	items := make([]json.RawMessage, 0, len(responses))
-	for _, fr := range responses {
+func writeInfoJSON(result appnode.InfoResult, out io.Writer) error {
+	items := make([]json.RawMessage, 0, len(result.Responses))
+	for _, fr := range result.Responses {
 		b, err := protojson.Marshal(fr)
 		if err != nil {
 			return fmt.Errorf("marshal radio info response: %w", err)
@@ -61,7 +79,8 @@ func writeInfoJSON(summary appnode.InfoSummary, responses []*pb.FromRadio, out i
 	}
 
 	return json.NewEncoder(out).Encode(map[string]any{
-		"summary":   summary,
+		"summary":   result.Summary,
+		"snapshot":  result.Snapshot,
 		"responses": items,
 	})
 }
@@ -3,11 +3,17 @@ package commands
 import (
 	"bytes"
 	"encoding/json"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/coreyvan/chirp/pkg/radio/capture"
+	"github.com/coreyvan/chirp/pkg/radio/frame"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestBuildInfoSummary(t *testing.T) {
@@ -40,13 +46,17 @@ func TestBuildInfoSummary(t *testing.T) {
 }
 
 func TestWriteInfoJSONIncludesSummaryAndResponses(t *testing.T) {
-	summary := appnode.InfoSummary{Responses: 1, MyNode: "!00000001"}
 	responses := []*pb.FromRadio{
 		{PayloadVariant: &pb.FromRadio_Rebooted{Rebooted: true}},
 	}
+	result := appnode.InfoResult{
+		Summary:   appnode.InfoSummary{Responses: 1, MyNode: "!00000001"},
+		Snapshot:  appnode.BuildSnapshot(responses),
+		Responses: responses,
+	}
 
 	var out bytes.Buffer
-	if err := writeInfoJSON(summary, responses, &out); err != nil {
+	if err := writeInfoJSON(result, &out); err != nil {
 		t.Fatalf("writeInfoJSON() error = %v", err)
 	}
 
@@ -60,6 +70,73 @@ func TestWriteInfoJSONIncludesSummaryAndResponses(t *testing.T) {
 	if _, ok := got["responses"]; !ok {
 		t.Fatalf("missing responses field in JSON output: %s", out.String())
 	}
+	if _, ok := got["snapshot"]; !ok {
+		t.Fatalf("missing snapshot field in JSON output: %s", out.String())
+	}
+}
+
+func TestInfoReplaysCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.chirpcap")
+	w, err := capture.Create(path)
+	if err != nil {
+		t.Fatalf("create capture: %v", err)
+	}
+	// The capture's handshake used nonce 42; the replay answers whatever
+	// nonce info sends.
+	for _, rec := range []struct {
+		dir capture.Direction
+		msg proto.Message
+	}{
+		{capture.ToRadio, &pb.ToRadio{PayloadVariant: &pb.ToRadio_WantConfigId{WantConfigId: 42}}},
+		{capture.FromRadio, &pb.FromRadio{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 0x16c3f424}}}},
+		{capture.FromRadio, &pb.FromRadio{PayloadVariant: &pb.FromRadio_NodeInfo{NodeInfo: &pb.NodeInfo{Num: 7}}}},
+		{capture.FromRadio, &pb.FromRadio{PayloadVariant: &pb.FromRadio_ConfigCompleteId{ConfigCompleteId: 42}}},
+	} {
+		payload, err := proto.Marshal(rec.msg)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		raw, err := frame.Encode(payload)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if err := w.RecordFrame(rec.dir, raw); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close capture: %v", err)
+	}
+
+	cliCtx := &Context{Port: "/dev/test", Replay: path, Timeout: 5 * time.Second}
+	cmd := newInfoCommand(cliCtx, nil)
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := out.String()
+	if !strings.Contains(got, "!16c3f424") {
+		t.Fatalf("missing replayed node: %q", got)
+	}
+	if !strings.Contains(got, "nodes           1") {
+		t.Fatalf("missing replayed node count: %q", got)
+	}
+}
+
+func TestValidateContextRejectsBadReplayFlags(t *testing.T) {
+	err := validateContext(&Context{Port: "/dev/test", Timeout: time.Second, ReplaySpeed: -1})
+	if err == nil || ExitCode(err) != 2 || !strings.Contains(err.Error(), "--replay-speed") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = validateContext(&Context{Port: "/dev/test", Timeout: time.Second, Replay: "a.chirpcap", Host: "node"})
+	if err == nil || ExitCode(err) != 2 || !strings.Contains(err.Error(), "--replay cannot be combined") {
+		t.Fatalf("unexpected error: %v", err)
+	}
 }
 
 func TestPrintKeyValueTable(t *testing.T) {
@@ -76,3 +153,60 @@ func TestPrintKeyValueTable(t *testing.T) {
 		t.Fatalf("unexpected table output:\n%s", got)
 	}
 }
+
+func TestInfoAgainstSimulatedNode(t *testing.T) {
+	cliCtx := &Context{Port: "sim://", Timeout: 5 * time.Second}
+	cmd := newInfoCommand(cliCtx, nil)
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := out.String()
+	if !strings.Contains(got, "!5ca1ab1e") {
+		t.Fatalf("missing simulated node: %q", got)
+	}
+	if !strings.Contains(got, "firmware        2.5.0.sim") {
+		t.Fatalf("missing simulated metadata: %q", got)
+	}
+}
+
+func TestInfoConfigOnlySkipsNodeDB(t *testing.T) {
+	r := &commandTestRadio{}
+	cmd := newInfoCommand(&Context{Port: "/dev/test", Timeout: time.Second}, func(string) (Radio, error) { return r, nil })
+	cmd.SetArgs([]string{"--config-only"})
+	cmd.SetOut(&bytes.Buffer{})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.infoMode != radio.HandshakeConfigOnly {
+		t.Fatalf("handshake mode = %v, want config-only", r.infoMode)
+	}
+
+	cmd = newInfoCommand(&Context{Port: "/dev/test", Timeout: time.Second}, func(string) (Radio, error) { return r, nil })
+	cmd.SetArgs([]string{"--config-only", "--nodes-only"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); ExitCode(err) != 2 {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
+
+func TestInfoSimulatedNodesOnly(t *testing.T) {
+	cmd := newInfoCommand(&Context{Port: "sim://", Timeout: 5 * time.Second}, nil)
+	cmd.SetArgs([]string{"--nodes-only"})
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := out.String()
+	if !strings.Contains(got, "nodes           3") || !strings.Contains(got, "configs         0") {
+		t.Fatalf("unexpected nodes-only summary: %q", got)
+	}
+}
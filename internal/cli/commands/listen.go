@@ -2,11 +2,14 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"time"
 
 	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/coreyvan/chirp/pkg/radio/capture"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 	"github.com/spf13/cobra"
 )
@@ -16,11 +19,19 @@ type listenOptions struct {
 	noTelemetry bool
 	noEvents    bool
 	noPackets   bool
+	record      string
+	noReconnect bool
+
+	// reopen, when set, lets runListen reconnect after the radio reboots or the
+	// stream dies; backoff paces its attempts.
+	reopen  radioOpener
+	backoff radio.Backoff
 }
 
 func newListenCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
 	opts := &listenOptions{
 		idleLog: 10 * time.Second,
+		backoff: radio.DefaultBackoff,
 	}
 
 	cmd := &cobra.Command{
@@ -32,8 +43,39 @@ func newListenCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
 				return newUserInputError(fmt.Errorf("--idle-log must be greater than 0"))
 			}
 
-			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(runCtx context.Context, radio Radio) error {
-				return runListen(runCtx, cmd.OutOrStdout(), radio, cliCtx.Port, opts)
+			open := opener
+			if open == nil {
+				open = newRadioOpener(cliCtx)
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(runCtx context.Context, r Radio) (err error) {
+				var w *capture.Writer
+				if opts.record != "" {
+					w, err = capture.Create(opts.record)
+					if err != nil {
+						return err
+					}
+					r.SetRecorder(w)
+					defer func() {
+						r.SetRecorder(nil)
+						if closeErr := w.Close(); closeErr != nil {
+							err = errors.Join(err, fmt.Errorf("close capture: %w", closeErr))
+						}
+					}()
+				}
+
+				// A replay cannot come back once it ends, so only live radios reconnect.
+				if !opts.noReconnect && cliCtx.Replay == "" {
+					opts.reopen = func(target string) (Radio, error) {
+						next, err := open(target)
+						if err == nil && w != nil {
+							next.SetRecorder(w)
+						}
+						return next, err
+					}
+				}
+
+				return runListen(runCtx, cmd.OutOrStdout(), r, cliCtx.endpoint(), opts)
 			}))
 		},
 	}
@@ -42,15 +84,17 @@ func newListenCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
 	cmd.Flags().BoolVar(&opts.noTelemetry, "no-telemetry", false, "suppress telemetry output")
 	cmd.Flags().BoolVar(&opts.noEvents, "no-events", false, "suppress event output")
 	cmd.Flags().BoolVar(&opts.noPackets, "no-packets", false, "suppress packet output")
+	cmd.Flags().StringVar(&opts.record, "record", "", "store raw frames in both directions to a .chirpcap file")
+	cmd.Flags().BoolVar(&opts.noReconnect, "no-reconnect", false, "exit when the radio disconnects or reboots instead of reconnecting")
 
 	return cmd
 }
 
-func runListen(ctx context.Context, out io.Writer, radio Radio, port string, opts *listenOptions) error {
+func runListen(ctx context.Context, out io.Writer, r Radio, port string, opts *listenOptions) error {
 	_, _ = fmt.Fprintf(out, "rx listener started on %s\n", port)
 
 	// Prime the device so nodes that stay quiet until polled begin streaming updates.
-	if responses, err := radio.GetRadioInfo(); err != nil {
+	if responses, err := r.GetRadioInfo(ctx); err != nil {
 		_, _ = fmt.Fprintf(out, "[ERR] get radio info: %v\n", err)
 	} else {
 		for _, fr := range responses {
@@ -58,16 +102,50 @@ func runListen(ctx context.Context, out io.Writer, radio Radio, port string, opt
 		}
 	}
 
+	var sup *radio.Supervisor[Radio]
+	if opts.reopen != nil {
+		sup = radio.NewSupervisor(port, r, func(target string) (Radio, error) { return opts.reopen(target) })
+		sup.Backoff = opts.backoff
+		sup.OnEvent = func(ev radio.ConnEvent) {
+			writeStreamLines(out, []appnode.StreamLine{appnode.RenderConnEvent(ev)}, opts)
+		}
+		// The original radio is closed by the caller; close whichever one replaced it.
+		defer func() { _ = sup.Close() }()
+	}
+
+	// reconnect swaps r for a freshly handshaken radio. It returns false once
+	// ctx is done.
+	reconnect := func(cause error) bool {
+		next, responses, err := sup.Reconnect(ctx, cause)
+		if err != nil {
+			return false
+		}
+		r = next
+		for _, fr := range responses {
+			logFromRadio(out, fr, opts)
+		}
+		return true
+	}
+
 	lastIdleLog := time.Now()
+	var lastStats radio.Stats
 
 	for {
-		select {
-		case <-ctx.Done():
+		fromRadioPackets, err := r.ReadResponse(ctx, true)
+		if ctx.Err() != nil {
 			return nil
-		default:
 		}
-
-		fromRadioPackets, err := radio.ReadResponse(true)
+		if errors.Is(err, radio.ErrClosed) {
+			if sup == nil {
+				_, _ = fmt.Fprintln(out, "[EVT] stream ended")
+				return nil
+			}
+			if !reconnect(err) {
+				return nil
+			}
+			lastStats = radio.Stats{}
+			continue
+		}
 		if err != nil {
 			_, _ = fmt.Fprintf(out, "[ERR] read response: %v\n", err)
 			select {
@@ -78,6 +156,11 @@ func runListen(ctx context.Context, out io.Writer, radio Radio, port string, opt
 			continue
 		}
 
+		if stats := r.Stats(); stats.Errors() != lastStats.Errors() {
+			_, _ = fmt.Fprintf(out, "[ERR] framing %s\n", stats)
+			lastStats = stats
+		}
+
 		if len(fromRadioPackets) == 0 {
 			if time.Since(lastIdleLog) >= opts.idleLog {
 				_, _ = fmt.Fprintln(out, "[IDLE] no packets")
@@ -86,8 +169,16 @@ func runListen(ctx context.Context, out io.Writer, radio Radio, port string, opt
 			continue
 		}
 
+		rebooted := false
 		for _, fr := range fromRadioPackets {
 			logFromRadio(out, fr, opts)
+			rebooted = rebooted || fr.GetRebooted()
+		}
+		if rebooted && sup != nil {
+			if !reconnect(radio.ErrRebooted) {
+				return nil
+			}
+			lastStats = radio.Stats{}
 		}
 	}
 }
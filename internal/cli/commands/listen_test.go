@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/coreyvan/chirp/pkg/radio/capture"
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 	"google.golang.org/protobuf/proto"
 )
@@ -19,11 +24,15 @@ type listenTestRadio struct {
 	infoResults []*pb.FromRadio
 	infoErr     error
 	infoCalls   int
+	stats       []radio.Stats
+	recorder    *capture.Writer
 }
 
 func (f *listenTestRadio) Close() error { return nil }
 
-func (f *listenTestRadio) ReadResponse(bool) ([]*pb.FromRadio, error) {
+func (f *listenTestRadio) OpenInbox() {}
+
+func (f *listenTestRadio) ReadResponse(context.Context, bool) ([]*pb.FromRadio, error) {
 	i := f.readIndex
 	f.readIndex++
 
@@ -38,15 +47,46 @@ func (f *listenTestRadio) ReadResponse(bool) ([]*pb.FromRadio, error) {
 	return packets, err
 }
 
-func (f *listenTestRadio) GetRadioInfo() ([]*pb.FromRadio, error) {
+func (f *listenTestRadio) Stats() radio.Stats {
+	i := f.readIndex - 1
+	if i >= 0 && i < len(f.stats) {
+		return f.stats[i]
+	}
+	if len(f.stats) > 0 {
+		return f.stats[len(f.stats)-1]
+	}
+	return radio.Stats{}
+}
+
+func (f *listenTestRadio) SetRecorder(w *capture.Writer) { f.recorder = w }
+
+func (f *listenTestRadio) GetRadioInfo(context.Context) ([]*pb.FromRadio, error) {
 	f.infoCalls++
 	return f.infoResults, f.infoErr
 }
-func (f *listenTestRadio) SendTextMessage(string, int64, int64) error { return nil }
-func (f *listenTestRadio) SetRadioOwner(string) error                 { return nil }
-func (f *listenTestRadio) SetModemMode(string) error                  { return nil }
-func (f *listenTestRadio) SetLocation(int32, int32, int32) error      { return nil }
-func (f *listenTestRadio) FactoryReset() error                        { return nil }
+func (f *listenTestRadio) Handshake(ctx context.Context, _ radio.HandshakeMode) ([]*pb.FromRadio, error) {
+	return f.GetRadioInfo(ctx)
+}
+func (f *listenTestRadio) SendTextMessage(context.Context, string, int64, int64) error { return nil }
+func (f *listenTestRadio) SendTextMessageAck(context.Context, string, int64, int64) (radio.Ack, error) {
+	return radio.Ack{}, nil
+}
+func (f *listenTestRadio) SetModemMode(context.Context, string) error             { return nil }
+func (f *listenTestRadio) SetLocation(context.Context, int32, int32, int32) error { return nil }
+func (f *listenTestRadio) FactoryReset(context.Context) error                     { return nil }
+func (f *listenTestRadio) Traceroute(context.Context, uint32, uint32) (radio.TracerouteReply, error) {
+	return radio.TracerouteReply{}, nil
+}
+func (f *listenTestRadio) RequestAdmin(context.Context, uint32, *pb.AdminMessage) (*pb.AdminMessage, error) {
+	return &pb.AdminMessage{}, nil
+}
+func (f *listenTestRadio) SendAdmin(context.Context, uint32, *pb.AdminMessage) error { return nil }
+func (f *listenTestRadio) GetFile(context.Context, string, io.Writer, xmodem.Options) (int64, error) {
+	return 0, nil
+}
+func (f *listenTestRadio) PutFile(context.Context, string, io.Reader, xmodem.Options) (int64, error) {
+	return 0, nil
+}
 
 func TestListenCommandRejectsNonPositiveIdleLog(t *testing.T) {
 	cliCtx := &Context{Port: "/dev/test", Timeout: time.Second}
@@ -296,6 +336,75 @@ func TestRunListenEmitsIdle(t *testing.T) {
 	}
 }
 
+func TestRunListenSurfacesFramingErrors(t *testing.T) {
+	r := &listenTestRadio{
+		readResults: [][]*pb.FromRadio{{}, {}, {}},
+		stats: []radio.Stats{
+			{},
+			{DecodeErrors: 1},
+			{DecodeErrors: 1},
+		},
+	}
+	opts := &listenOptions{idleLog: time.Hour}
+
+	var out bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	if err := runListen(ctx, &out, r, "/dev/test", opts); err != nil {
+		t.Fatalf("runListen() error = %v", err)
+	}
+
+	logs := out.String()
+	if strings.Count(logs, "[ERR] framing") != 1 {
+		t.Fatalf("expected exactly one framing line:\n%s", logs)
+	}
+	if !strings.Contains(logs, "decode_errors=1") {
+		t.Fatalf("missing decode error count:\n%s", logs)
+	}
+}
+
+func TestRunListenEndsWhenStreamCloses(t *testing.T) {
+	r := &listenTestRadio{
+		readErrors: []error{radio.ErrClosed},
+	}
+	opts := &listenOptions{idleLog: time.Hour}
+
+	var out bytes.Buffer
+	if err := runListen(context.Background(), &out, r, "replay://session.chirpcap", opts); err != nil {
+		t.Fatalf("runListen() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "[EVT] stream ended") {
+		t.Fatalf("missing stream end log:\n%s", out.String())
+	}
+}
+
+func TestListenCommandRecordsCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.chirpcap")
+	cliCtx := &Context{Port: "/dev/test", Timeout: time.Second}
+	r := &listenTestRadio{readErrors: []error{radio.ErrClosed}}
+	cmd := newListenCommand(cliCtx, func(string) (Radio, error) { return r, nil })
+	cmd.SetArgs([]string{"--record", path, "--no-reconnect"})
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.recorder != nil {
+		t.Fatalf("recorder should be detached after listen exits")
+	}
+	records, err := capture.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read capture: %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("records = %d, want 0", len(records))
+	}
+}
+
 func TestRunListenLogsGetRadioInfoError(t *testing.T) {
 	r := &listenTestRadio{
 		infoErr: errors.New("info fail"),
@@ -316,3 +425,121 @@ func TestRunListenLogsGetRadioInfoError(t *testing.T) {
 		t.Fatalf("missing radio info error log:\n%s", logs)
 	}
 }
+
+func TestRunListenReconnectsAfterStreamCloses(t *testing.T) {
+	first := &listenTestRadio{readErrors: []error{radio.ErrClosed}}
+	second := &listenTestRadio{
+		infoResults: []*pb.FromRadio{{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 0x5ca1ab1e}}}},
+		readResults: [][]*pb.FromRadio{{{PayloadVariant: &pb.FromRadio_LogRecord{LogRecord: &pb.LogRecord{Message: "after"}}}}},
+	}
+	opens := 0
+	opts := &listenOptions{
+		idleLog: time.Hour,
+		backoff: radio.Backoff{Initial: time.Millisecond, Max: time.Millisecond},
+		reopen: func(target string) (Radio, error) {
+			opens++
+			if opens == 1 {
+				return nil, errors.New("device not configured")
+			}
+			return second, nil
+		},
+	}
+
+	var out bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := runListen(ctx, &out, first, "/dev/test", opts); err != nil {
+		t.Fatalf("runListen() error = %v", err)
+	}
+
+	logs := out.String()
+	for _, want := range []string{
+		"[EVT] connection to /dev/test lost: radio connection closed",
+		"[ERR] reconnect attempt 1 failed: device not configured; retrying in 1ms",
+		"[EVT] reconnected on /dev/test after 2 attempt(s)",
+		"[EVT] my_info node_num=!5ca1ab1e",
+		`msg="after"`,
+	} {
+		if !strings.Contains(logs, want) {
+			t.Fatalf("missing %q:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs, "stream ended") {
+		t.Fatalf("listener should keep running after reconnect:\n%s", logs)
+	}
+}
+
+// unpluggedStreamer fails every read the way a serial port does once its USB
+// device has gone away.
+type unpluggedStreamer struct{}
+
+func (unpluggedStreamer) Read([]byte) (int, error) {
+	return 0, errors.New("read /dev/ttyUSB0: input/output error")
+}
+func (unpluggedStreamer) Write(p []byte) (int, error)        { return len(p), nil }
+func (unpluggedStreamer) Close() error                       { return nil }
+func (unpluggedStreamer) SetReadTimeout(time.Duration) error { return nil }
+
+func TestRunListenReconnectsAfterReadError(t *testing.T) {
+	first := radio.NewRadioFromStreamer(unpluggedStreamer{})
+	defer first.Close()
+	second := &listenTestRadio{
+		readResults: [][]*pb.FromRadio{{{PayloadVariant: &pb.FromRadio_LogRecord{LogRecord: &pb.LogRecord{Message: "after"}}}}},
+	}
+	opts := &listenOptions{
+		idleLog: time.Hour,
+		backoff: radio.Backoff{Initial: time.Millisecond, Max: time.Millisecond},
+		reopen:  func(string) (Radio, error) { return second, nil },
+	}
+
+	var out bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := runListen(ctx, &out, first, "/dev/ttyUSB0", opts); err != nil {
+		t.Fatalf("runListen() error = %v", err)
+	}
+
+	logs := out.String()
+	for _, want := range []string{
+		"[EVT] connection to /dev/ttyUSB0 lost: radio connection closed: read /dev/ttyUSB0: input/output error",
+		"[EVT] reconnected on /dev/ttyUSB0 after 1 attempt(s)",
+		`msg="after"`,
+	} {
+		if !strings.Contains(logs, want) {
+			t.Fatalf("missing %q:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs, "[ERR] read response") {
+		t.Fatalf("read error should reconnect, not be retried:\n%s", logs)
+	}
+}
+
+func TestRunListenRehandshakesAfterReboot(t *testing.T) {
+	r := &listenTestRadio{
+		readResults: [][]*pb.FromRadio{{{PayloadVariant: &pb.FromRadio_Rebooted{Rebooted: true}}}},
+	}
+	opts := &listenOptions{
+		idleLog: time.Hour,
+		backoff: radio.DefaultBackoff,
+		reopen: func(string) (Radio, error) {
+			t.Fatalf("radio that survived the reboot should not be reopened")
+			return nil, nil
+		},
+	}
+
+	var out bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := runListen(ctx, &out, r, "tcp://node", opts); err != nil {
+		t.Fatalf("runListen() error = %v", err)
+	}
+	if r.infoCalls != 2 {
+		t.Fatalf("GetRadioInfo() calls = %d, want 2", r.infoCalls)
+	}
+	if !strings.Contains(out.String(), "[EVT] reconnected on tcp://node after 1 attempt(s)") {
+		t.Fatalf("missing reconnect event:\n%s", out.String())
+	}
+}
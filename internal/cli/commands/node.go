@@ -0,0 +1,185 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/spf13/cobra"
+)
+
+func newNodeCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "node",
+		Short: "Curate the node's NodeDB",
+		Long: "Favorite, ignore, mute or remove entries of the node's NodeDB, or clear it.\n" +
+			"Nodes are given as arguments, with --file, or both; see \"chirp nodes\" for\n" +
+			"their IDs.",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+	}
+
+	cmd.AddCommand(newNodeActionCommand(cliCtx, opener, appnode.NodeFavorite, "favorited",
+		"Favorite nodes, so the NodeDB never drops them"))
+	cmd.AddCommand(newNodeActionCommand(cliCtx, opener, appnode.NodeUnfavorite, "unfavorited",
+		"Remove nodes from the favorites"))
+	cmd.AddCommand(newNodeActionCommand(cliCtx, opener, appnode.NodeIgnore, "ignored",
+		"Ignore nodes, dropping every packet they send"))
+	cmd.AddCommand(newNodeActionCommand(cliCtx, opener, appnode.NodeUnignore, "unignored",
+		"Stop ignoring nodes"))
+	cmd.AddCommand(newNodeActionCommand(cliCtx, opener, appnode.NodeMute, "mute toggled",
+		"Toggle whether nodes are muted; muting a muted node unmutes it"))
+	cmd.AddCommand(newNodeActionCommand(cliCtx, opener, appnode.NodeRemove, "removed",
+		"Remove nodes from the NodeDB until they are heard again"))
+	cmd.AddCommand(newNodeResetDBCommand(cliCtx, opener))
+	return cmd
+}
+
+func newNodeActionCommand(cliCtx *Context, opener radioOpener, action appnode.NodeAction, done, short string) *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   string(action) + " [node...]",
+		Short: short,
+		Long: short + ".\n\n" +
+			"--file reads more node IDs, one per line; text after the ID and lines\n" +
+			"starting with # are ignored, and - reads standard input. A node that fails\n" +
+			"does not stop the rest.",
+		Example: fmt.Sprintf("  chirp node %[1]s !a1b2c3d4\n  chirp node %[1]s --file junk-nodes.txt --dest !0badcafe", action),
+		Args:    wrapPositionalArgs(cobra.ArbitraryArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nums, err := nodeArgs(cmd, args, file)
+			if err != nil {
+				return err
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				results, err := newService(cliCtx, r).ManageNodes(ctx, action, nums)
+				if err != nil {
+					return mapServiceError(err)
+				}
+				return writeNodeActionResults(cmd.OutOrStdout(), cliCtx.JSON, action, done, results)
+			}))
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "", "file of node IDs, one per line (- for stdin)")
+	return cmd
+}
+
+// nodeArgs parses the node IDs given as arguments and in file, dropping
+// repeats.
+func nodeArgs(cmd *cobra.Command, args []string, file string) ([]uint32, error) {
+	var nums []uint32
+	seen := make(map[uint32]bool)
+	add := func(num uint32) {
+		if !seen[num] {
+			seen[num] = true
+			nums = append(nums, num)
+		}
+	}
+
+	for _, arg := range args {
+		num, err := radio.ParseNodeID(arg)
+		if err != nil {
+			return nil, newUserInputError(err)
+		}
+		add(num)
+	}
+	if file != "" {
+		data, err := readInputFile(cmd, file)
+		if err != nil {
+			return nil, newUserInputError(err)
+		}
+		listed, err := appnode.ParseNodeList(bytes.NewReader(data))
+		if err != nil {
+			return nil, mapServiceError(fmt.Errorf("%s: %w", file, err))
+		}
+		for _, num := range listed {
+			add(num)
+		}
+	}
+	if len(nums) == 0 {
+		return nil, newUserInputError(fmt.Errorf("give at least one node ID, as an argument or with --file"))
+	}
+	return nums, nil
+}
+
+func writeNodeActionResults(out io.Writer, asJSON bool, action appnode.NodeAction, done string, results []appnode.NodeActionResult) error {
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	if asJSON {
+		if err := json.NewEncoder(out).Encode(map[string]any{
+			"ok":      failed == 0,
+			"action":  action,
+			"results": results,
+		}); err != nil {
+			return err
+		}
+	} else {
+		for _, result := range results {
+			status := done
+			if result.Error != "" {
+				status = "failed: " + result.Error
+			}
+			if _, err := fmt.Fprintf(out, "%s %s\n", result.Node, status); err != nil {
+				return err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return newRuntimeError(fmt.Errorf("%s failed for %d of %d nodes", action, failed, len(results)))
+	}
+	return nil
+}
+
+func newNodeResetDBCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var (
+		yes           bool
+		keepFavorites bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reset-db",
+		Short: "Clear the node's NodeDB",
+		Long: "Clear every node but the node itself from its NodeDB. --keep-favorites keeps\n" +
+			"favorited nodes. Nodes come back as they are heard again.",
+		Example: "  chirp node reset-db --keep-favorites --yes",
+		Args:    wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			prompt := "Clear the node's NodeDB? [y/N] "
+			if keepFavorites {
+				prompt = "Clear the node's NodeDB, keeping favorites? [y/N] "
+			}
+			if err := confirmOrCancel(cmd, yes, prompt, "reset-db cancelled"); err != nil {
+				return err
+			}
+
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				if err := newService(cliCtx, r).ResetNodeDB(ctx, keepFavorites); err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{"ok": true, "keep_favorites": keepFavorites})
+				}
+				_, err := fmt.Fprintln(cmd.OutOrStdout(), "node db reset")
+				return err
+			}))
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
+	cmd.Flags().BoolVar(&keepFavorites, "keep-favorites", false, "keep favorited nodes")
+	return cmd
+}
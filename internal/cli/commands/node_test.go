@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNodeFavoriteReadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.txt")
+	if err := os.WriteFile(path, []byte("# trail nodes\n!a1b2c3d4 walker\n!0badcafe\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runSimCommand(t, "node", "favorite", "!0badcafe", "--file", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "!0badcafe favorited\n!a1b2c3d4 favorited\n" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestNodeActionNeedsANode(t *testing.T) {
+	_, err := runSimCommand(t, "node", "remove")
+	if ExitCode(err) != 2 {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
+
+func TestNodeResetDBAsksForConfirmation(t *testing.T) {
+	cmd := newRootCommand()
+	cmd.SetArgs([]string{"node", "reset-db", "--port", "sim://"})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetIn(strings.NewReader("n\n"))
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "reset-db cancelled") {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
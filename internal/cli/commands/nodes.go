@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/spf13/cobra"
+)
+
+func newNodesCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var (
+		sortBy  string
+		match   string
+		maxHops int
+		since   time.Duration
+		noSelf  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "List the nodes in the radio's node DB",
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			query := appnode.NodeQuery{
+				Sort:        appnode.NodeSort(sortBy),
+				Match:       match,
+				ExcludeSelf: noSelf,
+			}
+			if err := appnode.ValidateNodeQuery(query); err != nil {
+				return mapServiceError(err)
+			}
+			if maxHops >= 0 {
+				hops := uint32(maxHops)
+				query.MaxHops = &hops
+			}
+			if since < 0 {
+				return newUserInputError(fmt.Errorf("--since must not be negative"))
+			}
+			if since > 0 {
+				query.HeardSince = time.Now().Add(-since)
+			}
+
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(runCtx context.Context, r Radio) error {
+				result, err := appnode.NewService(r).Nodes(runCtx, query)
+				if err != nil {
+					return mapServiceError(err)
+				}
+				return writeNodes(cmd.OutOrStdout(), result, cliCtx.JSON, time.Now())
+			}))
+		},
+	}
+
+	cmd.Flags().StringVar(&sortBy, "sort", string(appnode.SortLastHeard), "sort order: last-heard|snr|distance")
+	cmd.Flags().StringVar(&match, "filter", "", "only nodes whose ID, name or hardware model contains this text")
+	cmd.Flags().IntVar(&maxHops, "max-hops", -1, "only nodes at most this many hops away (-1 for any)")
+	cmd.Flags().DurationVar(&since, "since", 0, "only nodes heard within this long, such as 2h")
+	cmd.Flags().BoolVar(&noSelf, "no-self", false, "leave the connected node out of the list")
+
+	return cmd
+}
+
+func writeNodes(out io.Writer, result appnode.NodesResult, asJSON bool, now time.Time) error {
+	if asJSON {
+		return json.NewEncoder(out).Encode(result)
+	}
+
+	rows := make([][]string, 0, len(result.Nodes))
+	for _, n := range result.Nodes {
+		id := n.ID
+		if n.Self {
+			id += "*"
+		}
+		rows = append(rows, []string{
+			id,
+			orDash(n.ShortName),
+			orDash(n.LongName),
+			n.HWModel,
+			optional(n.HopsAway, func(h uint32) string { return strconv.FormatUint(uint64(h), 10) }),
+			fmt.Sprintf("%.2f", n.SNR),
+			optional(n.Battery, func(b uint32) string { return fmt.Sprintf("%d%%", b) }),
+			optional(n.Distance, appnode.FormatDistance),
+			formatLastHeard(n.LastHeard, now),
+		})
+	}
+
+	if err := printTable(out, []string{"ID", "SHORT", "NAME", "HW", "HOPS", "SNR", "BATT", "DIST", "HEARD"}, rows); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "%d nodes (* = %s)\n", len(result.Nodes), result.Self)
+	return err
+}
+
+// formatLastHeard renders a Unix time as a coarse age, such as "5m ago".
+func formatLastHeard(ts uint32, now time.Time) string {
+	if ts == 0 {
+		return "-"
+	}
+	age := now.Sub(time.Unix(int64(ts), 0))
+	switch {
+	case age < time.Minute:
+		return "now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+	}
+}
+
+func optional[T any](v *T, format func(T) string) string {
+	if v == nil {
+		return "-"
+	}
+	return format(*v)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+)
+
+func TestNodesAgainstSimulatedNode(t *testing.T) {
+	cmd := newNodesCommand(&Context{Port: "sim://", Timeout: 5 * time.Second}, nil)
+	cmd.SetArgs([]string{"--sort", "snr", "--no-self"})
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	if !strings.HasPrefix(lines[0], "ID") || !strings.HasPrefix(lines[1], "!a1b2c3d4") || !strings.HasPrefix(lines[2], "!0badcafe") {
+		t.Fatalf("nodes not sorted by SNR:\n%s", out.String())
+	}
+	if !strings.Contains(lines[1], "6.50") || lines[3] != "2 nodes (* = !5ca1ab1e)" {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestNodesSimulatedJSONFilter(t *testing.T) {
+	cmd := newNodesCommand(&Context{Port: "sim://", Timeout: 5 * time.Second, JSON: true}, nil)
+	cmd.SetArgs([]string{"--filter", "trail", "--sort", "distance"})
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got appnode.NodesResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal output: %v, output=%q", err, out.String())
+	}
+	if len(got.Nodes) != 1 || got.Nodes[0].LongName != "Trail Walker" || got.Nodes[0].HopsAway == nil || *got.Nodes[0].HopsAway != 1 {
+		t.Fatalf("unexpected JSON: %s", out.String())
+	}
+}
+
+func TestNodesRejectsUnknownSort(t *testing.T) {
+	cmd := newNodesCommand(&Context{Port: "/dev/test", Timeout: time.Second}, func(string) (Radio, error) {
+		t.Fatalf("opener should not be called for invalid args")
+		return nil, nil
+	})
+	cmd.SetArgs([]string{"--sort", "name"})
+	cmd.SilenceUsage = true
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	if err := cmd.Execute(); ExitCode(err) != 2 {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
+
+func TestFormatLastHeard(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	for ts, want := range map[uint32]string{
+		0:                   "-",
+		1_000_000 - 5:       "now",
+		1_000_000 - 300:     "5m ago",
+		1_000_000 - 7200:    "2h ago",
+		1_000_000 - 3*86400: "3d ago",
+	} {
+		if got := formatLastHeard(ts, now); got != want {
+			t.Fatalf("formatLastHeard(%d) = %q, want %q", ts, got, want)
+		}
+	}
+}
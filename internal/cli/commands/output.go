@@ -3,6 +3,8 @@ package commands
 import (
 	"fmt"
 	"io"
+	"strings"
+	"text/tabwriter"
 )
 
 type keyValueRow struct {
@@ -25,3 +27,17 @@ func printKeyValueTable(out io.Writer, rows []keyValueRow) error {
 	}
 	return nil
 }
+
+// printTable writes rows under header in aligned columns.
+func printTable(out io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, strings.Join(header, "\t")); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/spf13/cobra"
+)
+
+func newOwnerCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "owner",
+		Short:       "Read the node's owner",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get",
+		Short: "Show the owner's names, flags and public key",
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				owner, err := newService(cliCtx, r).GetOwner(ctx)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(owner)
+				}
+				return writeOwner(cmd.OutOrStdout(), owner)
+			}))
+		},
+	})
+	return cmd
+}
+
+func writeOwner(out io.Writer, owner appnode.Owner) error {
+	return printKeyValueTable(out, []keyValueRow{
+		{Key: "id", Value: orDash(owner.ID)},
+		{Key: "long name", Value: orDash(owner.LongName)},
+		{Key: "short name", Value: orDash(owner.ShortName)},
+		{Key: "hardware", Value: owner.HWModel},
+		{Key: "role", Value: owner.Role},
+		{Key: "licensed", Value: strconv.FormatBool(owner.Licensed)},
+		{Key: "unmessagable", Value: strconv.FormatBool(owner.Unmessagable)},
+		{Key: "public key", Value: orDash(owner.PublicKey)},
+	})
+}
@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOwnerGetOnRemoteNode(t *testing.T) {
+	out, err := runSimCommand(t, "owner", "get", "--dest", "!a1b2c3d4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"id            !a1b2c3d4\n", "long name     Ridge Relay\n", "role          ROUTER\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestSetHamUppercasesCallSign(t *testing.T) {
+	out, err := runSimCommand(t, "set", "ham", "--callsign", "kd2abc", "--short", "KD2A", "--yes")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "ham mode set as KD2ABC (KD2A); primary channel is unencrypted\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/spf13/cobra"
+)
+
+func newPositionCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "position",
+		Short:       "Read, set and clear the node's fixed position",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+	}
+
+	cmd.AddCommand(newPositionGetCommand(cliCtx, opener))
+	cmd.AddCommand(newPositionSetCommand(cliCtx, opener))
+	cmd.AddCommand(newPositionClearCommand(cliCtx, opener))
+	return cmd
+}
+
+func newPositionGetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get",
+		Short: "Show the node's position from the NodeDB",
+		Long: "Show the node's last position as the connected node's NodeDB has it, in\n" +
+			"decimal degrees, degrees/minutes/seconds and MGRS, and whether it is a fixed\n" +
+			"position. --timeout applies to each request.",
+		Args: wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				report, err := newService(cliCtx, r).GetPosition(ctx)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(report)
+				}
+				return writePositionReport(cmd.OutOrStdout(), report)
+			}))
+		},
+	}
+}
+
+func newPositionSetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var in appnode.PositionInput
+
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Set a fixed position",
+		Long: "Store a fixed position on the node and turn on position.fixed_position, so\n" +
+			"GPS fixes no longer replace it. --lat and --lon take decimal degrees or\n" +
+			"degrees, minutes and seconds with a hemisphere; --utm and --mgrs take a\n" +
+			"grid reference instead.",
+		Example: "  chirp position set --lat 37.7749 --lon -122.4194 --alt 30\n" +
+			"  chirp position set --lat \"37 46 29.6 N\" --lon \"122 25 9.8 W\"\n" +
+			"  chirp position set --utm \"10S 551131 4180999\"\n" +
+			"  chirp position set --mgrs 10SEG5113080998 --alt 30",
+		Args: wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			position, err := appnode.ParsePositionInput(in)
+			if err != nil {
+				return mapServiceError(err)
+			}
+
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				result, err := newService(cliCtx, r).SetFixedPosition(ctx, position)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{
+						"ok":  true,
+						"lat": result.Lat,
+						"lon": result.Lon,
+						"alt": result.Alt,
+					})
+				}
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "fixed position set to %.7f, %.7f, %dm (%s)\n",
+					result.Lat, result.Lon, result.Alt, appnode.FormatDMS(result.Lat, result.Lon))
+				return err
+			}))
+		},
+	}
+
+	cmd.Flags().StringVar(&in.Lat, "lat", "", "latitude, such as 37.7749 or 37°46'29.6\"N")
+	cmd.Flags().StringVar(&in.Lon, "lon", "", "longitude, such as -122.4194 or 122°25'9.8\"W")
+	cmd.Flags().Int32Var(&in.Alt, "alt", 0, "altitude in meters above sea level")
+	cmd.Flags().StringVar(&in.UTM, "utm", "", "UTM coordinates as \"<zone><band> <easting> <northing>\"")
+	cmd.Flags().StringVar(&in.MGRS, "mgrs", "", "MGRS grid reference, such as 10SEG5113080998")
+	return cmd
+}
+
+func newPositionClearCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "Remove the fixed position",
+		Long:  "Remove the fixed position and turn off position.fixed_position, so the node\nreports its GPS position again.",
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				if err := newService(cliCtx, r).ClearFixedPosition(ctx); err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{"ok": true})
+				}
+				_, err := fmt.Fprintln(cmd.OutOrStdout(), "fixed position cleared")
+				return err
+			}))
+		},
+	}
+}
+
+func writePositionReport(out io.Writer, report appnode.PositionReport) error {
+	rows := []keyValueRow{
+		{Key: "node", Value: report.Node},
+		{Key: "fixed", Value: strconv.FormatBool(report.Fixed)},
+	}
+	if p := report.Position; p != nil {
+		updated := "-"
+		if p.Time != 0 {
+			updated = time.Unix(int64(p.Time), 0).UTC().Format(time.RFC3339)
+		}
+		rows = append(rows,
+			keyValueRow{Key: "latitude", Value: strconv.FormatFloat(p.Lat, 'f', 7, 64)},
+			keyValueRow{Key: "longitude", Value: strconv.FormatFloat(p.Lon, 'f', 7, 64)},
+			keyValueRow{Key: "altitude", Value: fmt.Sprintf("%dm", p.Alt)},
+			keyValueRow{Key: "dms", Value: appnode.FormatDMS(p.Lat, p.Lon)},
+			keyValueRow{Key: "mgrs", Value: orDash(appnode.FormatMGRS(p.Lat, p.Lon))},
+			keyValueRow{Key: "updated", Value: updated},
+		)
+	} else {
+		rows = append(rows, keyValueRow{Key: "position", Value: "-"})
+	}
+	return printKeyValueTable(out, rows)
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPositionSetAcceptsDMS(t *testing.T) {
+	out, err := runSimCommand(t, "position", "set", "--lat", `37°46'29.6"N`, "--lon", "122 25 9.8 W", "--alt", "30")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out, "fixed position set to 37.7748889, -122.4193889, 30m") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestPositionGetShowsGridReference(t *testing.T) {
+	out, err := runSimCommand(t, "position", "get")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"fixed      true\n", "latitude   37.7749000\n", "mgrs       10S EG 51130 80998\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPositionSetRejectsBadCoordinatesBeforeConnecting(t *testing.T) {
+	cmd := newRootCommand()
+	cmd.SetArgs([]string{"position", "set", "--lat", "95", "--lon", "10", "--port", "/dev/does-not-exist"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), "outside ±90°") {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/spf13/cobra"
+)
+
+func newProfileCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Export and import a node's whole configuration",
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+	}
+
+	cmd.AddCommand(newProfileExportCommand(cliCtx, opener))
+	cmd.AddCommand(newProfileImportCommand(cliCtx, opener))
+	return cmd
+}
+
+func newProfileExportCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var includeKeys bool
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print the node's profile as YAML",
+		Long: "Print the node's profile as YAML, or JSON with --json: owner names, channel URL,\n" +
+			"every config and module config section, the fixed position and canned messages.\n" +
+			"--timeout applies to each request.",
+		Example: "  chirp profile export > node.yaml",
+		Args:    wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				profile, err := newService(cliCtx, r).ExportProfile(ctx, appnode.ProfileOptions{IncludeKeys: includeKeys})
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				b, err := configJSON.Marshal(profile)
+				if err != nil {
+					return fmt.Errorf("marshal profile: %w", err)
+				}
+				if cliCtx.JSON {
+					_, err := fmt.Fprintln(cmd.OutOrStdout(), string(b))
+					return err
+				}
+				return writeYAML(cmd.OutOrStdout(), json.RawMessage(b))
+			}))
+		},
+	}
+
+	cmd.Flags().BoolVar(&includeKeys, "include-keys", false, "include the node's private and public keys")
+	return cmd
+}
+
+func newProfileImportCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var yes bool
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Apply a profile written by profile export",
+		Long: "Apply a profile written by profile export, from a file or - for stdin.\n\n" +
+			"The fields that differ on the node are listed first, then written in one\n" +
+			"edit-settings transaction once confirmed. Fields the profile leaves out are\n" +
+			"not touched, and a profile without keys keeps the node's own keys.",
+		Example: "  chirp profile import node.yaml\n  chirp profile import node.yaml --dry-run",
+		Args:    wrapPositionalArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := readInputFile(cmd, args[0])
+			if err != nil {
+				return newUserInputError(err)
+			}
+			profile, err := appnode.ParseProfile(data)
+			if err != nil {
+				return mapServiceError(err)
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				service := newService(cliCtx, r)
+				plan, err := service.PlanProfileImport(ctx, profile)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				out := cmd.OutOrStdout()
+				if !cliCtx.JSON {
+					if err := writeProfileChanges(out, plan.Changes); err != nil {
+						return err
+					}
+				}
+
+				apply := len(plan.Changes) > 0 && !dryRun
+				if apply && !yes {
+					confirmed, err := promptConfirm(cmd, fmt.Sprintf("Apply %d changes? [y/N] ", len(plan.Changes)))
+					if err != nil {
+						return newRuntimeError(fmt.Errorf("read confirmation: %w", err))
+					}
+					if !confirmed {
+						return newRuntimeError(fmt.Errorf("profile import cancelled"))
+					}
+				}
+				if apply {
+					if err := service.ApplyProfile(ctx, plan); err != nil {
+						return mapServiceError(err)
+					}
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(out).Encode(map[string]any{
+						"changes": plan.Changes,
+						"applied": apply,
+					})
+				}
+				if apply {
+					_, err = fmt.Fprintf(out, "applied %d changes\n", len(plan.Changes))
+				}
+				return err
+			}))
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list the changes without applying them")
+	return cmd
+}
+
+// readInputFile reads the file at path, or standard input when path is "-".
+func readInputFile(cmd *cobra.Command, path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(cmd.InOrStdin())
+	}
+	return os.ReadFile(path)
+}
+
+func writeProfileChanges(out io.Writer, changes []appnode.ConfigChange) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(out, "profile already matches the node")
+		return err
+	}
+
+	rows := make([]keyValueRow, len(changes))
+	for i, c := range changes {
+		rows[i] = keyValueRow{Key: c.Path, Value: c.Old + " -> " + c.New}
+	}
+	return printKeyValueTable(out, rows)
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfileExportImportRoundTrip(t *testing.T) {
+	exported, err := runSimCommand(t, "profile", "export")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	for _, want := range []string{"long_name: Chirp Simulator\n", "channel_url: https://meshtastic.org/e/#", "  lora:\n", "  mqtt:\n"} {
+		if !strings.Contains(exported, want) {
+			t.Fatalf("profile missing %q:\n%s", want, exported)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	if err := os.WriteFile(path, []byte(exported), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	out, err := runSimCommand(t, "profile", "import", path)
+	if err != nil || out != "profile already matches the node\n" {
+		t.Fatalf("import unchanged profile: out=%q err=%v", out, err)
+	}
+
+	edited := strings.Replace(exported, "long_name: Chirp Simulator", "long_name: Provisioned", 1)
+	if err := os.WriteFile(path, []byte(edited), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	out, err = runSimCommand(t, "profile", "import", path, "--yes")
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !strings.Contains(out, `"Chirp Simulator" -> "Provisioned"`) || !strings.HasSuffix(out, "applied 1 changes\n") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestProfileImportRejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	if err := os.WriteFile(path, []byte("config:\n  lora:\n    region: MARS\n"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	_, err := runSimCommand(t, "profile", "import", path)
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), "invalid profile") {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
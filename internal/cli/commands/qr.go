@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// qrQuietZone is the light border, in modules, scanners need around a code.
+const qrQuietZone = 4
+
+// writeQR draws text as a QR code with half-block characters, two module rows
+// per line. Light modules are drawn as blocks so that the code scans on a
+// dark terminal; invert draws dark modules instead, for light terminals.
+func writeQR(out io.Writer, text string, invert bool) error {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return fmt.Errorf("encode QR code: %w", err)
+	}
+
+	size := code.Size + 2*qrQuietZone
+	filled := func(x, y int) bool {
+		x, y = x-qrQuietZone, y-qrQuietZone
+		dark := x >= 0 && y >= 0 && x < code.Size && y < code.Size && code.Black(x, y)
+		return dark == invert
+	}
+
+	// QR codes have an odd number of modules per side, so the last line gets
+	// one extra row of quiet zone to fill its bottom half.
+	rows := size + size%2
+
+	var b strings.Builder
+	for y := 0; y < rows; y += 2 {
+		for x := 0; x < size; x++ {
+			top, bottom := filled(x, y), filled(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	_, err = io.WriteString(out, b.String())
+	return err
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
-	"sync"
 
+	appnode "github.com/coreyvan/chirp/internal/app/node"
 	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/coreyvan/chirp/pkg/radio/capture"
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 	"github.com/spf13/cobra"
 )
@@ -44,14 +47,59 @@ func validateContext(ctx *Context) error {
 	if ctx.Timeout <= 0 {
 		return newUserInputError(fmt.Errorf("--timeout must be greater than 0"))
 	}
+	if ctx.ReplaySpeed < 0 {
+		return newUserInputError(fmt.Errorf("--replay-speed must be >= 0"))
+	}
+	if strings.TrimSpace(ctx.Replay) != "" && strings.TrimSpace(ctx.Host) != "" {
+		return newUserInputError(fmt.Errorf("--replay cannot be combined with --host"))
+	}
+	ctx.destNum = 0
+	if dest := strings.TrimSpace(ctx.Dest); dest != "" {
+		num, err := radio.ParseNodeID(dest)
+		if err != nil {
+			return newUserInputError(fmt.Errorf("--dest: %w", err))
+		}
+		ctx.destNum = num
+	}
+	return nil
+}
+
+// remoteAdminAnnotation marks a command, and with it every subcommand, whose
+// admin messages can be sent to a remote node with --dest. A subcommand that
+// never talks to a node opts back out with the value "false".
+const remoteAdminAnnotation = "chirp.remote-admin"
+
+// checkDest rejects --dest on commands that only talk to the connected node,
+// and gives remote commands a longer default timeout.
+func checkDest(cmd *cobra.Command, ctx *Context) error {
+	if ctx.destNum == 0 {
+		return nil
+	}
+
+	supported := false
+	for c := cmd; c != nil; c = c.Parent() {
+		if v, ok := c.Annotations[remoteAdminAnnotation]; ok {
+			supported = v == "true"
+			break
+		}
+	}
+	if !supported {
+		return newUserInputError(fmt.Errorf("%s does not support --dest", cmd.CommandPath()))
+	}
+
+	if !cmd.Flags().Changed("timeout") {
+		ctx.Timeout = defaultDestTimeout
+	}
 	return nil
 }
 
+var errInterrupted = errors.New("command interrupted")
+
 func formatTimeoutError(timeout string) error {
 	return newRuntimeError(fmt.Errorf("command timed out after %s", timeout))
 }
 
-func formatOpenRadioError(port string, err error) error {
+func formatOpenRadioError(target string, err error) error {
 	hint := ""
 	msg := strings.ToLower(err.Error())
 	switch {
@@ -59,27 +107,56 @@ func formatOpenRadioError(port string, err error) error {
 		hint = " (port may already be in use by another process)"
 	case strings.Contains(msg, "permission denied"), strings.Contains(msg, "operation not permitted"):
 		hint = " (check serial port permissions)"
+	case strings.Contains(msg, "connection refused"), strings.Contains(msg, "no such host"), strings.Contains(msg, "i/o timeout"):
+		hint = " (check that the node's TCP API is reachable)"
 	}
 
-	return newRuntimeError(fmt.Errorf("failed to open radio on %q%s: %w", port, hint, err))
+	return newRuntimeError(fmt.Errorf("failed to open radio on %q%s: %w", target, hint, err))
 }
 
 // Radio describes the radio surface used by CLI commands.
 type Radio interface {
 	Close() error
-	ReadResponse(timeout bool) ([]*pb.FromRadio, error)
-	GetRadioInfo() ([]*pb.FromRadio, error)
-	SendTextMessage(message string, to int64, channel int64) error
-	SetRadioOwner(name string) error
-	SetModemMode(mode string) error
-	SetLocation(lat int32, long int32, alt int32) error
-	FactoryReset() error
+	OpenInbox()
+	ReadResponse(ctx context.Context, timeout bool) ([]*pb.FromRadio, error)
+	Stats() radio.Stats
+	SetRecorder(w *capture.Writer)
+	GetRadioInfo(ctx context.Context) ([]*pb.FromRadio, error)
+	Handshake(ctx context.Context, mode radio.HandshakeMode) ([]*pb.FromRadio, error)
+	SendTextMessage(ctx context.Context, message string, to int64, channel int64) error
+	SendTextMessageAck(ctx context.Context, message string, to int64, channel int64) (radio.Ack, error)
+	SetModemMode(ctx context.Context, mode string) error
+	SetLocation(ctx context.Context, lat int32, long int32, alt int32) error
+	FactoryReset(ctx context.Context) error
+	Traceroute(ctx context.Context, to uint32, channel uint32) (radio.TracerouteReply, error)
+	RequestAdmin(ctx context.Context, to uint32, msg *pb.AdminMessage) (*pb.AdminMessage, error)
+	SendAdmin(ctx context.Context, to uint32, msg *pb.AdminMessage) error
+	GetFile(ctx context.Context, name string, w io.Writer, opts xmodem.Options) (int64, error)
+	PutFile(ctx context.Context, name string, r io.Reader, opts xmodem.Options) (int64, error)
+}
+
+type radioOpener func(target string) (Radio, error)
+
+func defaultRadioOpener(target string) (Radio, error) {
+	return radio.NewRadio(target)
 }
 
-type radioOpener func(port string) (Radio, error)
+// newRadioOpener returns the opener for cliCtx: a capture replay when --replay
+// is set, otherwise a live radio.
+func newRadioOpener(cliCtx *Context) radioOpener {
+	path := strings.TrimSpace(cliCtx.Replay)
+	if path == "" {
+		return defaultRadioOpener
+	}
 
-func defaultRadioOpener(port string) (Radio, error) {
-	return radio.NewRadio(port)
+	speed := cliCtx.ReplaySpeed
+	return func(string) (Radio, error) {
+		replayer, err := capture.OpenReplay(path, speed)
+		if err != nil {
+			return nil, err
+		}
+		return radio.NewRadioFromStreamer(replayer), nil
+	}
 }
 
 // RadioRunner executes command logic using an opened radio instance.
@@ -94,37 +171,11 @@ func (f RadioRunnerFunc) Run(ctx context.Context, radio Radio) error {
 	return f(ctx, radio)
 }
 
-func runWithRadio(parent context.Context, cliCtx *Context, opener radioOpener, runner RadioRunner) (err error) {
+// runWithRadio opens the radio and runs runner under the --timeout deadline.
+func runWithRadio(parent context.Context, cliCtx *Context, opener radioOpener, runner RadioRunner) error {
 	if err := validateContext(cliCtx); err != nil {
 		return err
 	}
-	if opener == nil {
-		opener = defaultRadioOpener
-	}
-	if runner == nil {
-		return newRuntimeError(fmt.Errorf("internal error: missing command runner"))
-	}
-
-	r, err := opener(cliCtx.Port)
-	if err != nil {
-		return formatOpenRadioError(cliCtx.Port, err)
-	}
-
-	var closeOnce sync.Once
-	closeRadio := func() error {
-		var closeErr error
-		closeOnce.Do(func() {
-			closeErr = r.Close()
-		})
-		return closeErr
-	}
-
-	defer func() {
-		closeErr := closeRadio()
-		if closeErr != nil {
-			err = errors.Join(err, newRuntimeError(fmt.Errorf("close radio: %w", closeErr)))
-		}
-	}()
 
 	base := parent
 	if base == nil {
@@ -133,43 +184,49 @@ func runWithRadio(parent context.Context, cliCtx *Context, opener radioOpener, r
 	runCtx, cancel := context.WithTimeout(base, cliCtx.Timeout)
 	defer cancel()
 
-	result := make(chan error, 1)
-	go func() {
-		result <- runner.Run(runCtx, r)
-	}()
-
-	select {
-	case runErr := <-result:
-		if runErr == nil {
-			return nil
-		}
-		if errors.Is(runErr, context.DeadlineExceeded) {
-			return formatTimeoutError(cliCtx.Timeout.String())
-		}
-		return newRuntimeError(runErr)
-	case <-runCtx.Done():
-		_ = closeRadio()
-		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
-			return formatTimeoutError(cliCtx.Timeout.String())
-		}
-		return newRuntimeError(runCtx.Err())
-	}
+	return openAndRun(runCtx, cliCtx, opener, runner)
 }
 
-func runWithRadioNoTimeout(parent context.Context, cliCtx *Context, opener radioOpener, runner RadioRunner) (err error) {
+// runWithRadioNoTimeout is runWithRadio for streaming commands, which run until
+// parent is cancelled.
+func runWithRadioNoTimeout(parent context.Context, cliCtx *Context, opener radioOpener, runner RadioRunner) error {
 	if err := validateContext(cliCtx); err != nil {
 		return err
 	}
+
+	base := parent
+	if base == nil {
+		base = context.Background()
+	}
+
+	return openAndRun(base, cliCtx, opener, runner)
+}
+
+// newService returns a node service for r that applies --timeout to each
+// exchange with the radio, for commands that make several requests under
+// runWithRadioNoTimeout, and addresses the --dest node.
+func newService(cliCtx *Context, r Radio) *appnode.Service {
+	service := appnode.NewService(r)
+	service.SetRequestTimeout(cliCtx.Timeout)
+	service.SetDestination(cliCtx.destNum)
+	return service
+}
+
+// openAndRun opens the radio, runs runner with ctx and closes the radio once the
+// runner has returned. Radio calls return as soon as ctx is done, so the radio
+// is never closed under a runner that is still using it.
+func openAndRun(ctx context.Context, cliCtx *Context, opener radioOpener, runner RadioRunner) (err error) {
 	if opener == nil {
-		opener = defaultRadioOpener
+		opener = newRadioOpener(cliCtx)
 	}
 	if runner == nil {
 		return newRuntimeError(fmt.Errorf("internal error: missing command runner"))
 	}
 
-	r, err := opener(cliCtx.Port)
+	target := cliCtx.endpoint()
+	r, err := opener(target)
 	if err != nil {
-		return formatOpenRadioError(cliCtx.Port, err)
+		return formatOpenRadioError(target, err)
 	}
 
 	defer func() {
@@ -179,19 +236,17 @@ func runWithRadioNoTimeout(parent context.Context, cliCtx *Context, opener radio
 		}
 	}()
 
-	base := parent
-	if base == nil {
-		base = context.Background()
-	}
-
-	runErr := runner.Run(base, r)
-	if runErr == nil {
+	runErr := runner.Run(ctx, r)
+	switch {
+	case runErr == nil:
 		return nil
-	}
-	if errors.Is(runErr, context.DeadlineExceeded) {
+	case errors.Is(runErr, context.DeadlineExceeded):
 		return formatTimeoutError(cliCtx.Timeout.String())
+	case errors.Is(runErr, context.Canceled):
+		return newRuntimeError(errInterrupted)
+	default:
+		return newRuntimeError(runErr)
 	}
-	return newRuntimeError(runErr)
 }
 
 func newRadioCommand(use, short string, cliCtx *Context, opener radioOpener, runner RadioRunner) *cobra.Command {
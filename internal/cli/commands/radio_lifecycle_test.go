@@ -3,10 +3,15 @@ package commands
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/coreyvan/chirp/pkg/radio/capture"
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 )
 
@@ -20,33 +25,63 @@ func (f *fakeRadio) Close() error {
 	return f.closeErr
 }
 
-func (f *fakeRadio) ReadResponse(bool) ([]*pb.FromRadio, error) {
+func (f *fakeRadio) OpenInbox() {}
+
+func (f *fakeRadio) ReadResponse(context.Context, bool) ([]*pb.FromRadio, error) {
+	return nil, nil
+}
+
+func (f *fakeRadio) Stats() radio.Stats {
+	return radio.Stats{}
+}
+
+func (f *fakeRadio) SetRecorder(*capture.Writer) {}
+
+func (f *fakeRadio) GetRadioInfo(context.Context) ([]*pb.FromRadio, error) {
 	return nil, nil
 }
 
-func (f *fakeRadio) GetRadioInfo() ([]*pb.FromRadio, error) {
+func (f *fakeRadio) Handshake(context.Context, radio.HandshakeMode) ([]*pb.FromRadio, error) {
 	return nil, nil
 }
 
-func (f *fakeRadio) SendTextMessage(string, int64, int64) error {
+func (f *fakeRadio) SendTextMessage(context.Context, string, int64, int64) error {
 	return nil
 }
 
-func (f *fakeRadio) SetRadioOwner(string) error {
+func (f *fakeRadio) SendTextMessageAck(context.Context, string, int64, int64) (radio.Ack, error) {
+	return radio.Ack{}, nil
+}
+
+func (f *fakeRadio) SetModemMode(context.Context, string) error {
 	return nil
 }
 
-func (f *fakeRadio) SetModemMode(string) error {
+func (f *fakeRadio) SetLocation(context.Context, int32, int32, int32) error {
 	return nil
 }
 
-func (f *fakeRadio) SetLocation(int32, int32, int32) error {
+func (f *fakeRadio) FactoryReset(context.Context) error {
 	return nil
 }
 
-func (f *fakeRadio) FactoryReset() error {
+func (f *fakeRadio) Traceroute(context.Context, uint32, uint32) (radio.TracerouteReply, error) {
+	return radio.TracerouteReply{}, nil
+}
+
+func (f *fakeRadio) RequestAdmin(context.Context, uint32, *pb.AdminMessage) (*pb.AdminMessage, error) {
+	return &pb.AdminMessage{}, nil
+}
+
+func (f *fakeRadio) SendAdmin(context.Context, uint32, *pb.AdminMessage) error {
 	return nil
 }
+func (f *fakeRadio) GetFile(context.Context, string, io.Writer, xmodem.Options) (int64, error) {
+	return 0, nil
+}
+func (f *fakeRadio) PutFile(context.Context, string, io.Reader, xmodem.Options) (int64, error) {
+	return 0, nil
+}
 
 type fakeRunner struct {
 	calls int
@@ -93,6 +128,26 @@ func TestRunWithRadioSuccess(t *testing.T) {
 	}
 }
 
+func TestRunWithRadioUsesHostTarget(t *testing.T) {
+	ctx := &Context{
+		Port:    "/dev/test",
+		Host:    "meshtastic.local",
+		Timeout: time.Second,
+	}
+
+	var openedTarget string
+	err := runWithRadio(context.Background(), ctx, func(target string) (Radio, error) {
+		openedTarget = target
+		return &fakeRadio{}, nil
+	}, &fakeRunner{run: func(context.Context, Radio) error { return nil }})
+	if err != nil {
+		t.Fatalf("runWithRadio() error = %v", err)
+	}
+	if openedTarget != "tcp://meshtastic.local" {
+		t.Fatalf("opener called with %q, want tcp://meshtastic.local", openedTarget)
+	}
+}
+
 func TestRunWithRadioInvalidContext(t *testing.T) {
 	ctx := &Context{
 		Port:    "",
@@ -134,6 +189,27 @@ func TestRunWithRadioOpenErrorIncludesHint(t *testing.T) {
 	}
 }
 
+func TestRunWithRadioOpenErrorIncludesTCPHint(t *testing.T) {
+	ctx := &Context{
+		Port:    "/dev/test",
+		Host:    "10.0.0.5",
+		Timeout: time.Second,
+	}
+
+	err := runWithRadio(context.Background(), ctx, func(string) (Radio, error) {
+		return nil, errors.New("could not open tcp connection: dial tcp 10.0.0.5:4403: connect: connection refused")
+	}, &fakeRunner{run: func(context.Context, Radio) error { return nil }})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if !strings.Contains(err.Error(), `failed to open radio on "tcp://10.0.0.5"`) {
+		t.Fatalf("error = %q, missing target", err.Error())
+	}
+	if !strings.Contains(err.Error(), "TCP API is reachable") {
+		t.Fatalf("error = %q, missing tcp hint", err.Error())
+	}
+}
+
 func TestRunWithRadioTimeout(t *testing.T) {
 	ctx := &Context{
 		Port:    "/dev/test",
@@ -165,6 +241,54 @@ func TestRunWithRadioTimeout(t *testing.T) {
 	}
 }
 
+func TestRunWithRadioClosesOnlyAfterRunnerReturns(t *testing.T) {
+	ctx := &Context{
+		Port:    "/dev/test",
+		Timeout: 10 * time.Millisecond,
+	}
+
+	fRadio := &fakeRadio{}
+	runner := &fakeRunner{
+		run: func(runCtx context.Context, _ Radio) error {
+			<-runCtx.Done()
+			if fRadio.closeCalls != 0 {
+				t.Errorf("radio closed while the runner was still using it")
+			}
+			return runCtx.Err()
+		},
+	}
+
+	err := runWithRadio(context.Background(), ctx, func(string) (Radio, error) {
+		return fRadio, nil
+	}, runner)
+	if !strings.Contains(fmt.Sprint(err), "command timed out") {
+		t.Fatalf("error = %v, want timeout", err)
+	}
+	if fRadio.closeCalls != 1 {
+		t.Fatalf("close calls = %d, want 1", fRadio.closeCalls)
+	}
+}
+
+func TestRunWithRadioReportsInterrupt(t *testing.T) {
+	ctx := &Context{
+		Port:    "/dev/test",
+		Timeout: time.Second,
+	}
+
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := runWithRadio(parent, ctx, func(string) (Radio, error) {
+		return &fakeRadio{}, nil
+	}, &fakeRunner{run: func(runCtx context.Context, _ Radio) error {
+		<-runCtx.Done()
+		return runCtx.Err()
+	}})
+	if ExitCode(err) != 1 || !strings.Contains(err.Error(), "command interrupted") {
+		t.Fatalf("error = %v, want interrupted runtime error", err)
+	}
+}
+
 func TestRunWithRadioCloseError(t *testing.T) {
 	ctx := &Context{
 		Port:    "/dev/test",
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultPowerDelay  = 5 * time.Second
+	defaultWaitTimeout = 2 * time.Minute
+)
+
+func newRebootCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var (
+		delay       time.Duration
+		ota         bool
+		yes         bool
+		wait        bool
+		waitTimeout time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reboot",
+		Short: "Reboot the node",
+		Long: "Reboot the node after --in. --ota reboots into the bootloader's OTA update\n" +
+			"mode instead. --wait waits until the node has rebooted and answers a fresh\n" +
+			"config handshake, reconnecting if the port went away.",
+		Example:     "  chirp reboot --yes\n  chirp reboot --in 30s --wait",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := appnode.ValidatePowerDelay(delay); err != nil {
+				return mapServiceError(err)
+			}
+			if wait && ota {
+				return newUserInputError(fmt.Errorf("--wait cannot be combined with --ota"))
+			}
+			if wait && strings.TrimSpace(cliCtx.Dest) != "" {
+				return newUserInputError(fmt.Errorf("--wait cannot be combined with --dest"))
+			}
+			if waitTimeout <= 0 {
+				return newUserInputError(fmt.Errorf("--wait-timeout must be greater than 0"))
+			}
+
+			prompt := "Reboot the node " + powerWhen(delay) + "? [y/N] "
+			if ota {
+				prompt = "Reboot the node into OTA update mode " + powerWhen(delay) + "? [y/N] "
+			}
+			if err := confirmOrCancel(cmd, yes, prompt, "reboot cancelled"); err != nil {
+				return err
+			}
+
+			open := opener
+			if open == nil {
+				open = newRadioOpener(cliCtx)
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				if wait {
+					r.OpenInbox()
+				}
+				result, err := newService(cliCtx, r).Reboot(ctx, appnode.RebootRequest{Delay: delay, OTA: ota})
+				if err != nil {
+					return mapServiceError(err)
+				}
+				if !wait {
+					return writePowerResult(cmd.OutOrStdout(), cliCtx.JSON, result, false)
+				}
+
+				if !cliCtx.JSON {
+					if _, err := fmt.Fprintln(cmd.OutOrStdout(), "reboot sent; waiting for the node to come back"); err != nil {
+						return err
+					}
+				}
+				waitCtx, cancel := context.WithTimeout(ctx, delay+waitTimeout)
+				defer cancel()
+				if err := waitForReboot(waitCtx, r, cliCtx.endpoint(), open); err != nil {
+					if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+						return newRuntimeError(fmt.Errorf("node did not come back within %s", delay+waitTimeout))
+					}
+					return err
+				}
+				return writePowerResult(cmd.OutOrStdout(), cliCtx.JSON, result, true)
+			}))
+		},
+	}
+
+	cmd.Flags().DurationVar(&delay, "in", defaultPowerDelay, "delay before the node reboots")
+	cmd.Flags().BoolVar(&ota, "ota", false, "reboot into OTA firmware update mode")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
+	cmd.Flags().BoolVar(&wait, "wait", false, "wait for the node to come back after the reboot")
+	cmd.Flags().DurationVar(&waitTimeout, "wait-timeout", defaultWaitTimeout, "how long --wait waits once the delay has passed")
+	return cmd
+}
+
+func newShutdownCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var (
+		delay time.Duration
+		yes   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "shutdown",
+		Short: "Power the node off",
+		Long: "Power the node off after --in. It stays off until its button is pressed or\n" +
+			"it is power cycled, so a remote node cannot be brought back over the mesh.",
+		Example:     "  chirp shutdown --in 10s",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := appnode.ValidatePowerDelay(delay); err != nil {
+				return mapServiceError(err)
+			}
+			if err := confirmOrCancel(cmd, yes, "Shut the node down "+powerWhen(delay)+"? [y/N] ", "shutdown cancelled"); err != nil {
+				return err
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				result, err := newService(cliCtx, r).Shutdown(ctx, appnode.ShutdownRequest{Delay: delay})
+				if err != nil {
+					return mapServiceError(err)
+				}
+				return writePowerResult(cmd.OutOrStdout(), cliCtx.JSON, result, false)
+			}))
+		},
+	}
+
+	cmd.Flags().DurationVar(&delay, "in", defaultPowerDelay, "delay before the node powers off")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
+	return cmd
+}
+
+func confirmOrCancel(cmd *cobra.Command, yes bool, prompt, cancelled string) error {
+	if yes {
+		return nil
+	}
+	confirmed, err := promptConfirm(cmd, prompt)
+	if err != nil {
+		return newRuntimeError(fmt.Errorf("read confirmation: %w", err))
+	}
+	if !confirmed {
+		return newRuntimeError(errors.New(cancelled))
+	}
+	return nil
+}
+
+func powerWhen(delay time.Duration) string {
+	if delay <= 0 {
+		return "now"
+	}
+	return "in " + delay.String()
+}
+
+// waitForReboot waits for r to report a reboot, or for its connection to drop
+// or fail, then reconnects to target until the node answers a config
+// handshake. The radio it ends on, if not r, is closed before returning.
+func waitForReboot(ctx context.Context, r Radio, target string, open radioOpener) error {
+	sup := radio.NewSupervisor(target, r, func(target string) (Radio, error) { return open(target) })
+	defer func() {
+		if conn := sup.Conn(); conn != r {
+			_ = conn.Close()
+		}
+	}()
+
+	cause := radio.ErrRebooted
+	for rebooted := false; !rebooted; {
+		responses, err := r.ReadResponse(ctx, true)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			// A USB port that went away with the reboot fails rather than
+			// closing; either way the connection is lost.
+			cause = err
+			break
+		}
+		for _, fr := range responses {
+			rebooted = rebooted || fr.GetRebooted()
+		}
+	}
+
+	_, _, err := sup.Reconnect(ctx, cause)
+	return err
+}
+
+func writePowerResult(out io.Writer, asJSON bool, result appnode.PowerResult, back bool) error {
+	if asJSON {
+		fields := map[string]any{
+			"ok":      true,
+			"action":  result.Action,
+			"seconds": result.Seconds,
+		}
+		if back {
+			fields["back"] = true
+		}
+		return json.NewEncoder(out).Encode(fields)
+	}
+
+	if back {
+		_, err := fmt.Fprintln(out, "node is back")
+		return err
+	}
+	_, err := fmt.Fprintf(out, "%s in %ds sent\n", result.Action, result.Seconds)
+	return err
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+func TestRebootWaitsForTheNodeToComeBack(t *testing.T) {
+	out, err := runSimCommand(t, "reboot", "--in", "0s", "--wait", "--yes")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(out, "node is back\n") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestWaitForRebootReconnectsAfterReadError(t *testing.T) {
+	unplugged := &listenTestRadio{readErrors: []error{errors.New("read /dev/ttyUSB0: input/output error")}}
+	back := &listenTestRadio{
+		infoResults: []*pb.FromRadio{{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 0x5ca1ab1e}}}},
+	}
+	opens := 0
+	open := func(string) (Radio, error) {
+		opens++
+		return back, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := waitForReboot(ctx, unplugged, "/dev/ttyUSB0", open); err != nil {
+		t.Fatalf("waitForReboot() error = %v", err)
+	}
+	if opens != 1 || back.infoCalls != 1 {
+		t.Fatalf("opens = %d, handshakes = %d; want a reconnect and a handshake", opens, back.infoCalls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("waitForReboot took %s to notice the read error", elapsed)
+	}
+}
+
+func TestShutdownAsksForConfirmation(t *testing.T) {
+	cmd := newRootCommand()
+	cmd.SetArgs([]string{"shutdown", "--port", "/dev/does-not-exist"})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetIn(strings.NewReader("n\n"))
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "shutdown cancelled") {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	if out.String() != "Shut the node down in 5s? [y/N] " {
+		t.Fatalf("unexpected prompt %q", out.String())
+	}
+}
@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/spf13/cobra"
+)
+
+func newRingtoneCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ringtone",
+		Short: "Read, check and set the external notification ringtone",
+		Long: "The external notification module plays an RTTTL ringtone, such as\n" +
+			"\"alert:d=8,o=5,b=140:c,e,g,2c6\", on a PWM buzzer. A ringtone the firmware\n" +
+			"cannot parse plays nothing, so set checks it first.",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+	}
+
+	cmd.AddCommand(newRingtoneGetCommand(cliCtx, opener))
+	cmd.AddCommand(newRingtoneSetCommand(cliCtx, opener))
+	cmd.AddCommand(newRingtoneParseCommand(cliCtx))
+	return cmd
+}
+
+func newRingtoneGetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var notes bool
+
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Show the node's ringtone",
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				config, err := newService(cliCtx, r).GetRingtone(ctx)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				out := cmd.OutOrStdout()
+				if config.GetRingtone() == "" {
+					if cliCtx.JSON {
+						return json.NewEncoder(out).Encode(map[string]any{"ringtone": ""})
+					}
+					_, err := fmt.Fprintln(out, "no ringtone set; the firmware plays its default")
+					return err
+				}
+				// The node may hold a ringtone set by another client that
+				// does not parse; show it anyway.
+				tone, parseErr := appnode.ParseRTTTL(config.GetRingtone())
+				if cliCtx.JSON {
+					fields := map[string]any{"ringtone": config.GetRingtone()}
+					if parseErr != nil {
+						fields["error"] = parseErr.Error()
+					} else {
+						fields["parsed"] = tone
+						fields["total_ms"] = tone.Total().Milliseconds()
+					}
+					return json.NewEncoder(out).Encode(fields)
+				}
+
+				if _, err := fmt.Fprintln(out, config.GetRingtone()); err != nil {
+					return err
+				}
+				if parseErr != nil {
+					_, err := fmt.Fprintf(out, "warning: the node cannot play this ringtone: %v\n", parseErr)
+					return err
+				}
+				return writeRingtone(out, tone, notes)
+			}))
+		},
+	}
+
+	cmd.Flags().BoolVar(&notes, "notes", false, "list every note")
+	return cmd
+}
+
+func newRingtoneSetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:     "set [rtttl]",
+		Short:   "Check an RTTTL ringtone and set it on the node",
+		Example: "  chirp ringtone set \"alert:d=8,o=5,b=140:c,e,g,2c6\"\n  chirp ringtone set --file tune.rtttl",
+		Args:    wrapPositionalArgs(cobra.MaximumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rtttl, err := ringtoneArg(cmd, args, file)
+			if err != nil {
+				return err
+			}
+			if _, err := appnode.ParseRTTTL(rtttl); err != nil {
+				return mapServiceError(err)
+			}
+
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				tone, err := newService(cliCtx, r).SetRingtone(ctx, &pb.RTTTLConfig{Ringtone: rtttl})
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{
+						"ok":       true,
+						"ringtone": rtttl,
+						"total_ms": tone.Total().Milliseconds(),
+					})
+				}
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "ringtone %q set (%d notes, %s)\n", tone.Name, len(tone.Notes), formatToneLength(tone.Total()))
+				return err
+			}))
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "", "read the ringtone from a file (- for stdin)")
+	return cmd
+}
+
+func newRingtoneParseCommand(cliCtx *Context) *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:         "parse [rtttl]",
+		Short:       "Check an RTTTL ringtone and list its notes, without a radio",
+		Args:        wrapPositionalArgs(cobra.MaximumNArgs(1)),
+		Annotations: map[string]string{remoteAdminAnnotation: "false"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rtttl, err := ringtoneArg(cmd, args, file)
+			if err != nil {
+				return err
+			}
+			tone, err := appnode.ParseRTTTL(rtttl)
+			if err != nil {
+				return mapServiceError(err)
+			}
+
+			if cliCtx.JSON {
+				return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{
+					"parsed":   tone,
+					"total_ms": tone.Total().Milliseconds(),
+				})
+			}
+			return writeRingtone(cmd.OutOrStdout(), tone, true)
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "", "read the ringtone from a file (- for stdin)")
+	return cmd
+}
+
+// ringtoneArg returns the ringtone given as the argument or in file.
+func ringtoneArg(cmd *cobra.Command, args []string, file string) (string, error) {
+	switch {
+	case len(args) == 1 && file != "":
+		return "", newUserInputError(fmt.Errorf("give the ringtone as an argument or with --file, not both"))
+	case len(args) == 1:
+		return strings.TrimSpace(args[0]), nil
+	case file != "":
+		data, err := readInputFile(cmd, file)
+		if err != nil {
+			return "", newUserInputError(err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return "", newUserInputError(fmt.Errorf("give the ringtone as an argument or with --file"))
+	}
+}
+
+func writeRingtone(out io.Writer, tone appnode.Ringtone, notes bool) error {
+	if err := printKeyValueTable(out, []keyValueRow{
+		{Key: "name", Value: orDash(tone.Name)},
+		{Key: "defaults", Value: fmt.Sprintf("d=%d, o=%d, b=%d", tone.Duration, tone.Octave, tone.BPM)},
+		{Key: "notes", Value: strconv.Itoa(len(tone.Notes))},
+		{Key: "length", Value: formatToneLength(tone.Total())},
+	}); err != nil {
+		return err
+	}
+	if !notes {
+		return nil
+	}
+
+	if _, err := fmt.Fprintln(out); err != nil {
+		return err
+	}
+	rows := make([][]string, len(tone.Notes))
+	var at time.Duration
+	for i, n := range tone.Notes {
+		value := "1/" + strconv.Itoa(n.Duration)
+		if n.Dotted {
+			value += "."
+		}
+		freq := "-"
+		if !n.Rest() {
+			freq = fmt.Sprintf("%.1f", n.Frequency())
+		}
+		rows[i] = []string{strconv.Itoa(i + 1), n.String(), value, freq, formatToneLength(at), strconv.FormatInt(n.Length.Milliseconds(), 10)}
+		at += n.Length
+	}
+	return printTable(out, []string{"#", "NOTE", "VALUE", "HZ", "AT", "MS"}, rows)
+}
+
+// formatToneLength renders d to the millisecond, such as "2.571s".
+func formatToneLength(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
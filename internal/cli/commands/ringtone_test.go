@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRingtoneParseListsNotes(t *testing.T) {
+	out, err := runSimCommand(t, "ringtone", "parse", "alert:d=8,o=5,b=120:c,4e,p")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"length    1s\n", "2  e5    1/4    659.3  250ms  500\n", "3  p     1/8    -      750ms  250\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRingtoneSetAgainstSimulatedNode(t *testing.T) {
+	out, err := runSimCommand(t, "ringtone", "set", "alert:d=8,o=5,b=140:c,e,g,2c6")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "ringtone \"alert\" set (4 notes, 1.5s)\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestRingtoneSetRejectsBadOctaveBeforeConnecting(t *testing.T) {
+	cmd := newRingtoneSetCommand(&Context{Port: "/dev/test", Timeout: time.Second}, func(string) (Radio, error) {
+		t.Fatalf("opener should not be called for invalid args")
+		return nil, nil
+	})
+	cmd.SetArgs([]string{"alert:d=8,o=5,b=140:c,e9"})
+	cmd.SilenceUsage = true
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	if ExitCode(err) != 2 || !strings.Contains(err.Error(), "octave 9") {
+		t.Fatalf("expected user input error, got %v", err)
+	}
+}
@@ -1,7 +1,11 @@
 package commands
 
 import (
+	"context"
 	"errors"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -59,8 +63,9 @@ func ExitCode(err error) int {
 
 func newRootCommand() *cobra.Command {
 	ctx := &Context{
-		Port:    defaultPort,
-		Timeout: defaultTimeout,
+		Port:        defaultPort,
+		Timeout:     defaultTimeout,
+		ReplaySpeed: defaultReplaySpeed,
 	}
 
 	cmd := &cobra.Command{
@@ -68,8 +73,11 @@ func newRootCommand() *cobra.Command {
 		Short:         "A slim Meshtastic CLI",
 		SilenceUsage:  true,
 		SilenceErrors: true,
-		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
-			return validateContext(ctx)
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if err := validateContext(ctx); err != nil {
+				return err
+			}
+			return checkDest(cmd, ctx)
 		},
 	}
 
@@ -78,21 +86,43 @@ func newRootCommand() *cobra.Command {
 	})
 
 	cmd.PersistentFlags().StringVar(&ctx.Port, "port", defaultPort, "serial port for the Meshtastic node")
+	cmd.PersistentFlags().StringVar(&ctx.Host, "host", "", "TCP host[:port] of a meshtasticd or WiFi node (overrides --port)")
+	cmd.PersistentFlags().StringVar(&ctx.Replay, "replay", "", "replay a .chirpcap capture instead of talking to a radio")
+	cmd.PersistentFlags().Float64Var(&ctx.ReplaySpeed, "replay-speed", defaultReplaySpeed, "replay speed multiplier (0 replays as fast as possible)")
 	cmd.PersistentFlags().DurationVar(&ctx.Timeout, "timeout", defaultTimeout, "command timeout")
 	cmd.PersistentFlags().BoolVar(&ctx.JSON, "json", false, "print machine-readable output")
 	cmd.PersistentFlags().BoolVar(&ctx.Verbose, "verbose", false, "enable debug logs")
+	cmd.PersistentFlags().StringVar(&ctx.Dest, "dest", "", "send admin commands to this remote node (!nodeid) over the mesh")
 
 	cmd.AddCommand(newVersionCommand(ctx))
 	cmd.AddCommand(newListenCommand(ctx, nil))
 	cmd.AddCommand(newInfoCommand(ctx, nil))
 	cmd.AddCommand(newSendCommand(ctx, nil))
 	cmd.AddCommand(newSetCommand(ctx, nil))
+	cmd.AddCommand(newOwnerCommand(ctx, nil))
+	cmd.AddCommand(newPositionCommand(ctx, nil))
 	cmd.AddCommand(newFactoryResetCommand(ctx, nil))
+	cmd.AddCommand(newRebootCommand(ctx, nil))
+	cmd.AddCommand(newShutdownCommand(ctx, nil))
+	cmd.AddCommand(newTracerouteCommand(ctx, nil))
+	cmd.AddCommand(newNodesCommand(ctx, nil))
+	cmd.AddCommand(newNodeCommand(ctx, nil))
+	cmd.AddCommand(newConfigCommand(ctx, nil))
+	cmd.AddCommand(newChannelCommand(ctx, nil))
+	cmd.AddCommand(newProfileCommand(ctx, nil))
+	cmd.AddCommand(newCannedCommand(ctx, nil))
+	cmd.AddCommand(newRingtoneCommand(ctx, nil))
+	cmd.AddCommand(newBackupCommand(ctx, nil))
+	cmd.AddCommand(newFilesCommand(ctx, nil))
 
 	return cmd
 }
 
-// Execute runs the root command.
+// Execute runs the root command. Ctrl-C or SIGTERM cancels the command's
+// context, so radio calls stop waiting and the port is closed cleanly.
 func Execute() error {
-	return newRootCommand().Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return newRootCommand().ExecuteContext(ctx)
 }
@@ -4,16 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"time"
 
 	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/coreyvan/chirp/pkg/radio"
 	"github.com/spf13/cobra"
 )
 
+const defaultAckTimeout = 30 * time.Second
+
 func newSendTextCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
 	var (
-		to      int64
-		channel int64
-		message string
+		to         int64
+		channel    int64
+		message    string
+		waitAck    bool
+		ackTimeout time.Duration
 	)
 
 	cmd := &cobra.Command{
@@ -21,36 +28,45 @@ func newSendTextCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
 		Short: "Send a text message",
 		Args:  wrapPositionalArgs(cobra.NoArgs),
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			if err := appnode.ValidateSendTextRequest(appnode.SendTextRequest{
+			req := appnode.SendTextRequest{
 				Message: message,
 				To:      to,
 				Channel: channel,
-			}); err != nil {
+				WaitAck: waitAck,
+			}
+			if err := appnode.ValidateSendTextRequest(req); err != nil {
 				return mapServiceError(err)
 			}
+			if ackTimeout <= 0 {
+				return newUserInputError(fmt.Errorf("--ack-timeout must be greater than 0"))
+			}
 
-			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(_ context.Context, radio Radio) error {
+			run := RadioRunnerFunc(func(runCtx context.Context, radio Radio) error {
 				service := appnode.NewService(radio)
-				result, err := service.SendText(cmd.Context(), appnode.SendTextRequest{
-					Message: message,
-					To:      to,
-					Channel: channel,
-				})
+				result, err := service.SendText(runCtx, req)
 				if err != nil {
 					return mapServiceError(err)
 				}
 
-				if cliCtx.JSON {
-					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{
-						"ok":      true,
-						"to":      result.To,
-						"channel": result.Channel,
-						"message": result.Message,
-					})
+				if err := writeSendTextResult(cmd.OutOrStdout(), result, cliCtx.JSON); err != nil {
+					return err
+				}
+				if result.Ack != nil && !result.Ack.Delivered() {
+					return fmt.Errorf("message %d not acknowledged: %s", result.Ack.PacketID, describeAck(result.Ack))
 				}
+				return nil
+			})
 
-				_, err = fmt.Fprintf(cmd.OutOrStdout(), "sent text to=%d channel=%d\n", result.To, result.Channel)
-				return err
+			if !waitAck {
+				return runWithRadio(cmd.Context(), cliCtx, opener, run)
+			}
+
+			// Mesh acknowledgements routinely take longer than --timeout, so the
+			// wait has its own deadline, reported as an ack timeout.
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, radio Radio) error {
+				ackCtx, cancel := context.WithTimeout(ctx, ackTimeout)
+				defer cancel()
+				return run(ackCtx, radio)
 			}))
 		},
 	}
@@ -58,7 +74,43 @@ func newSendTextCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
 	cmd.Flags().Int64Var(&to, "to", 0, "destination node number (0 for broadcast)")
 	cmd.Flags().Int64Var(&channel, "channel", 0, "channel index")
 	cmd.Flags().StringVar(&message, "message", "", "message text")
+	cmd.Flags().BoolVar(&waitAck, "wait-ack", false, "wait for the mesh to acknowledge the message; exits 1 on NAK or timeout")
+	cmd.Flags().DurationVar(&ackTimeout, "ack-timeout", defaultAckTimeout, "how long --wait-ack waits for an acknowledgement")
 	_ = cmd.MarkFlagRequired("message")
 
 	return cmd
 }
+
+func writeSendTextResult(out io.Writer, result appnode.SendTextResult, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(out).Encode(map[string]any{
+			"ok":      result.Ack == nil || result.Ack.Delivered(),
+			"to":      result.To,
+			"channel": result.Channel,
+			"message": result.Message,
+			"ack":     result.Ack,
+		})
+	}
+
+	if _, err := fmt.Fprintf(out, "sent text to=%d channel=%d\n", result.To, result.Channel); err != nil {
+		return err
+	}
+	if result.Ack == nil {
+		return nil
+	}
+	_, err := fmt.Fprintf(out, "ack id=%d %s\n", result.Ack.PacketID, describeAck(result.Ack))
+	return err
+}
+
+func describeAck(ack *appnode.AckResult) string {
+	switch radio.AckStatus(ack.Status) {
+	case radio.AckDelivered:
+		return "delivered by " + ack.From
+	case radio.AckImplicit:
+		return "implicit (rebroadcast heard)"
+	case radio.AckNak:
+		return fmt.Sprintf("nak %s from %s", ack.Error, ack.From)
+	default:
+		return ack.Status
+	}
+}
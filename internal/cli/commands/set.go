@@ -10,6 +10,7 @@ func newSetCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
 	}
 
 	cmd.AddCommand(newSetOwnerCommand(cliCtx, opener))
+	cmd.AddCommand(newSetHamCommand(cliCtx, opener))
 	cmd.AddCommand(newSetModemCommand(cliCtx, opener))
 	cmd.AddCommand(newSetLocationCommand(cliCtx, opener))
 	return cmd
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/spf13/cobra"
+)
+
+func newSetHamCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var (
+		req appnode.HamModeRequest
+		yes bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "ham",
+		Short: "Switch to licensed amateur radio operation",
+		Long: "Set the node up for licensed amateur use: the owner takes the call sign as\n" +
+			"its long name and is marked licensed, duty cycle limits are lifted, and the\n" +
+			"primary channel's PSK is removed, since amateur rules forbid encryption.\n" +
+			"--short keeps the current short name when not given.",
+		Example:     "  chirp set ham --callsign KD2ABC --short KD2A\n  chirp set ham --callsign KD2ABC --tx-power 27 --frequency 433.5 --yes",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ham, err := appnode.ValidateHamModeRequest(req)
+			if err != nil {
+				return mapServiceError(err)
+			}
+			prompt := fmt.Sprintf("Switch to ham mode as %s? This turns off encryption on the primary channel. [y/N] ", ham.CallSign)
+			if err := confirmOrCancel(cmd, yes, prompt, "set ham cancelled"); err != nil {
+				return err
+			}
+
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, r Radio) error {
+				owner, err := newService(cliCtx, r).SetHamMode(ctx, ham)
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				if cliCtx.JSON {
+					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{"ok": true, "owner": owner})
+				}
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "ham mode set as %s (%s); primary channel is unencrypted\n", owner.LongName, owner.ShortName)
+				return err
+			}))
+		},
+	}
+
+	cmd.Flags().StringVar(&req.CallSign, "callsign", "", "amateur radio call sign, such as KD2ABC")
+	cmd.Flags().StringVar(&req.ShortName, "short", "", "owner short name, up to 4 characters")
+	cmd.Flags().Int32Var(&req.TxPower, "tx-power", 0, "transmit power in dBm (0 for the region's default)")
+	cmd.Flags().Float32Var(&req.Frequency, "frequency", 0, "override frequency in MHz (0 to use the channel's)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation prompt")
+	_ = cmd.MarkFlagRequired("callsign")
+	return cmd
+}
@@ -29,9 +29,9 @@ func newSetLocationCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
 				return mapServiceError(err)
 			}
 
-			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(_ context.Context, radio Radio) error {
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(runCtx context.Context, radio Radio) error {
 				service := appnode.NewService(radio)
-				result, err := service.SetLocation(cmd.Context(), appnode.SetLocationRequest{
+				result, err := service.SetLocation(runCtx, appnode.SetLocationRequest{
 					LatI: latI,
 					LonI: lonI,
 					Alt:  alt,
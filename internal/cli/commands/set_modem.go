@@ -22,9 +22,9 @@ func newSetModemCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
 				return mapServiceError(err)
 			}
 
-			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(_ context.Context, radio Radio) error {
+			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(runCtx context.Context, radio Radio) error {
 				service := appnode.NewService(radio)
-				result, err := service.SetModem(cmd.Context(), appnode.SetModemRequest{Mode: normalizedMode})
+				result, err := service.SetModem(runCtx, appnode.SetModemRequest{Mode: normalizedMode})
 				if err != nil {
 					return mapServiceError(err)
 				}
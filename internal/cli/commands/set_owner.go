@@ -10,39 +10,61 @@ import (
 )
 
 func newSetOwnerCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
-	var name string
+	var (
+		req          appnode.SetOwnerRequest
+		licensed     bool
+		unmessagable bool
+	)
 
 	cmd := &cobra.Command{
 		Use:   "owner",
-		Short: "Set owner long name",
-		Args:  wrapPositionalArgs(cobra.NoArgs),
+		Short: "Set the owner's names and flags",
+		Long: "Change the owner's long name, short name and flags; anything not given keeps\n" +
+			"its value. The long name holds up to 39 bytes of UTF-8 and the short name up\n" +
+			"to 4 characters in 4 bytes, so one emoji fits. --licensed only marks the\n" +
+			"owner as a licensed ham; \"chirp set ham\" also sets the call sign and turns\n" +
+			"off encryption.",
+		Example: "  chirp set owner --long \"Moon Station\" --short MOON\n" +
+			"  chirp set owner --short 🌙 --unmessagable",
+		Args:        wrapPositionalArgs(cobra.NoArgs),
+		Annotations: map[string]string{remoteAdminAnnotation: "true"},
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			if err := appnode.ValidateSetOwnerRequest(appnode.SetOwnerRequest{Name: name}); err != nil {
+			if cmd.Flags().Changed("licensed") {
+				req.Licensed = &licensed
+			}
+			if cmd.Flags().Changed("unmessagable") {
+				req.Unmessagable = &unmessagable
+			}
+			if err := appnode.ValidateSetOwnerRequest(req); err != nil {
 				return mapServiceError(err)
 			}
 
-			return runWithRadio(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(_ context.Context, radio Radio) error {
-				service := appnode.NewService(radio)
-				result, err := service.SetOwner(cmd.Context(), appnode.SetOwnerRequest{Name: name})
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(runCtx context.Context, radio Radio) error {
+				owner, err := newService(cliCtx, radio).SetOwner(runCtx, req)
 				if err != nil {
 					return mapServiceError(err)
 				}
 
 				if cliCtx.JSON {
 					return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]any{
-						"ok":   true,
-						"name": result.Name,
+						"ok":    true,
+						"name":  owner.LongName,
+						"owner": owner,
 					})
 				}
 
-				_, err = fmt.Fprintf(cmd.OutOrStdout(), "owner set to %q\n", result.Name)
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "owner set to %q (%s)\n", owner.LongName, owner.ShortName)
 				return err
 			}))
 		},
 	}
 
-	cmd.Flags().StringVar(&name, "name", "", "owner long name")
-	_ = cmd.MarkFlagRequired("name")
+	cmd.Flags().StringVar(&req.LongName, "long", "", "owner long name")
+	cmd.Flags().StringVar(&req.LongName, "name", "", "owner long name")
+	_ = cmd.Flags().MarkDeprecated("name", "use --long instead")
+	cmd.Flags().StringVar(&req.ShortName, "short", "", "owner short name, up to 4 characters")
+	cmd.Flags().BoolVar(&licensed, "licensed", false, "mark the owner as a licensed ham operator")
+	cmd.Flags().BoolVar(&unmessagable, "unmessagable", false, "tell other clients the node does not read direct messages")
 
 	return cmd
 }
@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	appnode "github.com/coreyvan/chirp/internal/app/node"
+	"github.com/coreyvan/chirp/pkg/radio"
+	"github.com/spf13/cobra"
+)
+
+const defaultTracerouteTimeout = 60 * time.Second
+
+func newTracerouteCommand(cliCtx *Context, opener radioOpener) *cobra.Command {
+	var (
+		to              string
+		channel         uint32
+		responseTimeout time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "traceroute",
+		Short: "Trace the route to a node and back",
+		Args:  wrapPositionalArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			dest, err := radio.ParseNodeID(to)
+			if err != nil {
+				return newUserInputError(fmt.Errorf("--to: %w", err))
+			}
+			if responseTimeout <= 0 {
+				return newUserInputError(fmt.Errorf("--response-timeout must be greater than 0"))
+			}
+
+			// The answer crosses the mesh twice, so like --wait-ack the wait has
+			// its own deadline instead of --timeout.
+			return runWithRadioNoTimeout(cmd.Context(), cliCtx, opener, RadioRunnerFunc(func(ctx context.Context, radio Radio) error {
+				traceCtx, cancel := context.WithTimeout(ctx, responseTimeout)
+				defer cancel()
+
+				service := appnode.NewService(radio)
+				result, err := service.Traceroute(traceCtx, appnode.TracerouteRequest{To: dest, Channel: channel})
+				if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+					return newRuntimeError(fmt.Errorf("no traceroute response from %s within %s", to, responseTimeout))
+				}
+				if err != nil {
+					return mapServiceError(err)
+				}
+
+				return writeTracerouteResult(cmd.OutOrStdout(), result, cliCtx.JSON)
+			}))
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", "", "destination node ID, such as !a1b2c3d4")
+	cmd.Flags().Uint32Var(&channel, "channel", 0, "channel index")
+	cmd.Flags().DurationVar(&responseTimeout, "response-timeout", defaultTracerouteTimeout, "how long to wait for the route to come back")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func writeTracerouteResult(out io.Writer, result appnode.TracerouteResult, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(out).Encode(result)
+	}
+
+	if _, err := fmt.Fprintf(out, "traceroute to %s\n", result.To); err != nil {
+		return err
+	}
+	return printKeyValueTable(out, []keyValueRow{
+		{Key: "forward", Value: appnode.FormatRoute(result.Forward)},
+		{Key: "return", Value: appnode.FormatRoute(result.Return)},
+	})
+}
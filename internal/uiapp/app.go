@@ -11,10 +11,15 @@ import (
 	appnode "github.com/coreyvan/chirp/internal/app/node"
 	"github.com/coreyvan/chirp/pkg/radio"
 	"github.com/coreyvan/chirp/pkg/serial"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
-const listenerEventName = "listener:line"
+const (
+	listenerEventName  = "listener:line"
+	statsCheckInterval = 5 * time.Second
+	loadInfoTimeout    = 10 * time.Second
+)
 
 type App struct {
 	ctx context.Context
@@ -36,6 +41,11 @@ type InfoView struct {
 	Summary appnode.InfoSummary `json:"summary"`
 }
 
+type NodesView struct {
+	Self  string              `json:"self"`
+	Nodes []appnode.NodeEntry `json:"nodes"`
+}
+
 type ListenerStatus struct {
 	Running bool `json:"running"`
 }
@@ -81,6 +91,7 @@ func (a *App) ListPorts() ([]string, error) {
 	return trimmed, nil
 }
 
+// Connect opens the radio at port, which may be a serial device or a tcp:// address.
 func (a *App) Connect(port string) error {
 	selectedPort := strings.TrimSpace(port)
 	if selectedPort == "" {
@@ -88,7 +99,8 @@ func (a *App) Connect(port string) error {
 	}
 
 	newRadio := &radio.Radio{}
-	if err := newRadio.Init(selectedPort); err != nil {
+	if err := newRadio.Init(a.currentContext(), selectedPort); err != nil {
+		_ = newRadio.Close()
 		return fmt.Errorf("failed to connect to %q: %w", selectedPort, err)
 	}
 
@@ -109,6 +121,16 @@ func (a *App) Connect(port string) error {
 	return nil
 }
 
+// ConnectHost opens a TCP connection to a meshtasticd instance or WiFi node at host[:port].
+func (a *App) ConnectHost(host string) error {
+	selectedHost := strings.TrimSpace(host)
+	if selectedHost == "" {
+		return errors.New("host is required")
+	}
+
+	return a.Connect(radio.TCPScheme + selectedHost)
+}
+
 func (a *App) Disconnect() error {
 	if err := a.StopListener(); err != nil {
 		return err
@@ -147,8 +169,11 @@ func (a *App) LoadInfo() (InfoView, error) {
 		return InfoView{}, err
 	}
 
+	ctx, cancel := context.WithTimeout(a.currentContext(), loadInfoTimeout)
+	defer cancel()
+
 	service := appnode.NewService(r)
-	info, err := service.Info(a.currentContext())
+	info, err := service.Info(ctx)
 	if err != nil {
 		return InfoView{}, err
 	}
@@ -156,6 +181,25 @@ func (a *App) LoadInfo() (InfoView, error) {
 	return InfoView{Summary: info.Summary}, nil
 }
 
+// LoadNodes fetches the node DB, most recently heard first.
+func (a *App) LoadNodes() (NodesView, error) {
+	r, err := a.currentRadio()
+	if err != nil {
+		return NodesView{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(a.currentContext(), loadInfoTimeout)
+	defer cancel()
+
+	service := appnode.NewService(r)
+	result, err := service.Nodes(ctx, appnode.NodeQuery{Sort: appnode.SortLastHeard})
+	if err != nil {
+		return NodesView{}, err
+	}
+
+	return NodesView{Self: result.Self, Nodes: result.Nodes}, nil
+}
+
 func (a *App) StartListener() error {
 	a.mu.Lock()
 	if a.radio == nil {
@@ -212,44 +256,94 @@ func (a *App) runListener(ctx context.Context, done chan struct{}, r *radio.Radi
 
 	a.emitListenerLine("EVT", fmt.Sprintf("rx listener started on %s", port), appnode.StreamCategoryEvent)
 
-	if responses, err := r.GetRadioInfo(); err != nil {
+	if responses, err := r.GetRadioInfo(ctx); err != nil {
 		a.emitListenerLine("ERR", fmt.Sprintf("get radio info: %v", err), appnode.StreamCategoryEvent)
 	} else {
-		for _, fr := range responses {
-			for _, line := range appnode.RenderFromRadio(fr) {
-				a.emitListenerLine(line.Label, line.Message, line.Category)
-			}
+		a.emitFromRadio(responses...)
+	}
+
+	sup := radio.NewSupervisor(port, r, radio.NewRadio)
+	sup.OnEvent = func(ev radio.ConnEvent) {
+		line := appnode.RenderConnEvent(ev)
+		a.emitListenerLine(line.Label, line.Message, line.Category)
+	}
+
+	// Subscribe after the handshake so its responses are not rendered twice. The
+	// radio fans frames out to every subscriber, so commands issued from the UI
+	// while the listener runs get their own copies.
+	sub := r.Subscribe(nil)
+	defer func() { sub.Close() }()
+
+	// reconnect replaces the app's radio with a freshly handshaken one. It
+	// returns false when the listener should stop instead.
+	reconnect := func(cause error) bool {
+		sub.Close()
+		next, responses, err := sup.Reconnect(ctx, cause)
+		if err != nil {
+			return false
+		}
+		if !a.replaceRadio(r, next, sup.Target()) {
+			_ = next.Close()
+			return false
 		}
+		r = next
+		a.emitFromRadio(responses...)
+		sub = r.Subscribe(nil)
+		return true
 	}
 
+	statsTicker := time.NewTicker(statsCheckInterval)
+	defer statsTicker.Stop()
+	var lastStats radio.Stats
+
 	for {
 		select {
+		case <-statsTicker.C:
+			if stats := r.Stats(); stats.Errors() != lastStats.Errors() {
+				a.emitListenerLine("ERR", fmt.Sprintf("framing %s", stats), appnode.StreamCategoryEvent)
+				lastStats = stats
+			}
 		case <-ctx.Done():
 			a.emitListenerLine("EVT", "rx listener stopped", appnode.StreamCategoryEvent)
 			return
-		default:
-		}
-
-		fromRadioPackets, err := r.ReadResponse(true)
-		if err != nil {
-			a.emitListenerLine("ERR", fmt.Sprintf("read response: %v", err), appnode.StreamCategoryEvent)
-			select {
-			case <-ctx.Done():
+		case fr, ok := <-sub.C():
+			cause := radio.ErrRebooted
+			if !ok {
+				cause = radio.ErrClosed
+			} else {
+				a.emitFromRadio(fr)
+				if !fr.GetRebooted() {
+					continue
+				}
+			}
+			if !reconnect(cause) {
+				<-ctx.Done()
 				a.emitListenerLine("EVT", "rx listener stopped", appnode.StreamCategoryEvent)
 				return
-			case <-time.After(300 * time.Millisecond):
 			}
-			continue
+			lastStats = radio.Stats{}
 		}
+	}
+}
 
-		if len(fromRadioPackets) == 0 {
-			continue
-		}
+// replaceRadio installs next as the connected radio if old is still the
+// current one, so a reconnect never resurrects a radio the user disconnected.
+func (a *App) replaceRadio(old, next *radio.Radio, port string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-		for _, fr := range fromRadioPackets {
-			for _, line := range appnode.RenderFromRadio(fr) {
-				a.emitListenerLine(line.Label, line.Message, line.Category)
-			}
+	if a.radio != old {
+		return false
+	}
+	a.radio = next
+	a.port = port
+	return true
+}
+
+func (a *App) emitFromRadio(responses ...*pb.FromRadio) {
+	for _, fr := range responses {
+		for _, line := range appnode.RenderFromRadio(fr) {
+			a.emitListenerLine(line.Label, line.Message, line.Category)
 		}
 	}
 }
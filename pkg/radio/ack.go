@@ -0,0 +1,162 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// AckStatus is how the mesh answered a packet sent with WantAck.
+type AckStatus string
+
+const (
+	// AckDelivered means the destination acknowledged the packet.
+	AckDelivered AckStatus = "delivered"
+	// AckImplicit means our node heard the packet rebroadcast but the
+	// destination did not answer; it is the only ack a broadcast gets.
+	AckImplicit AckStatus = "implicit"
+	// AckNak means the packet failed; Ack.Reason says why.
+	AckNak AckStatus = "nak"
+	// AckTimeout means no answer arrived before the context deadline.
+	AckTimeout AckStatus = "timeout"
+)
+
+// Ack is the outcome of waiting for a packet's acknowledgement.
+type Ack struct {
+	PacketID uint32
+	Status   AckStatus
+	// From is the node that sent the routing answer; zero on timeout.
+	From   uint32
+	Reason pb.Routing_Error
+}
+
+// ResponsesTo accepts decoded mesh packets that answer the packet with the given ID.
+func ResponsesTo(id uint32) Filter {
+	return func(fr *pb.FromRadio) bool {
+		decoded := fr.GetPacket().GetDecoded()
+		return decoded != nil && decoded.GetRequestId() == id
+	}
+}
+
+// sendAndAwaitAck sends p with WantAck set and waits for the ROUTING_APP packet
+// that answers it. A deadline on ctx is reported as AckTimeout rather than an
+// error; cancellation and a closed connection are errors.
+func (r *Radio) sendAndAwaitAck(ctx context.Context, p *pb.MeshPacket) (Ack, error) {
+	return r.sendAndAwaitAckFrom(ctx, p, func(Ack) bool { return true })
+}
+
+// sendAndAwaitDelivery is sendAndAwaitAck for packets that only count once the
+// destination answers: implicit acks, which just mean a neighbour heard the
+// packet, are skipped.
+func (r *Radio) sendAndAwaitDelivery(ctx context.Context, p *pb.MeshPacket) (Ack, error) {
+	return r.sendAndAwaitAckFrom(ctx, p, func(ack Ack) bool { return ack.Status != AckImplicit })
+}
+
+// sendAndAwaitAckFrom sends p and returns the first ack that final accepts.
+func (r *Radio) sendAndAwaitAckFrom(ctx context.Context, p *pb.MeshPacket, final func(Ack) bool) (Ack, error) {
+	if p.Id == 0 {
+		p.Id = newPacketID()
+	}
+	p.WantAck = true
+
+	out, err := proto.Marshal(&pb.ToRadio{PayloadVariant: &pb.ToRadio_Packet{Packet: p}})
+	if err != nil {
+		return Ack{}, err
+	}
+
+	// Subscribe before sending so a fast answer cannot be missed.
+	sub := r.Subscribe(ResponsesTo(p.Id))
+	defer sub.Close()
+
+	if err := r.SendPacket(ctx, out); err != nil {
+		return Ack{}, err
+	}
+
+	for {
+		select {
+		case fr, ok := <-sub.C():
+			if !ok {
+				return Ack{}, fmt.Errorf("wait for ack: %w", r.readError())
+			}
+			if ack, ok := parseAck(fr.GetPacket(), p); ok && final(ack) {
+				return ack, nil
+			}
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return Ack{PacketID: p.Id, Status: AckTimeout}, nil
+			}
+			return Ack{}, ctx.Err()
+		}
+	}
+}
+
+// sendAndAwaitResponse sends p with WantResponse set and returns the first
+// packet that answers it with a payload. Routing acks along the way are
+// skipped; a NAK is returned as a *RoutingError.
+func (r *Radio) sendAndAwaitResponse(ctx context.Context, p *pb.MeshPacket) (*pb.MeshPacket, error) {
+	if p.Id == 0 {
+		p.Id = newPacketID()
+	}
+	p.GetDecoded().WantResponse = true
+
+	out, err := proto.Marshal(&pb.ToRadio{PayloadVariant: &pb.ToRadio_Packet{Packet: p}})
+	if err != nil {
+		return nil, err
+	}
+
+	sub := r.Subscribe(ResponsesTo(p.Id))
+	defer sub.Close()
+
+	if err := r.SendPacket(ctx, out); err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case fr, ok := <-sub.C():
+			if !ok {
+				return nil, fmt.Errorf("wait for response: %w", r.readError())
+			}
+			resp := fr.GetPacket()
+			if ack, ok := parseAck(resp, p); ok {
+				if ack.Status == AckNak {
+					return nil, &RoutingError{From: ack.From, Reason: ack.Reason}
+				}
+				continue
+			}
+			return resp, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// parseAck interprets a response to sent as an acknowledgement. Answers that
+// are not routing packets, such as admin responses, are ignored.
+func parseAck(resp, sent *pb.MeshPacket) (Ack, bool) {
+	decoded := resp.GetDecoded()
+	if decoded.GetPortnum() != pb.PortNum_ROUTING_APP {
+		return Ack{}, false
+	}
+
+	var routing pb.Routing
+	if err := proto.Unmarshal(decoded.GetPayload(), &routing); err != nil {
+		return Ack{}, false
+	}
+
+	ack := Ack{PacketID: sent.GetId(), From: resp.GetFrom(), Reason: routing.GetErrorReason()}
+	switch {
+	case ack.Reason != pb.Routing_NONE:
+		ack.Status = AckNak
+	case sent.GetTo() != broadcastNum && resp.GetFrom() == sent.GetTo():
+		ack.Status = AckDelivered
+	default:
+		// Our own node answers with an implicit ack once it hears a neighbour
+		// rebroadcast the packet.
+		ack.Status = AckImplicit
+	}
+	return ack, true
+}
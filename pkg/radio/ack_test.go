@@ -0,0 +1,90 @@
+package radio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// routingReply answers a sent packet with a ROUTING_APP packet from the given
+// node. A zero requestID answers the packet that was sent.
+func routingReply(t *testing.T, sent *pb.MeshPacket, from, requestID uint32, reason pb.Routing_Error) *pb.FromRadio {
+	t.Helper()
+	payload, err := proto.Marshal(&pb.Routing{Variant: &pb.Routing_ErrorReason{ErrorReason: reason}})
+	require.NoError(t, err)
+	if requestID == 0 {
+		requestID = sent.GetId()
+	}
+	return &pb.FromRadio{PayloadVariant: &pb.FromRadio_Packet{Packet: &pb.MeshPacket{
+		From: from,
+		To:   1,
+		PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+			Portnum:   pb.PortNum_ROUTING_APP,
+			Payload:   payload,
+			RequestId: requestID,
+		}},
+	}}}
+}
+
+func TestSendTextMessageAckReportsOutcome(t *testing.T) {
+	const self, peer = uint32(1), uint32(0x0a0b0c0d)
+
+	for _, tc := range []struct {
+		name   string
+		to     int64
+		from   uint32
+		reason pb.Routing_Error
+		status AckStatus
+	}{
+		{name: "delivered", to: int64(peer), from: peer, status: AckDelivered},
+		// A relay heard is not delivery, so a DM keeps waiting for its destination.
+		{name: "implicit", to: int64(peer), from: self, status: AckTimeout},
+		{name: "broadcast", to: 0, from: self, status: AckImplicit},
+		{name: "nak", to: int64(peer), from: self, reason: pb.Routing_MAX_RETRANSMIT, status: AckNak},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &mockStreamer{idle: true}
+			m.respond = func(tr *pb.ToRadio) []*pb.FromRadio {
+				sent := tr.GetPacket()
+				return []*pb.FromRadio{
+					routingReply(t, sent, peer, sent.GetId()+1, pb.Routing_NONE),
+					routingReply(t, sent, tc.from, 0, tc.reason),
+				}
+			}
+			r := &Radio{streamer: m}
+			defer r.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			ack, err := r.SendTextMessageAck(ctx, "hi", tc.to, 0)
+			require.NoError(t, err)
+			require.Equal(t, tc.status, ack.Status)
+			wantFrom := tc.from
+			if tc.status == AckTimeout {
+				wantFrom = 0
+			}
+			require.Equal(t, wantFrom, ack.From)
+			require.Equal(t, tc.reason, ack.Reason)
+			require.Equal(t, decodeToRadio(t, m.writes[0]).GetPacket().GetId(), ack.PacketID)
+		})
+	}
+}
+
+func TestSendTextMessageAckTimesOut(t *testing.T) {
+	m := &mockStreamer{idle: true}
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	ack, err := r.SendTextMessageAck(ctx, "hi", 5, 0)
+	require.NoError(t, err)
+	require.Equal(t, AckTimeout, ack.Status)
+	require.NotZero(t, ack.PacketID)
+}
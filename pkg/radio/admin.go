@@ -0,0 +1,206 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// sessionLifetime is how long a remote node's session passkey is reused. The
+// firmware honours a passkey for 300 seconds; renewing a little early keeps a
+// write from racing its expiry on a slow mesh.
+const sessionLifetime = 280 * time.Second
+
+// adminSession is the session passkey a remote node handed out with its last
+// admin response. The firmware rejects remote writes that do not echo it.
+type adminSession struct {
+	passkey []byte
+	expires time.Time
+}
+
+// RequestAdmin sends msg to the node to, or to our own node when to is zero,
+// and returns the admin message it answers with, such as GetConfigResponse for
+// a GetConfigRequest. A remote node's answer also renews its session passkey.
+func (r *Radio) RequestAdmin(ctx context.Context, to uint32, msg *pb.AdminMessage) (*pb.AdminMessage, error) {
+	p, err := r.newAdminPacket(ctx, to, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.sendAndAwaitResponse(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := resp.GetDecoded()
+	if decoded.GetPortnum() != pb.PortNum_ADMIN_APP {
+		return nil, fmt.Errorf("admin request answered on %s", decoded.GetPortnum())
+	}
+	var answer pb.AdminMessage
+	if err := proto.Unmarshal(decoded.GetPayload(), &answer); err != nil {
+		return nil, fmt.Errorf("decode admin response: %w", err)
+	}
+	if p.GetPkiEncrypted() && len(answer.GetSessionPasskey()) > 0 {
+		r.storeSession(p.GetTo(), answer.GetSessionPasskey())
+	}
+	return &answer, nil
+}
+
+// SendAdmin sends msg to the node to, or to our own node when to is zero, and
+// waits for the node to acknowledge it. A NAK is returned as a *RoutingError
+// and a missing ack as ctx.Err().
+//
+// A remote node must acknowledge the write itself, and the write carries the
+// node's session passkey: one is requested first when none is held, and a
+// passkey the node has since rejected is renewed and the write sent once more.
+func (r *Radio) SendAdmin(ctx context.Context, to uint32, msg *pb.AdminMessage) error {
+	to, remote, err := r.adminDestination(ctx, to)
+	if err != nil {
+		return err
+	}
+	if !remote {
+		return r.sendAdminOnce(ctx, to, msg)
+	}
+
+	if r.session(to) == nil {
+		if err := r.openSession(ctx, to); err != nil {
+			return err
+		}
+	}
+	err = r.sendAdminOnce(ctx, to, msg)
+	var routingErr *RoutingError
+	if !errors.As(err, &routingErr) || routingErr.Reason != pb.Routing_ADMIN_BAD_SESSION_KEY {
+		return err
+	}
+
+	r.dropSession(to)
+	if err := r.openSession(ctx, to); err != nil {
+		return err
+	}
+	return r.sendAdminOnce(ctx, to, msg)
+}
+
+func (r *Radio) sendAdminOnce(ctx context.Context, to uint32, msg *pb.AdminMessage) error {
+	p, err := r.newAdminPacket(ctx, to, msg)
+	if err != nil {
+		return err
+	}
+
+	ack, err := r.sendAndAwaitDelivery(ctx, p)
+	if err != nil {
+		return err
+	}
+	switch ack.Status {
+	case AckNak:
+		return &RoutingError{From: ack.From, Reason: ack.Reason}
+	case AckTimeout:
+		return ctx.Err()
+	}
+	return nil
+}
+
+// openSession asks the remote node to for its session key config, which
+// RequestAdmin keeps the passkey of.
+func (r *Radio) openSession(ctx context.Context, to uint32) error {
+	_, err := r.RequestAdmin(ctx, to, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigRequest{
+		GetConfigRequest: pb.AdminMessage_SESSIONKEY_CONFIG,
+	}})
+	if err != nil {
+		return fmt.Errorf("request session passkey: %w", err)
+	}
+	if r.session(to) == nil {
+		return fmt.Errorf("request session passkey: %s answered without one", FormatNodeID(to))
+	}
+	return nil
+}
+
+func (r *Radio) newAdminPacket(ctx context.Context, to uint32, msg *pb.AdminMessage) (*pb.MeshPacket, error) {
+	to, remote, err := r.adminDestination(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	if remote {
+		// Remote admin travels PKI encrypted to the node's public key; the
+		// firmware drops admin packets for other nodes sent in the clear.
+		msg = proto.Clone(msg).(*pb.AdminMessage)
+		msg.SessionPasskey = r.session(to)
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.MeshPacket{
+		To:           to,
+		HopLimit:     defaultHopLimit,
+		PkiEncrypted: remote,
+		PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+			Portnum: pb.PortNum_ADMIN_APP,
+			Payload: payload,
+		}},
+	}, nil
+}
+
+// adminDestination resolves to, where zero means our own node, and reports
+// whether it is a node other than ours.
+func (r *Radio) adminDestination(ctx context.Context, to uint32) (uint32, bool, error) {
+	local, err := r.localNodeNum(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	if to == 0 {
+		return local, false, nil
+	}
+	return to, to != local, nil
+}
+
+// session returns the passkey held for node num, or nil when there is none or
+// it has expired.
+func (r *Radio) session(num uint32) []byte {
+	r.sessionMu.Lock()
+	defer r.sessionMu.Unlock()
+
+	s, ok := r.sessions[num]
+	if !ok || time.Now().After(s.expires) {
+		return nil
+	}
+	return s.passkey
+}
+
+func (r *Radio) storeSession(num uint32, passkey []byte) {
+	r.sessionMu.Lock()
+	defer r.sessionMu.Unlock()
+
+	if r.sessions == nil {
+		r.sessions = make(map[uint32]adminSession)
+	}
+	r.sessions[num] = adminSession{
+		passkey: append([]byte(nil), passkey...),
+		expires: time.Now().Add(sessionLifetime),
+	}
+}
+
+func (r *Radio) dropSession(num uint32) {
+	r.sessionMu.Lock()
+	defer r.sessionMu.Unlock()
+	delete(r.sessions, num)
+}
+
+// localNodeNum returns our node's number, running a config-only handshake to
+// learn it when no handshake has happened on this connection yet.
+func (r *Radio) localNodeNum(ctx context.Context) (uint32, error) {
+	if num := r.NodeNum(); num != 0 {
+		return num, nil
+	}
+	if _, err := r.Handshake(ctx, HandshakeConfigOnly); err != nil {
+		return 0, fmt.Errorf("%w: %w", errNodeNumUnknown, err)
+	}
+	if num := r.NodeNum(); num != 0 {
+		return num, nil
+	}
+	return 0, errNodeNumUnknown
+}
@@ -0,0 +1,140 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRequestAdminLearnsNodeNumAndReturnsResponse(t *testing.T) {
+	const self = uint32(0x5ca1ab1e)
+
+	m := &mockStreamer{idle: true}
+	handshake := completeHandshake(&pb.FromRadio{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: self}}})
+	m.respond = func(tr *pb.ToRadio) []*pb.FromRadio {
+		sent := tr.GetPacket()
+		if sent == nil {
+			return handshake(tr)
+		}
+		payload, err := proto.Marshal(&pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetOwnerResponse{
+			GetOwnerResponse: &pb.User{LongName: "Base"},
+		}})
+		require.NoError(t, err)
+		return []*pb.FromRadio{{PayloadVariant: &pb.FromRadio_Packet{Packet: &pb.MeshPacket{
+			From: self,
+			To:   self,
+			PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+				Portnum:   pb.PortNum_ADMIN_APP,
+				Payload:   payload,
+				RequestId: sent.GetId(),
+			}},
+		}}}}
+	}
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	resp, err := r.RequestAdmin(context.Background(), 0, &pb.AdminMessage{
+		PayloadVariant: &pb.AdminMessage_GetOwnerRequest{GetOwnerRequest: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Base", resp.GetGetOwnerResponse().GetLongName())
+
+	require.Len(t, m.writes, 2)
+	sent := decodeToRadio(t, m.writes[1]).GetPacket()
+	require.Equal(t, self, sent.GetTo())
+	require.Equal(t, pb.PortNum_ADMIN_APP, sent.GetDecoded().GetPortnum())
+	require.True(t, sent.GetDecoded().GetWantResponse())
+}
+
+func TestSendAdminWaitsForAck(t *testing.T) {
+	const self = uint32(7)
+
+	for _, tc := range []struct {
+		name   string
+		reason pb.Routing_Error
+	}{
+		{name: "ack", reason: pb.Routing_NONE},
+		{name: "nak", reason: pb.Routing_BAD_REQUEST},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &mockStreamer{idle: true}
+			m.respond = func(tr *pb.ToRadio) []*pb.FromRadio {
+				return []*pb.FromRadio{routingReply(t, tr.GetPacket(), self, 0, tc.reason)}
+			}
+			r := &Radio{streamer: m, nodeNum: self}
+			defer r.Close()
+
+			err := r.SendAdmin(context.Background(), 0, &pb.AdminMessage{
+				PayloadVariant: &pb.AdminMessage_BeginEditSettings{BeginEditSettings: true},
+			})
+			if tc.reason == pb.Routing_NONE {
+				require.NoError(t, err)
+				return
+			}
+			var routingErr *RoutingError
+			require.True(t, errors.As(err, &routingErr))
+			require.Equal(t, tc.reason, routingErr.Reason)
+		})
+	}
+}
+
+func TestSendAdminToRemoteNodeRenewsRejectedSessionPasskey(t *testing.T) {
+	const self, peer = uint32(7), uint32(0x0badcafe)
+
+	m := &mockStreamer{idle: true}
+	current := []byte("fresh")
+	var writes []*pb.AdminMessage
+	m.respond = func(tr *pb.ToRadio) []*pb.FromRadio {
+		sent := tr.GetPacket()
+		require.Equal(t, peer, sent.GetTo())
+		require.True(t, sent.GetPkiEncrypted())
+		var msg pb.AdminMessage
+		require.NoError(t, proto.Unmarshal(sent.GetDecoded().GetPayload(), &msg))
+
+		if msg.GetGetConfigRequest() == pb.AdminMessage_SESSIONKEY_CONFIG {
+			payload, err := proto.Marshal(&pb.AdminMessage{
+				SessionPasskey: current,
+				PayloadVariant: &pb.AdminMessage_GetConfigResponse{GetConfigResponse: &pb.Config{
+					PayloadVariant: &pb.Config_Sessionkey{Sessionkey: &pb.Config_SessionkeyConfig{}},
+				}},
+			})
+			require.NoError(t, err)
+			return []*pb.FromRadio{{PayloadVariant: &pb.FromRadio_Packet{Packet: &pb.MeshPacket{
+				From: peer,
+				To:   self,
+				PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+					Portnum:   pb.PortNum_ADMIN_APP,
+					Payload:   payload,
+					RequestId: sent.GetId(),
+				}},
+			}}}}
+		}
+
+		writes = append(writes, &msg)
+		reason := pb.Routing_NONE
+		if string(msg.GetSessionPasskey()) != string(current) {
+			reason = pb.Routing_ADMIN_BAD_SESSION_KEY
+		}
+		// Our node hears the packet relayed before the peer answers.
+		return []*pb.FromRadio{
+			routingReply(t, sent, self, 0, pb.Routing_NONE),
+			routingReply(t, sent, peer, 0, reason),
+		}
+	}
+	r := &Radio{streamer: m, nodeNum: self}
+	defer r.Close()
+	r.storeSession(peer, []byte("stale"))
+
+	err := r.SendAdmin(context.Background(), peer, &pb.AdminMessage{
+		PayloadVariant: &pb.AdminMessage_SetOwner{SetOwner: &pb.User{LongName: "Hilltop"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, writes, 2)
+	require.Equal(t, "stale", string(writes[0].GetSessionPasskey()))
+	require.Equal(t, "fresh", string(writes[1].GetSessionPasskey()))
+	require.Equal(t, "fresh", string(r.session(peer)))
+}
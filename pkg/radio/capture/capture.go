@@ -0,0 +1,213 @@
+// Package capture records raw radio frames to .chirpcap files and replays them.
+//
+// A capture starts with the 8-byte magic "CHIRPCAP" and a version byte,
+// followed by records of:
+//
+//	direction  uint8   (1 = from radio, 2 = to radio)
+//	timestamp  int64   (unix nanoseconds, big-endian)
+//	length     uint32  (big-endian)
+//	frame      [length]byte (the full frame, including its 0x94 0xC3 header)
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	magic   = "CHIRPCAP"
+	version = byte(1)
+
+	recordHeaderLen = 1 + 8 + 4
+	maxRecordLen    = 64 * 1024
+)
+
+var (
+	ErrBadMagic           = errors.New("not a chirp capture file")
+	ErrUnsupportedVersion = errors.New("unsupported capture version")
+	ErrCorruptRecord      = errors.New("corrupt capture record")
+)
+
+// Direction says which way a recorded frame travelled.
+type Direction byte
+
+const (
+	FromRadio Direction = 1
+	ToRadio   Direction = 2
+)
+
+func (d Direction) String() string {
+	switch d {
+	case FromRadio:
+		return "from_radio"
+	case ToRadio:
+		return "to_radio"
+	default:
+		return fmt.Sprintf("direction(%d)", byte(d))
+	}
+}
+
+// Record is one timestamped frame.
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	Frame     []byte
+}
+
+// Writer appends records to a capture. It is safe for concurrent use.
+type Writer struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	now    func() time.Time
+}
+
+// Create creates (or truncates) a capture file at path.
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create capture: %w", err)
+	}
+
+	w, err := NewWriter(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+// NewWriter writes a capture header to w and returns a Writer for its records.
+func NewWriter(w io.Writer) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(magic); err != nil {
+		return nil, fmt.Errorf("write capture header: %w", err)
+	}
+	if err := bw.WriteByte(version); err != nil {
+		return nil, fmt.Errorf("write capture header: %w", err)
+	}
+
+	return &Writer{w: bw, now: time.Now}, nil
+}
+
+// RecordFrame stores frame with the current time.
+func (w *Writer) RecordFrame(dir Direction, frame []byte) error {
+	return w.Write(Record{Time: w.now(), Direction: dir, Frame: frame})
+}
+
+func (w *Writer) Write(rec Record) error {
+	if len(rec.Frame) > maxRecordLen {
+		return ErrCorruptRecord
+	}
+
+	var header [recordHeaderLen]byte
+	header[0] = byte(rec.Direction)
+	binary.BigEndian.PutUint64(header[1:9], uint64(rec.Time.UnixNano()))
+	binary.BigEndian.PutUint32(header[9:13], uint32(len(rec.Frame)))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(rec.Frame); err != nil {
+		return err
+	}
+	// Flush per record so a capture survives the process being killed mid-session.
+	return w.w.Flush()
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.w.Flush()
+	if w.closer != nil {
+		err = errors.Join(err, w.closer.Close())
+	}
+	return err
+}
+
+// Reader reads records from a capture.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader validates the capture header and returns a Reader for its records.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, ErrBadMagic
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, ErrBadMagic
+	}
+	if header[len(magic)] != version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, header[len(magic)])
+	}
+
+	return &Reader{r: br}, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the capture.
+func (r *Reader) Next() (Record, error) {
+	var header [recordHeaderLen]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Record{}, ErrCorruptRecord
+		}
+		return Record{}, err
+	}
+
+	length := binary.BigEndian.Uint32(header[9:13])
+	if length > maxRecordLen {
+		return Record{}, ErrCorruptRecord
+	}
+
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r.r, frame); err != nil {
+		return Record{}, ErrCorruptRecord
+	}
+
+	return Record{
+		Time:      time.Unix(0, int64(binary.BigEndian.Uint64(header[1:9]))),
+		Direction: Direction(header[0]),
+		Frame:     frame,
+	}, nil
+}
+
+// ReadFile loads every record from the capture at path.
+func ReadFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open capture: %w", err)
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read capture %q: %w", path, err)
+	}
+
+	var records []Record
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read capture %q: %w", path, err)
+		}
+		records = append(records, rec)
+	}
+}
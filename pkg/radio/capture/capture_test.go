@@ -0,0 +1,79 @@
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+
+	t0 := time.Unix(1700000000, 123)
+	require.NoError(t, w.Write(Record{Time: t0, Direction: ToRadio, Frame: []byte{0x94, 0xc3, 0x00, 0x01, 0x18}}))
+	require.NoError(t, w.Write(Record{Time: t0.Add(time.Second), Direction: FromRadio, Frame: []byte{0x94, 0xc3, 0x00, 0x00}}))
+	require.NoError(t, w.Close())
+
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+
+	rec, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, ToRadio, rec.Direction)
+	require.True(t, rec.Time.Equal(t0))
+	require.Equal(t, []byte{0x94, 0xc3, 0x00, 0x01, 0x18}, rec.Frame)
+
+	rec, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, FromRadio, rec.Direction)
+	require.True(t, rec.Time.Equal(t0.Add(time.Second)))
+
+	_, err = r.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestCreateAndReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.chirpcap")
+
+	w, err := Create(path)
+	require.NoError(t, err)
+	require.NoError(t, w.RecordFrame(FromRadio, []byte{0x01}))
+	require.NoError(t, w.RecordFrame(ToRadio, []byte{0x02}))
+	require.NoError(t, w.Close())
+
+	records, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, []byte{0x01}, records[0].Frame)
+	require.Equal(t, ToRadio, records[1].Direction)
+	require.False(t, records[1].Time.Before(records[0].Time))
+}
+
+func TestNewReaderRejectsBadHeader(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("NOTACAP\x01")))
+	require.ErrorIs(t, err, ErrBadMagic)
+
+	_, err = NewReader(bytes.NewReader([]byte("CHIRPCAP\x09")))
+	require.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestReaderReportsTruncatedRecord(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(Record{Time: time.Now(), Direction: FromRadio, Frame: []byte{1, 2, 3, 4}}))
+
+	truncated := buf.Bytes()[:buf.Len()-2]
+	r, err := NewReader(bytes.NewReader(truncated))
+	require.NoError(t, err)
+
+	_, err = r.Next()
+	require.True(t, errors.Is(err, ErrCorruptRecord), "err = %v", err)
+}
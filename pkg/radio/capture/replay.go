@@ -0,0 +1,251 @@
+package capture
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio/frame"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// Replayer is a radio streamer that plays back the from-radio frames of a
+// capture, pacing them by their recorded timestamps divided by speed. A speed
+// of zero or less replays as fast as the reader consumes.
+//
+// Recorded want_config requests act as barriers: frames after one are held
+// until the client writes its own want_config, and the clock restarts from
+// there. The recorded ConfigCompleteId is rewritten to the client's nonce so
+// handshakes complete against a capture. Other writes are discarded, so
+// commands can run against a capture unchanged.
+type Replayer struct {
+	mu          sync.Mutex
+	records     []Record
+	barriers    map[int]uint32
+	speed       float64
+	next        int
+	pending     []byte
+	started     time.Time
+	base        time.Time
+	readTimeout time.Duration
+
+	// wantConfigs queues nonces written by the client that have not yet
+	// released a barrier; recorded and current map the last released barrier's
+	// nonce onto the client's.
+	wantConfigs []uint32
+	recorded    uint32
+	current     uint32
+	wrote       chan struct{}
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReplayer replays the from-radio frames in records.
+func NewReplayer(records []Record, speed float64) *Replayer {
+	kept := make([]Record, 0, len(records))
+	barriers := make(map[int]uint32)
+	for _, rec := range records {
+		switch rec.Direction {
+		case FromRadio:
+			kept = append(kept, rec)
+		case ToRadio:
+			if nonce := wantConfigID(rec.Frame); nonce != 0 {
+				barriers[len(kept)] = nonce
+				kept = append(kept, rec)
+			}
+		}
+	}
+
+	p := &Replayer{
+		records:  kept,
+		barriers: barriers,
+		speed:    speed,
+		wrote:    make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+	}
+	if len(kept) > 0 {
+		p.base = kept[0].Time
+	}
+	return p
+}
+
+// OpenReplay loads the capture at path for replay.
+func OpenReplay(path string, speed float64) (*Replayer, error) {
+	records, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(records, speed), nil
+}
+
+// Read returns the next frame's bytes once it is due. It returns 0 bytes and no
+// error when the read timeout expires first, like a quiet serial port, and
+// io.EOF after the last frame.
+func (p *Replayer) Read(b []byte) (int, error) {
+	for {
+		p.mu.Lock()
+		if len(p.pending) > 0 {
+			n := copy(b, p.pending)
+			p.pending = p.pending[n:]
+			p.mu.Unlock()
+			return n, nil
+		}
+		if p.next >= len(p.records) {
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		if p.started.IsZero() {
+			p.started = time.Now()
+		}
+		timeout := p.readTimeout
+
+		if nonce, ok := p.barriers[p.next]; ok {
+			if len(p.wantConfigs) == 0 {
+				p.mu.Unlock()
+				if !p.awaitWrite(timeout) {
+					return 0, io.EOF
+				}
+				if timeout > 0 {
+					return 0, nil
+				}
+				continue
+			}
+			p.recorded, p.current = nonce, p.wantConfigs[0]
+			p.wantConfigs = p.wantConfigs[1:]
+			p.started, p.base = time.Now(), p.records[p.next].Time
+			p.next++
+			p.mu.Unlock()
+			continue
+		}
+
+		wait := time.Until(p.dueLocked(p.next))
+		p.mu.Unlock()
+
+		if wait > 0 {
+			if timeout > 0 && wait > timeout {
+				if !p.sleep(timeout) {
+					return 0, io.EOF
+				}
+				return 0, nil
+			}
+			if !p.sleep(wait) {
+				return 0, io.EOF
+			}
+		}
+
+		p.mu.Lock()
+		p.pending = p.rewriteLocked(p.records[p.next].Frame)
+		p.next++
+		n := copy(b, p.pending)
+		p.pending = p.pending[n:]
+		p.mu.Unlock()
+		return n, nil
+	}
+}
+
+func (p *Replayer) dueLocked(i int) time.Time {
+	if p.speed <= 0 {
+		return p.started
+	}
+	offset := p.records[i].Time.Sub(p.base)
+	return p.started.Add(time.Duration(float64(offset) / p.speed))
+}
+
+// rewriteLocked swaps the recorded handshake nonce in a ConfigCompleteId frame
+// for the one the client asked for.
+func (p *Replayer) rewriteLocked(b []byte) []byte {
+	if p.recorded == 0 || p.recorded == p.current || len(b) < frame.HeaderLen {
+		return b
+	}
+
+	var fr pb.FromRadio
+	if err := proto.Unmarshal(b[frame.HeaderLen:], &fr); err != nil || fr.GetConfigCompleteId() != p.recorded {
+		return b
+	}
+	fr.PayloadVariant = &pb.FromRadio_ConfigCompleteId{ConfigCompleteId: p.current}
+	payload, err := proto.Marshal(&fr)
+	if err != nil {
+		return b
+	}
+	out, err := frame.Encode(payload)
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+func (p *Replayer) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-p.closed:
+		return false
+	}
+}
+
+// awaitWrite waits up to timeout, or indefinitely when timeout is zero, for the
+// client to write. It returns false once the replayer is closed.
+func (p *Replayer) awaitWrite(timeout time.Duration) bool {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-p.wrote:
+		return true
+	case <-expired:
+		return true
+	case <-p.closed:
+		return false
+	}
+}
+
+// Write notes want_config requests so the matching part of the capture can be
+// released, and discards everything else.
+func (p *Replayer) Write(b []byte) (int, error) {
+	if nonce := wantConfigID(b); nonce != 0 {
+		p.mu.Lock()
+		p.wantConfigs = append(p.wantConfigs, nonce)
+		p.mu.Unlock()
+
+		select {
+		case p.wrote <- struct{}{}:
+		default:
+		}
+	}
+	return len(b), nil
+}
+
+func (p *Replayer) Close() error {
+	p.closeOnce.Do(func() { close(p.closed) })
+	return nil
+}
+
+func (p *Replayer) SetReadTimeout(d time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readTimeout = d
+	return nil
+}
+
+// wantConfigID returns the want_config_id carried by a framed ToRadio, or 0
+// when b is anything else.
+func wantConfigID(b []byte) uint32 {
+	if len(b) < frame.HeaderLen || b[0] != frame.Start1 || b[1] != frame.Start2 {
+		return 0
+	}
+
+	var tr pb.ToRadio
+	if err := proto.Unmarshal(b[frame.HeaderLen:], &tr); err != nil {
+		return 0
+	}
+	return tr.GetWantConfigId()
+}
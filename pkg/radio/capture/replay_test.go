@@ -0,0 +1,141 @@
+package capture
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio/frame"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func framedMessage(t *testing.T, msg proto.Message) []byte {
+	t.Helper()
+	payload, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	b, err := frame.Encode(payload)
+	require.NoError(t, err)
+	return b
+}
+
+func replayRecords() []Record {
+	t0 := time.Unix(1700000000, 0)
+	return []Record{
+		{Time: t0, Direction: FromRadio, Frame: []byte{0x94, 0xc3, 0x00, 0x01, 0xaa}},
+		{Time: t0.Add(10 * time.Millisecond), Direction: ToRadio, Frame: []byte{0xff}},
+		{Time: t0.Add(200 * time.Millisecond), Direction: FromRadio, Frame: []byte{0x94, 0xc3, 0x00, 0x01, 0xbb}},
+	}
+}
+
+func TestReplayerReturnsInboundFramesThenEOF(t *testing.T) {
+	p := NewReplayer(replayRecords(), 0)
+
+	buf := make([]byte, 64)
+	n, err := p.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x94, 0xc3, 0x00, 0x01, 0xaa}, buf[:n])
+
+	n, err = p.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x94, 0xc3, 0x00, 0x01, 0xbb}, buf[:n])
+
+	_, err = p.Read(buf)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestReplayerSplitsFramesAcrossSmallReads(t *testing.T) {
+	p := NewReplayer(replayRecords(), 0)
+
+	buf := make([]byte, 2)
+	var got []byte
+	for len(got) < 5 {
+		n, err := p.Read(buf)
+		require.NoError(t, err)
+		got = append(got, buf[:n]...)
+	}
+	require.Equal(t, []byte{0x94, 0xc3, 0x00, 0x01, 0xaa}, got)
+}
+
+func TestReplayerPacesBySpeed(t *testing.T) {
+	p := NewReplayer(replayRecords(), 2)
+	buf := make([]byte, 64)
+
+	start := time.Now()
+	_, err := p.Read(buf)
+	require.NoError(t, err)
+	_, err = p.Read(buf)
+	require.NoError(t, err)
+
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(t, elapsed, 90*time.Millisecond)
+	require.Less(t, elapsed, 190*time.Millisecond)
+}
+
+func TestReplayerReadTimeoutReturnsNoData(t *testing.T) {
+	p := NewReplayer(replayRecords(), 1)
+	require.NoError(t, p.SetReadTimeout(10*time.Millisecond))
+	buf := make([]byte, 64)
+
+	_, err := p.Read(buf)
+	require.NoError(t, err)
+
+	n, err := p.Read(buf)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestReplayerDiscardsWritesAndStopsOnClose(t *testing.T) {
+	p := NewReplayer(replayRecords(), 0.001)
+
+	n, err := p.Write([]byte{1, 2, 3})
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	buf := make([]byte, 64)
+	_, err = p.Read(buf)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Read(buf)
+		done <- err
+	}()
+	require.NoError(t, p.Close())
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, io.EOF)
+	case <-time.After(time.Second):
+		t.Fatalf("Read did not return after Close")
+	}
+}
+
+func TestReplayerHoldsHandshakeUntilClientAsksAndRewritesNonce(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	wantConfig := func(id uint32) []byte {
+		return framedMessage(t, &pb.ToRadio{PayloadVariant: &pb.ToRadio_WantConfigId{WantConfigId: id}})
+	}
+	complete := framedMessage(t, &pb.FromRadio{PayloadVariant: &pb.FromRadio_ConfigCompleteId{ConfigCompleteId: 42}})
+
+	p := NewReplayer([]Record{
+		{Time: t0, Direction: ToRadio, Frame: wantConfig(42)},
+		{Time: t0.Add(10 * time.Millisecond), Direction: FromRadio, Frame: complete},
+	}, 0)
+	require.NoError(t, p.SetReadTimeout(10*time.Millisecond))
+	buf := make([]byte, 64)
+
+	n, err := p.Read(buf)
+	require.NoError(t, err)
+	require.Zero(t, n, "frames after a recorded want_config wait for the client's")
+
+	_, err = p.Write(wantConfig(7))
+	require.NoError(t, err)
+
+	n, err = p.Read(buf)
+	require.NoError(t, err)
+	var fr pb.FromRadio
+	require.NoError(t, proto.Unmarshal(buf[frame.HeaderLen:n], &fr))
+	require.Equal(t, uint32(7), fr.GetConfigCompleteId())
+}
@@ -0,0 +1,319 @@
+package radio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/coreyvan/chirp/pkg/radio/capture"
+	"github.com/coreyvan/chirp/pkg/radio/frame"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	subscriptionBuffer = 64
+	inboxBuffer        = 256
+)
+
+// ErrClosed is returned once the radio connection has stopped delivering frames.
+var ErrClosed = errors.New("radio connection closed")
+
+// Filter reports whether a subscriber wants to receive fr. A nil Filter accepts every frame.
+type Filter func(fr *pb.FromRadio) bool
+
+// PacketsOnPort accepts decoded mesh packets addressed to the given application port.
+func PacketsOnPort(port pb.PortNum) Filter {
+	return func(fr *pb.FromRadio) bool {
+		decoded := fr.GetPacket().GetDecoded()
+		return decoded != nil && decoded.GetPortnum() == port
+	}
+}
+
+// Subscription delivers FromRadio messages accepted by its filter until it is
+// closed or the radio's reader stops.
+type Subscription struct {
+	radio  *Radio
+	filter Filter
+	ch     chan *pb.FromRadio
+	closed bool
+
+	// A lossless subscription queues frames for pump instead of dropping them.
+	lossless bool
+	queue    []*pb.FromRadio
+	wake     chan struct{}
+	stop     chan struct{}
+	stopped  bool
+}
+
+// C returns the channel frames are delivered on. It is closed when the
+// subscription ends; unless the subscription is lossless, frames are dropped
+// rather than blocking the reader if the subscriber falls behind.
+func (s *Subscription) C() <-chan *pb.FromRadio {
+	return s.ch
+}
+
+// Close stops delivery and releases the subscription.
+func (s *Subscription) Close() {
+	s.radio.mu.Lock()
+	defer s.radio.mu.Unlock()
+
+	delete(s.radio.subs, s)
+	s.closeLocked()
+	if s.lossless && !s.stopped {
+		s.stopped = true
+		close(s.stop)
+	}
+}
+
+func (s *Subscription) closeLocked() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.lossless {
+		// pump closes ch once it has delivered what is queued.
+		s.signalLocked()
+		return
+	}
+	close(s.ch)
+}
+
+func (s *Subscription) signalLocked() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump moves queued frames of a lossless subscription onto its channel, and
+// closes the channel once the reader has stopped and the queue is drained or
+// the subscriber has closed the subscription.
+func (s *Subscription) pump() {
+	defer close(s.ch)
+
+	for {
+		s.radio.mu.Lock()
+		queue, closed := s.queue, s.closed
+		s.queue = nil
+		s.radio.mu.Unlock()
+
+		for _, fr := range queue {
+			select {
+			case s.ch <- fr:
+			case <-s.stop:
+				return
+			}
+		}
+		if len(queue) > 0 {
+			continue
+		}
+		if closed {
+			return
+		}
+		select {
+		case <-s.wake:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Subscribe registers a subscriber for frames accepted by filter and starts the
+// background reader if it is not already running.
+func (r *Radio) Subscribe(filter Filter) *Subscription {
+	return r.subscribe(filter, subscriptionBuffer)
+}
+
+func (r *Radio) subscribe(filter Filter, buffer int) *Subscription {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.subscribeLocked(filter, buffer)
+}
+
+// subscribeLossless is Subscribe for exchanges that a single dropped frame
+// would break, such as the config handshake: frames the subscriber has not
+// taken yet are queued without limit rather than dropped.
+func (r *Radio) subscribeLossless(filter Filter) *Subscription {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.newSubscriptionLocked(filter, 0)
+	s.lossless = true
+	s.wake = make(chan struct{}, 1)
+	s.stop = make(chan struct{})
+	go s.pump()
+	r.registerLocked(s)
+	return s
+}
+
+func (r *Radio) subscribeLocked(filter Filter, buffer int) *Subscription {
+	s := r.newSubscriptionLocked(filter, buffer)
+	r.registerLocked(s)
+	return s
+}
+
+func (r *Radio) newSubscriptionLocked(filter Filter, buffer int) *Subscription {
+	return &Subscription{
+		radio:  r,
+		filter: filter,
+		ch:     make(chan *pb.FromRadio, buffer),
+	}
+}
+
+func (r *Radio) registerLocked(s *Subscription) {
+	if r.readerExited {
+		s.closeLocked()
+		return
+	}
+	if r.subs == nil {
+		r.subs = make(map[*Subscription]struct{})
+	}
+	r.subs[s] = struct{}{}
+	r.startReaderLocked()
+}
+
+func (r *Radio) startReaderLocked() {
+	if r.readerDone != nil {
+		return
+	}
+	r.readerDone = make(chan struct{})
+	go r.readLoop()
+}
+
+// inboxSubscription returns the catch-all subscription backing ReadResponse.
+func (r *Radio) inboxSubscription() *Subscription {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.inbox == nil {
+		r.inbox = r.subscribeLocked(nil, inboxBuffer)
+	}
+	return r.inbox
+}
+
+// readError returns why the reader stopped, or nil while it is still running.
+func (r *Radio) readError() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.readErr
+}
+
+func (r *Radio) dispatch(fr *pb.FromRadio) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for s := range r.subs {
+		if s.filter != nil && !s.filter(fr) {
+			continue
+		}
+		if s.lossless {
+			s.queue = append(s.queue, fr)
+			s.signalLocked()
+			continue
+		}
+		select {
+		case s.ch <- fr:
+		default:
+			log.Printf("subscriber queue full, dropping %T", fr.GetPayloadVariant())
+		}
+	}
+}
+
+func (r *Radio) readLoop() {
+	err := r.readFrames()
+
+	r.mu.Lock()
+	r.readErr = err
+	r.readerExited = true
+	for s := range r.subs {
+		s.closeLocked()
+	}
+	r.subs = nil
+	done := r.readerDone
+	r.mu.Unlock()
+
+	close(done)
+}
+
+func (r *Radio) isClosing() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closing
+}
+
+// Stats reports how the background reader has fared on the wire.
+type Stats struct {
+	frame.Stats
+	// DecodeErrors counts well-framed payloads that were not valid FromRadio protobufs.
+	DecodeErrors uint64 `json:"decode_errors"`
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf(
+		"frames=%d bad_frames=%d bytes_skipped=%d decode_errors=%d",
+		s.Frames,
+		s.BadFrames,
+		s.BytesSkipped,
+		s.DecodeErrors,
+	)
+}
+
+// Errors reports the total number of corrupt frames and decode failures seen.
+// BytesSkipped is left out: on a serial link it is mostly the firmware's debug
+// log, which is routine rather than an error.
+func (s Stats) Errors() uint64 {
+	return s.BadFrames + s.DecodeErrors
+}
+
+// Stats returns framing and decode counters from the background reader.
+func (r *Radio) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// readFrames decodes framed FromRadio messages from the streamer and dispatches
+// them until the stream ends or the radio is closed. Whatever stops it, such as
+// a USB serial port that went away, is reported as ErrClosed: the connection
+// delivers nothing more either way, and callers reconnect on ErrClosed.
+func (r *Radio) readFrames() error {
+	if err := r.streamer.SetReadTimeout(readResponsePoll); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+
+	dec := frame.NewDecoder(r.streamer)
+	for {
+		payload, err := dec.Next()
+
+		r.mu.Lock()
+		r.stats.Stats = dec.Stats()
+		r.mu.Unlock()
+
+		if r.isClosing() || errors.Is(err, io.EOF) {
+			return ErrClosed
+		}
+		if errors.Is(err, frame.ErrNoData) || errors.Is(err, os.ErrDeadlineExceeded) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrClosed, err)
+		}
+
+		if raw, err := frame.Encode(payload); err == nil {
+			r.record(capture.FromRadio, raw)
+		}
+
+		fromRadio := &pb.FromRadio{}
+		if err := proto.Unmarshal(payload, fromRadio); err != nil {
+			r.mu.Lock()
+			r.stats.DecodeErrors++
+			r.mu.Unlock()
+			log.Printf("dropping undecodable frame: %v", err)
+			continue
+		}
+		r.dispatch(fromRadio)
+	}
+}
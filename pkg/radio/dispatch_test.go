@@ -0,0 +1,209 @@
+package radio
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio/frame"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func framedFromRadio(t *testing.T, msgs ...*pb.FromRadio) []byte {
+	t.Helper()
+	var out []byte
+	for _, msg := range msgs {
+		payload, err := proto.Marshal(msg)
+		require.NoError(t, err)
+		out = append(out, framed(payload)...)
+	}
+	return out
+}
+
+func textPacket(from uint32, text string) *pb.FromRadio {
+	return &pb.FromRadio{PayloadVariant: &pb.FromRadio_Packet{Packet: &pb.MeshPacket{
+		From: from,
+		PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+			Portnum: pb.PortNum_TEXT_MESSAGE_APP,
+			Payload: []byte(text),
+		}},
+	}}}
+}
+
+func receiveAll(t *testing.T, sub *Subscription) []*pb.FromRadio {
+	t.Helper()
+	var got []*pb.FromRadio
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case fr, ok := <-sub.C():
+			if !ok {
+				return got
+			}
+			got = append(got, fr)
+		case <-timeout:
+			t.Fatalf("subscription was not closed")
+		}
+	}
+}
+
+func TestDispatchFansOutToEverySubscriber(t *testing.T) {
+	stream := framedFromRadio(t,
+		&pb.FromRadio{PayloadVariant: &pb.FromRadio_Rebooted{Rebooted: true}},
+		textPacket(7, "hello"),
+	)
+
+	m := &mockStreamer{readSteps: stepsFromBytes(stream)}
+	r := &Radio{streamer: m}
+
+	m.mu.Lock()
+	all := r.Subscribe(nil)
+	texts := r.Subscribe(PacketsOnPort(pb.PortNum_TEXT_MESSAGE_APP))
+	m.mu.Unlock()
+
+	gotAll := receiveAll(t, all)
+	gotTexts := receiveAll(t, texts)
+
+	require.Len(t, gotAll, 2)
+	require.True(t, gotAll[0].GetRebooted())
+	require.Len(t, gotTexts, 1)
+	require.Equal(t, "hello", string(gotTexts[0].GetPacket().GetDecoded().GetPayload()))
+}
+
+func TestLosslessSubscriptionQueuesBurstForSlowSubscriber(t *testing.T) {
+	var msgs []*pb.FromRadio
+	for i := range 3 * subscriptionBuffer {
+		msgs = append(msgs, textPacket(uint32(i), "burst"))
+	}
+	m := &mockStreamer{readSteps: stepsFromBytes(framedFromRadio(t, msgs...))}
+	r := &Radio{streamer: m}
+
+	m.mu.Lock()
+	lossy := r.Subscribe(nil)
+	lossless := r.subscribeLossless(nil)
+	done := r.readerDone
+	m.mu.Unlock()
+
+	// Read nothing until the whole burst has been dispatched.
+	<-done
+
+	require.Len(t, receiveAll(t, lossy), subscriptionBuffer)
+	got := receiveAll(t, lossless)
+	require.Len(t, got, len(msgs))
+	for i, fr := range got {
+		require.Equal(t, uint32(i), fr.GetPacket().GetFrom())
+	}
+}
+
+func TestLosslessSubscriptionCloseStopsUnreadDelivery(t *testing.T) {
+	m := &mockStreamer{idle: true}
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	sub := r.subscribeLossless(nil)
+	r.dispatch(textPacket(1, "unread"))
+	sub.Close()
+	sub.Close()
+
+	receiveAll(t, sub)
+}
+
+func TestSubscribeAfterReaderExitReturnsClosedSubscription(t *testing.T) {
+	m := &mockStreamer{readSteps: stepsFromBytes(nil)}
+	r := &Radio{streamer: m}
+
+	receiveAll(t, r.Subscribe(nil))
+
+	sub := r.Subscribe(nil)
+	_, ok := <-sub.C()
+	require.False(t, ok)
+}
+
+func TestSubscriptionCloseStopsDelivery(t *testing.T) {
+	m := &mockStreamer{idle: true}
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	sub := r.Subscribe(nil)
+	sub.Close()
+	sub.Close()
+
+	_, ok := <-sub.C()
+	require.False(t, ok)
+}
+
+func TestReadResponseDoesNotStealGetRadioInfoFrames(t *testing.T) {
+	m := &mockStreamer{
+		idle:    true,
+		respond: completeHandshake(&pb.FromRadio{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 5}}}),
+	}
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	// Open the inbox before the handshake so both consumers see its replies.
+	r.OpenInbox()
+
+	info, err := r.GetRadioInfo(context.Background())
+	require.NoError(t, err)
+	require.Len(t, info, 2)
+
+	listened, err := r.ReadResponse(context.Background(), true)
+	require.NoError(t, err)
+	require.Len(t, listened, 2)
+}
+
+func TestStatsCountFramingAndDecodeErrors(t *testing.T) {
+	var stream []byte
+	stream = append(stream, []byte("junk")...)
+	stream = append(stream, frame.Start1, frame.Start2, 0x02, 0x01)
+	stream = append(stream, framed([]byte{0xff, 0xff, 0xff})...)
+	stream = append(stream, framedFromRadio(t, textPacket(1, "ok"))...)
+
+	m := &mockStreamer{readSteps: stepsFromBytes(stream)}
+	r := &Radio{streamer: m}
+
+	got := receiveAll(t, r.Subscribe(nil))
+	require.Len(t, got, 1)
+
+	stats := r.Stats()
+	require.Equal(t, uint64(2), stats.Frames)
+	require.Equal(t, uint64(1), stats.BadFrames)
+	require.Equal(t, uint64(len("junk")+4), stats.BytesSkipped)
+	require.Equal(t, uint64(1), stats.DecodeErrors)
+	require.Equal(t, uint64(2), stats.Errors())
+}
+
+func TestStatsSkippedDebugOutputIsNotAnError(t *testing.T) {
+	stream := append([]byte("INFO  | ??:??:?? 2 [Router] Lora RX\r\n"), framedFromRadio(t, textPacket(1, "ok"))...)
+	m := &mockStreamer{readSteps: stepsFromBytes(stream)}
+	r := &Radio{streamer: m}
+
+	receiveAll(t, r.Subscribe(nil))
+
+	stats := r.Stats()
+	require.NotZero(t, stats.BytesSkipped)
+	require.Zero(t, stats.Errors())
+}
+
+func TestSendPacketSerializesWriters(t *testing.T) {
+	m := &mockStreamer{}
+	r := &Radio{streamer: m}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, r.SendPacket(context.Background(), []byte{0x01, 0x02}))
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, m.writes, 20)
+	for _, w := range m.writes {
+		require.Equal(t, framed([]byte{0x01, 0x02}), w)
+	}
+}
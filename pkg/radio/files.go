@@ -0,0 +1,68 @@
+package radio
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// XModemPackets accepts the XModem packets the node sends during a file
+// transfer.
+func XModemPackets() Filter {
+	return func(fr *pb.FromRadio) bool {
+		return fr.GetXmodemPacket() != nil
+	}
+}
+
+// xmodemConn carries XModem packets over the radio's stream. XModem only
+// reaches the connected node, never one across the mesh.
+type xmodemConn struct {
+	radio *Radio
+	sub   *Subscription
+}
+
+// openXModem subscribes to XModem packets before anything is sent, so the
+// node's first answer cannot be missed.
+func (r *Radio) openXModem() *xmodemConn {
+	return &xmodemConn{radio: r, sub: r.Subscribe(XModemPackets())}
+}
+
+func (c *xmodemConn) Send(ctx context.Context, p *pb.XModem) error {
+	out, err := proto.Marshal(&pb.ToRadio{PayloadVariant: &pb.ToRadio_XmodemPacket{XmodemPacket: p}})
+	if err != nil {
+		return err
+	}
+	return c.radio.SendPacket(ctx, out)
+}
+
+func (c *xmodemConn) Receive(ctx context.Context) (*pb.XModem, error) {
+	select {
+	case fr, ok := <-c.sub.C():
+		if !ok {
+			return nil, fmt.Errorf("wait for xmodem packet: %w", c.radio.readError())
+		}
+		return fr.GetXmodemPacket(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// GetFile downloads the file name from the node's filesystem into w over
+// XModem and returns the number of bytes written.
+func (r *Radio) GetFile(ctx context.Context, name string, w io.Writer, opts xmodem.Options) (int64, error) {
+	conn := r.openXModem()
+	defer conn.sub.Close()
+	return xmodem.Receive(ctx, conn, name, w, opts)
+}
+
+// PutFile uploads the contents of rd to the node's filesystem as the file
+// name over XModem and returns the number of bytes sent.
+func (r *Radio) PutFile(ctx context.Context, name string, rd io.Reader, opts xmodem.Options) (int64, error) {
+	conn := r.openXModem()
+	defer conn.sub.Close()
+	return xmodem.Send(ctx, conn, name, rd, opts)
+}
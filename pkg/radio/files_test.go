@@ -0,0 +1,39 @@
+package radio
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRoundTripAgainstSimulatedNode(t *testing.T) {
+	r, err := NewRadio(SimScheme)
+	require.NoError(t, err)
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var welcome bytes.Buffer
+	_, err = r.GetFile(ctx, "/static/welcome.txt", &welcome, xmodem.Options{})
+	require.NoError(t, err)
+	require.Contains(t, welcome.String(), "simulator")
+
+	data := strings.Repeat("chirp ", 100)
+	n, err := r.PutFile(ctx, "/upload.txt", strings.NewReader(data), xmodem.Options{})
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), n)
+
+	var back bytes.Buffer
+	_, err = r.GetFile(ctx, "/upload.txt", &back, xmodem.Options{})
+	require.NoError(t, err)
+	require.Equal(t, data, back.String())
+
+	_, err = r.GetFile(ctx, "/missing.txt", &bytes.Buffer{}, xmodem.Options{})
+	require.ErrorIs(t, err, xmodem.ErrRefused)
+}
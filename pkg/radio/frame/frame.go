@@ -0,0 +1,178 @@
+// Package frame implements the Meshtastic stream framing used on serial and
+// TCP links: a 0x94 0xC3 start marker, a big-endian 16-bit payload length and
+// the protobuf payload itself.
+package frame
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+const (
+	Start1    = byte(0x94)
+	Start2    = byte(0xc3)
+	HeaderLen = 4
+	// MaxPayloadSize is the largest ToRadio/FromRadio payload the firmware emits.
+	MaxPayloadSize = 512
+
+	readBufferSize = 4096
+)
+
+var (
+	// ErrPayloadTooLarge is returned when encoding a payload over MaxPayloadSize.
+	ErrPayloadTooLarge = errors.New("frame payload too large")
+	// ErrNoData is returned by Decoder.Next when the underlying reader returned
+	// no bytes and no error, as serial ports do when their read timeout expires.
+	// Any partial frame is kept, so callers can simply call Next again.
+	ErrNoData = errors.New("no data available")
+)
+
+// Encode prepends the stream header to payload.
+func Encode(payload []byte) ([]byte, error) {
+	if len(payload) > MaxPayloadSize {
+		return nil, ErrPayloadTooLarge
+	}
+
+	out := make([]byte, 0, HeaderLen+len(payload))
+	out = append(out, Start1, Start2, byte(len(payload)>>8), byte(len(payload)))
+	return append(out, payload...), nil
+}
+
+// Encoder writes framed payloads to an io.Writer, one Write call per frame.
+type Encoder struct {
+	w io.Writer
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+func (e *Encoder) Encode(payload []byte) error {
+	b, err := Encode(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(b)
+	return err
+}
+
+// Stats counts what a Decoder has seen on the wire.
+type Stats struct {
+	Frames       uint64 `json:"frames"`
+	BadFrames    uint64 `json:"bad_frames"`
+	BytesSkipped uint64 `json:"bytes_skipped"`
+}
+
+// Decoder reads framed payloads from an io.Reader in large chunks. Bytes that
+// are not part of a frame (for example firmware debug output) are skipped, and
+// headers announcing an oversized payload are treated as corrupt: the decoder
+// resynchronizes on the next start marker instead of dropping the stream.
+type Decoder struct {
+	r     io.Reader
+	buf   []byte
+	start int
+	end   int
+	err   error
+	stats Stats
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:   r,
+		buf: make([]byte, readBufferSize),
+	}
+}
+
+// Next returns the payload of the next complete frame. The returned slice is
+// owned by the caller.
+func (d *Decoder) Next() ([]byte, error) {
+	for {
+		if payload, ok := d.parse(); ok {
+			return payload, nil
+		}
+		if err := d.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Stats returns the decoder's counters so far.
+func (d *Decoder) Stats() Stats {
+	return d.stats
+}
+
+func (d *Decoder) parse() ([]byte, bool) {
+	for d.end > d.start {
+		b := d.buf[d.start:d.end]
+
+		if b[0] != Start1 {
+			skip := bytes.IndexByte(b, Start1)
+			if skip < 0 {
+				skip = len(b)
+			}
+			d.skip(skip)
+			continue
+		}
+
+		if len(b) < 2 {
+			return nil, false
+		}
+		if b[1] != Start2 {
+			d.skip(1)
+			continue
+		}
+
+		if len(b) < HeaderLen {
+			return nil, false
+		}
+		payloadLen := int(b[2])<<8 | int(b[3])
+		if payloadLen > MaxPayloadSize {
+			d.stats.BadFrames++
+			d.skip(1)
+			continue
+		}
+
+		if len(b) < HeaderLen+payloadLen {
+			return nil, false
+		}
+
+		payload := make([]byte, payloadLen)
+		copy(payload, b[HeaderLen:HeaderLen+payloadLen])
+		d.start += HeaderLen + payloadLen
+		d.stats.Frames++
+		return payload, true
+	}
+
+	return nil, false
+}
+
+func (d *Decoder) skip(n int) {
+	d.start += n
+	d.stats.BytesSkipped += uint64(n)
+}
+
+func (d *Decoder) fill() error {
+	if d.err != nil {
+		err := d.err
+		d.err = nil
+		return err
+	}
+
+	if d.start > 0 {
+		d.end = copy(d.buf, d.buf[d.start:d.end])
+		d.start = 0
+	}
+
+	n, err := d.r.Read(d.buf[d.end:])
+	d.end += n
+	if n > 0 {
+		d.err = err
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ErrNoData
+}
@@ -0,0 +1,143 @@
+package frame
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustEncode(t *testing.T, payload []byte) []byte {
+	t.Helper()
+	b, err := Encode(payload)
+	require.NoError(t, err)
+	return b
+}
+
+func TestEncodeAddsHeader(t *testing.T) {
+	b, err := Encode([]byte{0x10, 0x20, 0x30})
+	require.NoError(t, err)
+	require.Equal(t, []byte{Start1, Start2, 0x00, 0x03, 0x10, 0x20, 0x30}, b)
+}
+
+func TestEncodeRejectsOversizedPayload(t *testing.T) {
+	_, err := Encode(make([]byte, MaxPayloadSize+1))
+	require.ErrorIs(t, err, ErrPayloadTooLarge)
+}
+
+func TestEncoderWritesOneFramePerCall(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	require.NoError(t, enc.Encode([]byte{0x01}))
+	require.NoError(t, enc.Encode([]byte{0x02, 0x03}))
+	require.Equal(t, []byte{Start1, Start2, 0, 1, 0x01, Start1, Start2, 0, 2, 0x02, 0x03}, out.Bytes())
+}
+
+func TestDecoderReadsFramesAcrossChunkBoundaries(t *testing.T) {
+	var stream []byte
+	stream = append(stream, mustEncode(t, []byte("first"))...)
+	stream = append(stream, mustEncode(t, []byte("second"))...)
+
+	dec := NewDecoder(iotest.OneByteReader(bytes.NewReader(stream)))
+
+	got, err := dec.Next()
+	require.NoError(t, err)
+	require.Equal(t, "first", string(got))
+
+	got, err = dec.Next()
+	require.NoError(t, err)
+	require.Equal(t, "second", string(got))
+
+	_, err = dec.Next()
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, Stats{Frames: 2}, dec.Stats())
+}
+
+func TestDecoderSkipsGarbageBetweenFrames(t *testing.T) {
+	var stream []byte
+	stream = append(stream, []byte("INFO | boot\r\n")...)
+	stream = append(stream, mustEncode(t, []byte{0xaa})...)
+	stream = append(stream, Start1, 0x00)
+	stream = append(stream, mustEncode(t, []byte{0xbb})...)
+
+	dec := NewDecoder(bytes.NewReader(stream))
+
+	got, err := dec.Next()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xaa}, got)
+
+	got, err = dec.Next()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xbb}, got)
+
+	stats := dec.Stats()
+	require.Equal(t, uint64(2), stats.Frames)
+	require.Equal(t, uint64(0), stats.BadFrames)
+	require.Equal(t, uint64(len("INFO | boot\r\n")+2), stats.BytesSkipped)
+}
+
+func TestDecoderResyncsAfterOversizedHeader(t *testing.T) {
+	var stream []byte
+	// Corrupt header announcing 0x0201 bytes; the real frame starts right after it.
+	stream = append(stream, Start1, Start2, 0x02, 0x01)
+	stream = append(stream, mustEncode(t, []byte("ok"))...)
+
+	dec := NewDecoder(bytes.NewReader(stream))
+
+	got, err := dec.Next()
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(got))
+
+	stats := dec.Stats()
+	require.Equal(t, uint64(1), stats.BadFrames)
+	require.Equal(t, uint64(4), stats.BytesSkipped)
+}
+
+type scriptedReader struct {
+	chunks [][]byte
+	errs   []error
+}
+
+func (s *scriptedReader) Read(p []byte) (int, error) {
+	if len(s.chunks) == 0 {
+		return 0, io.EOF
+	}
+	chunk, err := s.chunks[0], s.errs[0]
+	s.chunks, s.errs = s.chunks[1:], s.errs[1:]
+	return copy(p, chunk), err
+}
+
+func TestDecoderKeepsPartialFrameAcrossIdleReads(t *testing.T) {
+	encoded := mustEncode(t, []byte("split"))
+	r := &scriptedReader{
+		chunks: [][]byte{encoded[:3], nil, encoded[3:]},
+		errs:   []error{nil, nil, nil},
+	}
+	dec := NewDecoder(r)
+
+	_, err := dec.Next()
+	require.ErrorIs(t, err, ErrNoData)
+
+	got, err := dec.Next()
+	require.NoError(t, err)
+	require.Equal(t, "split", string(got))
+}
+
+func TestDecoderReturnsReadErrorAfterBufferedData(t *testing.T) {
+	boom := errors.New("boom")
+	r := &scriptedReader{
+		chunks: [][]byte{mustEncode(t, []byte("last"))},
+		errs:   []error{boom},
+	}
+	dec := NewDecoder(r)
+
+	got, err := dec.Next()
+	require.NoError(t, err)
+	require.Equal(t, "last", string(got))
+
+	_, err = dec.Next()
+	require.ErrorIs(t, err, boom)
+}
@@ -0,0 +1,114 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// ConfigOnlyNonce is the want_config_id that asks the firmware for its own
+	// node, config, module config and channels, skipping the rest of the node DB.
+	ConfigOnlyNonce uint32 = 69420
+	// NodesOnlyNonce is the want_config_id that asks the firmware for the node DB
+	// only.
+	NodesOnlyNonce uint32 = 69421
+)
+
+// ErrHandshakeIncomplete is returned when the radio does not finish a config
+// handshake before the context ends or the connection closes.
+var ErrHandshakeIncomplete = errors.New("config handshake incomplete")
+
+// HandshakeMode selects what the radio sends back from a config handshake.
+type HandshakeMode int
+
+const (
+	// HandshakeFull asks for everything: node info, config, channels and the node DB.
+	HandshakeFull HandshakeMode = iota
+	// HandshakeConfigOnly asks for the local node's config without the node DB.
+	HandshakeConfigOnly
+	// HandshakeNodesOnly asks for the node DB without config.
+	HandshakeNodesOnly
+)
+
+func (m HandshakeMode) String() string {
+	switch m {
+	case HandshakeConfigOnly:
+		return "config-only"
+	case HandshakeNodesOnly:
+		return "nodes-only"
+	default:
+		return "full"
+	}
+}
+
+// nonce returns the want_config_id that requests m. Full handshakes use a
+// random ID so a ConfigCompleteId left over from an earlier handshake, ours or
+// another client's, is never mistaken for the end of this one.
+func (m HandshakeMode) nonce() uint32 {
+	switch m {
+	case HandshakeConfigOnly:
+		return ConfigOnlyNonce
+	case HandshakeNodesOnly:
+		return NodesOnlyNonce
+	}
+
+	for {
+		n := rand.Uint32()
+		if n != 0 && n != ConfigOnlyNonce && n != NodesOnlyNonce {
+			return n
+		}
+	}
+}
+
+// Handshake sends want_config_id and collects every FromRadio message until
+// the radio answers with the matching ConfigCompleteId. If ctx ends or the
+// connection closes first, it returns what arrived so far together with an
+// error wrapping ErrHandshakeIncomplete and the cause.
+func (r *Radio) Handshake(ctx context.Context, mode HandshakeMode) ([]*pb.FromRadio, error) {
+	nonce := mode.nonce()
+	out, err := proto.Marshal(&pb.ToRadio{PayloadVariant: &pb.ToRadio_WantConfigId{WantConfigId: nonce}})
+	if err != nil {
+		return nil, err
+	}
+
+	// One dropped frame would leave the snapshot incomplete, or lose
+	// ConfigCompleteId and time out, so a node DB burst is queued instead.
+	sub := r.subscribeLossless(nil)
+	defer sub.Close()
+
+	if err := r.SendPacket(ctx, out); err != nil {
+		return nil, err
+	}
+
+	var responses []*pb.FromRadio
+	for {
+		select {
+		case fr, ok := <-sub.C():
+			if !ok {
+				return responses, fmt.Errorf("%w after %d messages: %w", ErrHandshakeIncomplete, len(responses), r.readError())
+			}
+			responses = append(responses, fr)
+			if myInfo := fr.GetMyInfo(); myInfo != nil && myInfo.GetMyNodeNum() != 0 {
+				r.setNodeNum(myInfo.GetMyNodeNum())
+			}
+			if fr.GetConfigCompleteId() == nonce {
+				return responses, nil
+			}
+		case <-ctx.Done():
+			return responses, fmt.Errorf("%w after %d messages: %w", ErrHandshakeIncomplete, len(responses), ctx.Err())
+		}
+	}
+}
+
+// GetRadioInfo runs a full config handshake, giving up when ctx ends or after
+// radioInfoTimeout, whichever comes first.
+func (r *Radio) GetRadioInfo(ctx context.Context) ([]*pb.FromRadio, error) {
+	ctx, cancel := context.WithTimeout(ctx, radioInfoTimeout)
+	defer cancel()
+	return r.Handshake(ctx, HandshakeFull)
+}
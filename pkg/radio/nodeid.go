@@ -0,0 +1,34 @@
+package radio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseNodeID accepts a node ID written as "!a1b2c3d4", "0xa1b2c3d4" or a
+// decimal node number. Zero and the broadcast address are not node IDs.
+func ParseNodeID(s string) (uint32, error) {
+	s = strings.TrimSpace(s)
+	var (
+		v   uint64
+		err error
+	)
+	switch {
+	case strings.HasPrefix(s, "!"):
+		v, err = strconv.ParseUint(s[1:], 16, 32)
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0X"):
+		v, err = strconv.ParseUint(s[2:], 16, 32)
+	default:
+		v, err = strconv.ParseUint(s, 10, 32)
+	}
+	if err != nil || v == 0 || uint32(v) == broadcastNum {
+		return 0, fmt.Errorf("invalid node id %q", s)
+	}
+	return uint32(v), nil
+}
+
+// FormatNodeID writes num the way Meshtastic clients display it, as "!a1b2c3d4".
+func FormatNodeID(num uint32) string {
+	return fmt.Sprintf("!%08x", num)
+}
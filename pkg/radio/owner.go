@@ -0,0 +1,95 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// The firmware stores owner names in fixed NUL-terminated fields. A short
+// name is also shown as at most four characters.
+const (
+	MaxLongNameBytes  = 39
+	MaxShortNameBytes = 4
+	MaxShortNameChars = 4
+)
+
+var errNameEmpty = errors.New("name is empty")
+
+// CheckOwnerName reports why the firmware would mangle name: invalid UTF-8,
+// a blank name, control characters, or more than maxChars characters or
+// maxBytes bytes. Limits count characters, so a name is never cut inside one.
+func CheckOwnerName(name string, maxChars, maxBytes int) error {
+	if !utf8.ValidString(name) {
+		return errors.New("is not valid UTF-8")
+	}
+	if strings.TrimSpace(name) == "" {
+		return errors.New("cannot be blank")
+	}
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		return errors.New("cannot contain control characters")
+	}
+	if n := utf8.RuneCountInString(name); n > maxChars {
+		return fmt.Errorf("%q is %d characters; at most %d fit", name, n, maxChars)
+	}
+	if len(name) > maxBytes {
+		return fmt.Errorf("%q is %d bytes in UTF-8; the node stores at most %d", name, len(name), maxBytes)
+	}
+	return nil
+}
+
+// shortNameOf returns the longest prefix of name, in whole characters, that
+// fits a short name.
+func shortNameOf(name string) string {
+	end := 0
+	for chars := 0; chars < MaxShortNameChars && end < len(name); chars++ {
+		_, size := utf8.DecodeRuneInString(name[end:])
+		if end+size > MaxShortNameBytes {
+			break
+		}
+		end += size
+	}
+	return name[:end]
+}
+
+// SetRadioOwner sets the owner name reported by this radio, and a short name
+// made of its first characters. Both are checked with CheckOwnerName first.
+func (r *Radio) SetRadioOwner(ctx context.Context, name string) error {
+	if name == "" {
+		return errNameEmpty
+	}
+	if err := CheckOwnerName(name, MaxLongNameBytes, MaxLongNameBytes); err != nil {
+		return fmt.Errorf("long name %w", err)
+	}
+	shortName := shortNameOf(strings.TrimLeftFunc(name, unicode.IsSpace))
+	if err := CheckOwnerName(shortName, MaxShortNameChars, MaxShortNameBytes); err != nil {
+		return fmt.Errorf("short name %w", err)
+	}
+
+	adminPacket := pb.AdminMessage{
+		PayloadVariant: &pb.AdminMessage_SetOwner{
+			SetOwner: &pb.User{
+				LongName:  name,
+				ShortName: shortName,
+			},
+		},
+	}
+
+	out, err := proto.Marshal(&adminPacket)
+	if err != nil {
+		return err
+	}
+
+	packet, err := r.createAdminPacket(r.NodeNum(), out)
+	if err != nil {
+		return err
+	}
+
+	return r.SendPacket(ctx, packet)
+}
@@ -0,0 +1,58 @@
+package radio
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSetRadioOwnerBuildsAdminPacket(t *testing.T) {
+	m := &mockStreamer{}
+	r := &Radio{streamer: m, nodeNum: 77}
+
+	require.NoError(t, r.SetRadioOwner(context.Background(), "Corey"))
+	require.Len(t, m.writes, 1)
+
+	toRadio := decodeToRadio(t, m.writes[0])
+	packet := toRadio.GetPacket()
+	require.NotNil(t, packet)
+	require.Equal(t, uint32(77), packet.GetTo())
+	require.Equal(t, pb.PortNum_ADMIN_APP, packet.GetDecoded().GetPortnum())
+
+	var admin pb.AdminMessage
+	require.NoError(t, proto.Unmarshal(packet.GetDecoded().GetPayload(), &admin))
+	require.Equal(t, "Corey", admin.GetSetOwner().GetLongName())
+	require.Equal(t, "Core", admin.GetSetOwner().GetShortName())
+}
+
+func TestSetRadioOwnerRejectsNamesTheNodeWouldMangle(t *testing.T) {
+	for name, want := range map[string]string{
+		"":        "name is empty",
+		"   ":     "long name cannot be blank",
+		"a\tb":    "long name cannot contain control characters",
+		"\xffabc": "long name is not valid UTF-8",
+		"Ünterwald Ünterwald Ünterwald Ünterwald": "is 43 bytes in UTF-8",
+	} {
+		m := &mockStreamer{}
+		r := &Radio{streamer: m, nodeNum: 77}
+
+		err := r.SetRadioOwner(context.Background(), name)
+		require.ErrorContains(t, err, want, name)
+		require.Empty(t, m.writes, name)
+	}
+}
+
+func TestShortNameOfKeepsWholeCharacters(t *testing.T) {
+	for name, want := range map[string]string{
+		"Al":         "Al",
+		"Ünterwald":  "Ünt",
+		"🦆 Duck":     "🦆",
+		"日本語のノード":    "日",
+		"\xffabcdef": "\xffabc",
+	} {
+		require.Equal(t, want, shortNameOf(name), name)
+	}
+}
@@ -1,41 +1,42 @@
 package radio
 
 import (
+	"context"
 	"errors"
-	"io"
 	"log"
 	"math/rand"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/coreyvan/chirp/pkg/radio/capture"
+	"github.com/coreyvan/chirp/pkg/radio/frame"
+	"github.com/coreyvan/chirp/pkg/radio/sim"
 	"github.com/coreyvan/chirp/pkg/serial"
+	"github.com/coreyvan/chirp/pkg/tcp"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 	"google.golang.org/protobuf/proto"
 )
 
 const (
-	start1                = byte(0x94)
-	start2                = byte(0xc3)
-	headerLen             = 4
-	maxToFromRadioSize    = 512
-	maxTextMessageLen     = 240
-	maxPacketID           = 2386827
-	broadcastNum          = uint32(0xffffffff)
-	defaultHopLimit       = uint32(3)
-	radioInfoConfigID     = 42
-	radioInfoMaxPolls     = 5
-	radioInfoPollInterval = 1 * time.Second
-	readResponseTimeout   = 2 * time.Second
-	readResponsePoll      = 200 * time.Millisecond
-	wakeSendAttempts      = 1
-	wakeSendInterval      = 300 * time.Millisecond
+	maxTextMessageLen = 240
+	maxPacketID       = 2386827
+	broadcastNum      = uint32(0xffffffff)
+	defaultHopLimit   = uint32(3)
+	radioInfoTimeout  = 5 * time.Second
+	readResponsePoll  = 200 * time.Millisecond
+	wedgedWriteGrace  = 500 * time.Millisecond
+
+	// TCPScheme prefixes radio targets that should be reached over TCP instead of serial.
+	TCPScheme = "tcp://"
+	// SimScheme prefixes radio targets served by an in-process simulated node;
+	// the rest of the target is an optional scenario file.
+	SimScheme = "sim://"
 )
 
 var (
 	errNodeNumUnknown  = errors.New("failed to determine node number")
-	errRadioInfoFailed = errors.New("failed to get radio info")
 	errMessageTooLarge = errors.New("message too large")
-	errNameTooShort    = errors.New("name too short")
 	errInvalidModem    = errors.New("invalid modem mode")
 )
 
@@ -46,14 +47,40 @@ type Streamer interface {
 	SetReadTimeout(d time.Duration) error
 }
 
-// Radio holds the port and serial io.ReadWriteCloser struct to maintain one serial connection.
+// writeDeadliner is implemented by streamers whose blocked Write can be cut
+// short with a deadline, such as a TCP connection.
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// Radio owns one connection to a node. A single background reader decodes
+// frames and fans them out to subscribers, and writes are serialized so callers
+// can share the connection.
 type Radio struct {
 	streamer Streamer
 	nodeNum  uint32
+
+	writeMu sync.Mutex
+
+	sessionMu sync.Mutex
+	sessions  map[uint32]adminSession
+
+	mu           sync.Mutex
+	subs         map[*Subscription]struct{}
+	inbox        *Subscription
+	readerDone   chan struct{}
+	readerExited bool
+	readErr      error
+	closing      bool
+	stats        Stats
+	recorder     *capture.Writer
 }
 
-func NewRadio(device string) (*Radio, error) {
-	streamer, err := serial.NewSerialStreamer(device)
+// NewRadio opens a radio at target, which is a serial device path, a tcp://
+// address such as tcp://meshtastic.local:4403, or a sim:// scenario such as
+// sim://scenario.yaml.
+func NewRadio(target string) (*Radio, error) {
+	streamer, err := openStreamer(target)
 	if err != nil {
 		return nil, err
 	}
@@ -61,28 +88,80 @@ func NewRadio(device string) (*Radio, error) {
 	return &Radio{streamer: streamer}, nil
 }
 
-// Init initializes serial connection and caches the local node number.
-func (r *Radio) Init(device string) error {
-	streamer, err := serial.NewSerialStreamer(device)
+// NewRadioFromStreamer wraps an already-open streamer, such as a capture replay.
+func NewRadioFromStreamer(streamer Streamer) *Radio {
+	return &Radio{streamer: streamer}
+}
+
+// Init initializes the connection to target and caches the local node number.
+func (r *Radio) Init(ctx context.Context, target string) error {
+	streamer, err := openStreamer(target)
 	if err != nil {
 		return err
 	}
 
 	r.streamer = streamer
-	return r.getNodeNum()
+	return r.getNodeNum(ctx)
 }
 
+// openStreamer picks the transport for target based on its scheme.
+func openStreamer(target string) (Streamer, error) {
+	if host, ok := strings.CutPrefix(target, TCPScheme); ok {
+		return tcp.NewTCPStreamer(host)
+	}
+	if scenario, ok := strings.CutPrefix(target, SimScheme); ok {
+		return sim.Open(scenario)
+	}
+	return serial.NewSerialStreamer(target)
+}
+
+// Close closes the connection and waits for the background reader to exit.
+// Closing an already closed radio does nothing.
 func (r *Radio) Close() error {
 	if r.streamer == nil {
 		return nil
 	}
 
-	return r.streamer.Close()
+	r.mu.Lock()
+	if r.closing {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closing = true
+	done := r.readerDone
+	r.mu.Unlock()
+
+	err := r.streamer.Close()
+	if done != nil {
+		<-done
+	}
+	return err
+}
+
+// SetRecorder stores every frame sent to or received from the radio in w.
+// Passing nil stops recording.
+func (r *Radio) SetRecorder(w *capture.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorder = w
+}
+
+func (r *Radio) record(dir capture.Direction, b []byte) {
+	r.mu.Lock()
+	w := r.recorder
+	r.mu.Unlock()
+	if w == nil {
+		return
+	}
+
+	if err := w.RecordFrame(dir, b); err != nil {
+		log.Printf("record frame: %v", err)
+	}
 }
 
 // getNodeNum queries the radio and stores the local node number.
-func (r *Radio) getNodeNum() error {
-	radioResponses, err := r.GetRadioInfo()
+func (r *Radio) getNodeNum(ctx context.Context) error {
+	radioResponses, err := r.GetRadioInfo(ctx)
 	if err != nil {
 		return err
 	}
@@ -99,19 +178,46 @@ func (r *Radio) getNodeNum() error {
 		return errNodeNumUnknown
 	}
 
-	r.nodeNum = nodeNum
+	r.setNodeNum(nodeNum)
 	return nil
 }
 
-// SendPacket takes a protobuf packet, constructs the appropriate header, and sends it to the radio.
-func (r *Radio) SendPacket(protobufPacket []byte) error {
-	packetLength := len(protobufPacket)
-	header := []byte{start1, start2, byte(packetLength>>8) & 0xff, byte(packetLength) & 0xff}
+// NodeNum returns the local node number learned from the most recent config
+// handshake, or 0 before the first one.
+func (r *Radio) NodeNum() uint32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.nodeNum
+}
+
+func (r *Radio) setNodeNum(num uint32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodeNum = num
+}
+
+// SendPacket takes a protobuf packet, constructs the appropriate header, and
+// sends it to the radio. Nothing is written once ctx is done, including while
+// waiting for another writer to finish, and a write still blocked when ctx ends
+// returns ctx.Err(), as described at write.
+func (r *Radio) SendPacket(ctx context.Context, protobufPacket []byte) error {
+	radioPacket, err := frame.Encode(protobufPacket)
+	if err != nil {
+		return err
+	}
+
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if r.isClosing() {
+		return ErrClosed
+	}
 
-	radioPacket := make([]byte, 0, len(header)+len(protobufPacket))
-	radioPacket = append(radioPacket, header...)
-	radioPacket = append(radioPacket, protobufPacket...)
-	n, err := r.streamer.Write(radioPacket)
+	// Record first so a capture never shows the reply ahead of its request.
+	r.record(capture.ToRadio, radioPacket)
+	n, err := r.write(ctx, radioPacket)
 	if err != nil {
 		return err
 	}
@@ -120,118 +226,108 @@ func (r *Radio) SendPacket(protobufPacket []byte) error {
 	return nil
 }
 
-// ReadResponse reads any responses in the serial port, converts them to FromRadio protobufs and returns them.
-func (r *Radio) ReadResponse(timeout bool) ([]*pb.FromRadio, error) {
-	if timeout {
-		if err := r.streamer.SetReadTimeout(readResponsePoll); err != nil {
-			return nil, err
-		}
+// write writes b to the streamer and returns ctx.Err() if ctx ends first. A
+// streamer that takes a write deadline has its Write cut short. Other streamers
+// cannot interrupt a Write, so write gives it wedgedWriteGrace to finish and
+// only then, on a wedged serial port for example, closes the radio to release
+// it; SendPacket refuses writes from then on, so none overlap the stuck one.
+func (r *Radio) write(ctx context.Context, b []byte) (int, error) {
+	if wd, ok := r.streamer.(writeDeadliner); ok {
+		return r.writeWithDeadline(ctx, wd, b)
 	}
 
-	b := make([]byte, 1)
-	buf := make([]byte, 0, maxToFromRadioSize+headerLen)
-	var previousByte byte
-	hasPrevious := false
-	repeatByteCounter := 0
-
-	var fromRadioPackets []*pb.FromRadio
-
-	deadline := time.Now().Add(readResponseTimeout)
-	for {
-		n, err := r.streamer.Read(b)
-
-		if n > 0 {
-			currentByte := b[0]
-			if hasPrevious && currentByte == previousByte {
-				repeatByteCounter++
-			} else {
-				repeatByteCounter = 0
-			}
-			previousByte = currentByte
-			hasPrevious = true
-		}
-
-		shouldBreakOnRepeat := repeatByteCounter > 20 && len(buf) < headerLen
-		if err == io.EOF || shouldBreakOnRepeat || errors.Is(err, os.ErrDeadlineExceeded) {
-			break
-		} else if err != nil {
-			return nil, err
-		}
-
-		if n == 0 {
-			if timeout && time.Now().After(deadline) {
-				break
-			}
-			continue
-		}
-
-		buf = append(buf, b[0])
-
-		switch len(buf) {
-		case 1:
-			if buf[0] != start1 {
-				buf = buf[:0]
-			}
-		case 2:
-			if buf[1] != start2 {
-				buf = buf[:0]
-			}
-		}
-
-		if len(buf) < headerLen {
-			continue
-		}
-
-		packetLength := int(buf[2])<<8 | int(buf[3])
-		if len(buf) == headerLen && packetLength > maxToFromRadioSize {
-			buf = buf[:0]
-			continue
-		}
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := r.streamer.Write(b)
+		done <- result{n, err}
+	}()
 
-		if len(buf) == packetLength+headerLen {
-			fromRadio := pb.FromRadio{}
-			if err := proto.Unmarshal(buf[headerLen:], &fromRadio); err != nil {
-				return nil, err
-			}
+	select {
+	case res := <-done:
+		return res.n, res.err
+	case <-ctx.Done():
+	}
 
-			fromRadioPackets = append(fromRadioPackets, &fromRadio)
-			buf = buf[:0]
-		}
+	select {
+	case res := <-done:
+		return res.n, ctx.Err()
+	case <-time.After(wedgedWriteGrace):
+		log.Printf("write blocked past its deadline, closing the radio")
+		_ = r.Close()
+		return 0, ctx.Err()
 	}
+}
 
-	return fromRadioPackets, nil
+// writeWithDeadline writes b, moving the write deadline to now when ctx ends
+// so the Write returns, and clears the deadline again for the next caller.
+func (r *Radio) writeWithDeadline(ctx context.Context, wd writeDeadliner, b []byte) (int, error) {
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		_ = wd.SetWriteDeadline(time.Now())
+		close(interrupted)
+	})
+
+	n, err := r.streamer.Write(b)
+	if !stop() {
+		<-interrupted
+		_ = wd.SetWriteDeadline(time.Time{})
+		return n, ctx.Err()
+	}
+	return n, err
 }
 
-// GetRadioInfo retrieves information from the radio including config and adjacent node information.
-func (r *Radio) GetRadioInfo() ([]*pb.FromRadio, error) {
-	nodeInfo := pb.ToRadio{PayloadVariant: &pb.ToRadio_WantConfigId{WantConfigId: radioInfoConfigID}}
+// OpenInbox starts queueing messages for ReadResponse without waiting for
+// any. The queue otherwise only starts with the first ReadResponse call, so a
+// caller that must not miss the answer to something it is about to send, such
+// as the Rebooted that follows a reboot request, opens the inbox first.
+func (r *Radio) OpenInbox() {
+	r.inboxSubscription()
+}
 
-	out, err := proto.Marshal(&nodeInfo)
-	if err != nil {
-		return nil, err
-	}
+// ReadResponse returns FromRadio messages received since the previous call. It
+// waits for at least one message, up to readResponsePoll when timeout is set,
+// and returns ErrClosed once the connection has stopped delivering frames or
+// ctx.Err() once ctx is done. Messages are fanned out by the background
+// reader, so ReadResponse never competes with GetRadioInfo or other
+// subscribers for bytes.
+func (r *Radio) ReadResponse(ctx context.Context, timeout bool) ([]*pb.FromRadio, error) {
+	inbox := r.inboxSubscription()
 
-	for attempt := 0; attempt < wakeSendAttempts; attempt++ {
-		if err := r.SendPacket(out); err != nil {
-			return nil, err
-		}
-		time.Sleep(wakeSendInterval)
+	var expired <-chan time.Time
+	if timeout {
+		timer := time.NewTimer(readResponsePoll)
+		defer timer.Stop()
+		expired = timer.C
 	}
 
-	for checks := 0; checks <= radioInfoMaxPolls; checks++ {
-		radioResponses, err := r.ReadResponse(true)
-		if err != nil {
-			return nil, err
+	var fromRadioPackets []*pb.FromRadio
+	select {
+	case fr, ok := <-inbox.C():
+		if !ok {
+			return nil, r.readError()
 		}
+		fromRadioPackets = append(fromRadioPackets, fr)
+	case <-expired:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 
-		if len(radioResponses) > 0 {
-			return radioResponses, nil
+	for {
+		select {
+		case fr, ok := <-inbox.C():
+			if !ok {
+				return fromRadioPackets, nil
+			}
+			fromRadioPackets = append(fromRadioPackets, fr)
+		default:
+			return fromRadioPackets, nil
 		}
-
-		time.Sleep(radioInfoPollInterval)
 	}
-
-	return nil, errRadioInfoFailed
 }
 
 // createAdminPacket builds an admin message packet to send to the radio.
@@ -261,78 +357,70 @@ func (r *Radio) createAdminPacket(nodeNum uint32, payload []byte) ([]byte, error
 }
 
 // SendTextMessage sends a text message to another radio (or broadcast if to == 0).
-func (r *Radio) SendTextMessage(message string, to int64, channel int64) error {
-	address := broadcastNum
-	if to != 0 {
-		address = uint32(to)
-	}
-
-	if len(message) > maxTextMessageLen {
-		return errMessageTooLarge
-	}
-
-	packetID := uint32(rand.New(rand.NewSource(time.Now().UnixNano())).Intn(maxPacketID) + 1)
-	radioMessage := pb.ToRadio{
-		PayloadVariant: &pb.ToRadio_Packet{
-			Packet: &pb.MeshPacket{
-				To:       address,
-				WantAck:  true,
-				Id:       packetID,
-				Channel:  uint32(channel),
-				HopLimit: defaultHopLimit,
-				PayloadVariant: &pb.MeshPacket_Decoded{
-					Decoded: &pb.Data{
-						Payload: []byte(message),
-						Portnum: pb.PortNum_TEXT_MESSAGE_APP,
-					},
-				},
-			},
-		},
+func (r *Radio) SendTextMessage(ctx context.Context, message string, to int64, channel int64) error {
+	packet, err := newTextPacket(message, to, channel)
+	if err != nil {
+		return err
 	}
 
-	out, err := proto.Marshal(&radioMessage)
+	out, err := proto.Marshal(&pb.ToRadio{PayloadVariant: &pb.ToRadio_Packet{Packet: packet}})
 	if err != nil {
 		return err
 	}
 
-	return r.SendPacket(out)
+	return r.SendPacket(ctx, out)
 }
 
-// SetRadioOwner sets the owner name reported by this radio.
-func (r *Radio) SetRadioOwner(name string) error {
-	if len(name) <= 2 {
-		return errNameTooShort
-	}
-
-	shortName := name
-	if len(shortName) > 3 {
-		shortName = shortName[:3]
+// SendTextMessageAck sends a text message like SendTextMessage and waits for
+// the mesh to acknowledge it. A direct message waits for the destination
+// itself, as hearing a neighbour relay it says nothing about delivery; a
+// broadcast settles for that implicit ack, the only one it gets. Set a
+// deadline on ctx to bound the wait; when it passes, the returned Ack has
+// status AckTimeout.
+func (r *Radio) SendTextMessageAck(ctx context.Context, message string, to int64, channel int64) (Ack, error) {
+	packet, err := newTextPacket(message, to, channel)
+	if err != nil {
+		return Ack{}, err
 	}
 
-	adminPacket := pb.AdminMessage{
-		PayloadVariant: &pb.AdminMessage_SetOwner{
-			SetOwner: &pb.User{
-				LongName:  name,
-				ShortName: shortName,
-			},
-		},
+	if packet.GetTo() == broadcastNum {
+		return r.sendAndAwaitAck(ctx, packet)
 	}
+	return r.sendAndAwaitDelivery(ctx, packet)
+}
 
-	out, err := proto.Marshal(&adminPacket)
-	if err != nil {
-		return err
+func newTextPacket(message string, to int64, channel int64) (*pb.MeshPacket, error) {
+	address := broadcastNum
+	if to != 0 {
+		address = uint32(to)
 	}
 
-	packet, err := r.createAdminPacket(r.nodeNum, out)
-	if err != nil {
-		return err
-	}
+	if len(message) > maxTextMessageLen {
+		return nil, errMessageTooLarge
+	}
+
+	return &pb.MeshPacket{
+		To:       address,
+		WantAck:  true,
+		Id:       newPacketID(),
+		Channel:  uint32(channel),
+		HopLimit: defaultHopLimit,
+		PayloadVariant: &pb.MeshPacket_Decoded{
+			Decoded: &pb.Data{
+				Payload: []byte(message),
+				Portnum: pb.PortNum_TEXT_MESSAGE_APP,
+			},
+		},
+	}, nil
+}
 
-	return r.SendPacket(packet)
+// newPacketID returns a random, non-zero mesh packet ID.
+func newPacketID() uint32 {
+	return uint32(rand.Intn(maxPacketID) + 1)
 }
 
 // SetModemMode sets the LoRa modem preset.
-func (r *Radio) SetModemMode(mode string) error {
+func (r *Radio) SetModemMode(ctx context.Context, mode string) error {
 	var modemSetting pb.Config_LoRaConfig_ModemPreset
 	switch mode {
 	case "lf":
@@ -372,16 +460,16 @@ func (r *Radio) SetModemMode(mode string) error {
 		return err
 	}
 
-	packet, err := r.createAdminPacket(r.nodeNum, out)
+	packet, err := r.createAdminPacket(r.NodeNum(), out)
 	if err != nil {
 		return err
 	}
 
-	return r.SendPacket(packet)
+	return r.SendPacket(ctx, packet)
 }
 
 // SetLocation sets a fixed position payload for the current node.
-func (r *Radio) SetLocation(lat int32, long int32, alt int32) error {
+func (r *Radio) SetLocation(ctx context.Context, lat int32, long int32, alt int32) error {
 	latCopy := lat
 	longCopy := long
 	altCopy := alt
@@ -399,7 +487,7 @@ func (r *Radio) SetLocation(lat int32, long int32, alt int32) error {
 	radioMessage := pb.ToRadio{
 		PayloadVariant: &pb.ToRadio_Packet{
 			Packet: &pb.MeshPacket{
-				To:      r.nodeNum,
+				To:      r.NodeNum(),
 				WantAck: true,
 				PayloadVariant: &pb.MeshPacket_Decoded{
 					Decoded: &pb.Data{
@@ -417,11 +505,11 @@ func (r *Radio) SetLocation(lat int32, long int32, alt int32) error {
 		return err
 	}
 
-	return r.SendPacket(packet)
+	return r.SendPacket(ctx, packet)
 }
 
 // FactoryReset sends a factory reset command to the radio.
-func (r *Radio) FactoryReset() error {
+func (r *Radio) FactoryReset(ctx context.Context) error {
 	adminPacket := pb.AdminMessage{
 		PayloadVariant: &pb.AdminMessage_FactoryResetDevice{
 			FactoryResetDevice: 1,
@@ -433,15 +521,15 @@ func (r *Radio) FactoryReset() error {
 		return err
 	}
 
-	packet, err := r.createAdminPacket(r.nodeNum, out)
+	packet, err := r.createAdminPacket(r.NodeNum(), out)
 	if err != nil {
 		return err
 	}
 
-	return r.SendPacket(packet)
+	return r.SendPacket(ctx, packet)
 }
 
 // FactoryRest is kept for compatibility with older callers that used a typo.
-func (r *Radio) FactoryRest() error {
-	return r.FactoryReset()
+func (r *Radio) FactoryRest(ctx context.Context) error {
+	return r.FactoryReset(ctx)
 }
@@ -3,6 +3,7 @@
 package radio
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -13,7 +14,7 @@ func TestGetRadioInfoIntegration(t *testing.T) {
 	require.NoError(t, err)
 	defer r.Close()
 
-	responses, err := r.GetRadioInfo()
+	responses, err := r.GetRadioInfo(context.Background())
 	require.NoError(t, err)
 	require.NotEmpty(t, responses)
 	t.Log(responses)
@@ -1,11 +1,18 @@
 package radio
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
+	"net"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/coreyvan/chirp/pkg/radio/capture"
+	"github.com/coreyvan/chirp/pkg/radio/frame"
 	pb "github.com/coreyvan/chirp/protogen/meshtastic"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
@@ -18,6 +25,7 @@ type readStep struct {
 }
 
 type mockStreamer struct {
+	mu              sync.Mutex
 	readSteps       []readStep
 	readIndex       int
 	writes          [][]byte
@@ -25,14 +33,26 @@ type mockStreamer struct {
 	closeCalled     bool
 	writeErr        error
 	readErr         error
+	// idle makes Read behave like an open serial port with nothing to say once
+	// readSteps are exhausted, instead of reporting EOF.
+	idle bool
+	// respond, if set, answers each written ToRadio by queueing its replies.
+	respond func(*pb.ToRadio) []*pb.FromRadio
 }
 
 func (m *mockStreamer) Read(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.readErr != nil {
 		return 0, m.readErr
 	}
 
 	if m.readIndex >= len(m.readSteps) {
+		if m.idle && !m.closeCalled {
+			time.Sleep(time.Millisecond)
+			return 0, nil
+		}
 		return 0, io.EOF
 	}
 
@@ -47,6 +67,9 @@ func (m *mockStreamer) Read(p []byte) (int, error) {
 }
 
 func (m *mockStreamer) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.writeErr != nil {
 		return 0, m.writeErr
 	}
@@ -54,21 +77,50 @@ func (m *mockStreamer) Write(p []byte) (int, error) {
 	b := make([]byte, len(p))
 	copy(b, p)
 	m.writes = append(m.writes, b)
+
+	if m.respond != nil {
+		var tr pb.ToRadio
+		if err := proto.Unmarshal(b[frame.HeaderLen:], &tr); err != nil {
+			return 0, err
+		}
+		for _, fr := range m.respond(&tr) {
+			payload, err := proto.Marshal(fr)
+			if err != nil {
+				return 0, err
+			}
+			for _, c := range framed(payload) {
+				m.readSteps = append(m.readSteps, readStep{b: []byte{c}, n: 1})
+			}
+		}
+	}
 	return len(p), nil
 }
 
 func (m *mockStreamer) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.closeCalled = true
 	return nil
 }
 
 func (m *mockStreamer) SetReadTimeout(d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.setReadTimeouts = append(m.setReadTimeouts, d)
 	return nil
 }
 
-func frame(payload []byte) []byte {
-	header := []byte{start1, start2, byte(len(payload) >> 8), byte(len(payload))}
+func (m *mockStreamer) readTimeouts() []time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]time.Duration(nil), m.setReadTimeouts...)
+}
+
+func framed(payload []byte) []byte {
+	header := []byte{frame.Start1, frame.Start2, byte(len(payload) >> 8), byte(len(payload))}
 	out := make([]byte, 0, len(header)+len(payload))
 	out = append(out, header...)
 	out = append(out, payload...)
@@ -84,14 +136,26 @@ func stepsFromBytes(b []byte) []readStep {
 	return steps
 }
 
+// completeHandshake answers a want_config with replies followed by the
+// matching ConfigCompleteId.
+func completeHandshake(replies ...*pb.FromRadio) func(*pb.ToRadio) []*pb.FromRadio {
+	return func(tr *pb.ToRadio) []*pb.FromRadio {
+		if tr.GetWantConfigId() == 0 {
+			return nil
+		}
+		complete := &pb.FromRadio{PayloadVariant: &pb.FromRadio_ConfigCompleteId{ConfigCompleteId: tr.GetWantConfigId()}}
+		return append(append([]*pb.FromRadio(nil), replies...), complete)
+	}
+}
+
 func decodeToRadio(t *testing.T, packet []byte) *pb.ToRadio {
 	t.Helper()
-	require.GreaterOrEqual(t, len(packet), headerLen)
-	require.Equal(t, start1, packet[0])
-	require.Equal(t, start2, packet[1])
+	require.GreaterOrEqual(t, len(packet), frame.HeaderLen)
+	require.Equal(t, frame.Start1, packet[0])
+	require.Equal(t, frame.Start2, packet[1])
 
 	var tr pb.ToRadio
-	require.NoError(t, proto.Unmarshal(packet[headerLen:], &tr))
+	require.NoError(t, proto.Unmarshal(packet[frame.HeaderLen:], &tr))
 	return &tr
 }
 
@@ -100,11 +164,11 @@ func TestSendPacketAddsHeader(t *testing.T) {
 	r := &Radio{streamer: m}
 
 	payload := []byte{0x10, 0x20, 0x30}
-	require.NoError(t, r.SendPacket(payload))
+	require.NoError(t, r.SendPacket(context.Background(), payload))
 	require.Len(t, m.writes, 1)
 
 	got := m.writes[0]
-	require.Equal(t, []byte{start1, start2, 0x00, 0x03, 0x10, 0x20, 0x30}, got)
+	require.Equal(t, []byte{frame.Start1, frame.Start2, 0x00, 0x03, 0x10, 0x20, 0x30}, got)
 }
 
 func TestReadResponseParsesSinglePacket(t *testing.T) {
@@ -116,15 +180,15 @@ func TestReadResponseParsesSinglePacket(t *testing.T) {
 	payload, err := proto.Marshal(&msg)
 	require.NoError(t, err)
 
-	streamBytes := append([]byte{0x00, 0x11, 0x22}, frame(payload)...)
+	streamBytes := append([]byte{0x00, 0x11, 0x22}, framed(payload)...)
 	m := &mockStreamer{readSteps: stepsFromBytes(streamBytes)}
 	r := &Radio{streamer: m}
 
-	packets, err := r.ReadResponse(false)
+	packets, err := r.ReadResponse(context.Background(), false)
 	require.NoError(t, err)
 	require.Len(t, packets, 1)
 	require.Equal(t, uint32(0x01020304), packets[0].GetMyInfo().GetMyNodeNum())
-	require.Empty(t, m.setReadTimeouts)
+	require.Equal(t, []time.Duration{readResponsePoll}, m.readTimeouts())
 }
 
 func TestReadResponseTimeoutSetsReadTimeoutAndReturnsOnDeadline(t *testing.T) {
@@ -132,67 +196,377 @@ func TestReadResponseTimeoutSetsReadTimeoutAndReturnsOnDeadline(t *testing.T) {
 		readSteps: []readStep{
 			{n: 0, err: os.ErrDeadlineExceeded},
 		},
+		idle: true,
 	}
 	r := &Radio{streamer: m}
+	defer r.Close()
 
-	packets, err := r.ReadResponse(true)
+	packets, err := r.ReadResponse(context.Background(), true)
 	require.NoError(t, err)
 	require.Empty(t, packets)
-	require.Equal(t, []time.Duration{readResponsePoll}, m.setReadTimeouts)
+	require.Equal(t, []time.Duration{readResponsePoll}, m.readTimeouts())
 }
 
 func TestReadResponseIgnoresOversizedPacket(t *testing.T) {
-	oversized := []byte{start1, start2, 0x02, 0x01, 0x00, 0x00, 0x00}
+	oversized := []byte{frame.Start1, frame.Start2, 0x02, 0x01, 0x00, 0x00, 0x00}
 	m := &mockStreamer{readSteps: stepsFromBytes(oversized)}
 	r := &Radio{streamer: m}
 
-	packets, err := r.ReadResponse(false)
-	require.NoError(t, err)
+	packets, err := r.ReadResponse(context.Background(), false)
+	require.ErrorIs(t, err, ErrClosed)
 	require.Empty(t, packets)
 }
 
+func TestReadResponseReturnsErrClosedAfterStreamEnds(t *testing.T) {
+	m := &mockStreamer{readSteps: stepsFromBytes(nil)}
+	r := &Radio{streamer: m}
+
+	_, err := r.ReadResponse(context.Background(), true)
+	require.ErrorIs(t, err, ErrClosed)
+
+	_, err = r.ReadResponse(context.Background(), true)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestReadResponseReturnsErrClosedAfterReadError(t *testing.T) {
+	m := &mockStreamer{readErr: errors.New("read /dev/ttyUSB0: input/output error")}
+	r := &Radio{streamer: m}
+
+	_, err := r.ReadResponse(context.Background(), true)
+	require.ErrorIs(t, err, ErrClosed)
+	require.ErrorContains(t, err, "input/output error")
+
+	_, err = r.ReadResponse(context.Background(), true)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
 func TestGetRadioInfoSendsWantConfigAndParsesResponse(t *testing.T) {
-	response := pb.FromRadio{
-		PayloadVariant: &pb.FromRadio_MyInfo{
-			MyInfo: &pb.MyNodeInfo{MyNodeNum: 0x0badf00d},
-		},
+	m := &mockStreamer{
+		idle:    true,
+		respond: completeHandshake(&pb.FromRadio{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 0x0badf00d}}}),
 	}
-	payload, err := proto.Marshal(&response)
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	packets, err := r.GetRadioInfo(context.Background())
 	require.NoError(t, err)
+	require.Len(t, packets, 2)
+	require.Len(t, m.writes, 1)
+	require.Equal(t, uint32(0x0badf00d), r.NodeNum())
+
+	nonce := decodeToRadio(t, m.writes[0]).GetWantConfigId()
+	require.NotZero(t, nonce)
+	require.NotContains(t, []uint32{ConfigOnlyNonce, NodesOnlyNonce}, nonce)
+	require.Equal(t, nonce, packets[1].GetConfigCompleteId())
+}
 
-	m := &mockStreamer{readSteps: stepsFromBytes(frame(payload))}
+func TestHandshakeIgnoresStaleConfigComplete(t *testing.T) {
+	stale := &pb.FromRadio{PayloadVariant: &pb.FromRadio_ConfigCompleteId{ConfigCompleteId: 42}}
+	m := &mockStreamer{idle: true, respond: completeHandshake(stale)}
 	r := &Radio{streamer: m}
+	defer r.Close()
 
-	packets, err := r.GetRadioInfo()
+	packets, err := r.Handshake(context.Background(), HandshakeFull)
 	require.NoError(t, err)
-	require.Len(t, packets, 1)
-	require.Len(t, m.writes, wakeSendAttempts)
+	require.Len(t, packets, 2)
+}
 
-	written := decodeToRadio(t, m.writes[0])
-	require.Equal(t, uint32(radioInfoConfigID), written.GetWantConfigId())
+func TestHandshakeSpecialModesSendReservedNonces(t *testing.T) {
+	for mode, nonce := range map[HandshakeMode]uint32{
+		HandshakeConfigOnly: ConfigOnlyNonce,
+		HandshakeNodesOnly:  NodesOnlyNonce,
+	} {
+		m := &mockStreamer{idle: true, respond: completeHandshake()}
+		r := &Radio{streamer: m}
+
+		packets, err := r.Handshake(context.Background(), mode)
+		require.NoError(t, err, mode)
+		require.Equal(t, nonce, decodeToRadio(t, m.writes[0]).GetWantConfigId())
+		require.Equal(t, nonce, packets[0].GetConfigCompleteId())
+		require.NoError(t, r.Close())
+	}
 }
 
-func TestGetNodeNumSetsNodeNum(t *testing.T) {
-	response := pb.FromRadio{
-		PayloadVariant: &pb.FromRadio_MyInfo{
-			MyInfo: &pb.MyNodeInfo{MyNodeNum: 12345},
+func TestHandshakeStopsAtDeadlineWithPartialResponses(t *testing.T) {
+	m := &mockStreamer{
+		idle: true,
+		respond: func(*pb.ToRadio) []*pb.FromRadio {
+			return []*pb.FromRadio{{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 9}}}}
 		},
 	}
-	payload, err := proto.Marshal(&response)
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	packets, err := r.Handshake(ctx, HandshakeFull)
+	require.ErrorIs(t, err, ErrHandshakeIncomplete)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Len(t, packets, 1)
+}
+
+func TestHandshakeFailsWhenStreamEnds(t *testing.T) {
+	m := &mockStreamer{readSteps: stepsFromBytes(nil)}
+	r := &Radio{streamer: m}
+
+	_, err := r.GetRadioInfo(context.Background())
+	require.ErrorIs(t, err, ErrHandshakeIncomplete)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+// serveFramedTCP emulates a meshtasticd stream endpoint: it reads one framed ToRadio,
+// hands it to respond, writes back the framed replies and closes the connection.
+func serveFramedTCP(t *testing.T, respond func(*pb.ToRadio) []*pb.FromRadio) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		header := make([]byte, frame.HeaderLen)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		if header[0] != frame.Start1 || header[1] != frame.Start2 {
+			return
+		}
+		payload := make([]byte, int(header[2])<<8|int(header[3]))
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+
+		var toRadio pb.ToRadio
+		if err := proto.Unmarshal(payload, &toRadio); err != nil {
+			return
+		}
+		for _, fr := range respond(&toRadio) {
+			out, err := proto.Marshal(fr)
+			if err != nil {
+				return
+			}
+			if _, err := conn.Write(framed(out)); err != nil {
+				return
+			}
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestGetRadioInfoOverTCP(t *testing.T) {
+	addr := serveFramedTCP(t, func(tr *pb.ToRadio) []*pb.FromRadio {
+		return []*pb.FromRadio{
+			{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 0x0a0b0c0d}}},
+			{PayloadVariant: &pb.FromRadio_ConfigCompleteId{ConfigCompleteId: tr.GetWantConfigId()}},
+		}
+	})
+
+	r, err := NewRadio(TCPScheme + addr)
+	require.NoError(t, err)
+	defer r.Close()
+
+	packets, err := r.GetRadioInfo(context.Background())
 	require.NoError(t, err)
+	require.Len(t, packets, 2)
+	require.Equal(t, uint32(0x0a0b0c0d), packets[0].GetMyInfo().GetMyNodeNum())
+	require.NotZero(t, packets[1].GetConfigCompleteId())
+}
+
+func TestRecorderCapturesBothDirectionsAndReplays(t *testing.T) {
+	myInfo := &pb.FromRadio{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 0x0badf00d}}}
 
-	m := &mockStreamer{readSteps: stepsFromBytes(frame(payload))}
+	var buf bytes.Buffer
+	w, err := capture.NewWriter(&buf)
+	require.NoError(t, err)
+
+	m := &mockStreamer{idle: true, respond: completeHandshake(myInfo)}
 	r := &Radio{streamer: m}
+	r.SetRecorder(w)
 
-	require.NoError(t, r.getNodeNum())
-	require.Equal(t, uint32(12345), r.nodeNum)
+	_, err = r.GetRadioInfo(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.NoError(t, w.Close())
+
+	reader, err := capture.NewReader(&buf)
+	require.NoError(t, err)
+	var records []capture.Record
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		records = append(records, rec)
+	}
+	require.Len(t, records, 3)
+	require.Equal(t, capture.ToRadio, records[0].Direction)
+	require.NotZero(t, decodeToRadio(t, records[0].Frame).GetWantConfigId())
+	require.Equal(t, framedFromRadio(t, myInfo), records[1].Frame)
+
+	replayed := NewRadioFromStreamer(capture.NewReplayer(records, 0))
+	defer replayed.Close()
+
+	packets, err := replayed.GetRadioInfo(context.Background())
+	require.NoError(t, err)
+	require.Len(t, packets, 2)
+	require.Equal(t, uint32(0x0badf00d), replayed.NodeNum())
+}
+
+func TestGetNodeNumSetsNodeNum(t *testing.T) {
+	m := &mockStreamer{
+		idle:    true,
+		respond: completeHandshake(&pb.FromRadio{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{MyNodeNum: 12345}}}),
+	}
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	require.NoError(t, r.getNodeNum(context.Background()))
+	require.Equal(t, uint32(12345), r.NodeNum())
+}
+
+func TestReadResponseReturnsWhenContextIsCancelled(t *testing.T) {
+	m := &mockStreamer{idle: true}
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.ReadResponse(ctx, false)
+		done <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatalf("ReadResponse did not return after cancel")
+	}
+}
+
+func TestSendPacketSkipsWriteAfterCancel(t *testing.T) {
+	m := &mockStreamer{}
+	r := &Radio{streamer: m}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.ErrorIs(t, r.SendTextMessage(ctx, "late", 0, 0), context.Canceled)
+	require.Empty(t, m.writes)
+}
+
+// wedgedStreamer blocks every Write until the streamer is closed, like a
+// serial port whose device has stopped draining its buffer.
+type wedgedStreamer struct {
+	mockStreamer
+	closed chan struct{}
+}
+
+func (w *wedgedStreamer) Write([]byte) (int, error) {
+	<-w.closed
+	return 0, os.ErrClosed
+}
+
+func (w *wedgedStreamer) Close() error {
+	close(w.closed)
+	return nil
+}
+
+func TestSendPacketClosesRadioWhenWriteOutlivesDeadline(t *testing.T) {
+	w := &wedgedStreamer{closed: make(chan struct{})}
+	r := &Radio{streamer: w}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	require.ErrorIs(t, r.SendTextMessage(ctx, "stuck", 0, 0), context.DeadlineExceeded)
+	require.Less(t, time.Since(start), wedgedWriteGrace+time.Second)
+	require.True(t, r.isClosing())
+
+	require.ErrorIs(t, r.SendTextMessage(context.Background(), "after", 0, 0), ErrClosed)
+}
+
+// slowStreamer finishes every Write after delay, like the serial streamer's
+// pause between frames.
+type slowStreamer struct {
+	mockStreamer
+	delay time.Duration
+}
+
+func (s *slowStreamer) Write(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.mockStreamer.Write(p)
+}
+
+func TestSendPacketKeepsRadioWhenSlowWriteFinishesAfterCancel(t *testing.T) {
+	s := &slowStreamer{delay: 50 * time.Millisecond}
+	r := &Radio{streamer: s}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, r.SendTextMessage(ctx, "slow", 0, 0), context.DeadlineExceeded)
+	require.False(t, r.isClosing())
+	require.NoError(t, r.SendTextMessage(context.Background(), "next", 0, 0))
+}
+
+// deadlineStreamer blocks a Write until its write deadline passes, like a TCP
+// connection whose peer has stopped reading.
+type deadlineStreamer struct {
+	mockStreamer
+	deadlines chan time.Time
+	blocked   bool
+}
+
+func (d *deadlineStreamer) Write(p []byte) (int, error) {
+	if !d.blocked {
+		return d.mockStreamer.Write(p)
+	}
+	for deadline := range d.deadlines {
+		if !deadline.IsZero() {
+			d.blocked = false
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+	return 0, os.ErrClosed
+}
+
+func (d *deadlineStreamer) SetWriteDeadline(t time.Time) error {
+	d.deadlines <- t
+	return nil
+}
+
+func TestSendPacketCutsWriteShortWithDeadline(t *testing.T) {
+	d := &deadlineStreamer{deadlines: make(chan time.Time, 2), blocked: true}
+	r := &Radio{streamer: d}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, r.SendTextMessage(ctx, "stuck", 0, 0), context.DeadlineExceeded)
+	require.False(t, r.isClosing())
+	require.False(t, d.closeCalled)
+	require.True(t, (<-d.deadlines).IsZero(), "deadline is cleared for the next write")
+
+	require.NoError(t, r.SendTextMessage(context.Background(), "next", 0, 0))
+	require.Len(t, d.writes, 1)
 }
 
 func TestSendTextMessageBroadcast(t *testing.T) {
 	m := &mockStreamer{}
 	r := &Radio{streamer: m}
 
-	require.NoError(t, r.SendTextMessage("hello", 0, 1))
+	require.NoError(t, r.SendTextMessage(context.Background(), "hello", 0, 1))
 	require.Len(t, m.writes, 1)
 
 	toRadio := decodeToRadio(t, m.writes[0])
@@ -214,36 +588,17 @@ func TestSendTextMessageRejectsLargePayload(t *testing.T) {
 		large[i] = 'a'
 	}
 
-	err := r.SendTextMessage(string(large), 0, 0)
+	err := r.SendTextMessage(context.Background(), string(large), 0, 0)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "message too large")
 	require.Empty(t, m.writes)
 }
 
-func TestSetRadioOwnerBuildsAdminPacket(t *testing.T) {
-	m := &mockStreamer{}
-	r := &Radio{streamer: m, nodeNum: 77}
-
-	require.NoError(t, r.SetRadioOwner("Corey"))
-	require.Len(t, m.writes, 1)
-
-	toRadio := decodeToRadio(t, m.writes[0])
-	packet := toRadio.GetPacket()
-	require.NotNil(t, packet)
-	require.Equal(t, uint32(77), packet.GetTo())
-	require.Equal(t, pb.PortNum_ADMIN_APP, packet.GetDecoded().GetPortnum())
-
-	var admin pb.AdminMessage
-	require.NoError(t, proto.Unmarshal(packet.GetDecoded().GetPayload(), &admin))
-	require.Equal(t, "Corey", admin.GetSetOwner().GetLongName())
-	require.Equal(t, "Cor", admin.GetSetOwner().GetShortName())
-}
-
 func TestSetModemModeBuildsAdminConfigPacket(t *testing.T) {
 	m := &mockStreamer{}
 	r := &Radio{streamer: m, nodeNum: 33}
 
-	require.NoError(t, r.SetModemMode("ls"))
+	require.NoError(t, r.SetModemMode(context.Background(), "ls"))
 	require.Len(t, m.writes, 1)
 
 	toRadio := decodeToRadio(t, m.writes[0])
@@ -259,7 +614,7 @@ func TestSetModemModeRejectsInvalid(t *testing.T) {
 	m := &mockStreamer{}
 	r := &Radio{streamer: m, nodeNum: 33}
 
-	err := r.SetModemMode("bad")
+	err := r.SetModemMode(context.Background(), "bad")
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "invalid modem mode")
 	require.Empty(t, m.writes)
@@ -269,7 +624,7 @@ func TestSetLocationBuildsPositionPacket(t *testing.T) {
 	m := &mockStreamer{}
 	r := &Radio{streamer: m, nodeNum: 88}
 
-	require.NoError(t, r.SetLocation(123, 456, 789))
+	require.NoError(t, r.SetLocation(context.Background(), 123, 456, 789))
 	require.Len(t, m.writes, 1)
 
 	toRadio := decodeToRadio(t, m.writes[0])
@@ -289,8 +644,8 @@ func TestFactoryResetAndAlias(t *testing.T) {
 	m := &mockStreamer{}
 	r := &Radio{streamer: m, nodeNum: 19}
 
-	require.NoError(t, r.FactoryReset())
-	require.NoError(t, r.FactoryRest())
+	require.NoError(t, r.FactoryReset(context.Background()))
+	require.NoError(t, r.FactoryRest(context.Background()))
 	require.Len(t, m.writes, 2)
 
 	for _, w := range m.writes {
@@ -314,3 +669,13 @@ func TestCloseDelegatesToStreamer(t *testing.T) {
 	require.True(t, m.closeCalled)
 }
 
+func TestCloseStopsBackgroundReader(t *testing.T) {
+	m := &mockStreamer{idle: true}
+	r := &Radio{streamer: m}
+
+	sub := r.Subscribe(nil)
+	require.NoError(t, r.Close())
+
+	_, ok := <-sub.C()
+	require.False(t, ok, "subscription should be closed when the radio closes")
+}
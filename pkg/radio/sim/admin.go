@@ -0,0 +1,283 @@
+package sim
+
+import (
+	"slices"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// handleAdminLocked applies an admin message addressed to this node. Get
+// requests are answered with the matching response; everything else is
+// acknowledged with a routing packet once it has changed the node's state.
+func (n *Node) handleAdminLocked(p *pb.MeshPacket) {
+	var msg pb.AdminMessage
+	if err := proto.Unmarshal(p.GetDecoded().GetPayload(), &msg); err != nil {
+		n.ackLocked(p, n.num, pb.Routing_BAD_REQUEST)
+		return
+	}
+
+	resp, reason := n.applyAdminLocked(&msg)
+	if resp == nil {
+		n.ackLocked(p, n.num, reason)
+		return
+	}
+
+	resp.SessionPasskey = n.passkey
+	n.sendLocked(n.replyLocked(p, n.num, pb.PortNum_ADMIN_APP, resp))
+}
+
+func (n *Node) applyAdminLocked(msg *pb.AdminMessage) (*pb.AdminMessage, pb.Routing_Error) {
+	switch v := msg.GetPayloadVariant().(type) {
+	case *pb.AdminMessage_GetOwnerRequest:
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetOwnerResponse{
+			GetOwnerResponse: proto.Clone(n.owner).(*pb.User),
+		}}, pb.Routing_NONE
+
+	case *pb.AdminMessage_GetConfigRequest:
+		c, ok := n.configs[protoreflect.FieldNumber(v.GetConfigRequest)+1]
+		if !ok {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigResponse{
+			GetConfigResponse: proto.Clone(c).(*pb.Config),
+		}}, pb.Routing_NONE
+
+	case *pb.AdminMessage_GetModuleConfigRequest:
+		c, ok := n.modules[protoreflect.FieldNumber(v.GetModuleConfigRequest)+1]
+		if !ok {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetModuleConfigResponse{
+			GetModuleConfigResponse: proto.Clone(c).(*pb.ModuleConfig),
+		}}, pb.Routing_NONE
+
+	case *pb.AdminMessage_GetChannelRequest:
+		// The request carries the channel index plus one.
+		index := int(v.GetChannelRequest) - 1
+		if index < 0 || index >= len(n.channels) {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetChannelResponse{
+			GetChannelResponse: proto.Clone(n.channels[index]).(*pb.Channel),
+		}}, pb.Routing_NONE
+
+	case *pb.AdminMessage_GetCannedMessageModuleMessagesRequest:
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetCannedMessageModuleMessagesResponse{
+			GetCannedMessageModuleMessagesResponse: n.cannedMessages,
+		}}, pb.Routing_NONE
+
+	case *pb.AdminMessage_GetRingtoneRequest:
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetRingtoneResponse{
+			GetRingtoneResponse: n.ringtone,
+		}}, pb.Routing_NONE
+
+	case *pb.AdminMessage_SetOwner:
+		n.setOwnerLocked(v.SetOwner)
+	case *pb.AdminMessage_SetHamMode:
+		n.setHamModeLocked(v.SetHamMode)
+
+	case *pb.AdminMessage_SetConfig:
+		num := fieldNumber(v.SetConfig)
+		if num == 0 {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		n.configs[num] = proto.Clone(v.SetConfig).(*pb.Config)
+		if device := v.SetConfig.GetDevice(); device != nil {
+			n.owner.Role = device.GetRole()
+		}
+
+	case *pb.AdminMessage_SetModuleConfig:
+		num := fieldNumber(v.SetModuleConfig)
+		if num == 0 {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		n.modules[num] = proto.Clone(v.SetModuleConfig).(*pb.ModuleConfig)
+
+	case *pb.AdminMessage_SetChannel:
+		index := int(v.SetChannel.GetIndex())
+		if index < 0 || index >= len(n.channels) {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		n.channels[index] = proto.Clone(v.SetChannel).(*pb.Channel)
+
+	case *pb.AdminMessage_SetCannedMessageModuleMessages:
+		// Like the firmware, an empty list is ignored rather than stored.
+		if v.SetCannedMessageModuleMessages != "" {
+			n.cannedMessages = v.SetCannedMessageModuleMessages
+		}
+	case *pb.AdminMessage_SetRingtoneMessage:
+		n.ringtone = v.SetRingtoneMessage
+
+	case *pb.AdminMessage_SetFixedPosition:
+		pos := proto.Clone(v.SetFixedPosition).(*pb.Position)
+		pos.Time = uint32(time.Now().Unix())
+		n.position = pos
+		n.setFixedPositionLocked(true)
+	case *pb.AdminMessage_RemoveFixedPosition:
+		n.position = nil
+		n.setFixedPositionLocked(false)
+
+	case *pb.AdminMessage_SetFavoriteNode:
+		n.updatePeerLocked(v.SetFavoriteNode, func(p *pb.NodeInfo) { p.IsFavorite = true })
+	case *pb.AdminMessage_RemoveFavoriteNode:
+		n.updatePeerLocked(v.RemoveFavoriteNode, func(p *pb.NodeInfo) { p.IsFavorite = false })
+	case *pb.AdminMessage_SetIgnoredNode:
+		n.updatePeerLocked(v.SetIgnoredNode, func(p *pb.NodeInfo) { p.IsIgnored = true })
+	case *pb.AdminMessage_RemoveIgnoredNode:
+		n.updatePeerLocked(v.RemoveIgnoredNode, func(p *pb.NodeInfo) { p.IsIgnored = false })
+	case *pb.AdminMessage_ToggleMutedNode:
+		n.updatePeerLocked(v.ToggleMutedNode, func(p *pb.NodeInfo) { p.IsMuted = !p.IsMuted })
+	case *pb.AdminMessage_RemoveByNodenum:
+		n.peers = slices.DeleteFunc(n.peers, func(p *pb.NodeInfo) bool { return p.GetNum() == v.RemoveByNodenum })
+	case *pb.AdminMessage_NodedbReset:
+		// true keeps favorites through the reset.
+		n.peers = slices.DeleteFunc(n.peers, func(p *pb.NodeInfo) bool {
+			return !v.NodedbReset || !p.GetIsFavorite()
+		})
+
+	case *pb.AdminMessage_BackupPreferences:
+		if n.backups == nil {
+			n.backups = make(map[pb.AdminMessage_BackupLocation]*backup)
+		}
+		n.backups[v.BackupPreferences] = n.backupLocked()
+	case *pb.AdminMessage_RestorePreferences:
+		b, ok := n.backups[v.RestorePreferences]
+		if !ok {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		n.restoreLocked(b)
+		n.rebootLocked(0)
+	case *pb.AdminMessage_RemoveBackupPreferences:
+		delete(n.backups, v.RemoveBackupPreferences)
+
+	case *pb.AdminMessage_DeleteFileRequest:
+		delete(n.files, v.DeleteFileRequest)
+
+	case *pb.AdminMessage_BeginEditSettings:
+		n.editing = true
+	case *pb.AdminMessage_CommitEditSettings:
+		n.editing = false
+
+	case *pb.AdminMessage_FactoryResetDevice:
+		n.resetLocked()
+		n.rebootLocked(0)
+	case *pb.AdminMessage_RebootSeconds:
+		n.rebootLocked(v.RebootSeconds)
+	case *pb.AdminMessage_RebootOtaSeconds:
+		n.rebootLocked(v.RebootOtaSeconds)
+	case *pb.AdminMessage_ShutdownSeconds:
+
+	default:
+		return nil, pb.Routing_BAD_REQUEST
+	}
+
+	return nil, pb.Routing_NONE
+}
+
+// setOwnerLocked merges owner like the firmware does: empty names are left
+// unchanged and the node ID cannot be overridden.
+func (n *Node) setOwnerLocked(owner *pb.User) {
+	if owner.GetLongName() != "" {
+		n.owner.LongName = owner.GetLongName()
+	}
+	if owner.GetShortName() != "" {
+		n.owner.ShortName = owner.GetShortName()
+	}
+	n.owner.IsLicensed = owner.GetIsLicensed()
+	if owner.IsUnmessagable != nil {
+		unmessagable := owner.GetIsUnmessagable()
+		n.owner.IsUnmessagable = &unmessagable
+	}
+}
+
+// setHamModeLocked applies ham parameters as the firmware does: the owner is
+// named after the call sign and licensed, LoRa limits are lifted, and the
+// primary channel loses its PSK so the node sends in the clear.
+func (n *Node) setHamModeLocked(p *pb.HamParameters) {
+	n.owner.LongName = p.GetCallSign()
+	n.owner.ShortName = p.GetShortName()
+	n.owner.IsLicensed = true
+
+	num := fieldNumber(&pb.Config{PayloadVariant: &pb.Config_Lora{}})
+	lora := proto.Clone(n.configs[num].GetLora()).(*pb.Config_LoRaConfig)
+	lora.OverrideDutyCycle = true
+	lora.TxPower = p.GetTxPower()
+	lora.OverrideFrequency = p.GetFrequency()
+	n.configs[num] = &pb.Config{PayloadVariant: &pb.Config_Lora{Lora: lora}}
+
+	for _, ch := range n.channels {
+		if ch.GetRole() == pb.Channel_PRIMARY && ch.Settings != nil {
+			ch.Settings.Psk = nil
+		}
+	}
+}
+
+func (n *Node) setFixedPositionLocked(fixed bool) {
+	num := fieldNumber(&pb.Config{PayloadVariant: &pb.Config_Position{}})
+	position := proto.Clone(n.configs[num].GetPosition()).(*pb.Config_PositionConfig)
+	position.FixedPosition = fixed
+	n.configs[num] = &pb.Config{PayloadVariant: &pb.Config_Position{Position: position}}
+}
+
+func (n *Node) updatePeerLocked(num uint32, update func(*pb.NodeInfo)) {
+	if peer := n.peerLocked(num); peer != nil {
+		update(peer)
+	}
+}
+
+// rebootLocked announces a reboot after the given delay in seconds; a negative
+// delay cancels, as on the firmware.
+func (n *Node) rebootLocked(seconds int32) {
+	if seconds < 0 {
+		return
+	}
+	n.afterLocked(time.Duration(seconds)*time.Second, func(time.Time) []*pb.FromRadio {
+		n.rebootCount++
+		return []*pb.FromRadio{{PayloadVariant: &pb.FromRadio_Rebooted{Rebooted: true}}}
+	})
+}
+
+// backup is a copy of the preferences the firmware backs up. It lives outside
+// the settings that resetLocked restores, so it survives a factory reset as
+// the firmware's backup file does.
+type backup struct {
+	owner    *pb.User
+	configs  map[protoreflect.FieldNumber]*pb.Config
+	modules  map[protoreflect.FieldNumber]*pb.ModuleConfig
+	channels []*pb.Channel
+}
+
+func (n *Node) backupLocked() *backup {
+	return &backup{
+		owner:    proto.Clone(n.owner).(*pb.User),
+		configs:  cloneSections(n.configs),
+		modules:  cloneSections(n.modules),
+		channels: cloneChannels(n.channels),
+	}
+}
+
+func (n *Node) restoreLocked(b *backup) {
+	n.owner = proto.Clone(b.owner).(*pb.User)
+	n.configs = cloneSections(b.configs)
+	n.modules = cloneSections(b.modules)
+	n.channels = cloneChannels(b.channels)
+}
+
+func cloneSections[M proto.Message](sections map[protoreflect.FieldNumber]M) map[protoreflect.FieldNumber]M {
+	out := make(map[protoreflect.FieldNumber]M, len(sections))
+	for num, msg := range sections {
+		out[num] = proto.Clone(msg).(M)
+	}
+	return out
+}
+
+func cloneChannels(channels []*pb.Channel) []*pb.Channel {
+	out := make([]*pb.Channel, len(channels))
+	for i, ch := range channels {
+		out[i] = proto.Clone(ch).(*pb.Channel)
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+package sim
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxRetransmits is how many NAKs the firmware answers for one block before
+// it cancels a transfer.
+const maxRetransmits = 25
+
+// transfer is the node's side of an XModem transfer, kept the way the
+// firmware's XModemAdapter keeps it.
+type transfer struct {
+	name         string
+	receiving    bool
+	transmitting bool
+	eot          bool
+	seq          uint32
+	offset       int
+	retransmits  int
+	block        *pb.XModem
+	incoming     bytes.Buffer
+}
+
+func (n *Node) loadFilesLocked() {
+	n.files = make(map[string][]byte, len(n.scenario.Files))
+	for _, f := range n.scenario.Files {
+		n.files[f.Name] = []byte(f.Content)
+	}
+}
+
+// fileManifestLocked lists the node's files, sorted by name, as the handshake
+// reports them.
+func (n *Node) fileManifestLocked() []*pb.FromRadio {
+	names := slices.Sorted(maps.Keys(n.files))
+	out := make([]*pb.FromRadio, 0, len(names))
+	for _, name := range names {
+		out = append(out, &pb.FromRadio{PayloadVariant: &pb.FromRadio_FileInfo{FileInfo: &pb.FileInfo{
+			FileName:  name,
+			SizeBytes: uint32(len(n.files[name])),
+		}}})
+	}
+	return out
+}
+
+// handleXModemLocked answers an XModem packet as the firmware does, including
+// its quirks: a short block, even an empty one, ends a download; CAN deletes
+// the file named in the transfer; and a stray packet is answered with CAN.
+func (n *Node) handleXModemLocked(p *pb.XModem) {
+	t := &n.transfer
+	switch p.GetControl() {
+	case pb.XModem_SOH, pb.XModem_STX:
+		if p.GetSeq() == 0 && !t.receiving && !t.transmitting {
+			t.name, _, _ = strings.Cut(string(p.GetBuffer()), "\x00")
+			if p.GetControl() == pb.XModem_SOH {
+				*t = transfer{name: t.name, receiving: true, seq: 1}
+				n.sendXModemLocked(pb.XModem_ACK)
+				return
+			}
+			if _, ok := n.files[t.name]; !ok {
+				n.sendXModemLocked(pb.XModem_NAK)
+				return
+			}
+			*t = transfer{name: t.name, transmitting: true, seq: 1}
+			n.sendBlockLocked()
+			return
+		}
+		switch {
+		case t.receiving && p.GetSeq() == t.seq && xmodem.Valid(p):
+			t.incoming.Write(p.GetBuffer())
+			t.seq++
+			n.sendXModemLocked(pb.XModem_ACK)
+		case t.receiving:
+			n.sendXModemLocked(pb.XModem_NAK)
+		case t.transmitting:
+			t.transmitting = false
+			n.sendXModemLocked(pb.XModem_CAN)
+		}
+
+	case pb.XModem_EOT:
+		n.sendXModemLocked(pb.XModem_ACK)
+		if t.receiving {
+			n.files[t.name] = bytes.Clone(t.incoming.Bytes())
+		}
+		t.receiving = false
+
+	case pb.XModem_CAN:
+		n.sendXModemLocked(pb.XModem_ACK)
+		delete(n.files, t.name)
+		t.receiving = false
+
+	case pb.XModem_ACK:
+		switch {
+		case !t.transmitting:
+			n.sendXModemLocked(pb.XModem_CAN)
+		case t.eot:
+			t.transmitting = false
+			n.sendXModemLocked(pb.XModem_EOT)
+		default:
+			t.seq++
+			n.sendBlockLocked()
+		}
+
+	case pb.XModem_NAK:
+		switch {
+		case !t.transmitting:
+			n.sendXModemLocked(pb.XModem_CAN)
+		case t.retransmits >= maxRetransmits:
+			t.transmitting = false
+			n.sendXModemLocked(pb.XModem_CAN)
+		default:
+			t.retransmits++
+			n.sendLocked(xmodemFrame(proto.Clone(t.block).(*pb.XModem)))
+		}
+	}
+}
+
+// sendBlockLocked sends the next block of the file being downloaded.
+func (n *Node) sendBlockLocked() {
+	t := &n.transfer
+	data := n.files[t.name][t.offset:]
+	if len(data) > xmodem.BlockSize {
+		data = data[:xmodem.BlockSize]
+	}
+	t.offset += len(data)
+	t.eot = len(data) < xmodem.BlockSize
+	t.retransmits = 0
+	t.block = xmodem.Block(t.seq, data)
+	n.sendLocked(xmodemFrame(proto.Clone(t.block).(*pb.XModem)))
+}
+
+func (n *Node) sendXModemLocked(c pb.XModem_Control) {
+	n.sendLocked(xmodemFrame(&pb.XModem{Control: c}))
+}
+
+func xmodemFrame(p *pb.XModem) *pb.FromRadio {
+	return &pb.FromRadio{PayloadVariant: &pb.FromRadio_XmodemPacket{XmodemPacket: p}}
+}
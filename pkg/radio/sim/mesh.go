@@ -0,0 +1,265 @@
+package sim
+
+import (
+	"log"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	simChannelUtil = float32(3.5)
+	simAirUtilTx   = float32(0.7)
+	// unknownSNR marks a hop whose SNR was not recorded in a RouteDiscovery.
+	unknownSNR = int32(-128)
+)
+
+// handlePacketLocked reacts to a mesh packet sent by the client. Packets for
+// this node are handled locally; others are "delivered" to peers, which ack or
+// answer admin messages after the scenario's latency, or fail when the
+// destination is unknown.
+func (n *Node) handlePacketLocked(p *pb.MeshPacket) {
+	data := p.GetDecoded()
+	if data == nil {
+		return
+	}
+
+	local := p.GetTo() == n.num || p.GetTo() == 0
+	switch {
+	case local && data.GetPortnum() == pb.PortNum_ADMIN_APP:
+		n.handleAdminLocked(p)
+	case local && data.GetPortnum() == pb.PortNum_POSITION_APP:
+		var pos pb.Position
+		if err := proto.Unmarshal(data.GetPayload(), &pos); err != nil {
+			n.ackLocked(p, n.num, pb.Routing_BAD_REQUEST)
+			return
+		}
+		pos.Time = uint32(time.Now().Unix())
+		n.position = &pos
+		n.ackLocked(p, n.num, pb.Routing_NONE)
+	case local:
+		n.ackLocked(p, n.num, pb.Routing_NONE)
+	case data.GetPortnum() == pb.PortNum_TRACEROUTE_APP && data.GetWantResponse() && n.peerLocked(p.GetTo()) != nil:
+		n.tracerouteLocked(p)
+	case data.GetPortnum() == pb.PortNum_ADMIN_APP && n.peerLocked(p.GetTo()) != nil:
+		n.handleRemoteAdminLocked(p)
+	case p.GetTo() == broadcastNum:
+		// Broadcasts are acknowledged implicitly when a neighbor rebroadcasts.
+		n.meshReplyLocked(p, n.num, pb.Routing_NONE)
+	case n.peerLocked(p.GetTo()) != nil:
+		n.meshReplyLocked(p, p.GetTo(), pb.Routing_NONE)
+	default:
+		n.meshReplyLocked(p, n.num, pb.Routing_MAX_RETRANSMIT)
+	}
+}
+
+// tracerouteLocked answers a traceroute to a peer the way the firmware would:
+// the request reaches the peer through one relay per hop away, each recording
+// itself and the SNR it heard the packet at, and the peer sends the filled in
+// RouteDiscovery back along the same relays.
+func (n *Node) tracerouteLocked(p *pb.MeshPacket) {
+	dest := p.GetTo()
+	relays := n.relaysLocked(n.peerSpecs[dest].HopsAway)
+	snr := func(num uint32) int32 {
+		if spec, ok := n.peerSpecs[num]; ok {
+			return int32(spec.SNR * 4)
+		}
+		return unknownSNR
+	}
+
+	var route pb.RouteDiscovery
+	for _, relay := range relays {
+		route.Route = append(route.Route, relay)
+		route.SnrTowards = append(route.SnrTowards, snr(relay))
+	}
+	route.SnrTowards = append(route.SnrTowards, snr(dest))
+
+	last := dest
+	for i := len(relays) - 1; i >= 0; i-- {
+		route.RouteBack = append(route.RouteBack, relays[i])
+		route.SnrBack = append(route.SnrBack, snr(last))
+		last = relays[i]
+	}
+	route.SnrBack = append(route.SnrBack, snr(last))
+
+	n.afterLocked(n.scenario.Node.Latency, func(time.Time) []*pb.FromRadio {
+		return []*pb.FromRadio{n.replyLocked(p, dest, pb.PortNum_TRACEROUTE_APP, &route)}
+	})
+}
+
+// relaysLocked picks the relays on the path to a peer hops away: for each hop,
+// the first scenario peer that distance from this node, or an unknown node
+// when the scenario has none.
+func (n *Node) relaysLocked(hops uint32) []uint32 {
+	relays := make([]uint32, 0, hops)
+	for h := range hops {
+		relay := broadcastNum
+		for _, spec := range n.scenario.Peers {
+			if spec.HopsAway == h {
+				relay, _ = parseNodeID(spec.ID)
+				break
+			}
+		}
+		relays = append(relays, relay)
+	}
+	return relays
+}
+
+// meshReplyLocked acks p from responder after the scenario latency.
+func (n *Node) meshReplyLocked(p *pb.MeshPacket, responder uint32, reason pb.Routing_Error) {
+	if !p.GetWantAck() {
+		return
+	}
+	n.afterLocked(n.scenario.Node.Latency, func(time.Time) []*pb.FromRadio {
+		return []*pb.FromRadio{n.routingLocked(p, responder, reason)}
+	})
+}
+
+func (n *Node) ackLocked(p *pb.MeshPacket, responder uint32, reason pb.Routing_Error) {
+	if !p.GetWantAck() {
+		return
+	}
+	n.sendLocked(n.routingLocked(p, responder, reason))
+}
+
+// routingLocked builds the ROUTING_APP reply for p that the firmware uses for
+// both acks and naks.
+func (n *Node) routingLocked(p *pb.MeshPacket, responder uint32, reason pb.Routing_Error) *pb.FromRadio {
+	routing := &pb.Routing{Variant: &pb.Routing_ErrorReason{ErrorReason: reason}}
+	return n.replyLocked(p, responder, pb.PortNum_ROUTING_APP, routing)
+}
+
+// replyLocked wraps msg in a packet from responder answering request p.
+func (n *Node) replyLocked(p *pb.MeshPacket, responder uint32, port pb.PortNum, msg proto.Message) *pb.FromRadio {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		log.Printf("sim: marshal reply: %v", err)
+	}
+
+	return &pb.FromRadio{PayloadVariant: &pb.FromRadio_Packet{Packet: &pb.MeshPacket{
+		From:    responder,
+		To:      n.num,
+		Channel: p.GetChannel(),
+		Id:      n.newPacketIDLocked(),
+		RxTime:  uint32(time.Now().Unix()),
+		PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+			Portnum:   port,
+			Payload:   payload,
+			RequestId: p.GetId(),
+		}},
+	}}}
+}
+
+// scheduleTrafficLocked queues the scenario's traffic relative to n.started.
+func (n *Node) scheduleTrafficLocked() {
+	for _, t := range n.scenario.Traffic {
+		remaining := t.Count
+		if remaining == 0 {
+			remaining = -1
+			if t.Every <= 0 {
+				remaining = 1
+			}
+		}
+
+		n.events = append(n.events, &event{
+			at:        n.started.Add(t.At),
+			every:     t.Every,
+			remaining: remaining,
+			fire: func(now time.Time) []*pb.FromRadio {
+				if pkt := n.trafficPacketLocked(t, now); pkt != nil {
+					return []*pb.FromRadio{pkt}
+				}
+				return nil
+			},
+		})
+	}
+}
+
+// trafficPacketLocked builds one synthetic packet for t and records what the
+// node learned from it in the sender's node info.
+func (n *Node) trafficPacketLocked(t TrafficSpec, now time.Time) *pb.FromRadio {
+	from, _ := parseNodeID(t.From)
+	to, _ := parseDestination(t.To)
+	spec := n.peerSpecs[from]
+	peer := n.heardLocked(from, now)
+
+	var (
+		port pb.PortNum
+		msg  []byte
+		err  error
+	)
+	switch t.Type {
+	case trafficText:
+		port, msg = pb.PortNum_TEXT_MESSAGE_APP, []byte(t.Text)
+	case trafficPosition:
+		pos := t.Position
+		if pos == nil {
+			pos = spec.Position
+		}
+		if pos == nil {
+			return nil
+		}
+		peer.Position = pos.proto(now)
+		port = pb.PortNum_POSITION_APP
+		msg, err = proto.Marshal(peer.Position)
+	case trafficTelemetry:
+		metrics := n.deviceMetricsLocked(t, spec, now)
+		peer.DeviceMetrics = metrics
+		port = pb.PortNum_TELEMETRY_APP
+		msg, err = proto.Marshal(&pb.Telemetry{
+			Time:    uint32(now.Unix()),
+			Variant: &pb.Telemetry_DeviceMetrics{DeviceMetrics: metrics},
+		})
+	}
+	if err != nil {
+		log.Printf("sim: marshal %s traffic: %v", t.Type, err)
+		return nil
+	}
+
+	rssi := spec.RSSI
+	if rssi == 0 {
+		rssi = defaultRSSI
+	}
+	hopLimit := defaultHopLimit - min(spec.HopsAway, defaultHopLimit)
+
+	return &pb.FromRadio{PayloadVariant: &pb.FromRadio_Packet{Packet: &pb.MeshPacket{
+		From:     from,
+		To:       to,
+		Channel:  t.Channel,
+		Id:       n.newPacketIDLocked(),
+		RxTime:   uint32(now.Unix()),
+		RxSnr:    spec.SNR,
+		RxRssi:   rssi,
+		HopLimit: hopLimit,
+		HopStart: defaultHopLimit,
+		PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+			Portnum: port,
+			Payload: msg,
+		}},
+	}}}
+}
+
+func (n *Node) deviceMetricsLocked(t TrafficSpec, spec PeerSpec, now time.Time) *pb.DeviceMetrics {
+	battery := t.Battery
+	if battery == 0 {
+		battery = spec.Battery
+	}
+	if battery == 0 {
+		battery = 100
+	}
+	voltage := t.Voltage
+	if voltage == 0 {
+		voltage = 3.3 + float32(min(battery, 100))*0.009
+	}
+	channelUtil, airUtil := simChannelUtil, simAirUtilTx
+	uptime := uint32(now.Sub(n.started).Seconds())
+
+	return &pb.DeviceMetrics{
+		BatteryLevel:       &battery,
+		Voltage:            &voltage,
+		ChannelUtilization: &channelUtil,
+		AirUtilTx:          &airUtil,
+		UptimeSeconds:      &uptime,
+	}
+}
@@ -0,0 +1,561 @@
+// Package sim implements an in-process Meshtastic node for hardware-free
+// development. A Node speaks the framed stream protocol like a serial or TCP
+// radio: it answers want_config_id handshakes, applies admin messages to its
+// own state and emits synthetic mesh traffic described by a Scenario.
+package sim
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log"
+	"maps"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio/frame"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	maxChannels = 8
+
+	// Special want_config_id nonces understood by the firmware.
+	configOnlyNonce = 69420
+	nodesOnlyNonce  = 69421
+
+	deviceStateVersion = 24
+	minAppVersion      = 30200
+	passkeyLen         = 8
+)
+
+// ErrClosed is returned by Write after the node has been closed.
+var ErrClosed = errors.New("simulated node closed")
+
+// Node is a simulated radio. It implements the radio streamer interface, so it
+// can be wrapped with radio.NewRadioFromStreamer or opened as sim://path.
+// State changes made through admin messages last until the node is closed.
+type Node struct {
+	scenario *Scenario
+
+	mu             sync.Mutex
+	num            uint32
+	owner          *pb.User
+	position       *pb.Position
+	configs        map[protoreflect.FieldNumber]*pb.Config
+	modules        map[protoreflect.FieldNumber]*pb.ModuleConfig
+	channels       []*pb.Channel
+	peers          []*pb.NodeInfo
+	peerSpecs      map[uint32]PeerSpec
+	remotes        map[uint32]*remoteNode
+	cannedMessages string
+	ringtone       string
+	backups        map[pb.AdminMessage_BackupLocation]*backup
+	files          map[string][]byte
+	transfer       transfer
+	passkey        []byte
+	editing        bool
+	rebootCount    uint32
+
+	nextID      uint32
+	started     time.Time
+	events      []*event
+	outbox      []byte
+	inbound     bytes.Buffer
+	decoder     *frame.Decoder
+	readTimeout time.Duration
+
+	wake      chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// event is a scheduled burst of from-radio messages.
+type event struct {
+	at        time.Time
+	every     time.Duration
+	remaining int // -1 repeats forever
+	fire      func(now time.Time) []*pb.FromRadio
+}
+
+// Open starts a node for the scenario at path, or DefaultScenario when path is
+// empty.
+func Open(path string) (*Node, error) {
+	if strings.TrimSpace(path) == "" {
+		return New(DefaultScenario())
+	}
+
+	scenario, err := LoadScenario(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(scenario)
+}
+
+// New starts a node for scenario. Traffic timings are relative to now.
+func New(scenario *Scenario) (*Node, error) {
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+
+	n := &Node{
+		scenario: scenario,
+		started:  time.Now(),
+		nextID:   uint32(randomInt(1 << 30)),
+		passkey:  randomBytes(passkeyLen),
+		wake:     make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+	}
+	n.decoder = frame.NewDecoder(&n.inbound)
+	n.resetLocked()
+	n.loadFilesLocked()
+	n.scheduleTrafficLocked()
+	return n, nil
+}
+
+// resetLocked restores the scenario's settings and peers.
+func (n *Node) resetLocked() {
+	spec := n.scenario.Node
+	n.num = defaultNodeNum
+	if spec.ID != "" {
+		n.num, _ = parseNodeID(spec.ID)
+	}
+	hw, _ := parseHWModel(spec.HWModel)
+	role, _ := parseRole(spec.Role)
+	region, _ := parseRegion(spec.Region)
+	preset, _ := parseModemPreset(spec.ModemPreset)
+
+	n.owner = &pb.User{
+		Id:        formatNodeID(n.num),
+		LongName:  spec.LongName,
+		ShortName: spec.ShortName,
+		HwModel:   hw,
+		Role:      role,
+	}
+	n.position = spec.Position.proto(time.Now())
+
+	n.configs = emptySections(func() *pb.Config { return &pb.Config{} })
+	n.configs[fieldNumber(&pb.Config{PayloadVariant: &pb.Config_Device{}})] = &pb.Config{
+		PayloadVariant: &pb.Config_Device{Device: &pb.Config_DeviceConfig{Role: role}},
+	}
+	n.configs[fieldNumber(&pb.Config{PayloadVariant: &pb.Config_Position{}})] = &pb.Config{
+		PayloadVariant: &pb.Config_Position{Position: &pb.Config_PositionConfig{FixedPosition: n.position != nil}},
+	}
+	n.configs[fieldNumber(&pb.Config{PayloadVariant: &pb.Config_Lora{}})] = &pb.Config{
+		PayloadVariant: &pb.Config_Lora{Lora: &pb.Config_LoRaConfig{
+			UsePreset:   true,
+			ModemPreset: preset,
+			Region:      region,
+			HopLimit:    defaultHopLimit,
+			TxEnabled:   true,
+		}},
+	}
+	n.modules = emptySections(func() *pb.ModuleConfig { return &pb.ModuleConfig{} })
+
+	n.channels = make([]*pb.Channel, maxChannels)
+	for i := range n.channels {
+		n.channels[i] = &pb.Channel{Index: int32(i), Settings: &pb.ChannelSettings{}}
+	}
+	for i, ch := range n.scenario.Channels {
+		psk, _ := parsePSK(ch.PSK)
+		role := pb.Channel_SECONDARY
+		if i == 0 {
+			role = pb.Channel_PRIMARY
+		}
+		n.channels[i] = &pb.Channel{
+			Index: int32(i),
+			Role:  role,
+			Settings: &pb.ChannelSettings{
+				Name:            ch.Name,
+				Psk:             psk,
+				UplinkEnabled:   ch.Uplink,
+				DownlinkEnabled: ch.Downlink,
+			},
+		}
+	}
+	if len(n.scenario.Channels) == 0 {
+		n.channels[0] = &pb.Channel{Role: pb.Channel_PRIMARY, Settings: &pb.ChannelSettings{Psk: []byte{0x01}}}
+	}
+
+	n.cannedMessages = ""
+	n.ringtone = ""
+
+	n.peers = nil
+	n.remotes = nil
+	n.peerSpecs = make(map[uint32]PeerSpec, len(n.scenario.Peers))
+	for _, spec := range n.scenario.Peers {
+		num, _ := parseNodeID(spec.ID)
+		n.peerSpecs[num] = spec
+		n.peers = append(n.peers, peerInfo(num, spec, time.Now()))
+	}
+}
+
+// emptySections returns one message per payload_variant of M, each holding an
+// empty section, keyed by the variant's field number. For Config and
+// ModuleConfig that number is the admin ConfigType or ModuleConfigType plus one.
+func emptySections[M proto.Message](newMsg func() M) map[protoreflect.FieldNumber]M {
+	out := make(map[protoreflect.FieldNumber]M)
+	oneof := newMsg().ProtoReflect().Descriptor().Oneofs().ByName("payload_variant")
+	for i := 0; i < oneof.Fields().Len(); i++ {
+		fd := oneof.Fields().Get(i)
+		msg := newMsg()
+		m := msg.ProtoReflect()
+		m.Set(fd, m.NewField(fd))
+		out[fd.Number()] = msg
+	}
+	return out
+}
+
+// fieldNumber reports which oneof variant msg holds, or 0 when it holds none.
+func fieldNumber(msg proto.Message) protoreflect.FieldNumber {
+	m := msg.ProtoReflect()
+	oneof := m.Descriptor().Oneofs().ByName("payload_variant")
+	if oneof == nil {
+		return 0
+	}
+	fd := m.WhichOneof(oneof)
+	if fd == nil {
+		return 0
+	}
+	return fd.Number()
+}
+
+func peerInfo(num uint32, spec PeerSpec, now time.Time) *pb.NodeInfo {
+	hw, _ := parseHWModel(spec.HWModel)
+	role, _ := parseRole(spec.Role)
+	hops := spec.HopsAway
+
+	info := &pb.NodeInfo{
+		Num: num,
+		User: &pb.User{
+			Id:        formatNodeID(num),
+			LongName:  spec.LongName,
+			ShortName: spec.ShortName,
+			HwModel:   hw,
+			Role:      role,
+		},
+		Position:  spec.Position.proto(now),
+		Snr:       spec.SNR,
+		LastHeard: uint32(now.Unix()),
+		HopsAway:  &hops,
+	}
+	if spec.Battery > 0 {
+		battery := spec.Battery
+		info.DeviceMetrics = &pb.DeviceMetrics{BatteryLevel: &battery}
+	}
+	return info
+}
+
+// NodeNum returns the simulated node's number.
+func (n *Node) NodeNum() uint32 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.num
+}
+
+// Editing reports whether an edit-settings transaction is open: the node has
+// had BeginEditSettings but not yet CommitEditSettings.
+func (n *Node) Editing() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.editing
+}
+
+// Read returns queued from-radio frames. Like a quiet serial port it returns 0
+// bytes and no error when the read timeout expires with nothing to send, and
+// io.EOF once the node is closed.
+func (n *Node) Read(p []byte) (int, error) {
+	for {
+		select {
+		case <-n.closed:
+			return 0, io.EOF
+		default:
+		}
+
+		n.mu.Lock()
+		now := time.Now()
+		n.fireDueLocked(now)
+		if len(n.outbox) > 0 {
+			c := copy(p, n.outbox)
+			n.outbox = n.outbox[c:]
+			n.mu.Unlock()
+			return c, nil
+		}
+		wait, scheduled := n.nextDueLocked(now)
+		timeout := n.readTimeout
+		n.mu.Unlock()
+
+		quiet := false
+		if timeout > 0 && (!scheduled || wait > timeout) {
+			wait, scheduled, quiet = timeout, true, true
+		}
+
+		var (
+			timer   *time.Timer
+			expired <-chan time.Time
+		)
+		if scheduled {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+
+		select {
+		case <-n.closed:
+			stopTimer(timer)
+			return 0, io.EOF
+		case <-n.wake:
+			stopTimer(timer)
+		case <-expired:
+			if quiet {
+				return 0, nil
+			}
+		}
+	}
+}
+
+func stopTimer(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
+
+// Write accepts framed ToRadio messages and reacts to each complete one.
+func (n *Node) Write(p []byte) (int, error) {
+	select {
+	case <-n.closed:
+		return 0, ErrClosed
+	default:
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.inbound.Write(p)
+	for {
+		payload, err := n.decoder.Next()
+		if err != nil {
+			break
+		}
+
+		var msg pb.ToRadio
+		if err := proto.Unmarshal(payload, &msg); err != nil {
+			log.Printf("sim: ignoring undecodable to-radio frame: %v", err)
+			continue
+		}
+		n.handleLocked(&msg)
+	}
+	return len(p), nil
+}
+
+func (n *Node) Close() error {
+	n.closeOnce.Do(func() { close(n.closed) })
+	return nil
+}
+
+func (n *Node) SetReadTimeout(d time.Duration) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.readTimeout = d
+	return nil
+}
+
+func (n *Node) handleLocked(msg *pb.ToRadio) {
+	switch v := msg.GetPayloadVariant().(type) {
+	case *pb.ToRadio_WantConfigId:
+		n.sendLocked(n.configFramesLocked(v.WantConfigId)...)
+	case *pb.ToRadio_Packet:
+		n.handlePacketLocked(v.Packet)
+	case *pb.ToRadio_XmodemPacket:
+		n.handleXModemLocked(v.XmodemPacket)
+	}
+}
+
+// configFramesLocked builds the handshake reply in firmware order: my info,
+// own node info, metadata, channels, config, module config, other nodes, the
+// file manifest and finally the config-complete marker echoing id.
+func (n *Node) configFramesLocked(id uint32) []*pb.FromRadio {
+	now := time.Now()
+	out := []*pb.FromRadio{
+		{PayloadVariant: &pb.FromRadio_MyInfo{MyInfo: &pb.MyNodeInfo{
+			MyNodeNum:     n.num,
+			RebootCount:   n.rebootCount,
+			MinAppVersion: minAppVersion,
+			PioEnv:        "sim",
+			NodedbCount:   uint32(len(n.peers) + 1),
+		}}},
+		{PayloadVariant: &pb.FromRadio_NodeInfo{NodeInfo: n.selfInfoLocked(now)}},
+	}
+
+	if id != nodesOnlyNonce {
+		out = append(out, &pb.FromRadio{PayloadVariant: &pb.FromRadio_Metadata{Metadata: n.metadataLocked()}})
+		for _, ch := range n.channels {
+			out = append(out, &pb.FromRadio{PayloadVariant: &pb.FromRadio_Channel{Channel: proto.Clone(ch).(*pb.Channel)}})
+		}
+		for _, num := range sortedKeys(n.configs) {
+			out = append(out, &pb.FromRadio{PayloadVariant: &pb.FromRadio_Config{Config: proto.Clone(n.configs[num]).(*pb.Config)}})
+		}
+		for _, num := range sortedKeys(n.modules) {
+			out = append(out, &pb.FromRadio{PayloadVariant: &pb.FromRadio_ModuleConfig{ModuleConfig: proto.Clone(n.modules[num]).(*pb.ModuleConfig)}})
+		}
+	}
+
+	if id != configOnlyNonce {
+		for _, peer := range n.peers {
+			out = append(out, &pb.FromRadio{PayloadVariant: &pb.FromRadio_NodeInfo{NodeInfo: proto.Clone(peer).(*pb.NodeInfo)}})
+		}
+	}
+	if id != nodesOnlyNonce {
+		out = append(out, n.fileManifestLocked()...)
+	}
+
+	return append(out, &pb.FromRadio{PayloadVariant: &pb.FromRadio_ConfigCompleteId{ConfigCompleteId: id}})
+}
+
+func sortedKeys[V any](m map[protoreflect.FieldNumber]V) []protoreflect.FieldNumber {
+	return slices.Sorted(maps.Keys(m))
+}
+
+func (n *Node) selfInfoLocked(now time.Time) *pb.NodeInfo {
+	battery := uint32(100)
+	uptime := uint32(now.Sub(n.started).Seconds())
+	info := &pb.NodeInfo{
+		Num:           n.num,
+		User:          proto.Clone(n.owner).(*pb.User),
+		LastHeard:     uint32(now.Unix()),
+		DeviceMetrics: &pb.DeviceMetrics{BatteryLevel: &battery, UptimeSeconds: &uptime},
+	}
+	if n.position != nil {
+		info.Position = proto.Clone(n.position).(*pb.Position)
+	}
+	return info
+}
+
+func (n *Node) metadataLocked() *pb.DeviceMetadata {
+	firmware := n.scenario.Node.Firmware
+	if firmware == "" {
+		firmware = defaultFirmware
+	}
+	return &pb.DeviceMetadata{
+		FirmwareVersion:    firmware,
+		DeviceStateVersion: deviceStateVersion,
+		CanShutdown:        true,
+		HasBluetooth:       true,
+		Role:               n.owner.GetRole(),
+		HwModel:            n.owner.GetHwModel(),
+	}
+}
+
+func (n *Node) peerLocked(num uint32) *pb.NodeInfo {
+	for _, peer := range n.peers {
+		if peer.GetNum() == num {
+			return peer
+		}
+	}
+	return nil
+}
+
+// heardLocked returns the peer num, adding it back to the node DB if it was
+// removed since it was last heard.
+func (n *Node) heardLocked(num uint32, now time.Time) *pb.NodeInfo {
+	peer := n.peerLocked(num)
+	if peer == nil {
+		peer = peerInfo(num, n.peerSpecs[num], now)
+		n.peers = append(n.peers, peer)
+	}
+	peer.LastHeard = uint32(now.Unix())
+	return peer
+}
+
+func (n *Node) newPacketIDLocked() uint32 {
+	n.nextID++
+	return n.nextID
+}
+
+// sendLocked frames msgs onto the outbox and wakes a blocked reader.
+func (n *Node) sendLocked(msgs ...*pb.FromRadio) {
+	for _, msg := range msgs {
+		if msg.GetId() == 0 {
+			msg.Id = n.newPacketIDLocked()
+		}
+		payload, err := proto.Marshal(msg)
+		if err != nil {
+			log.Printf("sim: marshal from-radio: %v", err)
+			continue
+		}
+		b, err := frame.Encode(payload)
+		if err != nil {
+			log.Printf("sim: %v", err)
+			continue
+		}
+		n.outbox = append(n.outbox, b...)
+	}
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// afterLocked schedules fire to run once after d; d <= 0 runs it now.
+func (n *Node) afterLocked(d time.Duration, fire func(now time.Time) []*pb.FromRadio) {
+	if d <= 0 {
+		n.sendLocked(fire(time.Now())...)
+		return
+	}
+	n.events = append(n.events, &event{at: time.Now().Add(d), remaining: 1, fire: fire})
+	n.sendLocked()
+}
+
+func (n *Node) fireDueLocked(now time.Time) {
+	kept := n.events[:0]
+	for _, ev := range n.events {
+		if !ev.at.After(now) {
+			n.sendLocked(ev.fire(now)...)
+			if ev.remaining > 0 {
+				ev.remaining--
+			}
+			if ev.remaining == 0 || ev.every <= 0 {
+				continue
+			}
+			ev.at = ev.at.Add(ev.every)
+			if !ev.at.After(now) {
+				ev.at = now.Add(ev.every)
+			}
+		}
+		kept = append(kept, ev)
+	}
+	n.events = kept
+}
+
+func (n *Node) nextDueLocked(now time.Time) (time.Duration, bool) {
+	if len(n.events) == 0 {
+		return 0, false
+	}
+	next := n.events[0].at
+	for _, ev := range n.events[1:] {
+		if ev.at.Before(next) {
+			next = ev.at
+		}
+	}
+	return max(next.Sub(now), 0), true
+}
+
+func randomInt(limit int64) int64 {
+	v, err := rand.Int(rand.Reader, big.NewInt(limit))
+	if err != nil {
+		return time.Now().UnixNano() % limit
+	}
+	return v.Int64()
+}
+
+func randomBytes(size int) []byte {
+	b := make([]byte, size)
+	_, _ = rand.Read(b)
+	return b
+}
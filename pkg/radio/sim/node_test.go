@@ -0,0 +1,437 @@
+package sim
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio/frame"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func openTestNode(t *testing.T, scenario *Scenario) (*Node, *frame.Decoder) {
+	t.Helper()
+	n, err := New(scenario)
+	require.NoError(t, err)
+	require.NoError(t, n.SetReadTimeout(20*time.Millisecond))
+	t.Cleanup(func() { _ = n.Close() })
+	return n, frame.NewDecoder(n)
+}
+
+func quietScenario() *Scenario {
+	s := DefaultScenario()
+	s.Traffic = nil
+	return s
+}
+
+func send(t *testing.T, n *Node, msg *pb.ToRadio) {
+	t.Helper()
+	payload, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	b, err := frame.Encode(payload)
+	require.NoError(t, err)
+	_, err = n.Write(b)
+	require.NoError(t, err)
+}
+
+func sendAdmin(t *testing.T, n *Node, id uint32, admin *pb.AdminMessage) {
+	t.Helper()
+	payload, err := proto.Marshal(admin)
+	require.NoError(t, err)
+	send(t, n, &pb.ToRadio{PayloadVariant: &pb.ToRadio_Packet{Packet: &pb.MeshPacket{
+		To:      n.NodeNum(),
+		Id:      id,
+		WantAck: true,
+		PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+			Portnum:      pb.PortNum_ADMIN_APP,
+			Payload:      payload,
+			WantResponse: true,
+		}},
+	}}})
+}
+
+// next returns the next from-radio message, failing after wait.
+func next(t *testing.T, dec *frame.Decoder, wait time.Duration) *pb.FromRadio {
+	t.Helper()
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		payload, err := dec.Next()
+		if errors.Is(err, frame.ErrNoData) {
+			continue
+		}
+		require.NoError(t, err)
+
+		var msg pb.FromRadio
+		require.NoError(t, proto.Unmarshal(payload, &msg))
+		return &msg
+	}
+	t.Fatalf("no message within %s", wait)
+	return nil
+}
+
+func handshake(t *testing.T, n *Node, dec *frame.Decoder, id uint32) []*pb.FromRadio {
+	t.Helper()
+	send(t, n, &pb.ToRadio{PayloadVariant: &pb.ToRadio_WantConfigId{WantConfigId: id}})
+
+	var got []*pb.FromRadio
+	for {
+		msg := next(t, dec, time.Second)
+		got = append(got, msg)
+		if msg.GetConfigCompleteId() != 0 {
+			require.Equal(t, id, msg.GetConfigCompleteId())
+			return got
+		}
+	}
+}
+
+func countVariants(msgs []*pb.FromRadio) map[string]int {
+	counts := make(map[string]int)
+	for _, msg := range msgs {
+		switch msg.GetPayloadVariant().(type) {
+		case *pb.FromRadio_MyInfo:
+			counts["my_info"]++
+		case *pb.FromRadio_NodeInfo:
+			counts["node_info"]++
+		case *pb.FromRadio_Metadata:
+			counts["metadata"]++
+		case *pb.FromRadio_Channel:
+			counts["channel"]++
+		case *pb.FromRadio_Config:
+			counts["config"]++
+		case *pb.FromRadio_ModuleConfig:
+			counts["module_config"]++
+		}
+	}
+	return counts
+}
+
+func TestHandshakeAnswersWantConfig(t *testing.T) {
+	n, dec := openTestNode(t, quietScenario())
+
+	got := handshake(t, n, dec, 1234)
+
+	require.Equal(t, defaultNodeNum, got[0].GetMyInfo().GetMyNodeNum())
+	require.Equal(t, "Chirp Simulator", got[1].GetNodeInfo().GetUser().GetLongName())
+	counts := countVariants(got)
+	require.Equal(t, 1, counts["metadata"])
+	require.Equal(t, maxChannels, counts["channel"])
+	require.Equal(t, 3, counts["node_info"])
+	require.Positive(t, counts["config"])
+	require.Positive(t, counts["module_config"])
+}
+
+func TestHandshakeSpecialNonces(t *testing.T) {
+	n, dec := openTestNode(t, quietScenario())
+
+	configOnly := countVariants(handshake(t, n, dec, configOnlyNonce))
+	require.Equal(t, 1, configOnly["node_info"])
+	require.Positive(t, configOnly["config"])
+
+	nodesOnly := countVariants(handshake(t, n, dec, nodesOnlyNonce))
+	require.Equal(t, 3, nodesOnly["node_info"])
+	require.Zero(t, nodesOnly["config"])
+	require.Zero(t, nodesOnly["channel"])
+}
+
+func TestAdminSetOwnerIsAckedAndPersists(t *testing.T) {
+	n, dec := openTestNode(t, quietScenario())
+
+	sendAdmin(t, n, 10, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetOwner{
+		SetOwner: &pb.User{LongName: "Renamed", ShortName: "RN"},
+	}})
+	ack := next(t, dec, time.Second).GetPacket()
+	require.Equal(t, pb.PortNum_ROUTING_APP, ack.GetDecoded().GetPortnum())
+	require.Equal(t, uint32(10), ack.GetDecoded().GetRequestId())
+
+	var routing pb.Routing
+	require.NoError(t, proto.Unmarshal(ack.GetDecoded().GetPayload(), &routing))
+	require.Equal(t, pb.Routing_NONE, routing.GetErrorReason())
+
+	sendAdmin(t, n, 11, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetOwnerRequest{GetOwnerRequest: true}})
+	resp := next(t, dec, time.Second).GetPacket()
+	require.Equal(t, uint32(11), resp.GetDecoded().GetRequestId())
+
+	var admin pb.AdminMessage
+	require.NoError(t, proto.Unmarshal(resp.GetDecoded().GetPayload(), &admin))
+	require.Equal(t, "Renamed", admin.GetGetOwnerResponse().GetLongName())
+	require.Equal(t, "RN", admin.GetGetOwnerResponse().GetShortName())
+	require.NotEmpty(t, admin.GetSessionPasskey())
+
+	info := handshake(t, n, dec, 7)
+	require.Equal(t, "Renamed", info[1].GetNodeInfo().GetUser().GetLongName())
+}
+
+func TestAdminSetConfigReplacesSection(t *testing.T) {
+	n, dec := openTestNode(t, quietScenario())
+
+	sendAdmin(t, n, 20, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetConfig{SetConfig: &pb.Config{
+		PayloadVariant: &pb.Config_Lora{Lora: &pb.Config_LoRaConfig{ModemPreset: pb.Config_LoRaConfig_SHORT_FAST}},
+	}}})
+	next(t, dec, time.Second)
+
+	sendAdmin(t, n, 21, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigRequest{
+		GetConfigRequest: pb.AdminMessage_LORA_CONFIG,
+	}})
+	var admin pb.AdminMessage
+	require.NoError(t, proto.Unmarshal(next(t, dec, time.Second).GetPacket().GetDecoded().GetPayload(), &admin))
+	require.Equal(t, pb.Config_LoRaConfig_SHORT_FAST, admin.GetGetConfigResponse().GetLora().GetModemPreset())
+}
+
+func TestAdminNodedbResetKeepsFavorites(t *testing.T) {
+	n, dec := openTestNode(t, quietScenario())
+
+	sendAdmin(t, n, 30, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetFavoriteNode{SetFavoriteNode: 0xa1b2c3d4}})
+	next(t, dec, time.Second)
+	sendAdmin(t, n, 31, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_NodedbReset{NodedbReset: true}})
+	next(t, dec, time.Second)
+
+	var peers []uint32
+	for _, msg := range handshake(t, n, dec, 8) {
+		if num := msg.GetNodeInfo().GetNum(); num != 0 && num != n.NodeNum() {
+			peers = append(peers, num)
+			require.True(t, msg.GetNodeInfo().GetIsFavorite())
+		}
+	}
+	require.Equal(t, []uint32{0xa1b2c3d4}, peers)
+}
+
+func TestAdminBackupSurvivesFactoryReset(t *testing.T) {
+	n, dec := openTestNode(t, quietScenario())
+
+	rename := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetOwner{SetOwner: &pb.User{LongName: "Backed up"}}}
+	sendAdmin(t, n, 40, rename)
+	next(t, dec, time.Second)
+	sendAdmin(t, n, 41, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_BackupPreferences{BackupPreferences: pb.AdminMessage_SD}})
+	next(t, dec, time.Second)
+
+	// A reset and a restore each answer with an ack and a reboot.
+	sendAdmin(t, n, 42, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_FactoryResetDevice{FactoryResetDevice: 1}})
+	next(t, dec, time.Second)
+	next(t, dec, time.Second)
+	sendAdmin(t, n, 43, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_RestorePreferences{RestorePreferences: pb.AdminMessage_SD}})
+	next(t, dec, time.Second)
+	next(t, dec, time.Second)
+
+	info := handshake(t, n, dec, 9)
+	require.Equal(t, "Backed up", info[1].GetNodeInfo().GetUser().GetLongName())
+
+	sendAdmin(t, n, 44, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_RemoveBackupPreferences{RemoveBackupPreferences: pb.AdminMessage_SD}})
+	next(t, dec, time.Second)
+	sendAdmin(t, n, 45, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_RestorePreferences{RestorePreferences: pb.AdminMessage_SD}})
+	var routing pb.Routing
+	require.NoError(t, proto.Unmarshal(next(t, dec, time.Second).GetPacket().GetDecoded().GetPayload(), &routing))
+	require.Equal(t, pb.Routing_BAD_REQUEST, routing.GetErrorReason())
+}
+
+func TestHandshakeListsFilesUntilDeleted(t *testing.T) {
+	scenario := quietScenario()
+	scenario.Files = []FileSpec{{Name: "/b.txt", Content: "bb"}, {Name: "/a.txt", Content: "a"}}
+	n, dec := openTestNode(t, scenario)
+
+	files := func() []*pb.FileInfo {
+		var out []*pb.FileInfo
+		for _, msg := range handshake(t, n, dec, configOnlyNonce) {
+			if info := msg.GetFileInfo(); info != nil {
+				out = append(out, info)
+			}
+		}
+		return out
+	}
+	listed := files()
+	require.Len(t, listed, 2)
+	require.Equal(t, "/a.txt", listed[0].GetFileName())
+	require.Equal(t, uint32(2), listed[1].GetSizeBytes())
+
+	sendAdmin(t, n, 50, &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_DeleteFileRequest{DeleteFileRequest: "/a.txt"}})
+	next(t, dec, time.Second)
+	listed = files()
+	require.Len(t, listed, 1)
+	require.Equal(t, "/b.txt", listed[0].GetFileName())
+}
+
+func TestTextIsAckedByPeerOrNaked(t *testing.T) {
+	n, dec := openTestNode(t, quietScenario())
+	peer, err := parseNodeID("!a1b2c3d4")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		to       uint32
+		from     uint32
+		expected pb.Routing_Error
+	}{
+		{to: peer, from: peer, expected: pb.Routing_NONE},
+		{to: 0x12345678, from: defaultNodeNum, expected: pb.Routing_MAX_RETRANSMIT},
+	} {
+		send(t, n, &pb.ToRadio{PayloadVariant: &pb.ToRadio_Packet{Packet: &pb.MeshPacket{
+			To:      tc.to,
+			Id:      99,
+			WantAck: true,
+			PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+				Portnum: pb.PortNum_TEXT_MESSAGE_APP,
+				Payload: []byte("hi"),
+			}},
+		}}})
+
+		ack := next(t, dec, time.Second).GetPacket()
+		require.Equal(t, tc.from, ack.GetFrom())
+		require.Equal(t, uint32(99), ack.GetDecoded().GetRequestId())
+
+		var routing pb.Routing
+		require.NoError(t, proto.Unmarshal(ack.GetDecoded().GetPayload(), &routing))
+		require.Equal(t, tc.expected, routing.GetErrorReason())
+	}
+}
+
+func TestTracerouteReturnsRouteThroughRelays(t *testing.T) {
+	n, dec := openTestNode(t, quietScenario())
+	relay, err := parseNodeID("!a1b2c3d4")
+	require.NoError(t, err)
+	dest, err := parseNodeID("!0badcafe")
+	require.NoError(t, err)
+
+	send(t, n, &pb.ToRadio{PayloadVariant: &pb.ToRadio_Packet{Packet: &pb.MeshPacket{
+		To: dest,
+		Id: 77,
+		PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+			Portnum:      pb.PortNum_TRACEROUTE_APP,
+			WantResponse: true,
+		}},
+	}}})
+
+	reply := next(t, dec, time.Second).GetPacket()
+	require.Equal(t, dest, reply.GetFrom())
+	require.Equal(t, pb.PortNum_TRACEROUTE_APP, reply.GetDecoded().GetPortnum())
+	require.Equal(t, uint32(77), reply.GetDecoded().GetRequestId())
+
+	var route pb.RouteDiscovery
+	require.NoError(t, proto.Unmarshal(reply.GetDecoded().GetPayload(), &route))
+	require.Equal(t, []uint32{relay}, route.GetRoute())
+	require.Equal(t, []int32{26, -17}, route.GetSnrTowards())
+	require.Equal(t, []uint32{relay}, route.GetRouteBack())
+	require.Equal(t, []int32{-17, 26}, route.GetSnrBack())
+}
+
+func TestTrafficFollowsScenarioSchedule(t *testing.T) {
+	s := quietScenario()
+	s.Traffic = []TrafficSpec{
+		{Type: trafficText, From: "!a1b2c3d4", Text: "ping", Every: 10 * time.Millisecond, Count: 2},
+		{Type: trafficTelemetry, From: "!0badcafe", At: 30 * time.Millisecond, Battery: 42},
+	}
+	_, dec := openTestNode(t, s)
+
+	for range 2 {
+		pkt := next(t, dec, time.Second).GetPacket()
+		require.Equal(t, pb.PortNum_TEXT_MESSAGE_APP, pkt.GetDecoded().GetPortnum())
+		require.Equal(t, "ping", string(pkt.GetDecoded().GetPayload()))
+		require.Equal(t, broadcastNum, pkt.GetTo())
+		require.Equal(t, float32(6.5), pkt.GetRxSnr())
+	}
+
+	pkt := next(t, dec, time.Second).GetPacket()
+	require.Equal(t, pb.PortNum_TELEMETRY_APP, pkt.GetDecoded().GetPortnum())
+	var tel pb.Telemetry
+	require.NoError(t, proto.Unmarshal(pkt.GetDecoded().GetPayload(), &tel))
+	require.Equal(t, uint32(42), tel.GetDeviceMetrics().GetBatteryLevel())
+	require.Equal(t, uint32(2), pkt.GetHopLimit())
+}
+
+func TestReadTimesOutAndStopsOnClose(t *testing.T) {
+	n, err := New(quietScenario())
+	require.NoError(t, err)
+	require.NoError(t, n.SetReadTimeout(5*time.Millisecond))
+
+	buf := make([]byte, 64)
+	got, err := n.Read(buf)
+	require.NoError(t, err)
+	require.Zero(t, got)
+
+	require.NoError(t, n.Close())
+	_, err = n.Read(buf)
+	require.ErrorIs(t, err, io.EOF)
+	_, err = n.Write([]byte{0x01})
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestLoadScenarioFile(t *testing.T) {
+	s, err := LoadScenario("testdata/two-peers.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "Sim Base", s.Node.LongName)
+	require.Equal(t, 250*time.Millisecond, s.Node.Latency)
+	require.Len(t, s.Peers, 2)
+	require.Len(t, s.Traffic, 4)
+	require.Equal(t, 30*time.Second, s.Traffic[0].Every)
+}
+
+func TestParseScenarioReportsEveryProblem(t *testing.T) {
+	_, err := ParseScenario([]byte(`
+node:
+  hw_model: NOT_A_BOARD
+channels:
+  - psk: "!!"
+peers:
+  - id: "!a1b2c3d4"
+traffic:
+  - type: chat
+    from: "!deadbeef"
+`))
+	require.Error(t, err)
+	for _, want := range []string{"node.hw_model", "channels[0].psk", "traffic[0].type", "traffic[0].from"} {
+		require.ErrorContains(t, err, want)
+	}
+}
+
+func TestRemoteAdminNeedsSessionPasskey(t *testing.T) {
+	n, dec := openTestNode(t, quietScenario())
+	peer, err := parseNodeID("!0badcafe")
+	require.NoError(t, err)
+
+	sendRemote := func(id uint32, pki bool, admin *pb.AdminMessage) *pb.MeshPacket {
+		payload, err := proto.Marshal(admin)
+		require.NoError(t, err)
+		send(t, n, &pb.ToRadio{PayloadVariant: &pb.ToRadio_Packet{Packet: &pb.MeshPacket{
+			To:           peer,
+			Id:           id,
+			WantAck:      true,
+			PkiEncrypted: pki,
+			PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+				Portnum:      pb.PortNum_ADMIN_APP,
+				Payload:      payload,
+				WantResponse: true,
+			}},
+		}}})
+		resp := next(t, dec, time.Second).GetPacket()
+		require.Equal(t, peer, resp.GetFrom())
+		require.Equal(t, id, resp.GetDecoded().GetRequestId())
+		return resp
+	}
+	reason := func(resp *pb.MeshPacket) pb.Routing_Error {
+		var routing pb.Routing
+		require.Equal(t, pb.PortNum_ROUTING_APP, resp.GetDecoded().GetPortnum())
+		require.NoError(t, proto.Unmarshal(resp.GetDecoded().GetPayload(), &routing))
+		return routing.GetErrorReason()
+	}
+	getSession := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigRequest{
+		GetConfigRequest: pb.AdminMessage_SESSIONKEY_CONFIG,
+	}}
+
+	require.Equal(t, pb.Routing_NOT_AUTHORIZED, reason(sendRemote(1, false, getSession)))
+
+	var admin pb.AdminMessage
+	require.NoError(t, proto.Unmarshal(sendRemote(2, true, getSession).GetDecoded().GetPayload(), &admin))
+	require.NotNil(t, admin.GetGetConfigResponse().GetSessionkey())
+	passkey := admin.GetSessionPasskey()
+	require.NotEmpty(t, passkey)
+
+	rename := &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_SetOwner{SetOwner: &pb.User{LongName: "Summit"}}}
+	require.Equal(t, pb.Routing_ADMIN_BAD_SESSION_KEY, reason(sendRemote(3, true, rename)))
+	rename.SessionPasskey = passkey
+	require.Equal(t, pb.Routing_NONE, reason(sendRemote(4, true, rename)))
+
+	require.NoError(t, proto.Unmarshal(sendRemote(5, true, &pb.AdminMessage{
+		PayloadVariant: &pb.AdminMessage_GetOwnerRequest{GetOwnerRequest: true},
+	}).GetDecoded().GetPayload(), &admin))
+	require.Equal(t, "Summit", admin.GetGetOwnerResponse().GetLongName())
+}
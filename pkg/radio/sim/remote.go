@@ -0,0 +1,161 @@
+package sim
+
+import (
+	"bytes"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// remoteNode is the admin state of a peer, created the first time the client
+// administers it over the mesh.
+type remoteNode struct {
+	owner   *pb.User
+	configs map[protoreflect.FieldNumber]*pb.Config
+	modules map[protoreflect.FieldNumber]*pb.ModuleConfig
+	passkey []byte
+}
+
+// remoteLocked returns the admin state of peer num. Its LoRa settings start as
+// this node's, as they must for the two to hear each other.
+func (n *Node) remoteLocked(num uint32) *remoteNode {
+	if r, ok := n.remotes[num]; ok {
+		return r
+	}
+
+	peer := n.peerLocked(num)
+	r := &remoteNode{
+		owner:   proto.Clone(peer.GetUser()).(*pb.User),
+		configs: emptySections(func() *pb.Config { return &pb.Config{} }),
+		modules: emptySections(func() *pb.ModuleConfig { return &pb.ModuleConfig{} }),
+		passkey: randomBytes(passkeyLen),
+	}
+	r.configs[fieldNumber(&pb.Config{PayloadVariant: &pb.Config_Device{}})] = &pb.Config{
+		PayloadVariant: &pb.Config_Device{Device: &pb.Config_DeviceConfig{Role: peer.GetUser().GetRole()}},
+	}
+	lora := fieldNumber(&pb.Config{PayloadVariant: &pb.Config_Lora{}})
+	r.configs[lora] = proto.Clone(n.configs[lora]).(*pb.Config)
+
+	if n.remotes == nil {
+		n.remotes = make(map[uint32]*remoteNode)
+	}
+	n.remotes[num] = r
+	return r
+}
+
+// handleRemoteAdminLocked answers an admin message for a peer after the
+// scenario latency, as the firmware on that peer would: it must arrive PKI
+// encrypted, get requests are answered with the peer's session passkey, and
+// writes are only applied when they carry that passkey back.
+func (n *Node) handleRemoteAdminLocked(p *pb.MeshPacket) {
+	peer := p.GetTo()
+	var msg pb.AdminMessage
+	if err := proto.Unmarshal(p.GetDecoded().GetPayload(), &msg); err != nil {
+		n.meshReplyLocked(p, peer, pb.Routing_BAD_REQUEST)
+		return
+	}
+	if !p.GetPkiEncrypted() {
+		n.meshReplyLocked(p, peer, pb.Routing_NOT_AUTHORIZED)
+		return
+	}
+
+	r := n.remoteLocked(peer)
+	resp, reason := n.applyRemoteAdminLocked(peer, r, &msg)
+	if resp == nil {
+		n.meshReplyLocked(p, peer, reason)
+		return
+	}
+
+	resp.SessionPasskey = r.passkey
+	n.afterLocked(n.scenario.Node.Latency, func(time.Time) []*pb.FromRadio {
+		return []*pb.FromRadio{n.replyLocked(p, peer, pb.PortNum_ADMIN_APP, resp)}
+	})
+}
+
+func (n *Node) applyRemoteAdminLocked(num uint32, r *remoteNode, msg *pb.AdminMessage) (*pb.AdminMessage, pb.Routing_Error) {
+	switch v := msg.GetPayloadVariant().(type) {
+	case *pb.AdminMessage_GetOwnerRequest:
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetOwnerResponse{
+			GetOwnerResponse: proto.Clone(r.owner).(*pb.User),
+		}}, pb.Routing_NONE
+
+	case *pb.AdminMessage_GetConfigRequest:
+		c, ok := r.configs[protoreflect.FieldNumber(v.GetConfigRequest)+1]
+		if !ok {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetConfigResponse{
+			GetConfigResponse: proto.Clone(c).(*pb.Config),
+		}}, pb.Routing_NONE
+
+	case *pb.AdminMessage_GetModuleConfigRequest:
+		c, ok := r.modules[protoreflect.FieldNumber(v.GetModuleConfigRequest)+1]
+		if !ok {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		return &pb.AdminMessage{PayloadVariant: &pb.AdminMessage_GetModuleConfigResponse{
+			GetModuleConfigResponse: proto.Clone(c).(*pb.ModuleConfig),
+		}}, pb.Routing_NONE
+	}
+
+	if !bytes.Equal(msg.GetSessionPasskey(), r.passkey) {
+		return nil, pb.Routing_ADMIN_BAD_SESSION_KEY
+	}
+
+	switch v := msg.GetPayloadVariant().(type) {
+	case *pb.AdminMessage_SetOwner:
+		if name := v.SetOwner.GetLongName(); name != "" {
+			r.owner.LongName = name
+		}
+		if name := v.SetOwner.GetShortName(); name != "" {
+			r.owner.ShortName = name
+		}
+		r.owner.IsLicensed = v.SetOwner.GetIsLicensed()
+		if v.SetOwner.IsUnmessagable != nil {
+			r.owner.IsUnmessagable = proto.Bool(v.SetOwner.GetIsUnmessagable())
+		}
+		// The new names reach our NodeDB with the peer's next node info.
+		n.updatePeerLocked(num, func(p *pb.NodeInfo) {
+			p.User.LongName = r.owner.GetLongName()
+			p.User.ShortName = r.owner.GetShortName()
+		})
+
+	case *pb.AdminMessage_SetHamMode:
+		r.owner.LongName = v.SetHamMode.GetCallSign()
+		r.owner.ShortName = v.SetHamMode.GetShortName()
+		r.owner.IsLicensed = true
+
+	case *pb.AdminMessage_SetConfig:
+		num := fieldNumber(v.SetConfig)
+		if num == 0 {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		r.configs[num] = proto.Clone(v.SetConfig).(*pb.Config)
+
+	case *pb.AdminMessage_SetModuleConfig:
+		num := fieldNumber(v.SetModuleConfig)
+		if num == 0 {
+			return nil, pb.Routing_BAD_REQUEST
+		}
+		r.modules[num] = proto.Clone(v.SetModuleConfig).(*pb.ModuleConfig)
+
+	case *pb.AdminMessage_BeginEditSettings, *pb.AdminMessage_CommitEditSettings,
+		*pb.AdminMessage_RebootSeconds, *pb.AdminMessage_RebootOtaSeconds, *pb.AdminMessage_ShutdownSeconds,
+		*pb.AdminMessage_FactoryResetDevice:
+
+	case *pb.AdminMessage_SetFixedPosition, *pb.AdminMessage_RemoveFixedPosition,
+		*pb.AdminMessage_SetFavoriteNode, *pb.AdminMessage_RemoveFavoriteNode,
+		*pb.AdminMessage_SetIgnoredNode, *pb.AdminMessage_RemoveIgnoredNode,
+		*pb.AdminMessage_ToggleMutedNode, *pb.AdminMessage_RemoveByNodenum, *pb.AdminMessage_NodedbReset,
+		*pb.AdminMessage_BackupPreferences, *pb.AdminMessage_RestorePreferences, *pb.AdminMessage_RemoveBackupPreferences:
+		// Acknowledged only; a peer's own position, NodeDB and backups are not
+		// modelled.
+
+	default:
+		return nil, pb.Routing_BAD_REQUEST
+	}
+
+	return nil, pb.Routing_NONE
+}
@@ -0,0 +1,366 @@
+package sim
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/radio/xmodem"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultNodeNum   = uint32(0x5ca1ab1e)
+	defaultFirmware  = "2.5.0.sim"
+	defaultRSSI      = -90
+	broadcastNum     = uint32(0xffffffff)
+	defaultHopLimit  = uint32(3)
+	broadcastKeyword = "broadcast"
+)
+
+// Scenario describes the simulated node, the peers it can hear and the mesh
+// traffic they generate. It is usually loaded from YAML:
+//
+//	node:
+//	  id: "!5ca1ab1e"
+//	  long_name: Sim Base
+//	  short_name: SIM
+//	  hw_model: TBEAM
+//	  region: US
+//	  modem_preset: LONG_FAST
+//	channels:
+//	  - name: ""
+//	    psk: default
+//	peers:
+//	  - id: "!a1b2c3d4"
+//	    long_name: Ridge Relay
+//	    short_name: RDG
+//	    snr: 6.5
+//	    position: {lat: 37.80, lon: -122.27, alt: 120}
+//	files:
+//	  - name: /static/hello.txt
+//	    content: hello from the node
+//	traffic:
+//	  - type: text
+//	    from: "!a1b2c3d4"
+//	    text: hello from the ridge
+//	    at: 2s
+//	    every: 30s
+type Scenario struct {
+	Node     NodeSpec      `yaml:"node"`
+	Channels []ChannelSpec `yaml:"channels"`
+	Peers    []PeerSpec    `yaml:"peers"`
+	Files    []FileSpec    `yaml:"files"`
+	Traffic  []TrafficSpec `yaml:"traffic"`
+}
+
+// NodeSpec is the identity and radio settings of the simulated node.
+type NodeSpec struct {
+	ID          string        `yaml:"id"`
+	LongName    string        `yaml:"long_name"`
+	ShortName   string        `yaml:"short_name"`
+	HWModel     string        `yaml:"hw_model"`
+	Role        string        `yaml:"role"`
+	Firmware    string        `yaml:"firmware"`
+	Region      string        `yaml:"region"`
+	ModemPreset string        `yaml:"modem_preset"`
+	Position    *PositionSpec `yaml:"position"`
+	// Latency delays replies that travel over the simulated mesh, such as acks
+	// from peers.
+	Latency time.Duration `yaml:"latency"`
+}
+
+// ChannelSpec configures one channel slot, in order starting at the primary.
+type ChannelSpec struct {
+	Name     string `yaml:"name"`
+	PSK      string `yaml:"psk"`
+	Uplink   bool   `yaml:"uplink"`
+	Downlink bool   `yaml:"downlink"`
+}
+
+// PeerSpec is another node the simulated node has heard.
+type PeerSpec struct {
+	ID        string        `yaml:"id"`
+	LongName  string        `yaml:"long_name"`
+	ShortName string        `yaml:"short_name"`
+	HWModel   string        `yaml:"hw_model"`
+	Role      string        `yaml:"role"`
+	SNR       float32       `yaml:"snr"`
+	RSSI      int32         `yaml:"rssi"`
+	HopsAway  uint32        `yaml:"hops_away"`
+	Battery   uint32        `yaml:"battery"`
+	Position  *PositionSpec `yaml:"position"`
+}
+
+// PositionSpec is a position in decimal degrees and meters.
+type PositionSpec struct {
+	Lat float64 `yaml:"lat"`
+	Lon float64 `yaml:"lon"`
+	Alt int32   `yaml:"alt"`
+}
+
+// FileSpec is a file in the node's filesystem, listed in the handshake's file
+// manifest and served over XModem.
+type FileSpec struct {
+	Name    string `yaml:"name"`
+	Content string `yaml:"content"`
+}
+
+// TrafficSpec schedules synthetic packets from a peer. A packet is first sent
+// At after the node opens and then Every interval, up to Count times when
+// Count is set.
+type TrafficSpec struct {
+	Type    string        `yaml:"type"`
+	From    string        `yaml:"from"`
+	To      string        `yaml:"to"`
+	Channel uint32        `yaml:"channel"`
+	At      time.Duration `yaml:"at"`
+	Every   time.Duration `yaml:"every"`
+	Count   int           `yaml:"count"`
+
+	Text     string        `yaml:"text"`
+	Position *PositionSpec `yaml:"position"`
+	Battery  uint32        `yaml:"battery"`
+	Voltage  float32       `yaml:"voltage"`
+}
+
+const (
+	trafficText      = "text"
+	trafficPosition  = "position"
+	trafficTelemetry = "telemetry"
+)
+
+// DefaultScenario is used for a bare sim:// target: one node on the default
+// channel with two peers chatting and reporting telemetry.
+func DefaultScenario() *Scenario {
+	return &Scenario{
+		Node: NodeSpec{
+			LongName:    "Chirp Simulator",
+			ShortName:   "SIM",
+			HWModel:     "TBEAM",
+			Region:      "US",
+			ModemPreset: "LONG_FAST",
+			Position:    &PositionSpec{Lat: 37.7749, Lon: -122.4194, Alt: 16},
+		},
+		Channels: []ChannelSpec{{PSK: "default"}},
+		Peers: []PeerSpec{
+			{ID: "!a1b2c3d4", LongName: "Ridge Relay", ShortName: "RDG", HWModel: "RAK4631", Role: "ROUTER", SNR: 6.5, Battery: 91, Position: &PositionSpec{Lat: 37.8044, Lon: -122.2712, Alt: 120}},
+			{ID: "!0badcafe", LongName: "Trail Walker", ShortName: "TRL", HWModel: "HELTEC_V3", SNR: -4.25, HopsAway: 1, Battery: 64, Position: &PositionSpec{Lat: 37.7599, Lon: -122.4148, Alt: 40}},
+		},
+		Files: []FileSpec{
+			{Name: "/static/welcome.txt", Content: "Welcome to the chirp simulator.\n"},
+		},
+		Traffic: []TrafficSpec{
+			{Type: trafficText, From: "!a1b2c3d4", Text: "hello from the ridge", At: 2 * time.Second, Every: 45 * time.Second},
+			{Type: trafficPosition, From: "!0badcafe", At: 5 * time.Second, Every: 30 * time.Second},
+			{Type: trafficTelemetry, From: "!a1b2c3d4", At: 8 * time.Second, Every: 60 * time.Second},
+		},
+	}
+}
+
+// LoadScenario reads and validates the YAML scenario at path.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario: %w", err)
+	}
+
+	return ParseScenario(data)
+}
+
+// ParseScenario decodes and validates a YAML scenario.
+func ParseScenario(data []byte) (*Scenario, error) {
+	var s Scenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse scenario: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks that every name, enum and node reference in s resolves.
+func (s *Scenario) Validate() error {
+	var errs []error
+
+	if s.Node.ID != "" {
+		if _, err := parseNodeID(s.Node.ID); err != nil {
+			errs = append(errs, fmt.Errorf("node.id: %w", err))
+		}
+	}
+	if _, err := parseHWModel(s.Node.HWModel); err != nil {
+		errs = append(errs, fmt.Errorf("node.hw_model: %w", err))
+	}
+	if _, err := parseRole(s.Node.Role); err != nil {
+		errs = append(errs, fmt.Errorf("node.role: %w", err))
+	}
+	if _, err := parseRegion(s.Node.Region); err != nil {
+		errs = append(errs, fmt.Errorf("node.region: %w", err))
+	}
+	if _, err := parseModemPreset(s.Node.ModemPreset); err != nil {
+		errs = append(errs, fmt.Errorf("node.modem_preset: %w", err))
+	}
+
+	if len(s.Channels) > maxChannels {
+		errs = append(errs, fmt.Errorf("channels: at most %d channels are supported", maxChannels))
+	}
+	for i, ch := range s.Channels {
+		if _, err := parsePSK(ch.PSK); err != nil {
+			errs = append(errs, fmt.Errorf("channels[%d].psk: %w", i, err))
+		}
+	}
+
+	peers := make(map[uint32]bool, len(s.Peers))
+	for i, p := range s.Peers {
+		num, err := parseNodeID(p.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("peers[%d].id: %w", i, err))
+			continue
+		}
+		peers[num] = true
+		if _, err := parseHWModel(p.HWModel); err != nil {
+			errs = append(errs, fmt.Errorf("peers[%d].hw_model: %w", i, err))
+		}
+		if _, err := parseRole(p.Role); err != nil {
+			errs = append(errs, fmt.Errorf("peers[%d].role: %w", i, err))
+		}
+	}
+
+	for i, f := range s.Files {
+		if err := xmodem.ValidateName(f.Name); err != nil {
+			errs = append(errs, fmt.Errorf("files[%d].name: %w", i, err))
+		}
+	}
+
+	for i, t := range s.Traffic {
+		switch t.Type {
+		case trafficText:
+			if t.Text == "" {
+				errs = append(errs, fmt.Errorf("traffic[%d].text is required", i))
+			}
+		case trafficPosition, trafficTelemetry:
+		default:
+			errs = append(errs, fmt.Errorf("traffic[%d].type %q must be one of text, position, telemetry", i, t.Type))
+		}
+
+		from, err := parseNodeID(t.From)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("traffic[%d].from: %w", i, err))
+		} else if !peers[from] {
+			errs = append(errs, fmt.Errorf("traffic[%d].from %q is not a listed peer", i, t.From))
+		}
+		if _, err := parseDestination(t.To); err != nil {
+			errs = append(errs, fmt.Errorf("traffic[%d].to: %w", i, err))
+		}
+		if t.At < 0 || t.Every < 0 || t.Count < 0 {
+			errs = append(errs, fmt.Errorf("traffic[%d]: at, every and count must not be negative", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseNodeID accepts "!a1b2c3d4", "0xa1b2c3d4" or a decimal node number.
+func parseNodeID(s string) (uint32, error) {
+	s = strings.TrimSpace(s)
+	var (
+		v   uint64
+		err error
+	)
+	switch {
+	case strings.HasPrefix(s, "!"):
+		v, err = strconv.ParseUint(s[1:], 16, 32)
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0X"):
+		v, err = strconv.ParseUint(s[2:], 16, 32)
+	default:
+		v, err = strconv.ParseUint(s, 10, 32)
+	}
+	if err != nil || v == 0 || uint32(v) == broadcastNum {
+		return 0, fmt.Errorf("invalid node id %q", s)
+	}
+	return uint32(v), nil
+}
+
+func parseDestination(s string) (uint32, error) {
+	if s == "" || s == broadcastKeyword {
+		return broadcastNum, nil
+	}
+	return parseNodeID(s)
+}
+
+func formatNodeID(num uint32) string {
+	return fmt.Sprintf("!%08x", num)
+}
+
+func parseEnum(name string, values map[string]int32) (int32, error) {
+	if name == "" {
+		return 0, nil
+	}
+	v, ok := values[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown value %q", name)
+	}
+	return v, nil
+}
+
+func parseHWModel(name string) (pb.HardwareModel, error) {
+	v, err := parseEnum(name, pb.HardwareModel_value)
+	return pb.HardwareModel(v), err
+}
+
+func parseRole(name string) (pb.Config_DeviceConfig_Role, error) {
+	v, err := parseEnum(name, pb.Config_DeviceConfig_Role_value)
+	return pb.Config_DeviceConfig_Role(v), err
+}
+
+func parseRegion(name string) (pb.Config_LoRaConfig_RegionCode, error) {
+	v, err := parseEnum(name, pb.Config_LoRaConfig_RegionCode_value)
+	return pb.Config_LoRaConfig_RegionCode(v), err
+}
+
+func parseModemPreset(name string) (pb.Config_LoRaConfig_ModemPreset, error) {
+	v, err := parseEnum(name, pb.Config_LoRaConfig_ModemPreset_value)
+	return pb.Config_LoRaConfig_ModemPreset(v), err
+}
+
+// parsePSK accepts "default" (the well-known key), "none" or base64 key bytes.
+func parsePSK(s string) ([]byte, error) {
+	switch s {
+	case "", "default":
+		return []byte{0x01}, nil
+	case "none":
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("psk must be base64, \"default\" or \"none\": %w", err)
+	}
+	switch len(key) {
+	case 0, 1, 16, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("psk must be 0, 1, 16 or 32 bytes, got %d", len(key))
+	}
+}
+
+func (p *PositionSpec) proto(now time.Time) *pb.Position {
+	if p == nil {
+		return nil
+	}
+	lat := int32(p.Lat * 1e7)
+	lon := int32(p.Lon * 1e7)
+	alt := p.Alt
+	return &pb.Position{
+		LatitudeI:  &lat,
+		LongitudeI: &lon,
+		Altitude:   &alt,
+		Time:       uint32(now.Unix()),
+	}
+}
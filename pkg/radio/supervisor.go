@@ -0,0 +1,213 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreyvan/chirp/pkg/serial"
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+const (
+	defaultReconnectInitial = 500 * time.Millisecond
+	defaultReconnectMax     = 30 * time.Second
+)
+
+// ErrRebooted is the reconnect cause for a FromRadio_Rebooted event.
+var ErrRebooted = errors.New("radio rebooted")
+
+// Handshaker is a connection the Supervisor can reopen and re-initialize.
+type Handshaker interface {
+	Close() error
+	GetRadioInfo(ctx context.Context) ([]*pb.FromRadio, error)
+}
+
+// Backoff doubles the wait between reconnect attempts from Initial up to Max.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// DefaultBackoff is used by NewSupervisor.
+var DefaultBackoff = Backoff{Initial: defaultReconnectInitial, Max: defaultReconnectMax}
+
+// Delay returns the wait after the given failed attempt, counting from 1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Initial
+	for i := 1; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	return min(d, b.Max)
+}
+
+// ConnEventKind says what a ConnEvent reports.
+type ConnEventKind string
+
+const (
+	ConnLost        ConnEventKind = "lost"
+	ConnRetrying    ConnEventKind = "retrying"
+	ConnReconnected ConnEventKind = "reconnected"
+)
+
+// ConnEvent reports progress while a Supervisor restores a connection.
+type ConnEvent struct {
+	Kind    ConnEventKind `json:"kind"`
+	Target  string        `json:"target"`
+	Attempt int           `json:"attempt,omitempty"`
+	Delay   time.Duration `json:"delay,omitempty"`
+	Err     error         `json:"-"`
+}
+
+func (e ConnEvent) String() string {
+	switch e.Kind {
+	case ConnLost:
+		return fmt.Sprintf("connection to %s lost: %v", e.Target, e.Err)
+	case ConnRetrying:
+		return fmt.Sprintf("reconnect attempt %d failed: %v; retrying in %s", e.Attempt, e.Err, e.Delay)
+	case ConnReconnected:
+		return fmt.Sprintf("reconnected on %s after %d attempt(s)", e.Target, e.Attempt)
+	default:
+		return string(e.Kind)
+	}
+}
+
+// Supervisor restores a connection after the device reboots or disappears. It
+// does not watch the connection itself: callers that see ErrClosed or a
+// FromRadio_Rebooted event call Reconnect, which reopens the target with
+// backoff, re-runs the config handshake and reports progress to OnEvent.
+type Supervisor[C Handshaker] struct {
+	// Backoff paces reopen attempts.
+	Backoff Backoff
+	// Candidates lists the targets to try on each attempt, most likely first.
+	// The default also tries similarly named serial ports, since USB radios
+	// often come back under a new device path.
+	Candidates func(target string) []string
+	// OnEvent, if set, receives every ConnEvent.
+	OnEvent func(ConnEvent)
+
+	open func(target string) (C, error)
+
+	mu     sync.Mutex
+	target string
+	conn   C
+}
+
+// NewSupervisor supervises conn, which was opened from target with open.
+func NewSupervisor[C Handshaker](target string, conn C, open func(target string) (C, error)) *Supervisor[C] {
+	return &Supervisor[C]{
+		Backoff:    DefaultBackoff,
+		Candidates: ReconnectCandidates,
+		open:       open,
+		target:     target,
+		conn:       conn,
+	}
+}
+
+// Conn returns the current connection.
+func (s *Supervisor[C]) Conn() C {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
+}
+
+// Target returns the target the current connection was opened from.
+func (s *Supervisor[C]) Target() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.target
+}
+
+// Reconnect replaces the current connection and returns the new one with its
+// handshake responses. After a reboot (cause wraps ErrRebooted) the existing
+// connection is given one handshake first, since links such as TCP can
+// survive a reboot. Reconnect retries until it succeeds or ctx is done.
+func (s *Supervisor[C]) Reconnect(ctx context.Context, cause error) (C, []*pb.FromRadio, error) {
+	s.mu.Lock()
+	target, old := s.target, s.conn
+	s.mu.Unlock()
+
+	s.emit(ConnEvent{Kind: ConnLost, Target: target, Err: cause})
+
+	if errors.Is(cause, ErrRebooted) {
+		if responses, err := old.GetRadioInfo(ctx); err == nil {
+			s.emit(ConnEvent{Kind: ConnReconnected, Target: target, Attempt: 1})
+			return old, responses, nil
+		}
+	}
+	_ = old.Close()
+
+	for attempt := 1; ; attempt++ {
+		var lastErr error
+		for _, candidate := range s.Candidates(target) {
+			conn, responses, err := s.handshake(ctx, candidate)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			s.mu.Lock()
+			s.target, s.conn = candidate, conn
+			s.mu.Unlock()
+
+			s.emit(ConnEvent{Kind: ConnReconnected, Target: candidate, Attempt: attempt})
+			return conn, responses, nil
+		}
+
+		delay := s.Backoff.Delay(attempt)
+		s.emit(ConnEvent{Kind: ConnRetrying, Target: target, Attempt: attempt, Delay: delay, Err: lastErr})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			var zero C
+			return zero, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Supervisor[C]) handshake(ctx context.Context, target string) (C, []*pb.FromRadio, error) {
+	var zero C
+	conn, err := s.open(target)
+	if err != nil {
+		return zero, nil, err
+	}
+
+	responses, err := conn.GetRadioInfo(ctx)
+	if err != nil {
+		_ = conn.Close()
+		return zero, nil, err
+	}
+	return conn, responses, nil
+}
+
+// Close closes the current connection.
+func (s *Supervisor[C]) Close() error {
+	return s.Conn().Close()
+}
+
+func (s *Supervisor[C]) emit(ev ConnEvent) {
+	if s.OnEvent != nil {
+		s.OnEvent(ev)
+	}
+}
+
+// ReconnectCandidates returns target followed, for serial device paths, by
+// other ports that look like the same kind of device.
+func ReconnectCandidates(target string) []string {
+	candidates := []string{target}
+	if strings.Contains(target, "://") {
+		return candidates
+	}
+
+	similar, err := serial.SimilarPorts(target)
+	if err != nil {
+		return candidates
+	}
+	return append(candidates, similar...)
+}
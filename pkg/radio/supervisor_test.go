@@ -0,0 +1,108 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConn struct {
+	name       string
+	infoErr    error
+	closed     bool
+	handshakes int
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) GetRadioInfo(context.Context) ([]*pb.FromRadio, error) {
+	c.handshakes++
+	if c.infoErr != nil {
+		return nil, c.infoErr
+	}
+	return []*pb.FromRadio{{PayloadVariant: &pb.FromRadio_ConfigCompleteId{ConfigCompleteId: 1}}}, nil
+}
+
+func TestBackoffDoublesUpToMax(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	require.Equal(t, 100*time.Millisecond, b.Delay(1))
+	require.Equal(t, 200*time.Millisecond, b.Delay(2))
+	require.Equal(t, 800*time.Millisecond, b.Delay(4))
+	require.Equal(t, time.Second, b.Delay(5))
+	require.Equal(t, time.Second, b.Delay(50))
+}
+
+func TestSupervisorReopensOnNewPathWithBackoff(t *testing.T) {
+	old := &fakeConn{name: "old"}
+	opens := 0
+	sup := NewSupervisor("/dev/ttyUSB0", old, func(target string) (*fakeConn, error) {
+		opens++
+		if target == "/dev/ttyUSB0" || opens < 3 {
+			return nil, errors.New("no such device")
+		}
+		return &fakeConn{name: target}, nil
+	})
+	sup.Backoff = Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+	sup.Candidates = func(target string) []string { return []string{target, "/dev/ttyUSB1"} }
+
+	var events []ConnEvent
+	sup.OnEvent = func(ev ConnEvent) { events = append(events, ev) }
+
+	conn, responses, err := sup.Reconnect(context.Background(), ErrClosed)
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+	require.Equal(t, "/dev/ttyUSB1", conn.name)
+	require.Same(t, conn, sup.Conn())
+	require.Equal(t, "/dev/ttyUSB1", sup.Target())
+	require.True(t, old.closed)
+
+	require.Len(t, events, 3)
+	require.Equal(t, ConnLost, events[0].Kind)
+	require.ErrorIs(t, events[0].Err, ErrClosed)
+	require.Equal(t, ConnRetrying, events[1].Kind)
+	require.Equal(t, ConnEvent{Kind: ConnReconnected, Target: "/dev/ttyUSB1", Attempt: 2}, events[2])
+}
+
+func TestSupervisorKeepsLiveConnectionAfterReboot(t *testing.T) {
+	current := &fakeConn{}
+	sup := NewSupervisor("tcp://node", current, func(string) (*fakeConn, error) {
+		t.Fatalf("should not reopen a connection that survived the reboot")
+		return nil, nil
+	})
+
+	conn, _, err := sup.Reconnect(context.Background(), ErrRebooted)
+	require.NoError(t, err)
+	require.Same(t, current, conn)
+	require.False(t, current.closed)
+	require.Equal(t, 1, current.handshakes)
+}
+
+func TestSupervisorStopsWhenContextEnds(t *testing.T) {
+	sup := NewSupervisor("sim://", &fakeConn{}, func(string) (*fakeConn, error) {
+		return nil, errors.New("down")
+	})
+	sup.Backoff = Backoff{Initial: time.Hour, Max: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sup.OnEvent = func(ev ConnEvent) {
+		if ev.Kind == ConnRetrying {
+			cancel()
+		}
+	}
+
+	_, _, err := sup.Reconnect(ctx, ErrClosed)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestReconnectCandidatesOnlyWidenSerialTargets(t *testing.T) {
+	require.Equal(t, []string{"tcp://node"}, ReconnectCandidates("tcp://node"))
+	require.Equal(t, []string{"sim://x.yaml"}, ReconnectCandidates("sim://x.yaml"))
+	require.Equal(t, "/dev/does-not-exist0", ReconnectCandidates("/dev/does-not-exist0")[0])
+}
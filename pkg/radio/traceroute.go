@@ -0,0 +1,63 @@
+package radio
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"google.golang.org/protobuf/proto"
+)
+
+// RoutingError is a NAK the mesh sent back instead of the answer a request
+// asked for.
+type RoutingError struct {
+	From   uint32
+	Reason pb.Routing_Error
+}
+
+func (e *RoutingError) Error() string {
+	return fmt.Sprintf("%s from %s", e.Reason, FormatNodeID(e.From))
+}
+
+// TracerouteReply is the destination's answer to a traceroute.
+type TracerouteReply struct {
+	// Packet is the TRACEROUTE_APP packet carrying the answer; it is sent from
+	// the destination to our node.
+	Packet *pb.MeshPacket
+	// Route lists the relays each way and the SNR every hop was heard at, as
+	// filled in by the nodes along the path.
+	Route *pb.RouteDiscovery
+}
+
+// Traceroute sends an empty RouteDiscovery to the node to and waits for the
+// destination to send it back. It returns ctx.Err() if no answer arrives
+// before ctx ends, and a *RoutingError if the mesh gives up on the request.
+func (r *Radio) Traceroute(ctx context.Context, to uint32, channel uint32) (TracerouteReply, error) {
+	payload, err := proto.Marshal(&pb.RouteDiscovery{})
+	if err != nil {
+		return TracerouteReply{}, err
+	}
+
+	resp, err := r.sendAndAwaitResponse(ctx, &pb.MeshPacket{
+		To:       to,
+		Channel:  channel,
+		HopLimit: defaultHopLimit,
+		PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+			Portnum: pb.PortNum_TRACEROUTE_APP,
+			Payload: payload,
+		}},
+	})
+	if err != nil {
+		return TracerouteReply{}, err
+	}
+
+	decoded := resp.GetDecoded()
+	if decoded.GetPortnum() != pb.PortNum_TRACEROUTE_APP {
+		return TracerouteReply{}, fmt.Errorf("traceroute answered on %s", decoded.GetPortnum())
+	}
+	var route pb.RouteDiscovery
+	if err := proto.Unmarshal(decoded.GetPayload(), &route); err != nil {
+		return TracerouteReply{}, fmt.Errorf("decode traceroute reply: %w", err)
+	}
+	return TracerouteReply{Packet: resp, Route: &route}, nil
+}
@@ -0,0 +1,81 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTracerouteWaitsPastAcksForRoute(t *testing.T) {
+	const self, relay, dest = uint32(1), uint32(0x0a0b0c0d), uint32(0x01020304)
+
+	m := &mockStreamer{idle: true}
+	m.respond = func(tr *pb.ToRadio) []*pb.FromRadio {
+		sent := tr.GetPacket()
+		payload, err := proto.Marshal(&pb.RouteDiscovery{
+			Route:      []uint32{relay},
+			SnrTowards: []int32{24, 10},
+		})
+		require.NoError(t, err)
+		return []*pb.FromRadio{
+			routingReply(t, sent, self, 0, pb.Routing_NONE),
+			{PayloadVariant: &pb.FromRadio_Packet{Packet: &pb.MeshPacket{
+				From: dest,
+				To:   self,
+				PayloadVariant: &pb.MeshPacket_Decoded{Decoded: &pb.Data{
+					Portnum:   pb.PortNum_TRACEROUTE_APP,
+					Payload:   payload,
+					RequestId: sent.GetId(),
+				}},
+			}}},
+		}
+	}
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	reply, err := r.Traceroute(context.Background(), dest, 0)
+	require.NoError(t, err)
+	require.Equal(t, dest, reply.Packet.GetFrom())
+	require.Equal(t, []uint32{relay}, reply.Route.GetRoute())
+	require.Equal(t, []int32{24, 10}, reply.Route.GetSnrTowards())
+
+	sent := decodeToRadio(t, m.writes[0]).GetPacket()
+	require.Equal(t, dest, sent.GetTo())
+	require.Equal(t, pb.PortNum_TRACEROUTE_APP, sent.GetDecoded().GetPortnum())
+	require.True(t, sent.GetDecoded().GetWantResponse())
+}
+
+func TestTracerouteReportsNak(t *testing.T) {
+	m := &mockStreamer{idle: true}
+	m.respond = func(tr *pb.ToRadio) []*pb.FromRadio {
+		return []*pb.FromRadio{routingReply(t, tr.GetPacket(), 1, 0, pb.Routing_NO_ROUTE)}
+	}
+	r := &Radio{streamer: m}
+	defer r.Close()
+
+	_, err := r.Traceroute(context.Background(), 5, 0)
+	var routingErr *RoutingError
+	require.True(t, errors.As(err, &routingErr))
+	require.Equal(t, pb.Routing_NO_ROUTE, routingErr.Reason)
+}
+
+func TestParseNodeID(t *testing.T) {
+	for in, want := range map[string]uint32{
+		"!a1b2c3d4":  0xa1b2c3d4,
+		"0xA1B2C3D4": 0xa1b2c3d4,
+		"2712847316": 0xa1b2c3d4,
+	} {
+		got, err := ParseNodeID(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "!", "0", "!ffffffff", "!xyz", "!1a2b3c4d5"} {
+		_, err := ParseNodeID(in)
+		require.Error(t, err, in)
+	}
+	require.Equal(t, "!0000002a", FormatNodeID(42))
+}
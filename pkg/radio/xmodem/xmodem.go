@@ -0,0 +1,350 @@
+// Package xmodem implements the XModem variant the Meshtastic firmware uses to
+// move files in and out of its filesystem. Blocks of up to 128 bytes with a
+// CRC-16 travel in XModem protobufs inside ToRadio and FromRadio messages
+// rather than as raw bytes on the wire.
+//
+// A transfer opens with block 0 carrying the file name: STX asks the node to
+// send that file, SOH asks it to store one. Data blocks are numbered from 1.
+// The receiver answers each with ACK, or NAK to have it sent again, and the
+// sender closes the transfer with EOT. CAN aborts it.
+package xmodem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+)
+
+const (
+	// BlockSize is the most data one XModem packet carries.
+	BlockSize = 128
+	// MaxNameBytes is the longest file name the firmware takes: its name
+	// buffer is BlockSize bytes and the name is sent with a NUL, so a longer
+	// name from an earlier transfer cannot leave its tail behind.
+	MaxNameBytes = BlockSize - 1
+
+	// DefaultTimeout is how long to wait for each answer from the node.
+	DefaultTimeout = 5 * time.Second
+	// DefaultRetries is how many times a block is sent or asked for again
+	// before the transfer fails; the firmware gives up after as many.
+	DefaultRetries = 25
+)
+
+var (
+	// ErrRefused is returned when the node cannot open the file, such as when
+	// it does not exist or the filesystem is full.
+	ErrRefused = errors.New("node refused the transfer")
+	// ErrCancelled is returned when the node aborts the transfer with CAN.
+	ErrCancelled = errors.New("node cancelled the transfer")
+	// ErrTooManyRetries is returned when a block still fails after
+	// Options.Retries attempts.
+	ErrTooManyRetries = errors.New("too many retries")
+)
+
+// Conn carries XModem packets to and from the node.
+type Conn interface {
+	Send(ctx context.Context, p *pb.XModem) error
+	// Receive returns the next packet from the node, or ctx.Err() when none
+	// arrives before ctx ends.
+	Receive(ctx context.Context) (*pb.XModem, error)
+}
+
+// Progress reports how far a transfer has got.
+type Progress struct {
+	// Bytes is the data transferred and acknowledged so far.
+	Bytes int64
+	// Retries counts every block sent or asked for again.
+	Retries int
+}
+
+// Options tunes a transfer. The zero value uses the defaults.
+type Options struct {
+	// Timeout bounds the wait for each answer; DefaultTimeout when zero.
+	Timeout time.Duration
+	// Retries bounds the attempts per block; DefaultRetries when zero.
+	Retries int
+	// Progress, when set, is called after every block and retry.
+	Progress func(Progress)
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	if o.Retries <= 0 {
+		o.Retries = DefaultRetries
+	}
+	if o.Progress == nil {
+		o.Progress = func(Progress) {}
+	}
+	return o
+}
+
+// CRC16 returns the CRC-16/XMODEM of b: polynomial 0x1021, initial value
+// zero, as the firmware computes it.
+func CRC16(b []byte) uint16 {
+	var crc uint16
+	for _, c := range b {
+		crc ^= uint16(c) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// ValidateName checks that name is a path the firmware can open.
+func ValidateName(name string) error {
+	switch {
+	case !strings.HasPrefix(name, "/"):
+		return fmt.Errorf("file name %q must be an absolute path such as /prefs/config.proto", name)
+	case strings.ContainsRune(name, 0):
+		return fmt.Errorf("file name %q contains a NUL byte", name)
+	case len(name) > MaxNameBytes:
+		return fmt.Errorf("file name is %d bytes; the node takes at most %d", len(name), MaxNameBytes)
+	}
+	return nil
+}
+
+// Block returns a data packet holding data, numbered seq.
+func Block(seq uint32, data []byte) *pb.XModem {
+	return &pb.XModem{
+		Control: pb.XModem_SOH,
+		Seq:     seq,
+		Crc16:   uint32(CRC16(data)),
+		Buffer:  append([]byte(nil), data...),
+	}
+}
+
+// Valid reports whether p is a data packet whose CRC matches its data.
+func Valid(p *pb.XModem) bool {
+	switch p.GetControl() {
+	case pb.XModem_SOH, pb.XModem_STX:
+	default:
+		return false
+	}
+	return len(p.GetBuffer()) <= BlockSize && uint32(CRC16(p.GetBuffer())) == p.GetCrc16()
+}
+
+func openPacket(control pb.XModem_Control, name string) *pb.XModem {
+	return &pb.XModem{Control: control, Buffer: append([]byte(name), 0)}
+}
+
+func control(c pb.XModem_Control) *pb.XModem {
+	return &pb.XModem{Control: c}
+}
+
+// receive waits up to timeout for the next packet. A packet that does not
+// arrive in time is returned as nil with no error.
+func receive(ctx context.Context, conn Conn, timeout time.Duration) (*pb.XModem, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	p, err := conn.Receive(waitCtx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// Receive asks the node for the file name and writes it to w, returning the
+// number of bytes written. A block that fails its CRC or does not arrive in
+// time is asked for again with NAK.
+//
+// Receive never sends CAN: the firmware deletes the file named in a transfer
+// when it gets one, even when it is the sender. The node has no timeout of its
+// own, so after a failed download it stays ready to send until the next
+// transfer, which it answers with CAN; that transfer has to be started again.
+func Receive(ctx context.Context, conn Conn, name string, w io.Writer, opts Options) (int64, error) {
+	if err := ValidateName(name); err != nil {
+		return 0, err
+	}
+	opts = opts.withDefaults()
+
+	if err := conn.Send(ctx, openPacket(pb.XModem_STX, name)); err != nil {
+		return 0, fmt.Errorf("request %s: %w", name, err)
+	}
+
+	var (
+		progress Progress
+		seq      = uint32(1)
+		attempts int
+		// nakked is set while a NAK is outstanding: one was sent and no block
+		// has been accepted since.
+		nakked bool
+	)
+	for {
+		p, err := receive(ctx, conn, opts.Timeout)
+		if err != nil {
+			return progress.Bytes, err
+		}
+
+		switch p.GetControl() {
+		case pb.XModem_SOH, pb.XModem_STX:
+			if p.GetSeq() == seq && Valid(p) {
+				if _, err := w.Write(p.GetBuffer()); err != nil {
+					return progress.Bytes, fmt.Errorf("write %s: %w", name, err)
+				}
+				if err := conn.Send(ctx, control(pb.XModem_ACK)); err != nil {
+					return progress.Bytes, err
+				}
+				progress.Bytes += int64(len(p.GetBuffer()))
+				opts.Progress(progress)
+				seq++
+				attempts = 0
+				nakked = false
+				continue
+			}
+			if p.GetSeq() == seq-1 && seq > 1 {
+				// Answering our NAK, the node sent the last block again, so
+				// our ACK for it was lost and it is still waiting. Otherwise
+				// this is a copy resent after the original arrived late and
+				// was ACKed: the node has moved on, and another ACK would be
+				// taken for the block it is sending now.
+				if !nakked {
+					continue
+				}
+				nakked = false
+				if err := conn.Send(ctx, control(pb.XModem_ACK)); err != nil {
+					return progress.Bytes, err
+				}
+				continue
+			}
+		case pb.XModem_EOT:
+			return progress.Bytes, nil
+		case pb.XModem_CAN:
+			return progress.Bytes, fmt.Errorf("get %s: %w", name, ErrCancelled)
+		case pb.XModem_NAK:
+			if seq == 1 {
+				return 0, fmt.Errorf("get %s: %w", name, ErrRefused)
+			}
+			continue
+		default:
+			if p != nil {
+				continue
+			}
+		}
+
+		// The block was damaged, out of order or missing.
+		attempts++
+		if attempts > opts.Retries {
+			return progress.Bytes, fmt.Errorf("get %s: block %d: %w", name, seq, ErrTooManyRetries)
+		}
+		progress.Retries++
+		opts.Progress(progress)
+		if err := conn.Send(ctx, control(pb.XModem_NAK)); err != nil {
+			return progress.Bytes, err
+		}
+		nakked = true
+	}
+}
+
+// Send stores the contents of r on the node as the file name, replacing any
+// file already there, and returns the number of bytes sent. A block the node
+// rejects or does not answer in time is sent again. If the transfer fails
+// after the node has opened the file, Send cancels it so the node removes the
+// partial file.
+func Send(ctx context.Context, conn Conn, name string, r io.Reader, opts Options) (int64, error) {
+	if err := ValidateName(name); err != nil {
+		return 0, err
+	}
+	opts = opts.withDefaults()
+
+	// The opening block is not sent twice: the firmware answers a repeated
+	// one with NAK once it has opened the file.
+	if err := conn.Send(ctx, openPacket(pb.XModem_SOH, name)); err != nil {
+		return 0, fmt.Errorf("open %s: %w", name, err)
+	}
+	answer, err := receive(ctx, conn, opts.Timeout)
+	switch {
+	case err != nil:
+		return 0, err
+	case answer == nil:
+		return 0, fmt.Errorf("put %s: no answer from the node", name)
+	case answer.GetControl() == pb.XModem_NAK:
+		return 0, fmt.Errorf("put %s: %w", name, ErrRefused)
+	case answer.GetControl() != pb.XModem_ACK:
+		return 0, fmt.Errorf("put %s: unexpected %s", name, answer.GetControl())
+	}
+
+	s := sender{conn: conn, opts: opts}
+	n, err := s.send(ctx, r)
+	if err != nil {
+		// Best effort: the node deletes the partial file on CAN.
+		_ = conn.Send(context.WithoutCancel(ctx), control(pb.XModem_CAN))
+		return n, fmt.Errorf("put %s: %w", name, err)
+	}
+	return n, nil
+}
+
+type sender struct {
+	conn     Conn
+	opts     Options
+	progress Progress
+}
+
+func (s *sender) send(ctx context.Context, r io.Reader) (int64, error) {
+	buf := make([]byte, BlockSize)
+	for seq := uint32(1); ; seq++ {
+		n, err := io.ReadFull(r, buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return s.progress.Bytes, fmt.Errorf("read: %w", err)
+		}
+
+		if err := s.deliver(ctx, Block(seq, buf[:n]), fmt.Sprintf("block %d", seq)); err != nil {
+			return s.progress.Bytes, err
+		}
+		s.progress.Bytes += int64(n)
+		s.opts.Progress(s.progress)
+		if n < BlockSize {
+			break
+		}
+	}
+	return s.progress.Bytes, s.deliver(ctx, control(pb.XModem_EOT), "EOT")
+}
+
+// deliver sends p until the node acknowledges it.
+func (s *sender) deliver(ctx context.Context, p *pb.XModem, what string) error {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if attempt > s.opts.Retries {
+				return fmt.Errorf("%s: %w", what, ErrTooManyRetries)
+			}
+			s.progress.Retries++
+			s.opts.Progress(s.progress)
+		}
+		if err := s.conn.Send(ctx, p); err != nil {
+			return err
+		}
+
+		answer, err := receive(ctx, s.conn, s.opts.Timeout)
+		if err != nil {
+			return err
+		}
+		switch answer.GetControl() {
+		case pb.XModem_ACK:
+			return nil
+		case pb.XModem_CAN:
+			return fmt.Errorf("%s: %w", what, ErrCancelled)
+		}
+		// NAK, no answer or a stray packet: send it again.
+	}
+}
@@ -0,0 +1,348 @@
+package xmodem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/coreyvan/chirp/protogen/meshtastic"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// peer is an in-memory node that answers XModem packets the way the firmware
+// does. Each packet it would send passes through fault first, which may
+// change it or return nil to drop it.
+type peer struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	out   chan *pb.XModem
+	fault func(*pb.XModem) *pb.XModem
+	got   []pb.XModem_Control
+
+	name         string
+	receiving    bool
+	transmitting bool
+	eot          bool
+	seq          uint32
+	offset       int
+	block        *pb.XModem
+	incoming     bytes.Buffer
+}
+
+func newPeer(files map[string][]byte) *peer {
+	return &peer{files: files, out: make(chan *pb.XModem, 8)}
+}
+
+func (p *peer) Receive(ctx context.Context) (*pb.XModem, error) {
+	select {
+	case x := <-p.out:
+		return x, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *peer) Send(_ context.Context, x *pb.XModem) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	x = proto.Clone(x).(*pb.XModem)
+	p.got = append(p.got, x.GetControl())
+	switch x.GetControl() {
+	case pb.XModem_SOH, pb.XModem_STX:
+		if x.GetSeq() == 0 && !p.receiving && !p.transmitting {
+			p.name = strings.TrimRight(string(x.GetBuffer()), "\x00")
+			if x.GetControl() == pb.XModem_SOH {
+				p.receiving, p.seq = true, 1
+				p.incoming.Reset()
+				p.reply(pb.XModem_ACK)
+				return nil
+			}
+			if _, ok := p.files[p.name]; !ok {
+				p.reply(pb.XModem_NAK)
+				return nil
+			}
+			p.transmitting, p.seq, p.offset = true, 1, 0
+			p.nextBlock()
+			return nil
+		}
+		switch {
+		case p.receiving && x.GetSeq() == p.seq && Valid(x):
+			p.incoming.Write(x.GetBuffer())
+			p.seq++
+			p.reply(pb.XModem_ACK)
+		case p.receiving:
+			p.reply(pb.XModem_NAK)
+		case p.transmitting:
+			p.transmitting = false
+			p.reply(pb.XModem_CAN)
+		}
+	case pb.XModem_EOT:
+		p.reply(pb.XModem_ACK)
+		if p.receiving {
+			p.files[p.name] = bytes.Clone(p.incoming.Bytes())
+		}
+		p.receiving = false
+	case pb.XModem_CAN:
+		p.reply(pb.XModem_ACK)
+		delete(p.files, p.name)
+		p.receiving = false
+	case pb.XModem_ACK:
+		if !p.transmitting {
+			p.reply(pb.XModem_CAN)
+			return nil
+		}
+		if p.eot {
+			p.transmitting, p.eot = false, false
+			p.reply(pb.XModem_EOT)
+			return nil
+		}
+		p.seq++
+		p.nextBlock()
+	case pb.XModem_NAK:
+		if !p.transmitting {
+			p.reply(pb.XModem_CAN)
+			return nil
+		}
+		p.send(proto.Clone(p.block).(*pb.XModem))
+	}
+	return nil
+}
+
+// nextBlock sends the next BlockSize bytes of the file. As on the firmware, a
+// short block, even an empty one, is the last.
+func (p *peer) nextBlock() {
+	data := p.files[p.name][p.offset:]
+	if len(data) > BlockSize {
+		data = data[:BlockSize]
+	}
+	p.offset += len(data)
+	p.eot = len(data) < BlockSize
+	p.block = Block(p.seq, data)
+	p.send(proto.Clone(p.block).(*pb.XModem))
+}
+
+func (p *peer) reply(c pb.XModem_Control) {
+	p.send(&pb.XModem{Control: c})
+}
+
+func (p *peer) send(x *pb.XModem) {
+	if p.fault != nil {
+		if x = p.fault(x); x == nil {
+			return
+		}
+	}
+	p.out <- x
+}
+
+func (p *peer) sent(c pb.XModem_Control) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, got := range p.got {
+		if got == c {
+			n++
+		}
+	}
+	return n
+}
+
+// faultOnce applies f to the first packet numbered seq.
+func faultOnce(seq uint32, f func(*pb.XModem) *pb.XModem) func(*pb.XModem) *pb.XModem {
+	done := false
+	return func(x *pb.XModem) *pb.XModem {
+		if done || x.GetSeq() != seq || x.GetControl() != pb.XModem_SOH {
+			return x
+		}
+		done = true
+		return f(x)
+	}
+}
+
+func corrupt(x *pb.XModem) *pb.XModem {
+	x.Buffer[0] ^= 0xff
+	return x
+}
+
+var quick = Options{Timeout: 50 * time.Millisecond}
+
+func TestCRC16MatchesXModemCheckValue(t *testing.T) {
+	require.Equal(t, uint16(0x31c3), CRC16([]byte("123456789")))
+	require.Equal(t, uint16(0), CRC16(nil))
+}
+
+func TestReceiveFilesOfEverySize(t *testing.T) {
+	for _, size := range []int{0, 1, BlockSize, BlockSize + 1, 3 * BlockSize} {
+		data := bytes.Repeat([]byte("abcdefg"), size/7+1)[:size]
+		p := newPeer(map[string][]byte{"/prefs/config.proto": data})
+
+		var got bytes.Buffer
+		n, err := Receive(context.Background(), p, "/prefs/config.proto", &got, quick)
+		require.NoError(t, err, "size %d", size)
+		require.Equal(t, int64(size), n)
+		require.Equal(t, string(data), got.String())
+	}
+}
+
+func TestReceiveAsksForDamagedAndMissingBlocksAgain(t *testing.T) {
+	data := bytes.Repeat([]byte{0x5a}, 3*BlockSize+10)
+	for name, fault := range map[string]func(*pb.XModem) *pb.XModem{
+		"corrupt": faultOnce(2, corrupt),
+		"dropped": faultOnce(3, func(*pb.XModem) *pb.XModem { return nil }),
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := newPeer(map[string][]byte{"/log.txt": data})
+			p.fault = fault
+
+			var (
+				got      bytes.Buffer
+				progress []Progress
+			)
+			opts := quick
+			opts.Progress = func(pr Progress) { progress = append(progress, pr) }
+			_, err := Receive(context.Background(), p, "/log.txt", &got, opts)
+			require.NoError(t, err)
+			require.Equal(t, data, got.Bytes())
+			require.Equal(t, 1, p.sent(pb.XModem_NAK))
+			require.Equal(t, Progress{Bytes: int64(len(data)), Retries: 1}, progress[len(progress)-1])
+		})
+	}
+}
+
+func TestReceiveIgnoresCopyOfLateBlock(t *testing.T) {
+	data := bytes.Repeat([]byte{0x5a}, 3*BlockSize+10)
+	p := newPeer(map[string][]byte{"/log.txt": data})
+
+	// Block 2 is held back past the receiver's timeout and arrives just ahead
+	// of the copy its NAK asks for.
+	var (
+		held *pb.XModem
+		done bool
+	)
+	p.fault = func(x *pb.XModem) *pb.XModem {
+		switch {
+		case !done && held == nil && x.GetSeq() == 2 && x.GetControl() == pb.XModem_SOH:
+			held = x
+			return nil
+		case held != nil:
+			p.out <- held
+			held, done = nil, true
+		}
+		return x
+	}
+
+	var got bytes.Buffer
+	_, err := Receive(context.Background(), p, "/log.txt", &got, quick)
+	require.NoError(t, err)
+	require.Equal(t, data, got.Bytes())
+	require.Equal(t, 1, p.sent(pb.XModem_NAK))
+	require.Equal(t, 4, p.sent(pb.XModem_ACK), "an ACK for the copy would be taken for the next block")
+	require.Empty(t, p.out, "the node answered a stray ACK")
+}
+
+func TestReceiveAcksBlockAgainWhenAckIsLost(t *testing.T) {
+	data := bytes.Repeat([]byte{0x5a}, 3*BlockSize+10)
+	p := newPeer(map[string][]byte{"/log.txt": data})
+
+	var got bytes.Buffer
+	_, err := Receive(context.Background(), &ackDroppingConn{Conn: p, n: 2}, "/log.txt", &got, quick)
+	require.NoError(t, err)
+	require.Equal(t, data, got.Bytes())
+	require.Equal(t, 1, p.sent(pb.XModem_NAK))
+	require.Empty(t, p.out)
+}
+
+// ackDroppingConn loses the nth ACK on its way to the node.
+type ackDroppingConn struct {
+	Conn
+	n    int
+	acks int
+}
+
+func (c *ackDroppingConn) Send(ctx context.Context, x *pb.XModem) error {
+	if x.GetControl() == pb.XModem_ACK {
+		if c.acks++; c.acks == c.n {
+			return nil
+		}
+	}
+	return c.Conn.Send(ctx, x)
+}
+
+func TestReceiveMissingFileIsRefused(t *testing.T) {
+	p := newPeer(map[string][]byte{})
+	_, err := Receive(context.Background(), p, "/nope", &bytes.Buffer{}, quick)
+	require.ErrorIs(t, err, ErrRefused)
+}
+
+func TestReceiveGivesUpAfterRetries(t *testing.T) {
+	p := newPeer(map[string][]byte{"/log.txt": bytes.Repeat([]byte{1}, 2*BlockSize)})
+	p.fault = func(x *pb.XModem) *pb.XModem {
+		if x.GetSeq() == 2 {
+			return corrupt(x)
+		}
+		return x
+	}
+
+	opts := quick
+	opts.Retries = 3
+	n, err := Receive(context.Background(), p, "/log.txt", &bytes.Buffer{}, opts)
+	require.ErrorIs(t, err, ErrTooManyRetries)
+	require.Equal(t, int64(BlockSize), n)
+	require.Equal(t, 3, p.sent(pb.XModem_NAK))
+	require.Zero(t, p.sent(pb.XModem_CAN), "CAN would delete the file on the node")
+}
+
+func TestSendStoresFile(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 40)
+	p := newPeer(map[string][]byte{})
+
+	n, err := Send(context.Background(), &corruptingConn{Conn: p, seq: 2}, "/upload.bin", bytes.NewReader(data), quick)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), n)
+	require.Equal(t, data, p.files["/upload.bin"])
+	// The opening block, four data blocks and block 2 once more.
+	require.Equal(t, 6, p.sent(pb.XModem_SOH))
+	require.Equal(t, 1, p.sent(pb.XModem_EOT))
+}
+
+// corruptingConn damages the first copy of block seq on its way to the node.
+type corruptingConn struct {
+	Conn
+	seq  uint32
+	done bool
+}
+
+func (c *corruptingConn) Send(ctx context.Context, x *pb.XModem) error {
+	if !c.done && x.GetControl() == pb.XModem_SOH && x.GetSeq() == c.seq {
+		c.done = true
+		x = proto.Clone(x).(*pb.XModem)
+		corrupt(x)
+	}
+	return c.Conn.Send(ctx, x)
+}
+
+func TestSendCancelsOnReadError(t *testing.T) {
+	p := newPeer(map[string][]byte{"/upload.bin": []byte("old")})
+	r := io.MultiReader(bytes.NewReader(bytes.Repeat([]byte{7}, BlockSize)), errReader{})
+
+	_, err := Send(context.Background(), p, "/upload.bin", r, quick)
+	require.ErrorContains(t, err, "read: disk on fire")
+	require.Equal(t, 1, p.sent(pb.XModem_CAN))
+	require.NotContains(t, p.files, "/upload.bin")
+}
+
+func TestValidateName(t *testing.T) {
+	require.NoError(t, ValidateName("/prefs/config.proto"))
+	require.Error(t, ValidateName("prefs/config.proto"))
+	require.Error(t, ValidateName("/"+strings.Repeat("a", MaxNameBytes)))
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("disk on fire") }
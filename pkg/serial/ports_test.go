@@ -0,0 +1,20 @@
+package serial
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimilarPortsMatchesSameDeviceFamily(t *testing.T) {
+	ports := []string{
+		"/dev/cu.usbmodem101",
+		"/dev/cu.usbmodem1101",
+		"/dev/cu.Bluetooth-Incoming-Port",
+		"/dev/ttyUSB0",
+	}
+
+	require.Equal(t, []string{"/dev/cu.usbmodem1101"}, similarPorts("/dev/cu.usbmodem101", ports))
+	require.Equal(t, []string{"/dev/ttyUSB0"}, similarPorts("/dev/ttyUSB1", ports))
+	require.Empty(t, similarPorts("/dev/ttyACM0", ports))
+}
@@ -2,6 +2,7 @@ package serial
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	sdkserial "go.bug.st/serial"
@@ -50,6 +51,30 @@ func GetPorts() ([]string, error) {
 	return p, nil
 }
 
+// SimilarPorts returns the available ports, other than device itself, that
+// share its name apart from trailing digits. USB radios often reappear under
+// such a name after a reboot, for example /dev/cu.usbmodem1101 instead of
+// /dev/cu.usbmodem101.
+func SimilarPorts(device string) ([]string, error) {
+	ports, err := GetPorts()
+	if err != nil {
+		return nil, err
+	}
+
+	return similarPorts(device, ports), nil
+}
+
+func similarPorts(device string, ports []string) []string {
+	family := strings.TrimRight(device, "0123456789")
+	var similar []string
+	for _, p := range ports {
+		if p != device && strings.TrimRight(p, "0123456789") == family {
+			similar = append(similar, p)
+		}
+	}
+	return similar
+}
+
 func (s *SerialStreamer) Read(p []byte) (int, error) {
 	return s.port.Read(p)
 }
@@ -0,0 +1,76 @@
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultPort is the TCP port meshtasticd and WiFi-enabled nodes expose the stream API on.
+	DefaultPort = "4403"
+	dialTimeout = 5 * time.Second
+)
+
+// TCPStreamer speaks the Meshtastic framed stream protocol over a TCP connection.
+type TCPStreamer struct {
+	conn        net.Conn
+	readTimeout time.Duration
+}
+
+// NewTCPStreamer dials host, appending DefaultPort when no port is given.
+func NewTCPStreamer(host string) (*TCPStreamer, error) {
+	conn, err := net.DialTimeout("tcp", Address(host), dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("could not open tcp connection: %w", err)
+	}
+
+	return &TCPStreamer{conn: conn}, nil
+}
+
+// Address normalizes host into a host:port pair, defaulting the port to DefaultPort.
+func Address(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, DefaultPort)
+}
+
+func (s *TCPStreamer) Close() error {
+	return s.conn.Close()
+}
+
+// Read reads from the connection. When a read timeout is set and expires, Read
+// returns 0 bytes and no error, matching the serial streamer's behavior.
+func (s *TCPStreamer) Read(p []byte) (int, error) {
+	if s.readTimeout > 0 {
+		if err := s.conn.SetReadDeadline(time.Now().Add(s.readTimeout)); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := s.conn.Read(p)
+	if err != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+		return n, nil
+	}
+	return n, err
+}
+
+func (s *TCPStreamer) Write(p []byte) (int, error) {
+	return s.conn.Write(p)
+}
+
+func (s *TCPStreamer) SetReadTimeout(d time.Duration) error {
+	s.readTimeout = d
+	if d <= 0 {
+		return s.conn.SetReadDeadline(time.Time{})
+	}
+	return nil
+}
+
+// SetWriteDeadline bounds pending and future writes; a zero t clears it.
+func (s *TCPStreamer) SetWriteDeadline(t time.Time) error {
+	return s.conn.SetWriteDeadline(t)
+}
@@ -0,0 +1,118 @@
+package tcp
+
+import (
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	return ln
+}
+
+func TestAddressAppendsDefaultPort(t *testing.T) {
+	require.Equal(t, "meshtastic.local:4403", Address("meshtastic.local"))
+	require.Equal(t, "10.0.0.5:4000", Address("10.0.0.5:4000"))
+	require.Equal(t, "[::1]:4403", Address("::1"))
+}
+
+func TestTCPStreamerReadWrite(t *testing.T) {
+	ln := listen(t)
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	s, err := NewTCPStreamer(ln.Addr().String())
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn := <-accepted
+	defer conn.Close()
+
+	n, err := s.Write([]byte{0x94, 0xc3, 0x00, 0x01, 0x2a})
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+
+	got := make([]byte, 5)
+	_, err = io.ReadFull(conn, got)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x94, 0xc3, 0x00, 0x01, 0x2a}, got)
+
+	_, err = conn.Write([]byte{0x01, 0x02})
+	require.NoError(t, err)
+
+	buf := make([]byte, 8)
+	n, err = s.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x01, 0x02}, buf[:n])
+}
+
+func TestTCPStreamerReadTimeoutReturnsNoData(t *testing.T) {
+	ln := listen(t)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(200 * time.Millisecond)
+		}
+	}()
+
+	s, err := NewTCPStreamer(ln.Addr().String())
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.SetReadTimeout(20*time.Millisecond))
+	n, err := s.Read(make([]byte, 8))
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestNewTCPStreamerDialError(t *testing.T) {
+	ln := listen(t)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	_, err := NewTCPStreamer(addr)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "could not open tcp connection")
+}
+
+func TestTCPStreamerWriteDeadline(t *testing.T) {
+	ln := listen(t)
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	s, err := NewTCPStreamer(ln.Addr().String())
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn := <-accepted
+	defer conn.Close()
+
+	require.NoError(t, s.SetWriteDeadline(time.Now()))
+	_, err = s.Write([]byte{0x01})
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	require.NoError(t, s.SetWriteDeadline(time.Time{}))
+	_, err = s.Write([]byte{0x02})
+	require.NoError(t, err)
+}